@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go-project/tenancy"
+	"io"
+	"time"
+)
+
+// emitirTokenAdmin atiende "main admin-token": firma con JWT_SECRET un token
+// de administrador del despliegue y lo escribe en out. La API sólo emite
+// tokens de empresa, así que éste es el camino para obtener el primer token
+// de /api/admin; quien tiene acceso al servidor ya conoce el secreto.
+func emitirTokenAdmin(args []string, secret string, now time.Time, out io.Writer) error {
+	fs := flag.NewFlagSet("admin-token", flag.ContinueOnError)
+	fs.SetOutput(out)
+	subject := fs.String("subject", "", "Identificador de quien usará el token (requerido)")
+	horas := fs.Int("ttl", 1, "Vigencia del token en horas")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if secret == "" {
+		return errors.New("JWT_SECRET no configurado")
+	}
+	if *subject == "" {
+		return errors.New("el sujeto es requerido (-subject)")
+	}
+	if *horas <= 0 || *horas > 24 {
+		return errors.New("la vigencia debe estar entre 1 y 24 horas")
+	}
+
+	token, err := tenancy.Sign([]byte(secret), tenancy.Claims{
+		Subject:   *subject,
+		Role:      tenancy.RoleAdmin,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Duration(*horas) * time.Hour).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, token)
+	return err
+}
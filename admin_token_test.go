@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"go-project/tenancy"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmitirTokenAdmin(t *testing.T) {
+	ahora := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	if err := emitirTokenAdmin([]string{"-subject", "ops", "-ttl", "2"}, "secreto", ahora, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// El token lo acepta la autenticación y abre las rutas de administración
+	c, err := tenancy.Parse([]byte("secreto"), strings.TrimSpace(out.String()), ahora)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Role != tenancy.RoleAdmin || c.Subject != "ops" || c.TenantID != "" ||
+		c.ExpiresAt != ahora.Add(2*time.Hour).Unix() {
+		t.Errorf("claims = %+v", c)
+	}
+
+	if err := emitirTokenAdmin([]string{"-subject", "ops"}, "", ahora, &out); err == nil {
+		t.Error("sin JWT_SECRET se esperaba error")
+	}
+	if err := emitirTokenAdmin(nil, "secreto", ahora, &out); err == nil {
+		t.Error("sin sujeto se esperaba error")
+	}
+	if err := emitirTokenAdmin([]string{"-subject", "ops", "-ttl", "48"}, "secreto", ahora, &out); err == nil {
+		t.Error("vigencia mayor a un día: se esperaba error")
+	}
+}
@@ -0,0 +1,200 @@
+package alerts
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// servidorSMTP simula un servidor SMTP local y entrega por el canal el
+// remitente, los destinatarios y el contenido de cada mensaje
+type correoRecibido struct {
+	from string
+	to   []string
+	data string
+}
+
+func servidorSMTP(t *testing.T) (string, chan correoRecibido) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	recibidos := make(chan correoRecibido, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		responder := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		responder("220 localhost ESMTP prueba")
+
+		var c correoRecibido
+		for {
+			linea, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			comando := strings.ToUpper(strings.TrimSpace(linea))
+			switch {
+			case strings.HasPrefix(comando, "EHLO"), strings.HasPrefix(comando, "HELO"):
+				responder("250 localhost")
+			case strings.HasPrefix(comando, "MAIL FROM:"):
+				c.from = strings.Trim(strings.TrimSpace(linea)[10:], "<>")
+				responder("250 OK")
+			case strings.HasPrefix(comando, "RCPT TO:"):
+				c.to = append(c.to, strings.Trim(strings.TrimSpace(linea)[8:], "<>"))
+				responder("250 OK")
+			case comando == "DATA":
+				responder("354 Fin con <CRLF>.<CRLF>")
+				var datos strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					datos.WriteString(l)
+				}
+				c.data = datos.String()
+				responder("250 OK")
+				recibidos <- c
+			case comando == "QUIT":
+				responder("221 Adiós")
+				return
+			default:
+				responder("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), recibidos
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, recibidos := servidorSMTP(t)
+	m := &SMTPMailer{Addr: addr, From: "alertas@tienda.mx"}
+
+	err := m.Send([]string{"gerente@tienda.mx", "compras@tienda.mx"}, "Artículo sin stock", "Línea 1\nLínea 2\n")
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	c := <-recibidos
+	if c.from != "alertas@tienda.mx" || len(c.to) != 2 {
+		t.Errorf("sobre recibido: %+v", c)
+	}
+	if !strings.Contains(c.data, "Subject: =?utf-8?q?Art=C3=ADculo_sin_stock?=\r\n") {
+		t.Errorf("asunto no codificado: %q", c.data)
+	}
+	if !strings.Contains(c.data, "\r\n\r\nLínea 1\r\nLínea 2\r\n") {
+		t.Errorf("cuerpo incorrecto: %q", c.data)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	a := Alert{
+		ID: uuid.New(), ProductName: "Leche 1L", StoreName: "Centro",
+		AlertType: "SIN_STOCK", Quantity: 0, MinStock: 10,
+		CreatedAt: time.Date(2024, 5, 3, 9, 30, 0, 0, time.UTC),
+	}
+	asunto, cuerpo, err := DefaultTemplates().Immediate(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asunto != "[SIN_STOCK] Leche 1L en Centro" {
+		t.Errorf("asunto = %q", asunto)
+	}
+	if !strings.Contains(cuerpo, "entró en sin stock") || !strings.Contains(cuerpo, "2024-05-03 09:30") {
+		t.Errorf("cuerpo = %q", cuerpo)
+	}
+
+	asunto, cuerpo, err = DefaultTemplates().DigestMail(Digest{Date: a.CreatedAt, Alerts: []Alert{a, a}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asunto != "Resumen de alertas de stock del 2024-05-03 (2)" || strings.Count(cuerpo, "- [SIN_STOCK]") != 2 {
+		t.Errorf("resumen = %q\n%s", asunto, cuerpo)
+	}
+
+	// Reemplazar el asunto inmediato desde un directorio de plantillas
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "immediate_subject.tmpl"), []byte("ALERTA {{.StoreName}}"), 0o644)
+	tpl, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asunto, _, _ := tpl.Immediate(a); asunto != "ALERTA Centro" {
+		t.Errorf("asunto personalizado = %q", asunto)
+	}
+}
+
+func TestResumenPorDestinatario(t *testing.T) {
+	empresa, otra := uuid.New(), uuid.New()
+	centro, norte, ajena := uuid.New(), uuid.New(), uuid.New()
+	lista := []destinatario{
+		{email: "central@tienda.mx", tenantID: empresa},
+		{email: "centro@tienda.mx", tenantID: empresa, storeID: &centro},
+		{email: "central@tienda.mx", tenantID: empresa, storeID: &norte},
+		{email: "otra@empresa.mx", tenantID: otra},
+	}
+	vigentes := []Alert{
+		{TenantID: empresa, StoreID: centro},
+		{TenantID: empresa, StoreID: norte},
+		{TenantID: empresa, StoreID: centro},
+		{TenantID: otra, StoreID: ajena},
+	}
+
+	resumen := resumenPorDestinatario(lista, vigentes)
+	if len(resumen["central@tienda.mx"]) != 3 || len(resumen["centro@tienda.mx"]) != 2 ||
+		len(resumen["otra@empresa.mx"]) != 1 {
+		t.Errorf("resumen = %v", resumen)
+	}
+}
+
+func TestRuleEvaluate(t *testing.T) {
+	casos := []struct {
+		nombre string
+		regla  Rule
+		m      Metrics
+		cumple bool
+		valor  float64
+	}{
+		// 30 salidas en 30 días: 1 diaria, 5 unidades alcanzan 5 días
+		{"cobertura baja", Rule{Type: RuleDaysOfCover, Threshold: 7, LookbackDays: 30},
+			Metrics{Quantity: 5, OutWindow: 30}, true, 5},
+		{"cobertura suficiente", Rule{Type: RuleDaysOfCover, Threshold: 7, LookbackDays: 30},
+			Metrics{Quantity: 10, OutWindow: 30}, false, 10},
+		{"sin salidas", Rule{Type: RuleDaysOfCover, Threshold: 7, LookbackDays: 30},
+			Metrics{Quantity: 0}, false, 0},
+		{"sobrestock", Rule{Type: RuleOverstock, Threshold: 100},
+			Metrics{Quantity: 150}, true, 150},
+		{"sin movimiento", Rule{Type: RuleDeadStock, Threshold: 90},
+			Metrics{Quantity: 3, DaysSinceMovement: 120}, true, 120},
+		{"sin movimiento y sin existencia", Rule{Type: RuleDeadStock, Threshold: 90},
+			Metrics{Quantity: 0, DaysSinceMovement: 120}, false, 120},
+		// 29 salidas en los 29 días previos (1 diaria) y 4 en el último
+		{"pico de salidas", Rule{Type: RuleOutSpike, Threshold: 3, LookbackDays: 30},
+			Metrics{OutWindow: 33, OutLastDay: 4}, true, 4},
+		{"salidas normales", Rule{Type: RuleOutSpike, Threshold: 3, LookbackDays: 30},
+			Metrics{OutWindow: 31, OutLastDay: 2}, false, 2},
+	}
+	for _, c := range casos {
+		cumple, valor, _ := c.regla.Evaluate(c.m)
+		if cumple != c.cumple || valor != c.valor {
+			t.Errorf("%s: cumple=%v valor=%v, se esperaba %v %v", c.nombre, cumple, valor, c.cumple, c.valor)
+		}
+	}
+	if SeverityRank(SeverityCritical) <= SeverityRank(SeverityWarning) || SeverityRank("ALTA") != 0 {
+		t.Error("orden de severidades incorrecto")
+	}
+}
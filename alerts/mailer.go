@@ -0,0 +1,56 @@
+// Package alerts detecta los productos que entran en STOCK_BAJO o SIN_STOCK,
+// guarda el historial de alertas y avisa por correo a los destinatarios de
+// cada tienda, de inmediato o en un resumen diario.
+package alerts
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mailer envía correos de texto
+type Mailer interface {
+	Send(to []string, subject, body string) error
+}
+
+// SMTPMailer envía por SMTP; usa STARTTLS cuando el servidor lo ofrece y
+// autenticación PLAIN si se indica usuario
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Send envía un correo a todos los destinatarios
+func (m *SMTPMailer) Send(to []string, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, to, buildMessage(m.From, to, subject, body, time.Now()))
+}
+
+// buildMessage arma el mensaje con encabezados MIME; el asunto se codifica
+// para admitir acentos
+func buildMessage(from string, to []string, subject, body string, fecha time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", fecha.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,291 @@
+package alerts
+
+import (
+	"database/sql"
+	"go-project/utils"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Notifier detecta las transiciones de alerta y envía los avisos
+type Notifier struct {
+	db        *sql.DB
+	mailer    Mailer
+	templates *Templates
+	// DigestHour hora local a partir de la cual se envía el resumen del día
+	DigestHour int
+}
+
+// NewNotifier crea un notificador; con mailer nil sólo registra el historial
+func NewNotifier(db *sql.DB, mailer Mailer, templates *Templates) *Notifier {
+	return &Notifier{db: db, mailer: mailer, templates: templates, DigestHour: 8}
+}
+
+// Run detecta transiciones y envía los avisos pendientes y, llegada la hora,
+// el resumen diario
+func (n *Notifier) Run(now time.Time) {
+	abiertas, resueltas, err := n.Detect()
+	if err != nil {
+		log.Printf("Error al detectar alertas de stock: %v", err)
+		return
+	}
+	if abiertas > 0 || resueltas > 0 {
+		log.Printf("Alertas de stock: %d nuevas, %d resueltas", abiertas, resueltas)
+	}
+	if n.mailer == nil {
+		return
+	}
+	if _, err := n.SendImmediate(); err != nil {
+		log.Printf("Error al enviar alertas de stock: %v", err)
+	}
+	if _, err := n.SendDigest(now); err != nil {
+		log.Printf("Error al enviar el resumen de alertas: %v", err)
+	}
+}
+
+// Detect compara las alertas vigentes con vw_inventory_alerts: resuelve las
+// que ya no aplican o que empeoraron de STOCK_BAJO a SIN_STOCK y abre una
+// alerta por cada producto y tienda que entró en alerta. El índice único de
+// alertas vigentes evita duplicados aunque varias réplicas detecten a la vez.
+// Corre con la conexión del sistema, por lo que cubre a todas las empresas.
+func (n *Notifier) Detect() (abiertas, resueltas int, err error) {
+	err = utils.WithTransaction(n.db, func(tx *sql.Tx) error {
+		res, err := tx.Exec(`
+            UPDATE prueba.alertas a
+            SET status = 'RESOLVED', resolvedAt = CURRENT_TIMESTAMP
+            WHERE a.status IN ('OPEN', 'ACKNOWLEDGED')
+              AND NOT EXISTS (
+                  SELECT 1 FROM prueba.vw_inventory_alerts v
+                  WHERE v.productId = a.productId AND v.storeId = a.storeId
+                    AND NOT (v.alert_type = 'SIN_STOCK' AND a.alertType = 'STOCK_BAJO')
+              )
+        `)
+		if err != nil {
+			return err
+		}
+		filas, _ := res.RowsAffected()
+		resueltas = int(filas)
+
+		res, err = tx.Exec(`
+            INSERT INTO prueba.alertas (id, tenantId, productId, storeId, alertType, quantity, minStock)
+            SELECT gen_random_uuid(), v.tenantId, v.productId, v.storeId, v.alert_type, v.quantity, v.minStock
+            FROM prueba.vw_inventory_alerts v
+            WHERE NOT EXISTS (
+                SELECT 1 FROM prueba.alertas a
+                WHERE a.productId = v.productId AND a.storeId = v.storeId
+                  AND a.status IN ('OPEN', 'ACKNOWLEDGED')
+            )
+            ON CONFLICT DO NOTHING
+        `)
+		if err != nil {
+			return err
+		}
+		filas, _ = res.RowsAffected()
+		abiertas = int(filas)
+		return nil
+	})
+	return abiertas, resueltas, err
+}
+
+const consultaAlertas = `
+    SELECT a.id, a.tenantId, a.productId, a.storeId, p.name, t.name, a.alertType,
+           a.quantity, a.minStock, a.created_at
+    FROM prueba.alertas a
+        JOIN catalogos.productos p ON p.id = a.productId
+        JOIN catalogos.tiendas t ON t.id = a.storeId
+    WHERE a.status = 'OPEN'
+      AND (a.snoozedUntil IS NULL OR a.snoozedUntil <= CURRENT_TIMESTAMP)`
+
+func escanearAlertas(rows *sql.Rows) ([]Alert, error) {
+	defer rows.Close()
+	var lista []Alert
+	for rows.Next() {
+		var a Alert
+		if err := rows.Scan(&a.ID, &a.TenantID, &a.ProductID, &a.StoreID, &a.ProductName, &a.StoreName,
+			&a.AlertType, &a.Quantity, &a.MinStock, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		lista = append(lista, a)
+	}
+	return lista, rows.Err()
+}
+
+// destinatario correo, empresa y tienda que vigila (nil para todas las de su empresa)
+type destinatario struct {
+	email    string
+	tenantID uuid.UUID
+	storeID  *uuid.UUID
+}
+
+// destinatarios correos activos de la modalidad IMMEDIATE o DIGEST
+func destinatarios(q utils.Querier, modo string) ([]destinatario, error) {
+	rows, err := q.Query(`
+        SELECT email, tenantId, storeId FROM prueba.alertasdestinatarios
+        WHERE activo = true AND mode = $1
+        ORDER BY email
+    `, modo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lista []destinatario
+	for rows.Next() {
+		var d destinatario
+		if err := rows.Scan(&d.email, &d.tenantID, &d.storeID); err != nil {
+			return nil, err
+		}
+		lista = append(lista, d)
+	}
+	return lista, rows.Err()
+}
+
+// correosPara correos (sin repetir) de la empresa que vigilan la tienda
+func correosPara(lista []destinatario, tenantID, storeID uuid.UUID) []string {
+	vistos := make(map[string]bool)
+	var correos []string
+	for _, d := range lista {
+		if d.tenantID != tenantID {
+			continue
+		}
+		if (d.storeID == nil || *d.storeID == storeID) && !vistos[d.email] {
+			vistos[d.email] = true
+			correos = append(correos, d.email)
+		}
+	}
+	return correos
+}
+
+// SendImmediate envía un aviso por cada alerta abierta aún no notificada (y
+// no pospuesta) a los destinatarios inmediatos de su tienda. Si el envío
+// falla la alerta queda pendiente para el siguiente ciclo.
+func (n *Notifier) SendImmediate() (int, error) {
+	enviadas := 0
+	err := utils.WithTransaction(n.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query(consultaAlertas + `
+              AND a.notifiedAt IS NULL
+            ORDER BY a.created_at
+            LIMIT 100
+            FOR UPDATE OF a SKIP LOCKED`)
+		if err != nil {
+			return err
+		}
+		pendientes, err := escanearAlertas(rows)
+		if err != nil {
+			return err
+		}
+		lista, err := destinatarios(tx, "IMMEDIATE")
+		if err != nil {
+			return err
+		}
+
+		var notificadas []uuid.UUID
+		for _, a := range pendientes {
+			if correos := correosPara(lista, a.TenantID, a.StoreID); len(correos) > 0 {
+				asunto, cuerpo, err := n.templates.Immediate(a)
+				if err != nil {
+					return err
+				}
+				if err := n.mailer.Send(correos, asunto, cuerpo); err != nil {
+					log.Printf("Error al enviar la alerta %s: %v", a.ID, err)
+					continue
+				}
+				enviadas++
+			}
+			notificadas = append(notificadas, a.ID)
+		}
+
+		_, err = tx.Exec(`
+            UPDATE prueba.alertas SET notifiedAt = CURRENT_TIMESTAMP WHERE id = ANY($1)
+        `, pq.Array(notificadas))
+		return err
+	})
+	return enviadas, err
+}
+
+// SendDigest envía una vez al día, a partir de DigestHour, el resumen de las
+// alertas abiertas a los destinatarios de resumen. El día de cada empresa se
+// reserva en alertasresumenes dentro de la transacción: si el envío falla se
+// reintenta.
+func (n *Notifier) SendDigest(now time.Time) (bool, error) {
+	if now.Hour() < n.DigestHour {
+		return false, nil
+	}
+	enviado := false
+	err := utils.WithTransaction(n.db, func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+            INSERT INTO prueba.alertasresumenes (tenantId, digestDate)
+            SELECT id, $1 FROM prueba.tenants WHERE activo = true
+            ON CONFLICT DO NOTHING
+            RETURNING tenantId
+        `, now.Format("2006-01-02"))
+		if err != nil {
+			return err
+		}
+		reservadas := make(map[uuid.UUID]bool)
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			reservadas[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(reservadas) == 0 {
+			return nil
+		}
+
+		rows, err = tx.Query(consultaAlertas+`
+              AND a.tenantId = ANY($1)
+            ORDER BY t.name, a.alertType DESC, p.name`, pq.Array(llaves(reservadas)))
+		if err != nil {
+			return err
+		}
+		vigentes, err := escanearAlertas(rows)
+		if err != nil {
+			return err
+		}
+		lista, err := destinatarios(tx, "DIGEST")
+		if err != nil {
+			return err
+		}
+
+		for correo, alertas := range resumenPorDestinatario(lista, vigentes) {
+			asunto, cuerpo, err := n.templates.DigestMail(Digest{Date: now, Alerts: alertas})
+			if err != nil {
+				return err
+			}
+			if err := n.mailer.Send([]string{correo}, asunto, cuerpo); err != nil {
+				return err
+			}
+		}
+		enviado = true
+		return nil
+	})
+	return enviado, err
+}
+
+func llaves(m map[uuid.UUID]bool) []string {
+	lista := make([]string, 0, len(m))
+	for id := range m {
+		lista = append(lista, id.String())
+	}
+	return lista
+}
+
+// resumenPorDestinatario agrupa las alertas de las tiendas que vigila cada correo
+func resumenPorDestinatario(lista []destinatario, vigentes []Alert) map[string][]Alert {
+	resumen := make(map[string][]Alert)
+	for _, a := range vigentes {
+		for _, correo := range correosPara(lista, a.TenantID, a.StoreID) {
+			resumen[correo] = append(resumen[correo], a)
+		}
+	}
+	return resumen
+}
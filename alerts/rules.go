@@ -0,0 +1,230 @@
+package alerts
+
+import (
+	"database/sql"
+	"fmt"
+	"go-project/utils"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Tipos de regla
+const (
+	RuleDaysOfCover = "DAYS_OF_COVER"
+	RuleOverstock   = "OVERSTOCK"
+	RuleDeadStock   = "DEAD_STOCK"
+	RuleOutSpike    = "OUT_SPIKE"
+)
+
+// Severidades, de menor a mayor
+const (
+	SeverityInfo     = "INFO"
+	SeverityWarning  = "WARNING"
+	SeverityCritical = "CRITICAL"
+)
+
+// SeverityRank orden de la severidad (0 si no es válida)
+func SeverityRank(s string) int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarning:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+// Rule regla de alerta definida por un operador
+type Rule struct {
+	ID           uuid.UUID
+	TenantID     uuid.UUID
+	Name         string
+	Type         string
+	Threshold    float64
+	LookbackDays int
+	Severity     string
+	ProductID    *uuid.UUID
+	Category     *string
+	StoreID      *uuid.UUID
+}
+
+// Metrics indicadores de un producto en una tienda para evaluar las reglas
+type Metrics struct {
+	ProductID uuid.UUID
+	StoreID   uuid.UUID
+	Quantity  int
+	// Salidas (OUT) en los últimos LookbackDays días, incluido el último día
+	OutWindow int
+	// Salidas de las últimas 24 horas
+	OutLastDay int
+	// Días desde el último movimiento (o desde el alta del inventario)
+	DaysSinceMovement int
+}
+
+// Evaluate indica si la regla se cumple para las métricas, con el valor
+// medido y una descripción
+func (r Rule) Evaluate(m Metrics) (bool, float64, string) {
+	switch r.Type {
+	case RuleDaysOfCover:
+		// Sin salidas la cobertura es infinita
+		if m.OutWindow <= 0 {
+			return false, 0, ""
+		}
+		promedio := float64(m.OutWindow) / float64(r.LookbackDays)
+		cobertura := float64(m.Quantity) / promedio
+		return cobertura < r.Threshold, cobertura,
+			fmt.Sprintf("Cobertura de %.1f días (mínimo %.0f) con %.2f salidas diarias", cobertura, r.Threshold, promedio)
+
+	case RuleOverstock:
+		return float64(m.Quantity) > r.Threshold, float64(m.Quantity),
+			fmt.Sprintf("Existencia de %d sobre el máximo de %.0f", m.Quantity, r.Threshold)
+
+	case RuleDeadStock:
+		return m.Quantity > 0 && float64(m.DaysSinceMovement) >= r.Threshold, float64(m.DaysSinceMovement),
+			fmt.Sprintf("%d unidades sin movimiento en %d días", m.Quantity, m.DaysSinceMovement)
+
+	case RuleOutSpike:
+		// Promedio diario de los días anteriores al último
+		base := float64(m.OutWindow-m.OutLastDay) / float64(r.LookbackDays-1)
+		if base <= 0 || m.OutLastDay <= 0 {
+			return false, 0, ""
+		}
+		factor := float64(m.OutLastDay) / base
+		return factor >= r.Threshold, factor,
+			fmt.Sprintf("Salidas del último día (%d) %.1f veces el promedio diario (%.2f)", m.OutLastDay, factor, base)
+	}
+	return false, 0, ""
+}
+
+// RuleEngine evalúa las reglas activas y mantiene sus resultados
+type RuleEngine struct {
+	db *sql.DB
+}
+
+// NewRuleEngine crea el evaluador de reglas
+func NewRuleEngine(db *sql.DB) *RuleEngine {
+	return &RuleEngine{db: db}
+}
+
+// Evaluate evalúa todas las reglas activas: registra o actualiza los
+// resultados que se cumplen y marca como CLEARED los que dejaron de
+// cumplirse. Devuelve el total de resultados activos.
+func (e *RuleEngine) Evaluate() (int, error) {
+	reglas, err := e.reglasActivas()
+	if err != nil {
+		return 0, err
+	}
+	activas := 0
+	for _, regla := range reglas {
+		n, err := e.evaluarRegla(regla)
+		if err != nil {
+			return activas, fmt.Errorf("regla %s: %w", regla.Name, err)
+		}
+		activas += n
+	}
+	return activas, nil
+}
+
+func (e *RuleEngine) reglasActivas() ([]Rule, error) {
+	rows, err := e.db.Query(`
+        SELECT id, tenantId, name, type, threshold, lookbackDays, severity, productId, category, storeId
+        FROM prueba.reglasalertas
+        WHERE activo = true
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var reglas []Rule
+	for rows.Next() {
+		var r Rule
+		if err := rows.Scan(&r.ID, &r.TenantID, &r.Name, &r.Type, &r.Threshold, &r.LookbackDays,
+			&r.Severity, &r.ProductID, &r.Category, &r.StoreID); err != nil {
+			return nil, err
+		}
+		reglas = append(reglas, r)
+	}
+	return reglas, rows.Err()
+}
+
+// metricas calcula los indicadores de los inventarios activos dentro del
+// alcance de la regla, siempre dentro de la empresa dueña de la regla
+func metricas(q utils.Querier, r Rule) ([]Metrics, error) {
+	rows, err := q.Query(`
+        SELECT i.productId, i.storeId, i.quantity,
+               COALESCE(SUM(m.quantity) FILTER (
+                   WHERE tm.effect = 'OUT' AND tm.isSale AND m.sourceStoreId = i.storeId
+                     AND m.timestamp >= CURRENT_TIMESTAMP - $1 * INTERVAL '1 day'), 0),
+               COALESCE(SUM(m.quantity) FILTER (
+                   WHERE tm.effect = 'OUT' AND tm.isSale AND m.sourceStoreId = i.storeId
+                     AND m.timestamp >= CURRENT_TIMESTAMP - INTERVAL '1 day'), 0),
+               EXTRACT(DAY FROM CURRENT_TIMESTAMP - GREATEST(MAX(m.timestamp), i.created_at))::int
+        FROM prueba.inventarios i
+            JOIN catalogos.productos p ON p.id = i.productId
+            LEFT JOIN prueba.movimientos m ON m.productId = i.productId
+                AND (m.sourceStoreId = i.storeId OR m.targetStoreId = i.storeId)
+            LEFT JOIN catalogos.tiposmovimiento tm ON tm.tenantId = m.tenantId AND tm.code = m.type
+        WHERE i.activo = true
+          AND i.tenantId = $5
+          AND ($2::uuid IS NULL OR i.productId = $2)
+          AND ($3::varchar IS NULL OR p.category = $3)
+          AND ($4::uuid IS NULL OR i.storeId = $4)
+        GROUP BY i.productId, i.storeId, i.quantity, i.created_at
+    `, r.LookbackDays, r.ProductID, r.Category, r.StoreID, r.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lista []Metrics
+	for rows.Next() {
+		var m Metrics
+		if err := rows.Scan(&m.ProductID, &m.StoreID, &m.Quantity, &m.OutWindow,
+			&m.OutLastDay, &m.DaysSinceMovement); err != nil {
+			return nil, err
+		}
+		lista = append(lista, m)
+	}
+	return lista, rows.Err()
+}
+
+func (e *RuleEngine) evaluarRegla(r Rule) (int, error) {
+	activas := 0
+	err := utils.WithTransaction(e.db, func(tx *sql.Tx) error {
+		lista, err := metricas(tx, r)
+		if err != nil {
+			return err
+		}
+
+		var vigentes []string
+		for _, m := range lista {
+			cumple, valor, mensaje := r.Evaluate(m)
+			if !cumple {
+				continue
+			}
+			_, err := tx.Exec(`
+                INSERT INTO prueba.reglasalertasresultados (id, tenantId, ruleId, productId, storeId, value, message)
+                VALUES ($1, $7, $2, $3, $4, $5, $6)
+                ON CONFLICT (ruleId, productId, storeId) WHERE status = 'ACTIVE' DO
+                UPDATE SET value = EXCLUDED.value, message = EXCLUDED.message,
+                           last_evaluated_at = CURRENT_TIMESTAMP
+            `, uuid.New(), r.ID, m.ProductID, m.StoreID, valor, mensaje, r.TenantID)
+			if err != nil {
+				return err
+			}
+			vigentes = append(vigentes, m.ProductID.String()+"/"+m.StoreID.String())
+		}
+		activas = len(vigentes)
+
+		_, err = tx.Exec(`
+            UPDATE prueba.reglasalertasresultados
+            SET status = 'CLEARED', cleared_at = CURRENT_TIMESTAMP
+            WHERE ruleId = $1 AND status = 'ACTIVE'
+              AND NOT (productId::text || '/' || storeId::text = ANY($2))
+        `, r.ID, pq.Array(vigentes))
+		return err
+	})
+	return activas, err
+}
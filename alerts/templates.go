@@ -0,0 +1,125 @@
+package alerts
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Alert alerta tal como se muestra en los correos
+type Alert struct {
+	ID          uuid.UUID
+	TenantID    uuid.UUID
+	ProductID   uuid.UUID
+	StoreID     uuid.UUID
+	ProductName string
+	StoreName   string
+	// STOCK_BAJO o SIN_STOCK
+	AlertType string
+	Quantity  int
+	MinStock  int
+	CreatedAt time.Time
+}
+
+// Digest datos del resumen diario de un destinatario
+type Digest struct {
+	Date   time.Time
+	Alerts []Alert
+}
+
+// Plantillas predeterminadas; cada una puede reemplazarse con un archivo
+// <nombre>.tmpl en el directorio de plantillas
+const plantillasPredeterminadas = `
+{{define "immediate_subject"}}[{{.AlertType}}] {{.ProductName}} en {{.StoreName}}{{end}}
+
+{{define "immediate_body"}}El producto {{.ProductName}} entró en {{tipo .AlertType}} en la tienda {{.StoreName}}.
+
+Existencia actual: {{.Quantity}}
+Stock mínimo:      {{.MinStock}}
+Detectada:         {{fecha .CreatedAt}}
+
+Para atender o posponer la alerta use el ID {{.ID}}.
+{{end}}
+
+{{define "digest_subject"}}Resumen de alertas de stock del {{dia .Date}} ({{len .Alerts}}){{end}}
+
+{{define "digest_body"}}Alertas de stock vigentes al {{dia .Date}}:
+{{range .Alerts}}
+- [{{.AlertType}}] {{.ProductName}} en {{.StoreName}}: {{.Quantity}} de {{.MinStock}} mínimo (desde {{fecha .CreatedAt}})
+{{- end}}
+
+Total: {{len .Alerts}}
+{{end}}
+`
+
+var funcionesPlantilla = template.FuncMap{
+	"fecha": func(t time.Time) string { return t.Format("2006-01-02 15:04") },
+	"dia":   func(t time.Time) string { return t.Format("2006-01-02") },
+	"tipo": func(tipo string) string {
+		if tipo == "SIN_STOCK" {
+			return "sin stock"
+		}
+		return "stock bajo"
+	},
+}
+
+// Templates plantillas de asunto y cuerpo de los avisos
+type Templates struct {
+	t *template.Template
+}
+
+// DefaultTemplates plantillas predeterminadas
+func DefaultTemplates() *Templates {
+	return &Templates{t: template.Must(template.New("alertas").Funcs(funcionesPlantilla).Parse(plantillasPredeterminadas))}
+}
+
+// LoadTemplates carga las predeterminadas y las reemplaza con los archivos
+// immediate_subject.tmpl, immediate_body.tmpl, digest_subject.tmpl y
+// digest_body.tmpl que existan en dir
+func LoadTemplates(dir string) (*Templates, error) {
+	tpl := DefaultTemplates()
+	if dir == "" {
+		return tpl, nil
+	}
+	archivos, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	for _, archivo := range archivos {
+		contenido, err := os.ReadFile(archivo)
+		if err != nil {
+			return nil, err
+		}
+		nombre := strings.TrimSuffix(filepath.Base(archivo), ".tmpl")
+		if _, err := tpl.t.New(nombre).Parse(string(contenido)); err != nil {
+			return nil, err
+		}
+	}
+	return tpl, nil
+}
+
+// Immediate asunto y cuerpo del aviso inmediato de una alerta
+func (t *Templates) Immediate(a Alert) (string, string, error) {
+	return t.render("immediate", a)
+}
+
+// DigestMail asunto y cuerpo del resumen diario
+func (t *Templates) DigestMail(d Digest) (string, string, error) {
+	return t.render("digest", d)
+}
+
+func (t *Templates) render(prefijo string, datos interface{}) (string, string, error) {
+	var asunto, cuerpo bytes.Buffer
+	if err := t.t.ExecuteTemplate(&asunto, prefijo+"_subject", datos); err != nil {
+		return "", "", err
+	}
+	if err := t.t.ExecuteTemplate(&cuerpo, prefijo+"_body", datos); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(asunto.String()), cuerpo.String(), nil
+}
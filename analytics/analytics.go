@@ -0,0 +1,201 @@
+// Package analytics calcula indicadores de inventario (rotación, días de
+// cobertura, sell-through y clasificación ABC) a partir de los movimientos y
+// las existencias.
+package analytics
+
+import (
+	"go-project/utils"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Agrupaciones disponibles
+const (
+	GroupProduct  = "product"
+	GroupCategory = "category"
+	GroupStore    = "store"
+)
+
+// Position actividad de un producto en una tienda durante el periodo
+type Position struct {
+	ProductID   uuid.UUID
+	ProductName string
+	Category    string
+	StoreID     uuid.UUID
+	StoreName   string
+	// Existencia al inicio y al fin del periodo
+	Beginning int
+	Ending    int
+	// Unidades recibidas (entradas y transferencias recibidas)
+	Received int
+	// Unidades vendidas (salidas de tipos marcados como venta)
+	Sold int
+}
+
+// Metric indicadores de un producto, categoría o tienda
+type Metric struct {
+	Key              string  `json:"key"`
+	Name             string  `json:"name"`
+	Beginning        int     `json:"beginning_quantity"`
+	Ending           int     `json:"ending_quantity"`
+	AverageInventory float64 `json:"average_inventory"`
+	Received         int     `json:"received"`
+	Sold             int     `json:"sold"`
+	// Veces que se vendió el inventario promedio en el periodo
+	Turnover *float64 `json:"turnover,omitempty"`
+	// Días que alcanza la existencia final al ritmo de venta del periodo
+	DaysOfCover *float64 `json:"days_of_cover,omitempty"`
+	// Porcentaje vendido de lo disponible (existencia inicial + recibido)
+	SellThrough *float64 `json:"sell_through,omitempty"`
+	// Participación en las ventas del periodo y acumulada (porcentajes)
+	SalesShare      float64 `json:"sales_share"`
+	CumulativeShare float64 `json:"cumulative_share"`
+	// A, B o C según la participación acumulada en las ventas
+	ABC string `json:"abc_class"`
+}
+
+// Thresholds límites de participación acumulada de las clases A y B
+// (0.8 y 0.95 de forma predeterminada)
+type Thresholds struct {
+	A float64
+	B float64
+}
+
+// DefaultThresholds límites clásicos 80/15/5
+var DefaultThresholds = Thresholds{A: 0.8, B: 0.95}
+
+// Compute agrupa las posiciones, calcula los indicadores del periodo de days
+// días y clasifica por ABC. El resultado se ordena de mayor a menor venta.
+func Compute(posiciones []Position, groupBy string, days float64, th Thresholds) []Metric {
+	grupos := map[string]*Metric{}
+	var orden []string
+	for _, p := range posiciones {
+		var key, name string
+		switch groupBy {
+		case GroupCategory:
+			key, name = p.Category, p.Category
+		case GroupStore:
+			key, name = p.StoreID.String(), p.StoreName
+		default:
+			key, name = p.ProductID.String(), p.ProductName
+		}
+		m, ok := grupos[key]
+		if !ok {
+			m = &Metric{Key: key, Name: name}
+			grupos[key] = m
+			orden = append(orden, key)
+		}
+		m.Beginning += p.Beginning
+		m.Ending += p.Ending
+		m.Received += p.Received
+		m.Sold += p.Sold
+	}
+
+	metricas := make([]Metric, 0, len(orden))
+	totalVendido := 0
+	for _, key := range orden {
+		m := grupos[key]
+		m.AverageInventory = redondear(float64(m.Beginning+m.Ending) / 2)
+		if m.AverageInventory > 0 {
+			m.Turnover = indicador(float64(m.Sold) / m.AverageInventory)
+		}
+		if m.Sold > 0 && days > 0 {
+			m.DaysOfCover = indicador(float64(m.Ending) / (float64(m.Sold) / days))
+		}
+		if disponible := m.Beginning + m.Received; disponible > 0 {
+			m.SellThrough = indicador(100 * float64(m.Sold) / float64(disponible))
+		}
+		totalVendido += m.Sold
+		metricas = append(metricas, *m)
+	}
+
+	sort.SliceStable(metricas, func(i, j int) bool {
+		if metricas[i].Sold != metricas[j].Sold {
+			return metricas[i].Sold > metricas[j].Sold
+		}
+		return metricas[i].Name < metricas[j].Name
+	})
+	Classify(metricas, totalVendido, th)
+	return metricas
+}
+
+// Classify asigna la clase ABC a métricas ordenadas de mayor a menor venta:
+// A mientras la participación acumulada previa no alcance th.A, B mientras no
+// alcance th.B y C el resto, incluidas las que no vendieron
+func Classify(metricas []Metric, totalVendido int, th Thresholds) {
+	acumulado := 0.0
+	for i := range metricas {
+		m := &metricas[i]
+		if totalVendido == 0 || m.Sold == 0 {
+			m.ABC = "C"
+			m.CumulativeShare = redondear(100 * acumulado)
+			continue
+		}
+		participacion := float64(m.Sold) / float64(totalVendido)
+		switch {
+		case acumulado < th.A:
+			m.ABC = "A"
+		case acumulado < th.B:
+			m.ABC = "B"
+		default:
+			m.ABC = "C"
+		}
+		acumulado += participacion
+		m.SalesShare = redondear(100 * participacion)
+		m.CumulativeShare = redondear(100 * acumulado)
+	}
+}
+
+// LoadPositions existencias inicial y final, recibido y vendido de cada
+// producto y tienda en [from, to). La existencia final se reconstruye desde
+// la actual descontando los movimientos posteriores a to.
+func LoadPositions(q utils.Querier, from, to time.Time, storeID *uuid.UUID, category string) ([]Position, error) {
+	rows, err := q.Query(`
+        SELECT i.productId, p.name, COALESCE(p.category, ''), i.storeId, t.name,
+               i.quantity - COALESCE(SUM(v.delta) FILTER (WHERE v.timestamp >= $2), 0) AS final,
+               COALESCE(SUM(v.delta) FILTER (WHERE v.timestamp >= $1 AND v.timestamp < $2), 0) AS neto,
+               COALESCE(SUM(v.delta) FILTER (
+                   WHERE v.timestamp >= $1 AND v.timestamp < $2
+                     AND v.effect IN ('IN', 'TRANSFER') AND v.delta > 0), 0) AS recibido,
+               COALESCE(-SUM(v.delta) FILTER (
+                   WHERE v.timestamp >= $1 AND v.timestamp < $2
+                     AND v.effect = 'OUT' AND v.isSale), 0) AS vendido
+        FROM prueba.inventarios i
+            JOIN catalogos.productos p ON p.id = i.productId
+            JOIN catalogos.tiendas t ON t.id = i.storeId
+            LEFT JOIN prueba.vw_movimientos_tienda v ON v.productId = i.productId
+                AND v.storeId = i.storeId AND v.timestamp >= $1
+        WHERE i.activo = true
+          AND ($3::uuid IS NULL OR i.storeId = $3)
+          AND ($4 = '' OR p.category = $4)
+        GROUP BY i.productId, p.name, p.category, i.storeId, t.name, i.quantity
+    `, from, to, storeID, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var lista []Position
+	for rows.Next() {
+		var p Position
+		var neto int
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.Category, &p.StoreID, &p.StoreName,
+			&p.Ending, &neto, &p.Received, &p.Sold); err != nil {
+			return nil, err
+		}
+		p.Beginning = p.Ending - neto
+		lista = append(lista, p)
+	}
+	return lista, rows.Err()
+}
+
+func indicador(v float64) *float64 {
+	r := redondear(v)
+	return &r
+}
+
+func redondear(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,56 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCompute(t *testing.T) {
+	centro, norte := uuid.New(), uuid.New()
+	leche, pan, sal, cafe := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	posiciones := []Position{
+		{ProductID: leche, ProductName: "Leche", Category: "Lácteos", StoreID: centro, StoreName: "Centro",
+			Beginning: 40, Ending: 20, Received: 30, Sold: 50},
+		{ProductID: leche, ProductName: "Leche", Category: "Lácteos", StoreID: norte, StoreName: "Norte",
+			Beginning: 20, Ending: 20, Received: 10, Sold: 10},
+		{ProductID: pan, ProductName: "Pan", Category: "Panadería", StoreID: centro, StoreName: "Centro",
+			Beginning: 10, Ending: 10, Received: 25, Sold: 25},
+		{ProductID: sal, ProductName: "Sal", Category: "Abarrotes", StoreID: centro, StoreName: "Centro",
+			Beginning: 5, Ending: 5, Sold: 15},
+		{ProductID: cafe, ProductName: "Café", Category: "Abarrotes", StoreID: norte, StoreName: "Norte",
+			Beginning: 8, Ending: 8},
+	}
+
+	metricas := Compute(posiciones, GroupProduct, 30, DefaultThresholds)
+	if len(metricas) != 4 || metricas[0].Name != "Leche" || metricas[3].Name != "Café" {
+		t.Fatalf("orden = %+v", metricas)
+	}
+
+	// Leche: 60 vendidas de 100 (60%), inventario promedio (60+40)/2 = 50
+	leches := metricas[0]
+	if leches.Sold != 60 || leches.AverageInventory != 50 || *leches.Turnover != 1.2 {
+		t.Errorf("leche = %+v", leches)
+	}
+	// 40 unidades a 2 diarias alcanzan 20 días; vendió 60 de 60+40 disponibles
+	if *leches.DaysOfCover != 20 || *leches.SellThrough != 60 {
+		t.Errorf("leche cobertura = %v, sell-through = %v", *leches.DaysOfCover, *leches.SellThrough)
+	}
+
+	clases := ""
+	for _, m := range metricas {
+		clases += m.ABC
+	}
+	// Leche 60%, Pan 25% (acumulado previo 60%), Sal 15% (previo 85%), Café sin venta
+	if clases != "AABC" {
+		t.Errorf("clases = %s", clases)
+	}
+	if metricas[3].DaysOfCover != nil || metricas[2].CumulativeShare != 100 {
+		t.Errorf("café = %+v, sal = %+v", metricas[3], metricas[2])
+	}
+
+	tiendas := Compute(posiciones, GroupStore, 30, DefaultThresholds)
+	if len(tiendas) != 2 || tiendas[0].Name != "Centro" || tiendas[0].Sold != 90 {
+		t.Errorf("tiendas = %+v", tiendas)
+	}
+}
@@ -0,0 +1,182 @@
+// Package balancing propone transferencias entre tiendas para cubrir el
+// stock mínimo de las tiendas desabastecidas con el excedente de las demás.
+package balancing
+
+import (
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// DefaultKeepRatio múltiplo del stock mínimo que conserva una tienda donante
+const DefaultKeepRatio = 1.5
+
+// Position existencia de un producto en una tienda
+type Position struct {
+	ProductID uuid.UUID
+	StoreID   uuid.UUID
+	Quantity  int
+	MinStock  int
+	// Unidades que pueden salir sin indicar ubicación; las ubicadas no se
+	// proponen para traslado
+	Movable int
+}
+
+// Transfer traslado propuesto
+type Transfer struct {
+	ProductID     uuid.UUID `json:"product_id"`
+	SourceStoreID uuid.UUID `json:"source_store_id"`
+	TargetStoreID uuid.UUID `json:"target_store_id"`
+	Quantity      int       `json:"quantity" example:"5"`
+	// Costo de la ruta (km en línea recta); se omite si alguna tienda no tiene coordenadas
+	Cost *float64 `json:"cost,omitempty" example:"12.5"`
+}
+
+// Shortage faltante que no pudo cubrirse con el excedente de otras tiendas
+type Shortage struct {
+	ProductID uuid.UUID `json:"product_id"`
+	StoreID   uuid.UUID `json:"store_id"`
+	Missing   int       `json:"missing" example:"3"`
+}
+
+// Plan transferencias propuestas y faltantes sin cubrir
+type Plan struct {
+	Transfers []Transfer `json:"transfers"`
+	Shortages []Shortage `json:"shortages"`
+}
+
+// CostFunc costo de trasladar de una tienda a otra; false si se desconoce
+type CostFunc func(source, target uuid.UUID) (float64, bool)
+
+// Options parámetros del planificador
+type Options struct {
+	// La donante conserva al menos KeepRatio veces su stock mínimo (nunca
+	// menos que el stock mínimo)
+	KeepRatio float64
+	// Costo máximo de una ruta; 0 sin límite. Las rutas de costo desconocido
+	// sólo se usan sin límite.
+	MaxCost float64
+}
+
+type donante struct {
+	storeID   uuid.UUID
+	excedente int
+}
+
+type receptora struct {
+	storeID uuid.UUID
+	deficit int
+}
+
+// Build arma el plan de todos los productos. Cada tienda bajo su stock
+// mínimo se completa hasta él, de la mayor carencia a la menor; si una
+// donante alcanza para todo el faltante se elige la más barata, y si no, la
+// de mayor excedente, para proponer el menor número de traslados.
+func Build(posiciones []Position, costo CostFunc, opt Options) Plan {
+	if opt.KeepRatio < 1 {
+		opt.KeepRatio = 1
+	}
+	porProducto := make(map[uuid.UUID][]Position)
+	var productos []uuid.UUID
+	for _, p := range posiciones {
+		if _, ok := porProducto[p.ProductID]; !ok {
+			productos = append(productos, p.ProductID)
+		}
+		porProducto[p.ProductID] = append(porProducto[p.ProductID], p)
+	}
+	sort.Slice(productos, func(i, j int) bool { return productos[i].String() < productos[j].String() })
+
+	plan := Plan{Transfers: []Transfer{}, Shortages: []Shortage{}}
+	for _, productID := range productos {
+		planearProducto(&plan, productID, porProducto[productID], costo, opt)
+	}
+	return plan
+}
+
+func planearProducto(plan *Plan, productID uuid.UUID, posiciones []Position, costo CostFunc, opt Options) {
+	var donantes []*donante
+	var receptoras []receptora
+	for _, p := range posiciones {
+		if p.Quantity < p.MinStock {
+			receptoras = append(receptoras, receptora{p.StoreID, p.MinStock - p.Quantity})
+			continue
+		}
+		conserva := int(math.Ceil(float64(p.MinStock) * opt.KeepRatio))
+		if excedente := minimo(p.Quantity-conserva, p.Movable); excedente > 0 {
+			donantes = append(donantes, &donante{p.StoreID, excedente})
+		}
+	}
+	sort.Slice(receptoras, func(i, j int) bool {
+		if receptoras[i].deficit != receptoras[j].deficit {
+			return receptoras[i].deficit > receptoras[j].deficit
+		}
+		return receptoras[i].storeID.String() < receptoras[j].storeID.String()
+	})
+	sort.Slice(donantes, func(i, j int) bool { return donantes[i].storeID.String() < donantes[j].storeID.String() })
+
+	for _, rec := range receptoras {
+		for rec.deficit > 0 {
+			d, c, conocido := elegirDonante(donantes, rec, costo, opt)
+			if d == nil {
+				break
+			}
+			cantidad := minimo(d.excedente, rec.deficit)
+			t := Transfer{ProductID: productID, SourceStoreID: d.storeID, TargetStoreID: rec.storeID, Quantity: cantidad}
+			if conocido {
+				c := math.Round(c*100) / 100
+				t.Cost = &c
+			}
+			plan.Transfers = append(plan.Transfers, t)
+			d.excedente -= cantidad
+			rec.deficit -= cantidad
+		}
+		if rec.deficit > 0 {
+			plan.Shortages = append(plan.Shortages, Shortage{ProductID: productID, StoreID: rec.storeID, Missing: rec.deficit})
+		}
+	}
+}
+
+// elegirDonante devuelve la donante de la siguiente transferencia y el costo
+// de su ruta; nil si ninguna donante alcanzable tiene excedente
+func elegirDonante(donantes []*donante, rec receptora, costo CostFunc, opt Options) (*donante, float64, bool) {
+	var elegida *donante
+	var costoElegida float64
+	var conocidoElegida, cubreElegida bool
+	for _, d := range donantes {
+		if d.excedente <= 0 || d.storeID == rec.storeID {
+			continue
+		}
+		c, conocido := costo(d.storeID, rec.storeID)
+		if opt.MaxCost > 0 && (!conocido || c > opt.MaxCost) {
+			continue
+		}
+		if !conocido {
+			c = math.Inf(1)
+		}
+		cubre := d.excedente >= rec.deficit
+
+		mejor := elegida == nil
+		switch {
+		case mejor:
+		case cubre != cubreElegida:
+			mejor = cubre
+		case cubre:
+			mejor = c < costoElegida
+		default:
+			mejor = d.excedente > elegida.excedente ||
+				(d.excedente == elegida.excedente && c < costoElegida)
+		}
+		if mejor {
+			elegida, costoElegida, conocidoElegida, cubreElegida = d, c, conocido, cubre
+		}
+	}
+	return elegida, costoElegida, conocidoElegida
+}
+
+func minimo(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,71 @@
+package balancing
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBuild(t *testing.T) {
+	producto := uuid.New()
+	norte, sur, centro, bodega := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	// Distancias entre tiendas; la bodega no tiene coordenadas
+	distancias := map[[2]uuid.UUID]float64{
+		{norte, centro}: 10, {sur, centro}: 4,
+		{norte, sur}: 14, {sur, norte}: 14,
+	}
+	costo := func(a, b uuid.UUID) (float64, bool) {
+		d, ok := distancias[[2]uuid.UUID{a, b}]
+		return d, ok
+	}
+
+	posiciones := []Position{
+		// Faltan 6 en el centro y 2 en el sur
+		{ProductID: producto, StoreID: centro, Quantity: 4, MinStock: 10, Movable: 4},
+		{ProductID: producto, StoreID: sur, Quantity: 8, MinStock: 10, Movable: 8},
+		// El norte conserva 15 y aporta 10; la bodega sólo puede mover 1 (el resto está ubicado)
+		{ProductID: producto, StoreID: norte, Quantity: 25, MinStock: 10, Movable: 25},
+		{ProductID: producto, StoreID: bodega, Quantity: 40, MinStock: 10, Movable: 1},
+	}
+
+	plan := Build(posiciones, costo, Options{KeepRatio: DefaultKeepRatio})
+	if len(plan.Shortages) != 0 {
+		t.Fatalf("faltantes = %+v", plan.Shortages)
+	}
+	if len(plan.Transfers) != 2 {
+		t.Fatalf("transferencias = %+v", plan.Transfers)
+	}
+	if tr := plan.Transfers[0]; tr.SourceStoreID != norte || tr.TargetStoreID != centro || tr.Quantity != 6 || tr.Cost == nil || *tr.Cost != 10 {
+		t.Errorf("primera transferencia = %+v", tr)
+	}
+	if tr := plan.Transfers[1]; tr.SourceStoreID != norte || tr.TargetStoreID != sur || tr.Quantity != 2 {
+		t.Errorf("segunda transferencia = %+v", tr)
+	}
+
+	// Con límite de distancia el sur no alcanza al norte ni a la bodega
+	plan = Build(posiciones, costo, Options{KeepRatio: DefaultKeepRatio, MaxCost: 12})
+	if len(plan.Transfers) != 1 || len(plan.Shortages) != 1 || plan.Shortages[0].StoreID != sur || plan.Shortages[0].Missing != 2 {
+		t.Errorf("plan con límite = %+v", plan)
+	}
+}
+
+func TestBuildSinExcedenteSuficiente(t *testing.T) {
+	producto := uuid.New()
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	sinCosto := func(uuid.UUID, uuid.UUID) (float64, bool) { return 0, false }
+
+	posiciones := []Position{
+		{ProductID: producto, StoreID: a, Quantity: 0, MinStock: 10, Movable: 0},
+		{ProductID: producto, StoreID: b, Quantity: 14, MinStock: 10, Movable: 14},
+		{ProductID: producto, StoreID: c, Quantity: 13, MinStock: 10, Movable: 13},
+	}
+	// Con KeepRatio 1 las donantes bajan hasta su stock mínimo: 4 + 3 = 7
+	plan := Build(posiciones, sinCosto, Options{KeepRatio: 1})
+	if len(plan.Transfers) != 2 || plan.Transfers[0].SourceStoreID != b || plan.Transfers[0].Quantity != 4 ||
+		plan.Transfers[0].Cost != nil {
+		t.Fatalf("transferencias = %+v", plan.Transfers)
+	}
+	if len(plan.Shortages) != 1 || plan.Shortages[0].Missing != 3 {
+		t.Errorf("faltantes = %+v", plan.Shortages)
+	}
+}
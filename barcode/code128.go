@@ -0,0 +1,121 @@
+package barcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedData indica contenido que la simbología no puede codificar
+var ErrUnsupportedData = errors.New("contenido no codificable")
+
+// Anchos de barra y espacio de cada símbolo Code 128 (0-102), de los
+// arranques A, B y C (103-105) y del paro (106)
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128CodeC  = 99
+	code128CodeB  = 100
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// Code128QuietZone módulos en blanco requeridos a cada lado del símbolo
+const Code128QuietZone = 10
+
+// Code128 codifica texto ASCII imprimible en Code 128, usando el juego C
+// para series de dígitos y el juego B para lo demás
+func Code128(data string) (*Symbol, error) {
+	valores, err := code128Values(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dígito de control: arranque más cada símbolo por su posición, módulo 103
+	suma := valores[0]
+	for i, v := range valores[1:] {
+		suma += v * (i + 1)
+	}
+	valores = append(valores, suma%103, code128Stop)
+
+	var modulos []bool
+	for _, v := range valores {
+		for i, ancho := range code128Patterns[v] {
+			for n := 0; n < int(ancho-'0'); n++ {
+				modulos = append(modulos, i%2 == 0)
+			}
+		}
+	}
+	return newLinear(modulos, Code128QuietZone), nil
+}
+
+// code128Values convierte el texto en valores de símbolo, empezando por el
+// arranque. Se cambia a juego C en series de al menos 4 dígitos al inicio o
+// al final y de al menos 6 en medio, donde el cambio compensa.
+func code128Values(data string) ([]int, error) {
+	if data == "" {
+		return nil, fmt.Errorf("%w: texto vacío", ErrUnsupportedData)
+	}
+	for i := 0; i < len(data); i++ {
+		if data[i] < 32 || data[i] > 126 {
+			return nil, fmt.Errorf("%w: Code 128 admite sólo ASCII imprimible", ErrUnsupportedData)
+		}
+	}
+
+	digitos := func(i int) int {
+		n := 0
+		for i+n < len(data) && data[i+n] >= '0' && data[i+n] <= '9' {
+			n++
+		}
+		return n
+	}
+
+	var valores []int
+	juegoC := false
+	if digitos(0) >= 4 {
+		juegoC = true
+		valores = append(valores, code128StartC)
+	} else {
+		valores = append(valores, code128StartB)
+	}
+
+	for i := 0; i < len(data); {
+		n := digitos(i)
+		if juegoC {
+			if n >= 2 {
+				valores = append(valores, int(data[i]-'0')*10+int(data[i+1]-'0'))
+				i += 2
+				continue
+			}
+			valores = append(valores, code128CodeB)
+			juegoC = false
+		}
+
+		// Cambiar a C si la serie de dígitos es suficientemente larga;
+		// con longitud impar el primer dígito va en B
+		if n >= 6 || (n >= 4 && i+n == len(data)) {
+			if n%2 == 1 {
+				valores = append(valores, int(data[i])-32)
+				i++
+			}
+			valores = append(valores, code128CodeC)
+			juegoC = true
+			continue
+		}
+		valores = append(valores, int(data[i])-32)
+		i++
+	}
+	return valores, nil
+}
@@ -0,0 +1,67 @@
+package barcode
+
+import "testing"
+
+func TestCode128Patrones(t *testing.T) {
+	vistos := map[string]bool{}
+	for i, p := range code128Patterns {
+		suma := 0
+		for _, c := range p {
+			suma += int(c - '0')
+		}
+		esperado := 11
+		if i == code128Stop {
+			esperado = 13
+		}
+		if suma != esperado {
+			t.Errorf("patrón %d (%s) mide %d módulos, se esperaban %d", i, p, suma, esperado)
+		}
+		if vistos[p] {
+			t.Errorf("patrón %d (%s) repetido", i, p)
+		}
+		vistos[p] = true
+	}
+}
+
+func TestCode128Valores(t *testing.T) {
+	casos := []struct {
+		data    string
+		valores []int
+	}{
+		// Sólo juego B
+		{"LAP-001", []int{code128StartB, 44, 33, 48, 13, 16, 16, 17}},
+		// Arranque en C con dígitos pares
+		{"123456", []int{code128StartC, 12, 34, 56}},
+		// Serie impar al final: el primer dígito va en B
+		{"AB12345", []int{code128StartB, 33, 34, 17, code128CodeC, 23, 45}},
+	}
+	for _, c := range casos {
+		valores, err := code128Values(c.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(valores) != len(c.valores) {
+			t.Errorf("%s = %v, se esperaba %v", c.data, valores, c.valores)
+			continue
+		}
+		for i := range valores {
+			if valores[i] != c.valores[i] {
+				t.Errorf("%s = %v, se esperaba %v", c.data, valores, c.valores)
+				break
+			}
+		}
+	}
+
+	s, err := Code128("LAP-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Arranque, 7 caracteres y control de 11 módulos más el paro de 13
+	if s.Cols != 11*9+13 || !s.Dark(0, 0) || !s.Dark(s.Cols-1, 0) {
+		t.Errorf("Code128 mide %d módulos, se esperaban %d con barras en los extremos", s.Cols, 11*9+13)
+	}
+
+	if _, err := Code128("ñ"); err == nil {
+		t.Error("se esperaba error con caracteres fuera de ASCII")
+	}
+}
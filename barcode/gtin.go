@@ -0,0 +1,67 @@
+// Package barcode valida y genera códigos de barras de productos.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidGTIN indica un código que no es un GTIN válido
+var ErrInvalidGTIN = errors.New("GTIN inválido")
+
+// Tipos de código según su longitud
+const (
+	EAN8   = "EAN8"
+	UPCA   = "UPCA"
+	EAN13  = "EAN13"
+	GTIN14 = "GTIN14"
+)
+
+var tiposPorLongitud = map[int]string{8: EAN8, 12: UPCA, 13: EAN13, 14: GTIN14}
+
+// CheckDigit calcula el dígito verificador GS1 de los dígitos dados (sin el
+// verificador): de derecha a izquierda se ponderan alternadamente por 3 y 1.
+func CheckDigit(digits string) (byte, error) {
+	suma := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if d < '0' || d > '9' {
+			return 0, fmt.Errorf("%w: %q contiene caracteres no numéricos", ErrInvalidGTIN, digits)
+		}
+		peso := 1
+		if (len(digits)-1-i)%2 == 0 {
+			peso = 3
+		}
+		suma += int(d-'0') * peso
+	}
+	return byte('0' + (10-suma%10)%10), nil
+}
+
+// Validate verifica longitud, dígitos y dígito verificador de un EAN-8,
+// UPC-A, EAN-13 o GTIN-14, y devuelve su tipo
+func Validate(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	tipo, ok := tiposPorLongitud[len(code)]
+	if !ok {
+		return "", fmt.Errorf("%w: longitud %d, se esperaban 8, 12, 13 o 14 dígitos", ErrInvalidGTIN, len(code))
+	}
+	esperado, err := CheckDigit(code[:len(code)-1])
+	if err != nil {
+		return "", err
+	}
+	if code[len(code)-1] != esperado {
+		return "", fmt.Errorf("%w: dígito verificador %c, se esperaba %c", ErrInvalidGTIN, code[len(code)-1], esperado)
+	}
+	return tipo, nil
+}
+
+// Normalize valida el código y lo completa con ceros a la izquierda a 14
+// dígitos, de modo que un UPC-A y su EAN-13 equivalente se almacenen igual
+func Normalize(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if _, err := Validate(code); err != nil {
+		return "", err
+	}
+	return strings.Repeat("0", 14-len(code)) + code, nil
+}
@@ -0,0 +1,46 @@
+package barcode
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	casos := []struct {
+		code string
+		tipo string
+		ok   bool
+	}{
+		{"4006381333931", EAN13, true},
+		{"036000291452", UPCA, true},
+		{"96385074", EAN8, true},
+		{"10012345678902", GTIN14, true},
+		{"4006381333932", "", false},
+		{"40063813339", "", false},
+		{"40063813339A1", "", false},
+	}
+
+	for _, c := range casos {
+		tipo, err := Validate(c.code)
+		if c.ok && (err != nil || tipo != c.tipo) {
+			t.Errorf("Validate(%q) = %q, %v; se esperaba %q", c.code, tipo, err, c.tipo)
+		}
+		if !c.ok && !errors.Is(err, ErrInvalidGTIN) {
+			t.Errorf("Validate(%q) debía fallar, error = %v", c.code, err)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	upc, err := Normalize("036000291452")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ean, err := Normalize("0036000291452")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upc != ean || upc != "00036000291452" {
+		t.Errorf("UPC-A %s y EAN-13 %s deben normalizarse igual", upc, ean)
+	}
+}
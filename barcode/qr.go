@@ -0,0 +1,443 @@
+package barcode
+
+import "fmt"
+
+// QRQuietZone módulos en blanco requeridos alrededor de un código QR
+const QRQuietZone = 4
+
+// qrVersion bloques de corrección de errores nivel M de una versión QR
+type qrVersion struct {
+	ecPorBloque int
+	// Codewords de datos de cada bloque
+	bloques []int
+	// Centros de los patrones de alineación
+	alineacion []int
+}
+
+// Versiones 1 a 10 con nivel de corrección M (hasta 213 bytes)
+var qrVersiones = [...]qrVersion{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+// Bits de nivel de corrección M en la información de formato
+const qrNivelM = 0
+
+// QR codifica los bytes del texto en modo byte con corrección de errores
+// nivel M, eligiendo la menor versión (1 a 10) que los contenga
+func QR(data string) (*Symbol, error) {
+	version := 0
+	for v := 1; v < len(qrVersiones); v++ {
+		bitsConteo := 8
+		if v >= 10 {
+			bitsConteo = 16
+		}
+		if 4+bitsConteo+8*len(data) <= 8*qrCapacidad(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%w: QR admite hasta 213 bytes", ErrUnsupportedData)
+	}
+
+	q := newQRMatriz(version)
+	q.dibujarFunciones()
+	q.dibujarDatos(qrCodewords(version, []byte(data)))
+
+	// Elegir la máscara con menor penalización
+	mejor, menor := 0, -1
+	for m := 0; m < 8; m++ {
+		q.aplicarMascara(m)
+		q.dibujarFormato(m)
+		if p := q.penalizacion(); menor < 0 || p < menor {
+			mejor, menor = m, p
+		}
+		q.aplicarMascara(m)
+	}
+	q.aplicarMascara(mejor)
+	q.dibujarFormato(mejor)
+
+	return &Symbol{Cols: q.size, Rows: q.size, QuietZone: QRQuietZone, modules: q.modulos}, nil
+}
+
+// qrCapacidad codewords de datos de una versión
+func qrCapacidad(version int) int {
+	total := 0
+	for _, n := range qrVersiones[version].bloques {
+		total += n
+	}
+	return total
+}
+
+// qrCodewords arma el flujo de datos (modo, longitud, bytes, terminador y
+// relleno), calcula la corrección de cada bloque y los intercala
+func qrCodewords(version int, data []byte) []byte {
+	capacidad := qrCapacidad(version)
+	var bits bitBuffer
+	bits.agregar(0x4, 4)
+	if version >= 10 {
+		bits.agregar(len(data), 16)
+	} else {
+		bits.agregar(len(data), 8)
+	}
+	for _, b := range data {
+		bits.agregar(int(b), 8)
+	}
+	for i := 0; i < 4 && len(bits) < capacidad*8; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for relleno := 0xEC; len(bits) < capacidad*8; relleno ^= 0xEC ^ 0x11 {
+		bits.agregar(relleno, 8)
+	}
+	datos := bits.bytes()
+
+	v := qrVersiones[version]
+	generador := rsGenerador(v.ecPorBloque)
+	var bloques, correcciones [][]byte
+	for _, n := range v.bloques {
+		bloques = append(bloques, datos[:n])
+		correcciones = append(correcciones, rsResiduo(datos[:n], generador))
+		datos = datos[n:]
+	}
+
+	var resultado []byte
+	for i := 0; i < v.bloques[len(v.bloques)-1]; i++ {
+		for _, b := range bloques {
+			if i < len(b) {
+				resultado = append(resultado, b[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPorBloque; i++ {
+		for _, c := range correcciones {
+			resultado = append(resultado, c[i])
+		}
+	}
+	return resultado
+}
+
+// bitBuffer secuencia de bits, el más significativo primero
+type bitBuffer []bool
+
+func (b *bitBuffer) agregar(valor, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, (valor>>i)&1 == 1)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	resultado := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			resultado[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return resultado
+}
+
+// Aritmética de Reed-Solomon en GF(256) con polinomio primitivo 0x11D
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// rsGenerador polinomio generador de grado n, coeficientes del mayor al menor
+func rsGenerador(n int) []byte {
+	g := []byte{1}
+	for i := 0; i < n; i++ {
+		siguiente := make([]byte, len(g)+1)
+		for j, c := range g {
+			siguiente[j] ^= c
+			siguiente[j+1] ^= gfMul(c, gfExp[i])
+		}
+		g = siguiente
+	}
+	return g
+}
+
+// rsResiduo codewords de corrección: residuo de datos·x^n entre el generador
+func rsResiduo(datos, generador []byte) []byte {
+	residuo := make([]byte, len(generador)-1)
+	for _, d := range datos {
+		factor := d ^ residuo[0]
+		copy(residuo, residuo[1:])
+		residuo[len(residuo)-1] = 0
+		for i := range residuo {
+			residuo[i] ^= gfMul(generador[i+1], factor)
+		}
+	}
+	return residuo
+}
+
+// qrMatriz módulos de un código QR en construcción
+type qrMatriz struct {
+	version   int
+	size      int
+	modulos   []bool
+	funciones []bool
+}
+
+func newQRMatriz(version int) *qrMatriz {
+	size := 17 + 4*version
+	return &qrMatriz{
+		version:   version,
+		size:      size,
+		modulos:   make([]bool, size*size),
+		funciones: make([]bool, size*size),
+	}
+}
+
+// fijar dibuja un módulo de función (no disponible para datos)
+func (q *qrMatriz) fijar(x, y int, oscuro bool) {
+	q.modulos[y*q.size+x] = oscuro
+	q.funciones[y*q.size+x] = true
+}
+
+// dibujarFunciones dibuja patrones de localización, separadores, alineación,
+// sincronización e información de versión, y reserva la zona de formato
+func (q *qrMatriz) dibujarFunciones() {
+	for i := 0; i < q.size; i++ {
+		q.fijar(6, i, i%2 == 0)
+		q.fijar(i, 6, i%2 == 0)
+	}
+
+	for _, c := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || y < 0 || x >= q.size || y >= q.size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				q.fijar(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	posiciones := qrVersiones[q.version].alineacion
+	ultimo := len(posiciones) - 1
+	for i, cy := range posiciones {
+		for j, cx := range posiciones {
+			// Se omiten las que se traslapan con los patrones de localización
+			if (i == 0 && j == 0) || (i == 0 && j == ultimo) || (i == ultimo && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.fijar(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	q.dibujarFormato(0)
+
+	if q.version >= 7 {
+		residuo := q.version
+		for i := 0; i < 12; i++ {
+			residuo = (residuo << 1) ^ ((residuo >> 11) * 0x1F25)
+		}
+		bits := q.version<<12 | residuo
+		for i := 0; i < 18; i++ {
+			oscuro := (bits>>i)&1 == 1
+			a, b := q.size-11+i%3, i/3
+			q.fijar(a, b, oscuro)
+			q.fijar(b, a, oscuro)
+		}
+	}
+}
+
+// qrFormato 15 bits de información de formato para nivel M y la máscara dada
+func qrFormato(mascara int) int {
+	datos := qrNivelM<<3 | mascara
+	residuo := datos
+	for i := 0; i < 10; i++ {
+		residuo = (residuo << 1) ^ ((residuo >> 9) * 0x537)
+	}
+	return (datos<<10 | residuo) ^ 0x5412
+}
+
+// dibujarFormato escribe las dos copias de la información de formato
+func (q *qrMatriz) dibujarFormato(mascara int) {
+	bits := qrFormato(mascara)
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.fijar(8, i, bit(i))
+	}
+	q.fijar(8, 7, bit(6))
+	q.fijar(8, 8, bit(7))
+	q.fijar(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.fijar(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.fijar(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.fijar(8, q.size-15+i, bit(i))
+	}
+	q.fijar(8, q.size-8, true)
+}
+
+// dibujarDatos coloca los codewords en zigzag por pares de columnas, de
+// derecha a izquierda, saltando la columna de sincronización
+func (q *qrMatriz) dibujarDatos(datos []byte) {
+	i := 0
+	for derecha := q.size - 1; derecha >= 1; derecha -= 2 {
+		if derecha == 6 {
+			derecha = 5
+		}
+		for vertical := 0; vertical < q.size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := derecha - j
+				y := vertical
+				if (derecha+1)&2 == 0 {
+					y = q.size - 1 - vertical
+				}
+				if q.funciones[y*q.size+x] || i >= len(datos)*8 {
+					continue
+				}
+				q.modulos[y*q.size+x] = (datos[i/8]>>(7-i%8))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// aplicarMascara invierte los módulos de datos que cumplen la condición de la
+// máscara; aplicarla dos veces la revierte
+func (q *qrMatriz) aplicarMascara(mascara int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invertir bool
+			switch mascara {
+			case 0:
+				invertir = (x+y)%2 == 0
+			case 1:
+				invertir = y%2 == 0
+			case 2:
+				invertir = x%3 == 0
+			case 3:
+				invertir = (x+y)%3 == 0
+			case 4:
+				invertir = (x/3+y/2)%2 == 0
+			case 5:
+				invertir = x*y%2+x*y%3 == 0
+			case 6:
+				invertir = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invertir = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invertir && !q.funciones[y*q.size+x] {
+				q.modulos[y*q.size+x] = !q.modulos[y*q.size+x]
+			}
+		}
+	}
+}
+
+// penalizacion evalúa las cuatro reglas de la norma: series de 5 o más
+// módulos iguales, bloques de 2x2, patrones parecidos a los de localización
+// y desbalance entre módulos oscuros y claros
+func (q *qrMatriz) penalizacion() int {
+	oscuro := func(x, y int) bool { return q.modulos[y*q.size+x] }
+	total := 0
+
+	for _, filas := range []bool{true, false} {
+		for a := 0; a < q.size; a++ {
+			serie := 0
+			var patron int
+			var anterior bool
+			for b := 0; b < q.size; b++ {
+				actual := oscuro(b, a)
+				if !filas {
+					actual = oscuro(a, b)
+				}
+				if b > 0 && actual == anterior {
+					serie++
+				} else {
+					if serie >= 5 {
+						total += serie - 2
+					}
+					serie = 1
+				}
+				anterior = actual
+
+				// Ventana de 11 módulos para 1011101 con 4 claros antes o después
+				patron = (patron<<1 | b2i(actual)) & 0x7FF
+				if b >= 10 && (patron == 0x5D0 || patron == 0x05D) {
+					total += 40
+				}
+			}
+			if serie >= 5 {
+				total += serie - 2
+			}
+		}
+	}
+
+	oscuros := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if oscuro(x, y) {
+				oscuros++
+			}
+			if x < q.size-1 && y < q.size-1 {
+				c := oscuro(x, y)
+				if c == oscuro(x+1, y) && c == oscuro(x, y+1) && c == oscuro(x+1, y+1) {
+					total += 3
+				}
+			}
+		}
+	}
+
+	porcentaje := oscuros * 100 / (q.size * q.size)
+	total += 10 * (abs(porcentaje-50) / 5)
+	return total
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
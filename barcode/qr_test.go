@@ -0,0 +1,147 @@
+package barcode
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" versión 1-M (ejemplo de la norma)
+	datos := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	esperado := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	residuo := rsResiduo(datos, rsGenerador(10))
+	if !bytes.Equal(residuo, esperado) {
+		t.Errorf("corrección = %v, se esperaba %v", residuo, esperado)
+	}
+}
+
+func TestQRFormato(t *testing.T) {
+	casos := map[int]string{0: "101010000010010", 4: "100010111111001", 7: "100101010100000"}
+	for mascara, esperado := range casos {
+		if f := fmt.Sprintf("%015b", qrFormato(mascara)); f != esperado {
+			t.Errorf("formato M%d = %s, se esperaba %s", mascara, f, esperado)
+		}
+	}
+}
+
+func TestQR(t *testing.T) {
+	s, err := QR("LAP-001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Cols != 21 || s.Rows != 21 {
+		t.Fatalf("se esperaba versión 1 (21x21), se obtuvo %dx%d", s.Cols, s.Rows)
+	}
+	// Patrones de localización en tres esquinas y módulo oscuro fijo
+	for _, c := range [][2]int{{0, 0}, {20, 0}, {0, 20}, {3, 3}, {8, 13}} {
+		if !s.Dark(c[0], c[1]) {
+			t.Errorf("el módulo %v debía ser oscuro", c)
+		}
+	}
+	if s.Dark(1, 1) || s.Dark(7, 0) {
+		t.Error("los anillos claros del patrón de localización deben ser claros")
+	}
+
+	largo, err := QR(strings.Repeat("x", 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if largo.Cols != 57 {
+		t.Errorf("200 bytes requieren versión 10 (57x57), se obtuvo %d", largo.Cols)
+	}
+	if _, err := QR(strings.Repeat("x", 214)); err == nil {
+		t.Error("se esperaba error con más de 213 bytes")
+	}
+}
+
+func TestRender(t *testing.T) {
+	s, _ := QR("LAP-001")
+	var buf bytes.Buffer
+	if err := WritePNG(&buf, s, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != (21+8)*4 || b.Dy() != (21+8)*4 {
+		t.Errorf("PNG de %dx%d, se esperaba %d", b.Dx(), b.Dy(), (21+8)*4)
+	}
+
+	lineal, _ := Code128("LAP-001")
+	buf.Reset()
+	if err := WriteSVG(&buf, lineal, 2, 80); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "<svg") || !strings.Contains(buf.String(), `height="80"`) {
+		t.Errorf("SVG inesperado: %.80s", buf.String())
+	}
+}
+
+// TestQRLectura lee el símbolo generado como lo haría un lector: obtiene la
+// máscara de la información de formato, la revierte y recupera los codewords
+func TestQRLectura(t *testing.T) {
+	for _, texto := range []string{"LAP-001", strings.Repeat("SKU-12345|", 12)} {
+		s, err := QR(texto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		version := (s.Cols - 17) / 4
+		ref := newQRMatriz(version)
+		ref.dibujarFunciones()
+
+		formato := 0
+		for i := 0; i <= 5; i++ {
+			formato |= b2i(s.Dark(8, i)) << i
+		}
+		formato |= b2i(s.Dark(8, 7))<<6 | b2i(s.Dark(8, 8))<<7 | b2i(s.Dark(7, 8))<<8
+		for i := 9; i < 15; i++ {
+			formato |= b2i(s.Dark(14-i, 8)) << i
+		}
+		mascara := -1
+		for m := 0; m < 8; m++ {
+			if qrFormato(m) == formato {
+				mascara = m
+			}
+		}
+		if mascara < 0 {
+			t.Fatalf("%q: información de formato %015b inválida", texto, formato)
+		}
+
+		lector := newQRMatriz(version)
+		copy(lector.modulos, s.modules)
+		copy(lector.funciones, ref.funciones)
+		lector.aplicarMascara(mascara)
+
+		esperado := qrCodewords(version, []byte(texto))
+		leido := make([]byte, len(esperado))
+		i := 0
+		for derecha := lector.size - 1; derecha >= 1; derecha -= 2 {
+			if derecha == 6 {
+				derecha = 5
+			}
+			for vertical := 0; vertical < lector.size; vertical++ {
+				for j := 0; j < 2; j++ {
+					x, y := derecha-j, vertical
+					if (derecha+1)&2 == 0 {
+						y = lector.size - 1 - vertical
+					}
+					if lector.funciones[y*lector.size+x] || i >= len(leido)*8 {
+						continue
+					}
+					if lector.modulos[y*lector.size+x] {
+						leido[i/8] |= 0x80 >> (i % 8)
+					}
+					i++
+				}
+			}
+		}
+		if !bytes.Equal(leido, esperado) {
+			t.Errorf("%q: codewords leídos no coinciden", texto)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package barcode
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Symbol código de barras ya codificado como una cuadrícula de módulos. Los
+// códigos lineales (Code 128) tienen una sola fila que se estira al dibujar.
+type Symbol struct {
+	Cols, Rows int
+	// Módulos en blanco que deben rodear al símbolo
+	QuietZone int
+	modules   []bool
+}
+
+func newLinear(modules []bool, quiet int) *Symbol {
+	return &Symbol{Cols: len(modules), Rows: 1, QuietZone: quiet, modules: modules}
+}
+
+// Dark indica si el módulo de la columna x y la fila y es oscuro
+func (s *Symbol) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= s.Cols || y >= s.Rows {
+		return false
+	}
+	return s.modules[y*s.Cols+x]
+}
+
+// Linear indica si el símbolo es un código de barras de una dimensión
+func (s *Symbol) Linear() bool {
+	return s.Rows == 1
+}
+
+// dimensiones calcula el tamaño en pixeles del símbolo con su zona de
+// silencio: scale pixeles por módulo y, en códigos lineales, barHeight de alto
+func (s *Symbol) dimensiones(scale, barHeight int) (ancho, alto, altoModulo int) {
+	ancho = (s.Cols + 2*s.QuietZone) * scale
+	if s.Linear() {
+		return ancho, barHeight, barHeight
+	}
+	return ancho, (s.Rows + 2*s.QuietZone) * scale, scale
+}
+
+// WritePNG dibuja el símbolo en blanco y negro como imagen PNG
+func WritePNG(w io.Writer, s *Symbol, scale, barHeight int) error {
+	ancho, alto, altoModulo := s.dimensiones(scale, barHeight)
+	img := image.NewGray(image.Rect(0, 0, ancho, alto))
+	for i := range img.Pix {
+		img.Pix[i] = 0xFF
+	}
+
+	margenY := 0
+	if !s.Linear() {
+		margenY = s.QuietZone * scale
+	}
+	for y := 0; y < s.Rows; y++ {
+		for x := 0; x < s.Cols; x++ {
+			if !s.Dark(x, y) {
+				continue
+			}
+			x0 := (x + s.QuietZone) * scale
+			y0 := margenY + y*altoModulo
+			for py := y0; py < y0+altoModulo; py++ {
+				for px := x0; px < x0+scale; px++ {
+					img.SetGray(px, py, color.Gray{Y: 0})
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// WriteSVG dibuja el símbolo como SVG, uniendo los módulos oscuros contiguos
+// de cada fila en un solo rectángulo
+func WriteSVG(w io.Writer, s *Symbol, scale, barHeight int) error {
+	ancho, alto, altoModulo := s.dimensiones(scale, barHeight)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		ancho, alto, ancho, alto)
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, ancho, alto)
+
+	margenY := 0
+	if !s.Linear() {
+		margenY = s.QuietZone * scale
+	}
+	for y := 0; y < s.Rows; y++ {
+		for x := 0; x < s.Cols; {
+			if !s.Dark(x, y) {
+				x++
+				continue
+			}
+			inicio := x
+			for x < s.Cols && s.Dark(x, y) {
+				x++
+			}
+			fmt.Fprintf(bw, "M%d %dh%dv%dh-%dz", (inicio+s.QuietZone)*scale, margenY+y*altoModulo,
+				(x-inicio)*scale, altoModulo, (x-inicio)*scale)
+		}
+	}
+	bw.WriteString(`"/></svg>`)
+	return bw.Flush()
+}
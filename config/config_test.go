@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	base := Config{AppEnv: "production", JWTSecret: "s3cr3to-largo", DBTenantPassword: "clave"}
+	if err := base.Validate(); err != nil {
+		t.Fatalf("configuración válida rechazada: %v", err)
+	}
+
+	casos := map[string]func(c *Config){
+		"sin secreto":              func(c *Config) { c.JWTSecret = "" },
+		"secreto de ejemplo":       func(c *Config) { c.JWTSecret = jwtSecretEjemplo },
+		"auth desactivada en prod": func(c *Config) { c.AuthDisabled = true },
+		"sin contraseña del rol":   func(c *Config) { c.DBTenantPassword = "" },
+	}
+	for nombre, cambiar := range casos {
+		c := base
+		cambiar(&c)
+		if c.Validate() == nil {
+			t.Errorf("%s: se esperaba error", nombre)
+		}
+	}
+
+	// En desarrollo se puede operar sin autenticación, pero sólo de forma explícita
+	dev := Config{AppEnv: "development", DBTenantPassword: "clave"}
+	if dev.Validate() == nil {
+		t.Error("desarrollo sin secreto ni AUTH_DISABLED: se esperaba error")
+	}
+	dev.AuthDisabled = true
+	if err := dev.Validate(); err != nil {
+		t.Errorf("desarrollo con AUTH_DISABLED: %v", err)
+	}
+}
@@ -1,17 +1,84 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+// Secreto de ejemplo de la documentación; nunca es válido en producción
+const jwtSecretEjemplo = "cambiar-en-produccion"
 
 type Config struct {
 	AppEnv   string
 	LogLevel string
+	// BrokerURL dirección de NATS para publicar los eventos de dominio;
+	// vacía desactiva la publicación y los eventos esperan en la bandeja
+	BrokerURL string
+	// Servidor SMTP para las alertas de stock (host:puerto); vacío no envía correos
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUser     string
+	SMTPPassword string
+	// Hora local del resumen diario de alertas y directorio de plantillas propias
+	AlertDigestHour   int
+	AlertTemplatesDir string
+	// JWTSecret firma los tokens de acceso; es obligatorio salvo con
+	// AuthDisabled
+	JWTSecret string
+	// AuthDisabled desactiva la autenticación de forma explícita (desarrollo:
+	// la empresa se indica con el encabezado X-Tenant-ID)
+	AuthDisabled bool
+	// Rol de Postgres sujeto a las políticas de aislamiento por empresa; la
+	// contraseña es obligatoria
+	DBTenantUser     string
+	DBTenantPassword string
+	// Horas que se conserva cada Idempotency-Key antes de poder reutilizarse;
+	// una petición interrumpida deja su clave bloqueada durante todo este tiempo
+	IdempotencyTTLHours int
 }
 
 func LoadConfig() Config {
 	return Config{
-		AppEnv:   getEnv("APP_ENV", "development"),
-		LogLevel: getEnv("LOG_LEVEL", "debug"),
+		AppEnv:    getEnv("APP_ENV", "development"),
+		LogLevel:  getEnv("LOG_LEVEL", "debug"),
+		BrokerURL: getEnv("BROKER_URL", ""),
+
+		SMTPAddr:          getEnv("SMTP_ADDR", ""),
+		SMTPFrom:          getEnv("SMTP_FROM", "alertas@localhost"),
+		SMTPUser:          getEnv("SMTP_USER", ""),
+		SMTPPassword:      getEnv("SMTP_PASSWORD", ""),
+		AlertDigestHour:   getEnvInt("ALERT_DIGEST_HOUR", 8),
+		AlertTemplatesDir: getEnv("ALERT_TEMPLATES_DIR", ""),
+
+		JWTSecret:        getEnv("JWT_SECRET", ""),
+		AuthDisabled:     getEnvBool("AUTH_DISABLED", false),
+		DBTenantUser:     getEnv("DB_TENANT_USER", "inventario_app"),
+		DBTenantPassword: getEnv("DB_TENANT_PASSWORD", ""),
+
+		IdempotencyTTLHours: getEnvInt("IDEMPOTENCY_TTL_HOURS", 24),
+	}
+}
+
+// Validate rechaza las configuraciones que dejarían la API abierta: sin
+// JWT_SECRET sólo se arranca con AUTH_DISABLED=true, y en producción
+// (APP_ENV=production) se exige un secreto propio y la autenticación activa
+func (c Config) Validate() error {
+	if c.AppEnv == "production" {
+		if c.AuthDisabled {
+			return errors.New("AUTH_DISABLED no se admite con APP_ENV=production")
+		}
+		if c.JWTSecret == "" || c.JWTSecret == jwtSecretEjemplo {
+			return errors.New("JWT_SECRET vacío o de ejemplo con APP_ENV=production")
+		}
+	}
+	if c.JWTSecret == "" && !c.AuthDisabled {
+		return errors.New("JWT_SECRET no configurado; defina AUTH_DISABLED=true para operar sin autenticación")
+	}
+	if c.DBTenantPassword == "" {
+		return errors.New("DB_TENANT_PASSWORD no configurado")
 	}
+	return nil
 }
 
 func getEnv(key, fallback string) string {
@@ -20,3 +87,17 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(getEnv(key, "")); err == nil {
+		return n
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if b, err := strconv.ParseBool(getEnv(key, "")); err == nil {
+		return b
+	}
+	return fallback
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Ejemplo de la sección 1.3 del RFC 6455
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("AcceptKey = %q", got)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	for _, n := range []int{0, 5, 125, 126, 300, 70000} {
+		p := bytes.Repeat([]byte("a"), n)
+		for _, mask := range [][]byte{nil, {1, 2, 3, 4}} {
+			var buf bytes.Buffer
+			if err := writeFrame(&buf, opText, p, mask); err != nil {
+				t.Fatal(err)
+			}
+			op, got, err := readFrame(&buf, 1<<20)
+			if err != nil {
+				t.Fatalf("n=%d: %v", n, err)
+			}
+			if op != opText || !bytes.Equal(got, p) {
+				t.Errorf("n=%d mask=%v: frame no coincide", n, mask)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	writeFrame(&buf, opText, make([]byte, 200), []byte{1, 2, 3, 4})
+	if _, _, err := readFrame(&buf, 100); err == nil {
+		t.Error("se esperaba error por mensaje demasiado grande")
+	}
+}
+
+func TestHubFiltro(t *testing.T) {
+	tienda, otra := uuid.New(), uuid.New()
+	h := NewHub(nil)
+	todos := h.Subscribe(Filter{})
+	deTienda := h.Subscribe(Filter{StoreID: &tienda})
+
+	h.Publish(Event{ID: 1, StoreID: tienda})
+	h.Publish(Event{ID: 2, StoreID: otra})
+
+	if len(todos.C) != 2 || len(deTienda.C) != 1 {
+		t.Fatalf("recibidos: todos=%d tienda=%d", len(todos.C), len(deTienda.C))
+	}
+	if e := <-deTienda.C; e.ID != 1 {
+		t.Errorf("evento de tienda = %d", e.ID)
+	}
+
+	deTienda.Close()
+	deTienda.Close()
+	if _, ok := <-deTienda.C; ok {
+		t.Error("el canal debía cerrarse")
+	}
+}
+
+func TestHubSuscriptorLento(t *testing.T) {
+	h := NewHub(nil)
+	s := h.Subscribe(Filter{})
+	for i := 0; i <= subscriberBuffer; i++ {
+		h.Publish(Event{ID: int64(i + 1)})
+	}
+	n := 0
+	for range s.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("recibidos %d, se esperaban %d antes de desconectar", n, subscriberBuffer)
+	}
+}
+
+func TestHubFiltroEmpresa(t *testing.T) {
+	empresa, otra := uuid.New(), uuid.New()
+	h := NewHub(nil)
+	s := h.Subscribe(Filter{TenantID: &empresa})
+
+	h.Publish(Event{ID: 1, TenantID: empresa})
+	h.Publish(Event{ID: 2, TenantID: otra})
+
+	if len(s.C) != 1 {
+		t.Fatalf("recibidos %d, se esperaba sólo el de la empresa", len(s.C))
+	}
+	if e := <-s.C; e.ID != 1 {
+		t.Errorf("evento = %d", e.ID)
+	}
+}
+
+func TestHubSuscripcionDesde(t *testing.T) {
+	h := NewHub(nil)
+	h.Publish(Event{ID: 7})
+	s := h.Subscribe(Filter{})
+	h.Publish(Event{ID: 8})
+	if s.Desde != 7 || len(s.C) != 1 {
+		t.Errorf("Desde = %d, recibidos %d", s.Desde, len(s.C))
+	}
+	if r := Reset(s.Desde); r.ID != 7 || r.Type != TypeReset {
+		t.Errorf("Reset = %+v", r)
+	}
+}
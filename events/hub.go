@@ -0,0 +1,270 @@
+// Package events distribuye en tiempo real los cambios de inventario. Los
+// eventos se registran en prueba.eventosinventario mediante triggers y se
+// anuncian con NOTIFY, de modo que todas las réplicas de la aplicación los
+// reciben sin importar cuál de ellas hizo el cambio.
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-project/utils"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Channel canal de NOTIFY en el que los triggers anuncian cada evento nuevo
+const Channel = "eventos_inventario"
+
+// Tamaño del búfer de cada suscriptor; si se llena se le desconecta para no
+// frenar a los demás
+const subscriberBuffer = 256
+
+// TypeReset avisa al cliente que no se le pueden reenviar todos los eventos
+// perdidos: debe recargar el estado y continuar desde el ID del aviso
+const TypeReset = "RESET"
+
+// Llave del candado consultivo con el que las réplicas se turnan para ordenar
+// los eventos
+const ordenLockKey = 7310402
+
+// Event cambio de inventario tal como se envía a los clientes
+type Event struct {
+	// ID posición del evento en el orden en que se confirmó; un cliente
+	// reanuda pidiendo los posteriores al último que recibió
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	ProductID uuid.UUID       `json:"product_id"`
+	StoreID   uuid.UUID       `json:"store_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Empresa dueña del evento; no se envía a los clientes
+	TenantID uuid.UUID `json:"-"`
+}
+
+// Filter restringe los eventos a una empresa, tienda y/o producto; nil acepta todos
+type Filter struct {
+	TenantID  *uuid.UUID
+	StoreID   *uuid.UUID
+	ProductID *uuid.UUID
+}
+
+// Match indica si el evento cumple el filtro
+func (f Filter) Match(e Event) bool {
+	if f.TenantID != nil && *f.TenantID != e.TenantID {
+		return false
+	}
+	if f.StoreID != nil && *f.StoreID != e.StoreID {
+		return false
+	}
+	if f.ProductID != nil && *f.ProductID != e.ProductID {
+		return false
+	}
+	return true
+}
+
+// Subscription suscripción a los eventos en vivo. El canal se cierra al
+// cancelar o cuando el suscriptor no consume a tiempo.
+type Subscription struct {
+	C <-chan Event
+	// Desde último evento publicado antes de suscribirse; C recibe sólo los
+	// posteriores
+	Desde  int64
+	c      chan Event
+	filter Filter
+	hub    *Hub
+}
+
+// Close cancela la suscripción
+func (s *Subscription) Close() {
+	s.hub.quitar(s)
+}
+
+// Hub recibe las notificaciones de Postgres y las reparte entre los suscriptores
+type Hub struct {
+	db *sql.DB
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	ultimo int64
+}
+
+// NewHub crea un hub sin suscriptores
+func NewHub(db *sql.DB) *Hub {
+	return &Hub{db: db, subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registra un suscriptor para los eventos que cumplan el filtro
+func (h *Hub) Subscribe(f Filter) *Subscription {
+	c := make(chan Event, subscriberBuffer)
+	s := &Subscription{C: c, c: c, filter: f, hub: h}
+	h.mu.Lock()
+	s.Desde = h.ultimo
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) quitar(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.c)
+	}
+}
+
+// Publish entrega el evento a los suscriptores cuyo filtro lo acepta
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e.ID > h.ultimo {
+		h.ultimo = e.ID
+	}
+	for s := range h.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+		select {
+		case s.c <- e:
+		default:
+			// Suscriptor lento: se le desconecta y podrá reanudar con el último ID
+			delete(h.subs, s)
+			close(s.c)
+		}
+	}
+}
+
+// Listen escucha el canal de NOTIFY en segundo plano; con cada aviso ordena
+// los eventos confirmados y publica los nuevos. Tras una reconexión recupera
+// los que se hayan perdido.
+func (h *Hub) Listen(dsn string) (stop func(), err error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Error en la escucha de eventos de inventario: %v", err)
+		}
+	})
+	if err := listener.Listen(Channel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	// Partir del último evento existente para no reenviar el historial
+	if err := h.db.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM prueba.eventosinventario`).Scan(&h.ultimo); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		verificar := time.NewTicker(90 * time.Second)
+		defer verificar.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-listener.Notify:
+				// Un aviso nil indica que la conexión se restableció y pudieron
+				// perderse notificaciones; en ambos casos basta ponerse al día
+				h.ponerAlDia()
+			case <-verificar.C:
+				go listener.Ping()
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		listener.Close()
+	}, nil
+}
+
+// ordenar asigna la posición seq a los eventos confirmados que aún no la
+// tienen. El id del evento sale de una secuencia al insertar y una
+// transacción que confirma tarde deja un id menor detrás de otros ya
+// entregados; seq en cambio crece en el orden en que las réplicas, por
+// turnos, ven confirmados los eventos.
+func (h *Hub) ordenar() error {
+	return utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, ordenLockKey); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			UPDATE prueba.eventosinventario e SET seq = o.seq
+			FROM (SELECT id, nextval('prueba.eventosinventario_orden_seq') AS seq
+			      FROM (SELECT id FROM prueba.eventosinventario WHERE seq IS NULL ORDER BY id) p) o
+			WHERE e.id = o.id`)
+		return err
+	})
+}
+
+// ponerAlDia ordena los eventos confirmados y publica los posteriores al
+// último publicado
+func (h *Hub) ponerAlDia() {
+	if err := h.ordenar(); err != nil {
+		log.Printf("Error al ordenar eventos de inventario: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	desde := h.ultimo
+	h.mu.Unlock()
+
+	rows, err := h.db.Query(consultaEventos+` WHERE seq > $1 ORDER BY seq`, desde)
+	if err != nil {
+		log.Printf("Error al recuperar eventos de inventario: %v", err)
+		return
+	}
+	eventos, err := escanearEventos(rows)
+	if err != nil {
+		log.Printf("Error al recuperar eventos de inventario: %v", err)
+		return
+	}
+	for _, e := range eventos {
+		h.Publish(e)
+	}
+}
+
+// Replay devuelve los eventos posteriores a afterID que cumplen el filtro,
+// en orden, para que un cliente reanude la transmisión
+func (h *Hub) Replay(f Filter, afterID int64, limit int) ([]Event, error) {
+	query := consultaEventos + ` WHERE seq > $1
+		AND ($2::uuid IS NULL OR storeId = $2)
+		AND ($3::uuid IS NULL OR productId = $3)
+		AND ($4::uuid IS NULL OR tenantId = $4)
+		ORDER BY seq
+		LIMIT $5`
+	rows, err := h.db.Query(query, afterID, f.StoreID, f.ProductID, f.TenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return escanearEventos(rows)
+}
+
+const consultaEventos = `
+	SELECT seq, type, productId, storeId, payload, created_at, tenantId
+	FROM prueba.eventosinventario`
+
+func escanearEventos(rows *sql.Rows) ([]Event, error) {
+	defer rows.Close()
+	var eventos []Event
+	for rows.Next() {
+		var e Event
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.ProductID, &e.StoreID, &payload, &e.CreatedAt, &e.TenantID); err != nil {
+			return nil, err
+		}
+		e.Data = payload
+		eventos = append(eventos, e)
+	}
+	return eventos, rows.Err()
+}
+
+// Reset aviso de que se perdieron demasiados eventos; el cliente continúa
+// después de desde
+func Reset(desde int64) Event {
+	return Event{ID: desde, Type: TypeReset, Data: json.RawMessage(`{}`), CreatedAt: time.Now()}
+}
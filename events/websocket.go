@@ -0,0 +1,228 @@
+package events
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Implementación mínima de WebSocket (RFC 6455) para el lado servidor: sólo
+// mensajes de texto sin fragmentar, ping/pong y cierre.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Códigos de operación de los frames
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// Tamaño máximo aceptado para los mensajes del cliente
+const maxClientPayload = 4096
+
+// ErrClosed indica que la conexión WebSocket ya se cerró
+var ErrClosed = errors.New("conexión websocket cerrada")
+
+// Conn conexión WebSocket ya establecida
+type Conn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	mu sync.Mutex // serializa las escrituras
+}
+
+// AcceptKey calcula Sec-WebSocket-Accept a partir de Sec-WebSocket-Key
+func AcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func encabezadoContiene(r *http.Request, nombre, valor string) bool {
+	for _, v := range r.Header.Values(nombre) {
+		for _, parte := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(parte), valor) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade completa el handshake de WebSocket y toma el control de la conexión
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !encabezadoContiene(r, "Connection", "upgrade") ||
+		!encabezadoContiene(r, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "Se requiere una conexión WebSocket", http.StatusBadRequest)
+		return nil, errors.New("handshake websocket inválido")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Versión de WebSocket no soportada", http.StatusUpgradeRequired)
+		return nil, errors.New("versión websocket no soportada")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "El servidor no soporta WebSocket", http.StatusInternalServerError)
+		return nil, errors.New("la respuesta no admite hijack")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// Quitar los plazos que el servidor HTTP haya fijado a la conexión
+	conn.SetDeadline(time.Time{})
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, rw: rw}, nil
+}
+
+// WriteText envía un mensaje de texto
+func (c *Conn) WriteText(p []byte) error {
+	return c.escribirFrame(opText, p)
+}
+
+// Ping envía un ping; el cliente responde con pong
+func (c *Conn) Ping() error {
+	return c.escribirFrame(opPing, nil)
+}
+
+// Close envía el frame de cierre y cierra la conexión
+func (c *Conn) Close() error {
+	c.escribirFrame(opClose, []byte{0x03, 0xE8}) // 1000: cierre normal
+	return c.conn.Close()
+}
+
+func (c *Conn) escribirFrame(op byte, p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := writeFrame(c.rw.Writer, op, p, nil); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// ReadMessage lee el siguiente mensaje de texto o binario del cliente,
+// respondiendo a los ping y devolviendo ErrClosed cuando el cliente cierra
+func (c *Conn) ReadMessage() ([]byte, error) {
+	for {
+		op, p, err := readFrame(c.rw.Reader, maxClientPayload)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case opClose:
+			return nil, ErrClosed
+		case opPing:
+			if err := c.escribirFrame(opPong, p); err != nil {
+				return nil, err
+			}
+		case opPong:
+		default:
+			return p, nil
+		}
+	}
+}
+
+// writeFrame escribe un frame final; el servidor no enmascara (mask nil),
+// el cliente debe hacerlo
+func writeFrame(w io.Writer, op byte, p []byte, mask []byte) error {
+	encabezado := []byte{0x80 | op}
+	bitMascara := byte(0)
+	if mask != nil {
+		bitMascara = 0x80
+	}
+	switch n := len(p); {
+	case n < 126:
+		encabezado = append(encabezado, bitMascara|byte(n))
+	case n <= 0xFFFF:
+		encabezado = append(encabezado, bitMascara|126, 0, 0)
+		binary.BigEndian.PutUint16(encabezado[2:], uint16(n))
+	default:
+		encabezado = append(encabezado, bitMascara|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(encabezado[2:], uint64(n))
+	}
+	if mask != nil {
+		encabezado = append(encabezado, mask...)
+		enmascarado := make([]byte, len(p))
+		for i := range p {
+			enmascarado[i] = p[i] ^ mask[i%4]
+		}
+		p = enmascarado
+	}
+	if _, err := w.Write(encabezado); err != nil {
+		return err
+	}
+	_, err := w.Write(p)
+	return err
+}
+
+// readFrame lee un frame completo y le quita la máscara
+func readFrame(r io.Reader, limite int) (byte, []byte, error) {
+	var encabezado [2]byte
+	if _, err := io.ReadFull(r, encabezado[:]); err != nil {
+		return 0, nil, err
+	}
+	if encabezado[0]&0x80 == 0 {
+		return 0, nil, errors.New("mensajes fragmentados no soportados")
+	}
+	op := encabezado[0] & 0x0F
+	enmascarado := encabezado[1]&0x80 != 0
+
+	n := uint64(encabezado[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > uint64(limite) {
+		return 0, nil, errors.New("mensaje websocket demasiado grande")
+	}
+
+	var mask [4]byte
+	if enmascarado {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	p := make([]byte, n)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return 0, nil, err
+	}
+	if enmascarado {
+		for i := range p {
+			p[i] ^= mask[i%4]
+		}
+	}
+	return op, p, nil
+}
@@ -0,0 +1,259 @@
+// Package forecast calcula pronósticos de demanda diaria por producto y
+// tienda a partir de las salidas registradas en prueba.movimientos (tipos con
+// efecto OUT marcados como venta).
+package forecast
+
+import (
+	"errors"
+	"fmt"
+	"go-project/utils"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Modelos disponibles
+const (
+	ModelMovingAverage = "moving_average"
+	ModelExpSmoothing  = "exp_smoothing"
+)
+
+// ErrInsufficientHistory la serie es demasiado corta para el modelo
+var ErrInsufficientHistory = errors.New("historia insuficiente para el modelo")
+
+// Series demanda diaria a partir de Start (un valor por día)
+type Series struct {
+	Start  time.Time
+	Values []float64
+}
+
+// Date fecha del i-ésimo valor de la serie (i puede exceder la serie)
+func (s Series) Date(i int) time.Time {
+	return s.Start.AddDate(0, 0, i)
+}
+
+// Model modelo de pronóstico: a partir de la historia devuelve los valores
+// de los siguientes horizon días y la desviación estándar de sus errores de
+// un paso dentro de la muestra
+type Model interface {
+	Name() string
+	Forecast(history []float64, horizon int) ([]float64, float64, error)
+}
+
+// MovingAverage pronostica el promedio de los últimos Window días
+type MovingAverage struct {
+	Window int
+}
+
+func (m MovingAverage) Name() string { return ModelMovingAverage }
+
+func (m MovingAverage) Forecast(history []float64, horizon int) ([]float64, float64, error) {
+	if m.Window < 1 || len(history) < m.Window {
+		return nil, 0, ErrInsufficientHistory
+	}
+	var residuos []float64
+	for t := m.Window; t < len(history); t++ {
+		residuos = append(residuos, history[t]-promedio(history[t-m.Window:t]))
+	}
+	valor := promedio(history[len(history)-m.Window:])
+	pronostico := make([]float64, horizon)
+	for i := range pronostico {
+		pronostico[i] = valor
+	}
+	return pronostico, desviacion(residuos), nil
+}
+
+// SeasonalSmoothing suavizado exponencial del nivel con estacionalidad
+// aditiva de Period días (7 para la semana)
+type SeasonalSmoothing struct {
+	// Alpha peso de la observación más reciente en el nivel (0-1)
+	Alpha float64
+	// Gamma peso de la observación más reciente en el índice estacional (0-1)
+	Gamma  float64
+	Period int
+}
+
+func (m SeasonalSmoothing) Name() string { return ModelExpSmoothing }
+
+func (m SeasonalSmoothing) Forecast(history []float64, horizon int) ([]float64, float64, error) {
+	p := m.Period
+	if p < 1 || m.Alpha <= 0 || m.Alpha > 1 || m.Gamma < 0 || m.Gamma > 1 {
+		return nil, 0, fmt.Errorf("parámetros inválidos: alpha=%v gamma=%v period=%d", m.Alpha, m.Gamma, p)
+	}
+	if len(history) < 2*p {
+		return nil, 0, ErrInsufficientHistory
+	}
+
+	// Nivel inicial: promedio del primer periodo; estacionalidad inicial:
+	// desviación de cada día respecto de ese promedio
+	nivel := promedio(history[:p])
+	estacional := make([]float64, p)
+	for i := 0; i < p; i++ {
+		estacional[i] = history[i] - nivel
+	}
+
+	var residuos []float64
+	for t := p; t < len(history); t++ {
+		s := estacional[t%p]
+		residuos = append(residuos, history[t]-(nivel+s))
+		nivel = m.Alpha*(history[t]-s) + (1-m.Alpha)*nivel
+		estacional[t%p] = m.Gamma*(history[t]-nivel) + (1-m.Gamma)*s
+	}
+
+	pronostico := make([]float64, horizon)
+	for k := range pronostico {
+		pronostico[k] = math.Max(0, nivel+estacional[(len(history)+k)%p])
+	}
+	return pronostico, desviacion(residuos), nil
+}
+
+// Point valor pronosticado de un día con su banda de confianza
+type Point struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+	Lower float64   `json:"lower"`
+	Upper float64   `json:"upper"`
+}
+
+// ZScore valor z de una confianza bilateral (0.95 -> 1.96)
+func ZScore(confidence float64) float64 {
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+// Forecast pronostica los horizon días siguientes a la serie. La banda se
+// abre con la raíz del número de pasos y no baja de cero.
+func Forecast(m Model, s Series, horizon int, confidence float64) ([]Point, error) {
+	valores, sigma, err := m.Forecast(s.Values, horizon)
+	if err != nil {
+		return nil, err
+	}
+	z := ZScore(confidence)
+	puntos := make([]Point, horizon)
+	for k, v := range valores {
+		margen := z * sigma * math.Sqrt(float64(k+1))
+		puntos[k] = Point{
+			Date:  s.Date(len(s.Values) + k),
+			Value: redondear(v),
+			Lower: redondear(math.Max(0, v-margen)),
+			Upper: redondear(v + margen),
+		}
+	}
+	return puntos, nil
+}
+
+// Accuracy errores de un backtest
+type Accuracy struct {
+	// Pronósticos evaluados
+	Points int `json:"points"`
+	// Error absoluto medio
+	MAE float64 `json:"mae"`
+	// Raíz del error cuadrático medio
+	RMSE float64 `json:"rmse"`
+	// Error porcentual absoluto medio, sólo sobre días con demanda
+	MAPE *float64 `json:"mape,omitempty"`
+	// Sesgo medio (pronóstico - real); positivo indica sobreestimación
+	Bias float64 `json:"bias"`
+}
+
+// Backtest evalúa el modelo con origen móvil sobre los últimos holdout días:
+// para cada día de origen ajusta con la historia previa y compara los
+// horizon días pronosticados que caen dentro de la serie con lo real.
+func Backtest(m Model, s Series, holdout, horizon int) (Accuracy, error) {
+	n := len(s.Values)
+	if holdout < 1 || holdout >= n {
+		return Accuracy{}, ErrInsufficientHistory
+	}
+	var acc Accuracy
+	var absoluto, cuadrado, sesgo, porcentual float64
+	conDemanda := 0
+	for origen := n - holdout; origen < n; origen++ {
+		valores, _, err := m.Forecast(s.Values[:origen], horizon)
+		if err != nil {
+			return Accuracy{}, err
+		}
+		for k, v := range valores {
+			if origen+k >= n {
+				break
+			}
+			real := s.Values[origen+k]
+			e := v - real
+			absoluto += math.Abs(e)
+			cuadrado += e * e
+			sesgo += e
+			if real != 0 {
+				porcentual += math.Abs(e) / real
+				conDemanda++
+			}
+			acc.Points++
+		}
+	}
+	total := float64(acc.Points)
+	acc.MAE = redondear(absoluto / total)
+	acc.RMSE = redondear(math.Sqrt(cuadrado / total))
+	acc.Bias = redondear(sesgo / total)
+	if conDemanda > 0 {
+		mape := redondear(100 * porcentual / float64(conDemanda))
+		acc.MAPE = &mape
+	}
+	return acc, nil
+}
+
+// LoadDemand serie de salidas diarias del producto en los últimos days días
+// hasta end inclusive; sin tienda suma todas las tiendas
+func LoadDemand(q utils.Querier, productID uuid.UUID, storeID *uuid.UUID, days int, end time.Time) (Series, error) {
+	fin := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	inicio := fin.AddDate(0, 0, -(days - 1))
+	rows, err := q.Query(`
+        SELECT d::date, COALESCE(SUM(m.quantity), 0)
+        FROM generate_series($3::date, $4::date, INTERVAL '1 day') d
+            LEFT JOIN (prueba.movimientos m
+                JOIN catalogos.tiposmovimiento t ON t.tenantId = m.tenantId AND t.code = m.type
+                    AND t.effect = 'OUT' AND t.isSale) ON m.productId = $1
+                AND ($2::uuid IS NULL OR m.sourceStoreId = $2)
+                AND m.timestamp >= d AND m.timestamp < d + INTERVAL '1 day'
+        GROUP BY d
+        ORDER BY d
+    `, productID, storeID, inicio, fin)
+	if err != nil {
+		return Series{}, err
+	}
+	defer rows.Close()
+	s := Series{Start: inicio}
+	for rows.Next() {
+		var dia time.Time
+		var cantidad float64
+		if err := rows.Scan(&dia, &cantidad); err != nil {
+			return Series{}, err
+		}
+		s.Values = append(s.Values, cantidad)
+	}
+	return s, rows.Err()
+}
+
+func promedio(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	suma := 0.0
+	for _, x := range v {
+		suma += x
+	}
+	return suma / float64(len(v))
+}
+
+// desviacion raíz del error cuadrático medio de los residuos
+func desviacion(residuos []float64) float64 {
+	if len(residuos) == 0 {
+		return 0
+	}
+	suma := 0.0
+	for _, r := range residuos {
+		suma += r * r
+	}
+	return math.Sqrt(suma / float64(len(residuos)))
+}
+
+func redondear(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,82 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// semanal serie con patrón semanal fijo (más venta el fin de semana)
+func semanal(semanas int) Series {
+	patron := []float64{10, 8, 8, 9, 12, 20, 18}
+	s := Series{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	for i := 0; i < semanas*7; i++ {
+		s.Values = append(s.Values, patron[i%7])
+	}
+	return s
+}
+
+func TestMovingAverage(t *testing.T) {
+	s := Series{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Values: []float64{1, 2, 3, 4, 5, 6}}
+	puntos, err := Forecast(MovingAverage{Window: 3}, s, 2, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if puntos[0].Value != 5 || puntos[1].Value != 5 {
+		t.Errorf("pronóstico = %+v", puntos)
+	}
+	if !puntos[0].Date.Equal(time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("fecha = %v", puntos[0].Date)
+	}
+	// Cada residuo es 2 y la banda crece con el horizonte
+	if puntos[0].Lower >= puntos[0].Value || puntos[1].Upper-puntos[1].Value <= puntos[0].Upper-puntos[0].Value {
+		t.Errorf("bandas = %+v", puntos)
+	}
+
+	if _, err := Forecast(MovingAverage{Window: 10}, s, 2, 0.95); err != ErrInsufficientHistory {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSeasonalSmoothing(t *testing.T) {
+	s := semanal(6)
+	puntos, err := Forecast(SeasonalSmoothing{Alpha: 0.3, Gamma: 0.2, Period: 7}, s, 7, 0.95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Una serie perfectamente estacional se reproduce sin error
+	for k, p := range puntos {
+		if want := s.Values[k]; math.Abs(p.Value-want) > 0.01 || p.Upper != p.Value {
+			t.Errorf("día %d: %+v, se esperaba %v", k, p, want)
+		}
+	}
+
+	if _, err := Forecast(SeasonalSmoothing{Alpha: 0.3, Gamma: 0.2, Period: 7}, semanal(1), 7, 0.95); err != ErrInsufficientHistory {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	s := semanal(8)
+	estacional, err := Backtest(SeasonalSmoothing{Alpha: 0.3, Gamma: 0.2, Period: 7}, s, 14, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	promedio, err := Backtest(MovingAverage{Window: 7}, s, 14, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if estacional.MAE != 0 || promedio.MAE <= estacional.MAE || promedio.MAPE == nil {
+		t.Errorf("estacional = %+v, promedio = %+v", estacional, promedio)
+	}
+	// 14 orígenes con 7, 7, ..., 2, 1 días dentro de la serie
+	if estacional.Points != 7*7+28 {
+		t.Errorf("puntos = %d", estacional.Points)
+	}
+}
+
+func TestZScore(t *testing.T) {
+	if z := ZScore(0.95); math.Abs(z-1.96) > 0.01 {
+		t.Errorf("z(0.95) = %v", z)
+	}
+}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// DestinatarioAlertas correo que recibe las alertas de stock
+// @Description Destinatario de alertas de stock
+type DestinatarioAlertas struct {
+	ID uuid.UUID `json:"id"`
+	// Tienda vigilada; si se omite recibe las alertas de todas
+	StoreID *uuid.UUID `json:"store_id,omitempty"`
+	Email   string     `json:"email" example:"gerente@tienda.mx"`
+	// IMMEDIATE (un correo por alerta) o DIGEST (resumen diario)
+	Mode      string    `json:"mode" example:"IMMEDIATE"`
+	Activo    bool      `json:"activo"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CrearDestinatarioAlertas modelo para registrar un destinatario
+type CrearDestinatarioAlertas struct {
+	StoreID *uuid.UUID `json:"store_id,omitempty"`
+	Email   string     `json:"email" binding:"required" example:"gerente@tienda.mx"`
+	Mode    string     `json:"mode,omitempty" example:"DIGEST"`
+}
+
+// AlertaHistorial alerta registrada al entrar un producto en STOCK_BAJO o SIN_STOCK
+type AlertaHistorial struct {
+	ID             uuid.UUID  `json:"id"`
+	ProductID      uuid.UUID  `json:"product_id"`
+	StoreID        uuid.UUID  `json:"store_id"`
+	ProductName    string     `json:"product_name"`
+	StoreName      string     `json:"store_name"`
+	AlertType      string     `json:"alert_type" example:"SIN_STOCK"`
+	Quantity       int        `json:"quantity"`
+	MinStock       int        `json:"min_stock"`
+	Status         string     `json:"status" example:"OPEN"`
+	SnoozedUntil   *time.Time `json:"snoozed_until,omitempty"`
+	AcknowledgedBy *string    `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	NotifiedAt     *time.Time `json:"notified_at,omitempty"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// AtenderAlerta datos para atender una alerta
+type AtenderAlerta struct {
+	AcknowledgedBy string `json:"acknowledged_by" binding:"required" example:"jlopez"`
+}
+
+// PosponerAlerta datos para posponer una alerta
+type PosponerAlerta struct {
+	// Minutos a posponer (alternativa a until)
+	Minutes int `json:"minutes,omitempty" example:"240"`
+	// Fecha hasta la que se pospone
+	Until *time.Time `json:"until,omitempty"`
+}
+
+type AlertHandler struct {
+	db *sql.DB
+}
+
+func NewAlertHandler(db *sql.DB) *AlertHandler {
+	return &AlertHandler{db: db}
+}
+
+// ListarDestinatariosAlertas godoc
+// @Summary      Listar destinatarios de alertas
+// @Description  Obtiene los correos que reciben alertas de stock
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   DestinatarioAlertas
+// @Router       /ListarDestinatariosAlertas [get]
+func (h *AlertHandler) ListarDestinatariosAlertas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, storeId, email, mode, activo, created_at, updated_at
+        FROM prueba.alertasdestinatarios
+        WHERE activo = true
+        ORDER BY email
+    `)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var lista []DestinatarioAlertas
+	for rows.Next() {
+		var d DestinatarioAlertas
+		if err := rows.Scan(&d.ID, &d.StoreID, &d.Email, &d.Mode, &d.Activo, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lista = append(lista, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lista)
+}
+
+// CrearDestinatarioAlertas godoc
+// @Summary      Crear destinatario de alertas
+// @Description  Registra un correo para recibir las alertas de una tienda (o de todas) de inmediato o en resumen diario
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Param        destinatario body CrearDestinatarioAlertas true "Datos del destinatario"
+// @Success      201  {object}  DestinatarioAlertas
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearDestinatarioAlertas [post]
+func (h *AlertHandler) CrearDestinatarioAlertas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearDestinatarioAlertas
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if _, err := mail.ParseAddress(c.Email); err != nil {
+		http.Error(w, "Correo inválido", http.StatusBadRequest)
+		return
+	}
+	if c.Mode == "" {
+		c.Mode = "IMMEDIATE"
+	}
+	if c.Mode != "IMMEDIATE" && c.Mode != "DIGEST" {
+		http.Error(w, "Modalidad inválida, use IMMEDIATE o DIGEST", http.StatusBadRequest)
+		return
+	}
+
+	var d DestinatarioAlertas
+	err := h.db.QueryRow(`
+        INSERT INTO prueba.alertasdestinatarios (id, storeId, email, mode, activo)
+        VALUES ($1, $2, $3, $4, true)
+        RETURNING id, storeId, email, mode, activo, created_at, updated_at
+    `, uuid.New(), c.StoreID, c.Email, c.Mode).Scan(
+		&d.ID, &d.StoreID, &d.Email, &d.Mode, &d.Activo, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(d)
+}
+
+// EliminarDestinatarioAlertas godoc
+// @Summary      Eliminar destinatario de alertas
+// @Description  Deja de enviar alertas al destinatario
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Param        id query string true "ID del destinatario"
+// @Success      204  "No Content"
+// @Failure      404  {object}  map[string]string
+// @Router       /EliminarDestinatarioAlertas [delete]
+func (h *AlertHandler) EliminarDestinatarioAlertas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+        UPDATE prueba.alertasdestinatarios SET activo = false, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND activo = true
+    `, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		http.Error(w, "Destinatario no encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAlertHistory godoc
+// @Summary      Historial de alertas de stock
+// @Description  Obtiene las alertas registradas, de la más reciente a la más antigua
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        product_id query string false "Filtrar por producto"
+// @Param        status query string false "OPEN, ACKNOWLEDGED o RESOLVED"
+// @Param        limit query int false "Máximo de alertas (predeterminado 100)"
+// @Success      200  {array}   AlertaHistorial
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/alerts/history [get]
+func (h *AlertHandler) GetAlertHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var storeID, productID *uuid.UUID
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+			return
+		}
+		productID = &id
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "OPEN" && status != "ACKNOWLEDGED" && status != "RESOLVED" {
+		http.Error(w, "Estado inválido", http.StatusBadRequest)
+		return
+	}
+	limite := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Límite inválido", http.StatusBadRequest)
+			return
+		}
+		limite = n
+	}
+
+	rows, err := h.db.Query(`
+        SELECT a.id, a.productId, a.storeId, p.name, t.name, a.alertType, a.quantity, a.minStock,
+               a.status, a.snoozedUntil, a.acknowledgedBy, a.acknowledgedAt, a.notifiedAt,
+               a.resolvedAt, a.created_at
+        FROM prueba.alertas a
+            JOIN catalogos.productos p ON p.id = a.productId
+            JOIN catalogos.tiendas t ON t.id = a.storeId
+        WHERE ($1::uuid IS NULL OR a.storeId = $1)
+          AND ($2::uuid IS NULL OR a.productId = $2)
+          AND ($3 = '' OR a.status = $3)
+        ORDER BY a.created_at DESC
+        LIMIT $4
+    `, storeID, productID, status, limite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var historial []AlertaHistorial
+	for rows.Next() {
+		var a AlertaHistorial
+		err := rows.Scan(&a.ID, &a.ProductID, &a.StoreID, &a.ProductName, &a.StoreName,
+			&a.AlertType, &a.Quantity, &a.MinStock, &a.Status, &a.SnoozedUntil,
+			&a.AcknowledgedBy, &a.AcknowledgedAt, &a.NotifiedAt, &a.ResolvedAt, &a.CreatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		historial = append(historial, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(historial)
+}
+
+// AcknowledgeAlert godoc
+// @Summary      Atender alerta
+// @Description  Marca una alerta abierta como atendida; deja de incluirse en avisos y resúmenes
+// @Description  hasta que se resuelva y el producto vuelva a entrar en alerta
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la alerta"
+// @Param        datos body AtenderAlerta true "Quién atiende"
+// @Success      200  {object}  AlertaHistorial
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /inventory/alerts/{id}/acknowledge [post]
+func (h *AlertHandler) AcknowledgeAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+	var datos AtenderAlerta
+	if err := json.NewDecoder(r.Body).Decode(&datos); err != nil || datos.AcknowledgedBy == "" {
+		http.Error(w, "Se requiere acknowledged_by", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+        UPDATE prueba.alertas
+        SET status = 'ACKNOWLEDGED', acknowledgedBy = $2, acknowledgedAt = CURRENT_TIMESTAMP
+        WHERE id = $1 AND status = 'OPEN'
+    `, id, datos.AcknowledgedBy)
+	h.responderAlerta(w, id, result, err)
+}
+
+// SnoozeAlert godoc
+// @Summary      Posponer alerta
+// @Description  Suspende los avisos de una alerta abierta; al vencer, si sigue vigente, se vuelve a avisar
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la alerta"
+// @Param        datos body PosponerAlerta true "Minutos o fecha límite"
+// @Success      200  {object}  AlertaHistorial
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /inventory/alerts/{id}/snooze [post]
+func (h *AlertHandler) SnoozeAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+	var datos PosponerAlerta
+	if err := json.NewDecoder(r.Body).Decode(&datos); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+
+	hasta := time.Now().Add(time.Duration(datos.Minutes) * time.Minute)
+	if datos.Until != nil {
+		hasta = *datos.Until
+	}
+	if (datos.Until == nil && datos.Minutes <= 0) || !hasta.After(time.Now()) {
+		http.Error(w, "Indique minutos positivos o una fecha futura", http.StatusBadRequest)
+		return
+	}
+
+	// Reiniciar el aviso para que se repita al vencer la suspensión
+	result, err := h.db.Exec(`
+        UPDATE prueba.alertas SET snoozedUntil = $2, notifiedAt = NULL
+        WHERE id = $1 AND status = 'OPEN'
+    `, id, hasta)
+	h.responderAlerta(w, id, result, err)
+}
+
+// responderAlerta responde con la alerta actualizada o 404 si no estaba abierta
+func (h *AlertHandler) responderAlerta(w http.ResponseWriter, id uuid.UUID, result sql.Result, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Alerta abierta no encontrada", http.StatusNotFound)
+		return
+	}
+
+	var a AlertaHistorial
+	err = h.db.QueryRow(`
+        SELECT a.id, a.productId, a.storeId, p.name, t.name, a.alertType, a.quantity, a.minStock,
+               a.status, a.snoozedUntil, a.acknowledgedBy, a.acknowledgedAt, a.notifiedAt,
+               a.resolvedAt, a.created_at
+        FROM prueba.alertas a
+            JOIN catalogos.productos p ON p.id = a.productId
+            JOIN catalogos.tiendas t ON t.id = a.storeId
+        WHERE a.id = $1
+    `, id).Scan(&a.ID, &a.ProductID, &a.StoreID, &a.ProductName, &a.StoreName,
+		&a.AlertType, &a.Quantity, &a.MinStock, &a.Status, &a.SnoozedUntil,
+		&a.AcknowledgedBy, &a.AcknowledgedAt, &a.NotifiedAt, &a.ResolvedAt, &a.CreatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
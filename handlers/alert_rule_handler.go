@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-project/alerts"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReglaAlerta regla configurable de alertas de inventario
+// @Description Regla de alerta por producto, categoría o tienda
+type ReglaAlerta struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name" example:"Cobertura lácteos"`
+	// DAYS_OF_COVER, OVERSTOCK, DEAD_STOCK u OUT_SPIKE
+	Type string `json:"type" example:"DAYS_OF_COVER"`
+	// Días de cobertura mínimos, existencia máxima, días sin movimiento o
+	// factor sobre el promedio diario de salidas, según el tipo
+	Threshold float64 `json:"threshold" example:"7"`
+	// Días de historia para promediar las salidas
+	LookbackDays int    `json:"lookback_days" example:"30"`
+	Severity     string `json:"severity" example:"WARNING"`
+	// Alcance; los campos omitidos aplican a todos
+	ProductID *uuid.UUID `json:"product_id,omitempty"`
+	Category  *string    `json:"category,omitempty" example:"Lácteos"`
+	StoreID   *uuid.UUID `json:"store_id,omitempty"`
+	Activo    bool       `json:"activo"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CrearReglaAlerta modelo para registrar una regla de alerta
+type CrearReglaAlerta struct {
+	Name         string     `json:"name" binding:"required" example:"Stock sin movimiento"`
+	Type         string     `json:"type" binding:"required" example:"DEAD_STOCK"`
+	Threshold    float64    `json:"threshold" binding:"required,gt=0" example:"90"`
+	LookbackDays int        `json:"lookback_days,omitempty" example:"30"`
+	Severity     string     `json:"severity,omitempty" example:"INFO"`
+	ProductID    *uuid.UUID `json:"product_id,omitempty"`
+	Category     *string    `json:"category,omitempty"`
+	StoreID      *uuid.UUID `json:"store_id,omitempty"`
+}
+
+type AlertRuleHandler struct {
+	db     *sql.DB
+	engine *alerts.RuleEngine
+}
+
+func NewAlertRuleHandler(db *sql.DB) *AlertRuleHandler {
+	return &AlertRuleHandler{db: db, engine: alerts.NewRuleEngine(db)}
+}
+
+const columnasReglaAlerta = `id, name, type, threshold, lookbackDays, severity,
+        productId, category, storeId, activo, created_at, updated_at`
+
+func escanearReglaAlerta(s interface{ Scan(...interface{}) error }, g *ReglaAlerta) error {
+	return s.Scan(&g.ID, &g.Name, &g.Type, &g.Threshold, &g.LookbackDays, &g.Severity,
+		&g.ProductID, &g.Category, &g.StoreID, &g.Activo, &g.CreatedAt, &g.UpdatedAt)
+}
+
+// ListarReglasAlertas godoc
+// @Summary      Listar reglas de alertas
+// @Description  Obtiene las reglas de alertas activas
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   ReglaAlerta
+// @Router       /ListarReglasAlertas [get]
+func (h *AlertRuleHandler) ListarReglasAlertas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := h.db.Query(`SELECT ` + columnasReglaAlerta + `
+        FROM prueba.reglasalertas
+        WHERE activo = true
+        ORDER BY name
+    `)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var lista []ReglaAlerta
+	for rows.Next() {
+		var g ReglaAlerta
+		if err := escanearReglaAlerta(rows, &g); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lista = append(lista, g)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lista)
+}
+
+// CrearReglaAlerta godoc
+// @Summary      Crear regla de alerta
+// @Description  Registra una regla de días de cobertura, sobrestock, stock sin movimiento o pico de salidas
+// @Description  para un producto, una categoría, una tienda o cualquier combinación
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Param        regla body CrearReglaAlerta true "Datos de la regla"
+// @Success      201  {object}  ReglaAlerta
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearReglaAlerta [post]
+func (h *AlertRuleHandler) CrearReglaAlerta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearReglaAlerta
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if c.Name == "" {
+		http.Error(w, "El nombre es requerido", http.StatusBadRequest)
+		return
+	}
+	switch c.Type {
+	case alerts.RuleDaysOfCover, alerts.RuleOverstock, alerts.RuleDeadStock, alerts.RuleOutSpike:
+	default:
+		http.Error(w, "Tipo inválido, use DAYS_OF_COVER, OVERSTOCK, DEAD_STOCK u OUT_SPIKE", http.StatusBadRequest)
+		return
+	}
+	if c.Threshold <= 0 {
+		http.Error(w, "El umbral debe ser mayor a cero", http.StatusBadRequest)
+		return
+	}
+	if c.LookbackDays == 0 {
+		c.LookbackDays = 30
+	}
+	if c.LookbackDays < 2 {
+		http.Error(w, "lookback_days debe ser al menos 2", http.StatusBadRequest)
+		return
+	}
+	if c.Severity == "" {
+		c.Severity = alerts.SeverityWarning
+	}
+	if alerts.SeverityRank(c.Severity) == 0 {
+		http.Error(w, "Severidad inválida, use INFO, WARNING o CRITICAL", http.StatusBadRequest)
+		return
+	}
+
+	var g ReglaAlerta
+	err := escanearReglaAlerta(h.db.QueryRow(`
+        INSERT INTO prueba.reglasalertas (id, name, type, threshold, lookbackDays, severity,
+            productId, category, storeId, activo)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, true)
+        RETURNING `+columnasReglaAlerta,
+		uuid.New(), c.Name, c.Type, c.Threshold, c.LookbackDays, c.Severity,
+		c.ProductID, c.Category, c.StoreID), &g)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(g)
+}
+
+// EliminarReglaAlerta godoc
+// @Summary      Eliminar regla de alerta
+// @Description  Desactiva la regla y retira sus alertas activas
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Param        id query string true "ID de la regla"
+// @Success      204  "No Content"
+// @Failure      404  {object}  map[string]string
+// @Router       /EliminarReglaAlerta [delete]
+func (h *AlertRuleHandler) EliminarReglaAlerta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        UPDATE prueba.reglasalertas SET activo = false, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND activo = true
+    `, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Regla no encontrada", http.StatusNotFound)
+		return
+	}
+	_, err = tx.Exec(`
+        UPDATE prueba.reglasalertasresultados SET status = 'CLEARED', cleared_at = CURRENT_TIMESTAMP
+        WHERE ruleId = $1 AND status = 'ACTIVE'
+    `, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EvaluateAlertRules godoc
+// @Summary      Evaluar reglas de alertas
+// @Description  Evalúa de inmediato todas las reglas activas sin esperar al proceso programado
+// @Tags         alertas
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  map[string]int
+// @Router       /inventory/alerts/rules/evaluate [post]
+func (h *AlertRuleHandler) EvaluateAlertRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	activas, err := h.engine.Evaluate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"active_alerts": activas})
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-project/analytics"
+	"go-project/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReporteAnalitica indicadores de inventario de un periodo
+type ReporteAnalitica struct {
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Days        float64            `json:"days"`
+	GroupBy     string             `json:"group_by" example:"product"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Lines       []analytics.Metric `json:"lines"`
+}
+
+type AnalyticsHandler struct {
+	db    *sql.DB
+	cache *utils.Cache
+}
+
+func NewAnalyticsHandler(db *sql.DB) *AnalyticsHandler {
+	return &AnalyticsHandler{db: db, cache: utils.NewCache(5 * time.Minute)}
+}
+
+// GetInventoryAnalytics godoc
+// @Summary      Analítica de inventario
+// @Description  Calcula por producto, categoría o tienda la rotación (vendido / inventario promedio), los días
+// @Description  de cobertura, el sell-through (vendido / existencia inicial + recibido) y la clasificación ABC
+// @Description  por participación en las ventas del periodo. El resultado se conserva 5 minutos, se responde
+// @Description  con ETag y puede exportarse en CSV.
+// @Tags         reportes
+// @Accept       json
+// @Produce      json
+// @Produce      text/csv
+// @Param        from query string false "Inicio del periodo (por defecto hace 30 días)"
+// @Param        to query string false "Fin del periodo, exclusivo (por defecto mañana)"
+// @Param        group_by query string false "Agrupación (product, category, store)" default(product)
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        category query string false "Filtrar por categoría"
+// @Param        a query number false "Participación acumulada de la clase A" default(0.8)
+// @Param        b query number false "Participación acumulada de la clase B" default(0.95)
+// @Param        format query string false "json o csv" default(json)
+// @Success      200  {object}  ReporteAnalitica
+// @Success      304  "Not Modified"
+// @Failure      400  {object}  map[string]string
+// @Router       /reports/analytics [get]
+func (h *AnalyticsHandler) GetInventoryAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	hoy := time.Now().Truncate(24 * time.Hour)
+	hasta := hoy.AddDate(0, 0, 1)
+	desde := hasta.AddDate(0, 0, -30)
+	var err error
+	if v := q.Get("from"); v != "" {
+		if desde, err = parseFecha(v); err != nil {
+			http.Error(w, "Fecha de inicio inválida", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if hasta, err = parseFecha(v); err != nil {
+			http.Error(w, "Fecha de fin inválida", http.StatusBadRequest)
+			return
+		}
+	}
+	if !desde.Before(hasta) {
+		http.Error(w, "La fecha de inicio debe ser anterior a la de fin", http.StatusBadRequest)
+		return
+	}
+
+	agrupacion := q.Get("group_by")
+	if agrupacion == "" {
+		agrupacion = analytics.GroupProduct
+	}
+	if agrupacion != analytics.GroupProduct && agrupacion != analytics.GroupCategory && agrupacion != analytics.GroupStore {
+		http.Error(w, "Agrupación inválida", http.StatusBadRequest)
+		return
+	}
+
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	limites := analytics.DefaultThresholds
+	if v := q.Get("a"); v != "" {
+		if limites.A, err = strconv.ParseFloat(v, 64); err != nil {
+			http.Error(w, "Límite de la clase A inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("b"); v != "" {
+		if limites.B, err = strconv.ParseFloat(v, 64); err != nil {
+			http.Error(w, "Límite de la clase B inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	if limites.A <= 0 || limites.A >= limites.B || limites.B > 1 {
+		http.Error(w, "Los límites deben cumplir 0 < a < b <= 1", http.StatusBadRequest)
+		return
+	}
+
+	formato := q.Get("format")
+	if formato == "" {
+		formato = "json"
+	}
+	if formato != "json" && formato != "csv" {
+		http.Error(w, "Formato inválido, use json o csv", http.StatusBadRequest)
+		return
+	}
+
+	clave := fmt.Sprintf("%s|%s|%s|%v|%s|%v|%v|%s", desde.Format(time.RFC3339), hasta.Format(time.RFC3339),
+		agrupacion, storeID, q.Get("category"), limites.A, limites.B, formato)
+	cuerpo, ok := h.cache.Get(clave)
+	if !ok {
+		posiciones, err := analytics.LoadPositions(h.db, desde, hasta, storeID, q.Get("category"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		reporte := ReporteAnalitica{
+			From:        desde,
+			To:          hasta,
+			Days:        hasta.Sub(desde).Hours() / 24,
+			GroupBy:     agrupacion,
+			GeneratedAt: time.Now(),
+		}
+		reporte.Lines = analytics.Compute(posiciones, agrupacion, reporte.Days, limites)
+
+		if formato == "csv" {
+			cuerpo, err = analiticaCSV(reporte)
+		} else {
+			cuerpo, err = json.Marshal(reporte)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.cache.Set(clave, cuerpo)
+	}
+
+	suma := sha256.Sum256(cuerpo)
+	etag := `"` + hex.EncodeToString(suma[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if formato == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="analitica_%s_%s.csv"`,
+			agrupacion, hasta.Format("20060102")))
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Write(cuerpo)
+}
+
+// analiticaCSV exporta las líneas del reporte; los indicadores sin valor
+// quedan vacíos
+func analiticaCSV(reporte ReporteAnalitica) ([]byte, error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write([]string{"key", "name", "beginning_quantity", "ending_quantity", "average_inventory",
+		"received", "sold", "turnover", "days_of_cover", "sell_through", "sales_share",
+		"cumulative_share", "abc_class"})
+	opcional := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', 2, 64)
+	}
+	for _, m := range reporte.Lines {
+		cw.Write([]string{
+			m.Key, m.Name,
+			strconv.Itoa(m.Beginning), strconv.Itoa(m.Ending),
+			strconv.FormatFloat(m.AverageInventory, 'f', 2, 64),
+			strconv.Itoa(m.Received), strconv.Itoa(m.Sold),
+			opcional(m.Turnover), opcional(m.DaysOfCover), opcional(m.SellThrough),
+			strconv.FormatFloat(m.SalesShare, 'f', 2, 64),
+			strconv.FormatFloat(m.CumulativeShare, 'f', 2, 64),
+			m.ABC,
+		})
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
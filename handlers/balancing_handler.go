@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-project/balancing"
+	"go-project/stores"
+	"go-project/utils"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// AplicarBalanceo transferencias a ejecutar; si se omiten se calcula el plan
+// con los parámetros de la consulta
+type AplicarBalanceo struct {
+	Transfers []balancing.Transfer `json:"transfers"`
+}
+
+// TransferenciaAplicada transferencia del plan ejecutada
+type TransferenciaAplicada struct {
+	balancing.Transfer
+	MovementID uuid.UUID `json:"movement_id"`
+}
+
+type BalancingHandler struct {
+	db *sql.DB
+}
+
+func NewBalancingHandler(db *sql.DB) *BalancingHandler {
+	return &BalancingHandler{db: db}
+}
+
+// parametrosBalanceo filtros y opciones del planificador
+type parametrosBalanceo struct {
+	productID *uuid.UUID
+	opciones  balancing.Options
+}
+
+func leerParametrosBalanceo(r *http.Request) (parametrosBalanceo, error) {
+	q := r.URL.Query()
+	p := parametrosBalanceo{opciones: balancing.Options{KeepRatio: balancing.DefaultKeepRatio}}
+	if v := q.Get("product_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return p, errors.New("ID de producto inválido")
+		}
+		p.productID = &id
+	}
+	if v := q.Get("keep_ratio"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 1 || f > 10 {
+			return p, errors.New("keep_ratio inválido, use un valor entre 1 y 10")
+		}
+		p.opciones.KeepRatio = f
+	}
+	if v := q.Get("max_distance_km"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			return p, errors.New("max_distance_km inválido, use un valor mayor que 0")
+		}
+		p.opciones.MaxCost = f
+	}
+	return p, nil
+}
+
+// PreviewBalancing godoc
+// @Summary      Vista previa del balanceo de existencias
+// @Description  Compara la existencia de cada producto con su stock mínimo en todas las tiendas y propone
+// @Description  el menor número de transferencias desde las tiendas con excedente hacia las que están por
+// @Description  debajo del mínimo, prefiriendo las más cercanas. Las donantes conservan keep_ratio veces
+// @Description  su stock mínimo; no se proponen productos con número de serie, productos en conteo físico
+// @Description  ni mercancía ya ubicada. No modifica el inventario.
+// @Tags         inventario
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string false "Limitar a un producto"
+// @Param        keep_ratio query number false "Múltiplo del stock mínimo que conserva la donante (predeterminado 1.5)"
+// @Param        max_distance_km query number false "Distancia máxima entre tiendas (requiere coordenadas)"
+// @Success      200  {object}  balancing.Plan
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/balancing/preview [get]
+func (h *BalancingHandler) PreviewBalancing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, err := leerParametrosBalanceo(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, err := planearBalanceo(h.db, p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// ApplyBalancing godoc
+// @Summary      Aplicar el balanceo de existencias
+// @Description  Ejecuta en una sola transacción las transferencias recibidas (normalmente las de la vista
+// @Description  previa); si el cuerpo se omite o no trae transferencias, calcula el plan con los mismos
+// @Description  parámetros de la vista previa y lo ejecuta. Si alguna transferencia falla no se aplica ninguna.
+// @Tags         inventario
+// @Accept       json
+// @Produce      json
+// @Param        plan body AplicarBalanceo false "Transferencias a ejecutar"
+// @Param        product_id query string false "Limitar a un producto (plan calculado)"
+// @Param        keep_ratio query number false "Múltiplo del stock mínimo que conserva la donante (plan calculado)"
+// @Param        max_distance_km query number false "Distancia máxima entre tiendas (plan calculado)"
+// @Success      200  {array}   TransferenciaAplicada
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/balancing/apply [post]
+func (h *BalancingHandler) ApplyBalancing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var solicitud AplicarBalanceo
+	if err := json.NewDecoder(r.Body).Decode(&solicitud); err != nil && err != io.EOF {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	for _, t := range solicitud.Transfers {
+		if t.ProductID == uuid.Nil || t.SourceStoreID == uuid.Nil || t.TargetStoreID == uuid.Nil ||
+			t.SourceStoreID == t.TargetStoreID || t.Quantity <= 0 {
+			http.Error(w, "Transferencia inválida: indique producto, tiendas distintas y cantidad positiva", http.StatusBadRequest)
+			return
+		}
+	}
+
+	transferencias := solicitud.Transfers
+	if len(transferencias) == 0 {
+		p, err := leerParametrosBalanceo(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		plan, err := planearBalanceo(h.db, p)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		transferencias = plan.Transfers
+	}
+
+	lineas := make([]StockTransfer, len(transferencias))
+	for i, t := range transferencias {
+		lineas[i] = StockTransfer{ProductID: t.ProductID, SourceStoreID: t.SourceStoreID,
+			TargetStoreID: t.TargetStoreID, Quantity: t.Quantity}
+	}
+	aplicadas := []TransferenciaAplicada{}
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		resultados, err := transferirLote(tx, lineas)
+		for i, res := range resultados {
+			aplicadas = append(aplicadas, TransferenciaAplicada{Transfer: transferencias[i], MovementID: res.MovementID})
+		}
+		return err
+	})
+
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(aplicadas)
+}
+
+// planearBalanceo carga las existencias y las coordenadas de las tiendas
+// activas y arma el plan; el costo de cada ruta es la distancia en km
+func planearBalanceo(q utils.Querier, p parametrosBalanceo) (balancing.Plan, error) {
+	rows, err := q.Query(`
+        SELECT i.productId, i.storeId, i.quantity, i.minStock,
+               i.quantity - COALESCE((SELECT SUM(iu.quantity) FROM prueba.inventariosubicaciones iu
+                                      WHERE iu.productId = i.productId AND iu.storeId = i.storeId), 0)
+        FROM prueba.inventarios i
+        JOIN catalogos.productos p ON p.id = i.productId
+        JOIN catalogos.tiendas t ON t.id = i.storeId
+        WHERE i.activo = true AND p.activo = true AND t.activo = true
+          AND p.track_serials = false
+          AND ($1::uuid IS NULL OR i.productId = $1)
+          AND NOT EXISTS (
+              SELECT 1 FROM prueba.conteoslineas l
+              JOIN prueba.conteos c ON l.countId = c.id
+              WHERE l.productId = i.productId AND c.storeId = i.storeId
+                AND c.status = 'OPEN' AND c.activo = true)
+    `, p.productID)
+	if err != nil {
+		return balancing.Plan{}, err
+	}
+	defer rows.Close()
+
+	var posiciones []balancing.Position
+	for rows.Next() {
+		var pos balancing.Position
+		if err := rows.Scan(&pos.ProductID, &pos.StoreID, &pos.Quantity, &pos.MinStock, &pos.Movable); err != nil {
+			return balancing.Plan{}, err
+		}
+		posiciones = append(posiciones, pos)
+	}
+	if err := rows.Err(); err != nil {
+		return balancing.Plan{}, err
+	}
+
+	coordenadas, err := coordenadasTiendas(q)
+	if err != nil {
+		return balancing.Plan{}, err
+	}
+	distancia := func(origen, destino uuid.UUID) (float64, bool) {
+		a, okA := coordenadas[origen]
+		b, okB := coordenadas[destino]
+		if !okA || !okB {
+			return 0, false
+		}
+		return stores.DistanceKm(a[0], a[1], b[0], b[1]), true
+	}
+	return balancing.Build(posiciones, distancia, p.opciones), nil
+}
+
+// coordenadasTiendas latitud y longitud de las tiendas activas que las tienen
+func coordenadasTiendas(q utils.Querier) (map[uuid.UUID][2]float64, error) {
+	rows, err := q.Query(`
+        SELECT id, latitude, longitude FROM catalogos.tiendas
+        WHERE activo = true AND latitude IS NOT NULL
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coordenadas := make(map[uuid.UUID][2]float64)
+	for rows.Next() {
+		var id uuid.UUID
+		var lat, lng float64
+		if err := rows.Scan(&id, &lat, &lng); err != nil {
+			return nil, err
+		}
+		coordenadas[id] = [2]float64{lat, lng}
+	}
+	return coordenadas, rows.Err()
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/barcode"
+	"go-project/utils"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// errCodigoBarrasInvalido indica un código de barras inválido o no registrado
+var errCodigoBarrasInvalido = errors.New("Código de barras inválido")
+
+// CodigoBarras código GTIN asociado a un producto
+// @Description Código de barras de un producto
+type CodigoBarras struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+	Code      string    `json:"code" example:"07501031311309"`
+	Type      string    `json:"type" example:"EAN13"`
+	IsPrimary bool      `json:"is_primary"`
+	Activo    bool      `json:"activo"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CrearCodigoBarras modelo para asociar un código a un producto
+type CrearCodigoBarras struct {
+	ProductID uuid.UUID `json:"product_id" binding:"required"`
+	// EAN-8, UPC-A, EAN-13 o GTIN-14 con dígito verificador
+	Code      string `json:"code" example:"7501031311309" binding:"required"`
+	IsPrimary bool   `json:"is_primary"`
+}
+
+// ExistenciaCodigo existencia del producto escaneado en la tienda
+type ExistenciaCodigo struct {
+	StoreID   uuid.UUID                    `json:"store_id"`
+	StoreName string                       `json:"store_name"`
+	Quantity  int                          `json:"quantity"`
+	MinStock  int                          `json:"min_stock"`
+	Locations []InventarioUbicacionDetalle `json:"locations,omitempty"`
+}
+
+// ConsultaCodigo resultado de escanear un código
+type ConsultaCodigo struct {
+	Barcode CodigoBarras      `json:"barcode"`
+	Product ProductoDetalle   `json:"product"`
+	Stock   *ExistenciaCodigo `json:"stock,omitempty"`
+}
+
+type BarcodeHandler struct {
+	db *sql.DB
+}
+
+func NewBarcodeHandler(db *sql.DB) *BarcodeHandler {
+	return &BarcodeHandler{db: db}
+}
+
+// ListarCodigosBarras godoc
+// @Summary      Listar códigos de barras
+// @Description  Obtiene los códigos de barras activos de un producto
+// @Tags         codigos
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string true "ID del producto"
+// @Success      200  {array}   CodigoBarras
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarCodigosBarras [get]
+func (h *BarcodeHandler) ListarCodigosBarras(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	productID, err := uuid.Parse(r.URL.Query().Get("product_id"))
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, productId, code, type, is_primary, activo, created_at, updated_at
+        FROM catalogos.codigosbarras
+        WHERE productId = $1 AND activo = true
+        ORDER BY is_primary DESC, created_at
+    `, productID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var codigos []CodigoBarras
+	for rows.Next() {
+		var c CodigoBarras
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.Code, &c.Type, &c.IsPrimary,
+			&c.Activo, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		codigos = append(codigos, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codigos)
+}
+
+// CrearCodigoBarras godoc
+// @Summary      Agregar código de barras
+// @Description  Asocia un código EAN-8, UPC-A, EAN-13 o GTIN-14 a un producto validando su dígito verificador
+// @Tags         codigos
+// @Accept       json
+// @Produce      json
+// @Param        codigo body CrearCodigoBarras true "Código de barras"
+// @Success      201  {object}  CodigoBarras
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearCodigoBarras [post]
+func (h *BarcodeHandler) CrearCodigoBarras(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearCodigoBarras
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+
+	tipo, err := barcode.Validate(c.Code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	normalizado, _ := barcode.Normalize(c.Code)
+
+	var codigo CodigoBarras
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var existe bool
+		if err := tx.QueryRow(`
+            SELECT EXISTS(SELECT 1 FROM catalogos.productos WHERE id = $1)
+        `, c.ProductID).Scan(&existe); err != nil {
+			return err
+		}
+		if !existe {
+			return fmt.Errorf("%w: producto no encontrado", errCodigoBarrasInvalido)
+		}
+
+		var otro uuid.UUID
+		err := tx.QueryRow(`
+            SELECT productId FROM catalogos.codigosbarras WHERE code = $1 AND activo = true
+        `, normalizado).Scan(&otro)
+		if err == nil {
+			return fmt.Errorf("%w: el código ya está asignado al producto %s", errCodigoBarrasInvalido, otro)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		// El primer código del producto es el principal
+		var tieneCodigos bool
+		if err := tx.QueryRow(`
+            SELECT EXISTS(SELECT 1 FROM catalogos.codigosbarras WHERE productId = $1 AND activo = true)
+        `, c.ProductID).Scan(&tieneCodigos); err != nil {
+			return err
+		}
+		principal := c.IsPrimary || !tieneCodigos
+		if principal {
+			if _, err := tx.Exec(`
+                UPDATE catalogos.codigosbarras SET is_primary = false
+                WHERE productId = $1 AND is_primary = true
+            `, c.ProductID); err != nil {
+				return err
+			}
+		}
+
+		return tx.QueryRow(`
+            INSERT INTO catalogos.codigosbarras (id, productId, code, type, is_primary)
+            VALUES ($1, $2, $3, $4, $5)
+            RETURNING id, productId, code, type, is_primary, activo, created_at, updated_at
+        `, uuid.New(), c.ProductID, normalizado, tipo, principal).Scan(
+			&codigo.ID, &codigo.ProductID, &codigo.Code, &codigo.Type, &codigo.IsPrimary,
+			&codigo.Activo, &codigo.CreatedAt, &codigo.UpdatedAt)
+	})
+
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(codigo)
+}
+
+// EliminarCodigoBarras godoc
+// @Summary      Eliminar código de barras
+// @Description  Desactiva un código de barras de un producto
+// @Tags         codigos
+// @Accept       json
+// @Produce      json
+// @Param        id query string true "ID del código de barras"
+// @Success      204  "No Content"
+// @Failure      404  {object}  map[string]string
+// @Router       /EliminarCodigoBarras [delete]
+func (h *BarcodeHandler) EliminarCodigoBarras(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+        UPDATE catalogos.codigosbarras SET activo = false, is_primary = false
+        WHERE id = $1 AND activo = true
+    `, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		http.Error(w, "Código de barras no encontrado", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LookupBarcode godoc
+// @Summary      Consultar código escaneado
+// @Description  Resuelve un código de barras al producto y, si se indica la tienda, a su existencia en ella
+// @Tags         codigos
+// @Accept       json
+// @Produce      json
+// @Param        code path string true "Código escaneado (EAN-8, UPC-A, EAN-13 o GTIN-14)"
+// @Param        store_id query string false "ID de la tienda"
+// @Success      200  {object}  ConsultaCodigo
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /barcodes/{code} [get]
+func (h *BarcodeHandler) LookupBarcode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	normalizado, err := barcode.Normalize(mux.Vars(r)["code"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var storeID *uuid.UUID
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	var consulta ConsultaCodigo
+	c, p := &consulta.Barcode, &consulta.Product
+	err = h.db.QueryRow(`
+        SELECT b.id, b.productId, b.code, b.type, b.is_primary, b.activo, b.created_at, b.updated_at,
+               p.id, p.name, p.description, p.category, p.price, p.currency, p.sku,
+               p.track_lots, p.track_serials, p.activo, p.created_at, p.updated_at
+        FROM catalogos.codigosbarras b
+        JOIN catalogos.productos p ON b.productId = p.id
+        WHERE b.code = $1 AND b.activo = true
+    `, normalizado).Scan(
+		&c.ID, &c.ProductID, &c.Code, &c.Type, &c.IsPrimary, &c.Activo, &c.CreatedAt, &c.UpdatedAt,
+		&p.ID, &p.Name, &p.Description, &p.Category, &p.Price.Amount, &p.Price.Currency, &p.SKU,
+		&p.TrackLots, &p.TrackSerials, &p.Activo, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Código de barras no registrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if storeID != nil {
+		existencia := ExistenciaCodigo{StoreID: *storeID}
+		err := h.db.QueryRow(`
+            SELECT t.name, COALESCE(i.quantity, 0), COALESCE(i.minStock, 0)
+            FROM catalogos.tiendas t
+            LEFT JOIN prueba.inventarios i
+                   ON i.storeId = t.id AND i.productId = $2 AND i.activo = true
+            WHERE t.id = $1
+        `, *storeID, p.ID).Scan(&existencia.StoreName, &existencia.Quantity, &existencia.MinStock)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Tienda no encontrada", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		detalle, err := existenciasPorUbicacion(h.db, *storeID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, d := range detalle {
+			if d.ProductID == p.ID && d.LocationID != nil {
+				existencia.Locations = append(existencia.Locations, d)
+			}
+		}
+		consulta.Stock = &existencia
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(consulta)
+}
+
+// resolverProducto obtiene el producto de una solicitud que puede traer
+// product_id, código de barras o ambos (en cuyo caso deben coincidir)
+func resolverProducto(q utils.Querier, productID uuid.UUID, codigo string) (uuid.UUID, error) {
+	if codigo == "" {
+		if productID == uuid.Nil {
+			return productID, fmt.Errorf("%w: se requiere product_id o barcode", errCodigoBarrasInvalido)
+		}
+		return productID, nil
+	}
+
+	normalizado, err := barcode.Normalize(codigo)
+	if err != nil {
+		return productID, fmt.Errorf("%w: %v", errCodigoBarrasInvalido, err)
+	}
+
+	var encontrado uuid.UUID
+	err = q.QueryRow(`
+        SELECT productId FROM catalogos.codigosbarras WHERE code = $1 AND activo = true
+    `, normalizado).Scan(&encontrado)
+	if err == sql.ErrNoRows {
+		return productID, fmt.Errorf("%w: %s no está registrado", errCodigoBarrasInvalido, codigo)
+	}
+	if err != nil {
+		return productID, err
+	}
+
+	if productID != uuid.Nil && productID != encontrado {
+		return productID, fmt.Errorf("%w: %s no corresponde al producto indicado", errCodigoBarrasInvalido, codigo)
+	}
+	return encontrado, nil
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/utils"
+	"net/http"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Máximo de líneas de una transferencia en lote
+const maxLineasLote = 500
+
+// errLineaInvalida línea de transferencia sin tiendas o cantidad
+var errLineaInvalida = errors.New("Línea inválida")
+
+// TransferenciaLote transferencias que se aplican todas o ninguna
+type TransferenciaLote struct {
+	Lines []StockTransfer `json:"lines"`
+}
+
+// ResultadoTransferencia línea aplicada y existencias resultantes en ambas tiendas
+type ResultadoTransferencia struct {
+	// Número de línea en la solicitud, desde 1
+	Line          int       `json:"line" example:"1"`
+	ProductID     uuid.UUID `json:"product_id"`
+	SourceStoreID uuid.UUID `json:"source_store_id"`
+	TargetStoreID uuid.UUID `json:"target_store_id"`
+	Quantity      int       `json:"quantity" example:"5"`
+	MovementID    uuid.UUID `json:"movement_id"`
+	// Existencias después de aplicar la línea
+	SourceQuantity int `json:"source_quantity" example:"20"`
+	TargetQuantity int `json:"target_quantity" example:"12"`
+}
+
+// TransferInventoryBatch godoc
+// @Summary      Transferir productos entre tiendas en lote
+// @Description  Aplica varias transferencias en una sola transacción: si alguna línea falla no se aplica
+// @Description  ninguna. Las existencias involucradas se bloquean en orden de producto y tienda antes de
+// @Description  mover, para que lotes concurrentes no se bloqueen mutuamente, y se verifican todas las líneas
+// @Description  antes de escribir; el error indica la primera línea sin existencia suficiente, contando lo que
+// @Description  mueven las líneas anteriores. Cada línea admite los mismos
+// @Description  campos que /inventory/transfer (código de barras, lote, series y ubicaciones).
+// @Tags         inventario
+// @Accept       json
+// @Produce      json
+// @Param        lote body TransferenciaLote true "Líneas de la transferencia"
+// @Success      200  {array}   ResultadoTransferencia
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/transfer/batch [post]
+func (h *InventoryHandler) TransferInventoryBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var lote TransferenciaLote
+	if err := json.NewDecoder(r.Body).Decode(&lote); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if len(lote.Lines) == 0 || len(lote.Lines) > maxLineasLote {
+		http.Error(w, fmt.Sprintf("Indique entre 1 y %d líneas", maxLineasLote), http.StatusBadRequest)
+		return
+	}
+
+	var resultados []ResultadoTransferencia
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var err error
+		resultados, err = transferirLote(tx, lote.Lines)
+		return err
+	})
+
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resultados)
+}
+
+// transferirLote aplica las transferencias dentro de la transacción después
+// de bloquear todas las existencias involucradas en orden determinista y
+// verificar que alcancen para todas las líneas
+func transferirLote(tx *sql.Tx, lineas []StockTransfer) ([]ResultadoTransferencia, error) {
+	// Resolver productos antes de bloquear para conocer todas las existencias
+	for i := range lineas {
+		l := &lineas[i]
+		productID, err := resolverProducto(tx, l.ProductID, l.Barcode)
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", i+1, err)
+		}
+		l.ProductID, l.Barcode = productID, ""
+		if l.Quantity == 0 && len(l.Serials) > 0 {
+			l.Quantity = len(l.Serials)
+		}
+		if l.Quantity <= 0 || l.SourceStoreID == uuid.Nil || l.TargetStoreID == uuid.Nil ||
+			l.SourceStoreID == l.TargetStoreID {
+			return nil, fmt.Errorf("línea %d: %w: indique dos tiendas distintas y cantidad positiva", i+1, errLineaInvalida)
+		}
+	}
+
+	existencias, err := bloquearExistencias(tx, lineas)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verificar todas las líneas antes de escribir: una línea sin existencia
+	// suficiente rechaza el lote completo
+	resultados, err := simularLote(lineas, existencias)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range lineas {
+		movementID, err := ejecutarTransferencia(tx, &lineas[i])
+		if err != nil {
+			return nil, fmt.Errorf("línea %d: %w", i+1, err)
+		}
+		resultados[i].MovementID = movementID
+	}
+	return resultados, nil
+}
+
+// claveExistencia registro de inventario de un producto en una tienda
+type claveExistencia struct{ productID, storeID uuid.UUID }
+
+// existenciaBloqueada cantidad de un registro de inventario bloqueado
+type existenciaBloqueada struct {
+	cantidad int
+	activo   bool
+}
+
+// clavesBloqueo registros de origen y destino de las líneas, sin repetir y
+// ordenados por producto y tienda como los compara Postgres, para que dos lotes
+// que comparten existencias las bloqueen en el mismo orden
+func clavesBloqueo(lineas []StockTransfer) []claveExistencia {
+	vistas := make(map[claveExistencia]bool)
+	var claves []claveExistencia
+	for _, l := range lineas {
+		for _, tienda := range []uuid.UUID{l.SourceStoreID, l.TargetStoreID} {
+			k := claveExistencia{l.ProductID, tienda}
+			if !vistas[k] {
+				vistas[k] = true
+				claves = append(claves, k)
+			}
+		}
+	}
+	sort.Slice(claves, func(i, j int) bool {
+		if c := bytes.Compare(claves[i].productID[:], claves[j].productID[:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(claves[i].storeID[:], claves[j].storeID[:]) < 0
+	})
+	return claves
+}
+
+// bloquearExistencias toma FOR UPDATE los registros de inventario de origen y
+// destino de todas las líneas en el orden de clavesBloqueo y devuelve sus
+// cantidades; los registros que aún no existen no aparecen
+func bloquearExistencias(tx *sql.Tx, lineas []StockTransfer) (map[claveExistencia]existenciaBloqueada, error) {
+	claves := clavesBloqueo(lineas)
+	productos := make([]uuid.UUID, len(claves))
+	tiendas := make([]uuid.UUID, len(claves))
+	for i, k := range claves {
+		productos[i], tiendas[i] = k.productID, k.storeID
+	}
+	rows, err := tx.Query(`
+        SELECT i.productId, i.storeId, i.quantity, i.activo FROM prueba.inventarios i
+        JOIN unnest($1::uuid[], $2::uuid[]) AS k(productId, storeId)
+            ON i.productId = k.productId AND i.storeId = k.storeId
+        ORDER BY i.productId, i.storeId
+        FOR UPDATE OF i
+    `, pq.Array(productos), pq.Array(tiendas))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existencias := make(map[claveExistencia]existenciaBloqueada, len(claves))
+	for rows.Next() {
+		var k claveExistencia
+		var e existenciaBloqueada
+		if err := rows.Scan(&k.productID, &k.storeID, &e.cantidad, &e.activo); err != nil {
+			return nil, err
+		}
+		existencias[k] = e
+	}
+	return existencias, rows.Err()
+}
+
+// simularLote aplica las líneas en orden sobre las existencias bloqueadas y
+// devuelve las existencias resultantes de cada línea. Falla en la primera
+// línea cuyo origen no tiene inventario activo o cantidad suficiente, contando
+// lo que las líneas anteriores ya movieron.
+func simularLote(lineas []StockTransfer, existencias map[claveExistencia]existenciaBloqueada) ([]ResultadoTransferencia, error) {
+	saldo := make(map[claveExistencia]existenciaBloqueada, len(existencias))
+	for k, e := range existencias {
+		saldo[k] = e
+	}
+
+	resultados := make([]ResultadoTransferencia, 0, len(lineas))
+	for i, l := range lineas {
+		origen := claveExistencia{l.ProductID, l.SourceStoreID}
+		destino := claveExistencia{l.ProductID, l.TargetStoreID}
+		o, ok := saldo[origen]
+		if !ok || !o.activo {
+			return nil, fmt.Errorf("línea %d: %w", i+1, errSinInventario)
+		}
+		if o.cantidad < l.Quantity {
+			return nil, fmt.Errorf("línea %d: %w: hay %d y se piden %d", i+1, errStockInsuficiente, o.cantidad, l.Quantity)
+		}
+		o.cantidad -= l.Quantity
+		saldo[origen] = o
+
+		// El destino se crea activo si no existe
+		d, ok := saldo[destino]
+		if !ok {
+			d.activo = true
+		}
+		d.cantidad += l.Quantity
+		saldo[destino] = d
+
+		resultados = append(resultados, ResultadoTransferencia{Line: i + 1, ProductID: l.ProductID,
+			SourceStoreID: l.SourceStoreID, TargetStoreID: l.TargetStoreID, Quantity: l.Quantity,
+			SourceQuantity: o.cantidad, TargetQuantity: d.cantidad})
+	}
+	return resultados, nil
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestClavesBloqueo(t *testing.T) {
+	a := uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	b := uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	centro := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	norte := uuid.MustParse("20000000-0000-0000-0000-000000000000")
+	sur := uuid.MustParse("30000000-0000-0000-0000-000000000000")
+
+	// Dos lotes con las mismas existencias en orden inverso bloquean igual
+	uno := clavesBloqueo([]StockTransfer{
+		{ProductID: b, SourceStoreID: sur, TargetStoreID: centro, Quantity: 1},
+		{ProductID: a, SourceStoreID: norte, TargetStoreID: centro, Quantity: 1},
+		{ProductID: a, SourceStoreID: centro, TargetStoreID: norte, Quantity: 1},
+	})
+	otro := clavesBloqueo([]StockTransfer{
+		{ProductID: a, SourceStoreID: centro, TargetStoreID: norte, Quantity: 1},
+		{ProductID: b, SourceStoreID: centro, TargetStoreID: sur, Quantity: 1},
+	})
+
+	esperado := []claveExistencia{{a, centro}, {a, norte}, {b, centro}, {b, sur}}
+	for nombre, claves := range map[string][]claveExistencia{"uno": uno, "otro": otro} {
+		if len(claves) != len(esperado) {
+			t.Fatalf("%s: claves = %v", nombre, claves)
+		}
+		for i := range esperado {
+			if claves[i] != esperado[i] {
+				t.Errorf("%s: clave %d = %v, se esperaba %v", nombre, i, claves[i], esperado[i])
+			}
+		}
+	}
+
+	// El orden es el de los bytes del UUID, igual que en Postgres
+	x, y := uuid.New(), uuid.New()
+	claves := clavesBloqueo([]StockTransfer{{ProductID: x, SourceStoreID: centro, TargetStoreID: norte},
+		{ProductID: y, SourceStoreID: centro, TargetStoreID: norte}})
+	if bytes.Compare(claves[0].productID[:], claves[2].productID[:]) > 0 {
+		t.Errorf("claves fuera de orden: %v", claves)
+	}
+}
+
+func TestSimularLote(t *testing.T) {
+	producto, otro := uuid.New(), uuid.New()
+	centro, norte, sur := uuid.New(), uuid.New(), uuid.New()
+	existencias := map[claveExistencia]existenciaBloqueada{
+		{producto, centro}: {cantidad: 10, activo: true},
+		{producto, norte}:  {cantidad: 2, activo: true},
+		{otro, centro}:     {cantidad: 5, activo: false},
+	}
+	linea := func(p, origen, destino uuid.UUID, cantidad int) StockTransfer {
+		return StockTransfer{ProductID: p, SourceStoreID: origen, TargetStoreID: destino, Quantity: cantidad}
+	}
+
+	// Cada línea reporta las existencias después de las anteriores
+	resultados, err := simularLote([]StockTransfer{
+		linea(producto, centro, norte, 6),
+		linea(producto, norte, sur, 7),
+		linea(producto, centro, sur, 4),
+	}, existencias)
+	if err != nil {
+		t.Fatalf("simularLote: %v", err)
+	}
+	esperados := []struct{ origen, destino int }{{4, 8}, {1, 7}, {0, 11}}
+	if len(resultados) != len(esperados) {
+		t.Fatalf("resultados = %+v", resultados)
+	}
+	for i, e := range esperados {
+		r := resultados[i]
+		if r.Line != i+1 || r.SourceQuantity != e.origen || r.TargetQuantity != e.destino {
+			t.Errorf("línea %d = %+v, se esperaba origen %d y destino %d", i+1, r, e.origen, e.destino)
+		}
+	}
+
+	// La segunda línea ya no alcanza por lo que movió la primera: se rechaza todo
+	resultados, err = simularLote([]StockTransfer{
+		linea(producto, centro, norte, 8),
+		linea(producto, centro, sur, 3),
+	}, existencias)
+	if !errors.Is(err, errStockInsuficiente) || !strings.HasPrefix(err.Error(), "línea 2:") || resultados != nil {
+		t.Errorf("línea corta: %v %+v", err, resultados)
+	}
+
+	// Origen sin inventario o inactivo
+	if _, err := simularLote([]StockTransfer{linea(producto, sur, centro, 1)}, existencias); !errors.Is(err, errSinInventario) {
+		t.Errorf("sin inventario: err = %v", err)
+	}
+	if _, err := simularLote([]StockTransfer{linea(otro, centro, norte, 1)}, existencias); !errors.Is(err, errSinInventario) {
+		t.Errorf("inventario inactivo: err = %v", err)
+	}
+
+	// La simulación no altera las existencias bloqueadas
+	if e := existencias[claveExistencia{producto, centro}]; e.cantidad != 10 {
+		t.Errorf("existencias modificadas: %+v", e)
+	}
+}
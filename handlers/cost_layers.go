@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"database/sql"
+	"go-project/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// capaCosto entrada de mercancía con su costo unitario
+type capaCosto struct {
+	ID         uuid.UUID
+	Quantity   int
+	Remaining  int
+	UnitCost   money.Money
+	ReceivedAt time.Time
+}
+
+// consumoCapa cantidad tomada de una capa de costo por una salida
+type consumoCapa struct {
+	LayerID  uuid.UUID
+	Quantity int
+	UnitCost money.Money
+}
+
+// registrarCapa crea una capa de costo para una entrada de mercancía
+func registrarCapa(tx *sql.Tx, productID, storeID uuid.UUID, movementID *uuid.UUID, cantidad int, costo money.Money, fecha time.Time) error {
+	_, err := tx.Exec(`
+        INSERT INTO prueba.capascosto (
+            id, productId, storeId, movementId, quantity, remaining,
+            unit_cost, currency, received_at
+        ) VALUES ($1, $2, $3, $4, $5, $5, $6, $7, $8)
+    `, uuid.New(), productID, storeID, movementID, cantidad,
+		costo.Amount, costo.Currency, fecha)
+	return err
+}
+
+// consumirCapas descuenta una salida de las capas más antiguas (FIFO) y
+// registra el costo consumido de cada una. Si las capas no cubren toda la
+// cantidad (existencias previas sin costo), se consume sólo lo disponible.
+func consumirCapas(tx *sql.Tx, productID, storeID uuid.UUID, movementID *uuid.UUID, cantidad int) ([]consumoCapa, error) {
+	return consumirCapasDe(tx, productID, storeID, movementID, nil, cantidad)
+}
+
+// consumirCapasDe igual que consumirCapas, pero consume primero las capas que
+// creó el movimiento de entrada indicado (por ejemplo, la baja de lo que una
+// RMA acaba de reingresar sale al costo de ese reingreso)
+func consumirCapasDe(tx *sql.Tx, productID, storeID uuid.UUID, movementID, entradaID *uuid.UUID, cantidad int) ([]consumoCapa, error) {
+	rows, err := tx.Query(`
+        SELECT id, quantity, remaining, unit_cost, currency, received_at
+        FROM prueba.capascosto
+        WHERE productId = $1 AND storeId = $2 AND remaining > 0 AND activo = true
+        ORDER BY ($3::uuid IS NOT NULL AND movementId = $3) DESC, received_at, created_at
+        FOR UPDATE
+    `, productID, storeID, entradaID)
+	if err != nil {
+		return nil, err
+	}
+
+	var consumos []consumoCapa
+	pendiente := cantidad
+	for rows.Next() && pendiente > 0 {
+		var c capaCosto
+		if err := rows.Scan(&c.ID, &c.Quantity, &c.Remaining,
+			&c.UnitCost.Amount, &c.UnitCost.Currency, &c.ReceivedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tomar := c.Remaining
+		if tomar > pendiente {
+			tomar = pendiente
+		}
+		consumos = append(consumos, consumoCapa{LayerID: c.ID, Quantity: tomar, UnitCost: c.UnitCost})
+		pendiente -= tomar
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range consumos {
+		if _, err := tx.Exec(`
+            UPDATE prueba.capascosto SET remaining = remaining - $2 WHERE id = $1
+        `, c.LayerID, c.Quantity); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+            INSERT INTO prueba.consumoscosto (id, movementId, layerId, quantity, unit_cost, currency)
+            VALUES ($1, $2, $3, $4, $5, $6)
+        `, uuid.New(), movementID, c.LayerID, c.Quantity, c.UnitCost.Amount, c.UnitCost.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	return consumos, nil
+}
+
+// trasladarCapas mueve el costo de una transferencia: consume capas FIFO en la
+// tienda origen y crea capas con el mismo costo en la tienda destino
+func trasladarCapas(tx *sql.Tx, productID, sourceStoreID, targetStoreID uuid.UUID, movementID *uuid.UUID, cantidad int, fecha time.Time) error {
+	consumos, err := consumirCapas(tx, productID, sourceStoreID, movementID, cantidad)
+	if err != nil {
+		return err
+	}
+	for _, c := range consumos {
+		if err := registrarCapa(tx, productID, targetStoreID, movementID, c.Quantity, c.UnitCost, fecha); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,857 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/money"
+	"go-project/utils"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// errConteoInvalido indica una operación no válida sobre un conteo físico
+var errConteoInvalido = errors.New("Conteo inválido")
+
+// errProductoEnConteo indica que el producto está congelado por un conteo abierto
+var errProductoEnConteo = errors.New("El producto está en conteo físico en la tienda")
+
+// TipoConteo alcance de un conteo físico
+type TipoConteo string
+
+const (
+	ConteoFULL  TipoConteo = "FULL"
+	ConteoCYCLE TipoConteo = "CYCLE"
+)
+
+// Estados de un conteo y decisiones de sus líneas
+const (
+	ConteoAbierto   = "OPEN"
+	ConteoAprobado  = "APPROVED"
+	ConteoCancelado = "CANCELLED"
+
+	LineaPendiente = "PENDING"
+	LineaAceptada  = "ACCEPTED"
+	LineaRechazada = "REJECTED"
+	LineaSinContar = "NOT_COUNTED"
+)
+
+// Conteo sesión de conteo físico en una tienda
+// @Description Conteo físico de inventario
+type Conteo struct {
+	ID         uuid.UUID  `json:"id"`
+	StoreID    uuid.UUID  `json:"store_id"`
+	StoreName  string     `json:"store_name"`
+	Type       TipoConteo `json:"type" example:"CYCLE"`
+	Status     string     `json:"status" example:"OPEN"`
+	Notes      string     `json:"notes,omitempty"`
+	ApprovedBy *string    `json:"approved_by,omitempty"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CrearConteo modelo para iniciar un conteo
+type CrearConteo struct {
+	StoreID uuid.UUID  `json:"store_id" binding:"required"`
+	Type    TipoConteo `json:"type" example:"CYCLE" binding:"required"`
+	// Productos a contar en un conteo cíclico
+	ProductIDs []uuid.UUID `json:"product_ids,omitempty"`
+	Notes      string      `json:"notes,omitempty"`
+}
+
+// CapturaConteo cantidad contada de un producto, opcionalmente en una ubicación
+type CapturaConteo struct {
+	ProductID  uuid.UUID  `json:"product_id" binding:"required"`
+	LocationID *uuid.UUID `json:"location_id,omitempty"`
+	Quantity   int        `json:"quantity" example:"24"`
+}
+
+// RegistrarCapturas capturas de un contador
+type RegistrarCapturas struct {
+	Counter string          `json:"counter" example:"jperez" binding:"required"`
+	Entries []CapturaConteo `json:"entries" binding:"required"`
+}
+
+// AprobarConteo decisión sobre las diferencias de un conteo; se aceptan todas
+// las líneas contadas salvo las rechazadas
+type AprobarConteo struct {
+	ApprovedBy         string      `json:"approved_by" example:"gerente" binding:"required"`
+	RejectedProductIDs []uuid.UUID `json:"rejected_product_ids,omitempty"`
+}
+
+// ConteoUbicacion cantidad contada en una ubicación (la última captura prevalece)
+type ConteoUbicacion struct {
+	LocationID   *uuid.UUID `json:"location_id,omitempty"`
+	LocationCode *string    `json:"location_code,omitempty"`
+	Quantity     int        `json:"quantity"`
+	Counters     []string   `json:"counters"`
+	// Los contadores capturaron cantidades distintas
+	Discrepancy bool `json:"discrepancy"`
+}
+
+// VarianzaLinea diferencia entre lo contado y la existencia del sistema
+type VarianzaLinea struct {
+	ProductID       uuid.UUID         `json:"product_id"`
+	ProductName     string            `json:"product_name"`
+	SystemQuantity  int               `json:"system_quantity"`
+	CountedQuantity *int              `json:"counted_quantity,omitempty"`
+	Variance        *int              `json:"variance,omitempty"`
+	Discrepancy     bool              `json:"discrepancy"`
+	Decision        string            `json:"decision" example:"PENDING"`
+	MovementID      *uuid.UUID        `json:"movement_id,omitempty"`
+	Locations       []ConteoUbicacion `json:"locations,omitempty"`
+}
+
+// ReporteVarianzas conteo con sus diferencias por producto
+type ReporteVarianzas struct {
+	Conteo
+	Lines      []VarianzaLinea `json:"lines"`
+	Counted    int             `json:"counted"`
+	NotCounted int             `json:"not_counted"`
+	// Suma de diferencias en unidades (sobrantes menos faltantes)
+	NetVariance int `json:"net_variance"`
+}
+
+// capturaConteo captura tal como se almacena
+type capturaConteo struct {
+	ProductID    uuid.UUID
+	LocationID   *uuid.UUID
+	LocationCode *string
+	Counter      string
+	Quantity     int
+}
+
+type CountHandler struct {
+	db *sql.DB
+}
+
+func NewCountHandler(db *sql.DB) *CountHandler {
+	return &CountHandler{db: db}
+}
+
+// ListarConteos godoc
+// @Summary      Listar conteos físicos
+// @Description  Obtiene los conteos físicos, opcionalmente filtrados por tienda y estado
+// @Tags         conteos
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string false "ID de la tienda"
+// @Param        status query string false "OPEN, APPROVED o CANCELLED"
+// @Success      200  {array}   Conteo
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarConteos [get]
+func (h *CountHandler) ListarConteos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var storeID *uuid.UUID
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+	status := r.URL.Query().Get("status")
+
+	rows, err := h.db.Query(consultaConteos+`
+        AND ($1::uuid IS NULL OR c.storeId = $1) AND ($2 = '' OR c.status = $2)
+        ORDER BY c.created_at DESC`, storeID, status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var conteos []Conteo
+	for rows.Next() {
+		c, err := escanearConteo(rows)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		conteos = append(conteos, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conteos)
+}
+
+// CrearConteo godoc
+// @Summary      Iniciar conteo físico
+// @Description  Inicia un conteo de toda la tienda (FULL) o de productos seleccionados (CYCLE). Registra la existencia del sistema y congela los movimientos de los productos contados hasta aprobar o cancelar.
+// @Tags         conteos
+// @Accept       json
+// @Produce      json
+// @Param        conteo body CrearConteo true "Datos del conteo"
+// @Success      201  {object}  ReporteVarianzas
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearConteo [post]
+func (h *CountHandler) CrearConteo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearConteo
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+
+	if c.Type != ConteoFULL && c.Type != ConteoCYCLE {
+		http.Error(w, "Tipo de conteo inválido", http.StatusBadRequest)
+		return
+	}
+	if c.Type == ConteoCYCLE && len(c.ProductIDs) == 0 {
+		http.Error(w, "Un conteo cíclico requiere al menos un producto", http.StatusBadRequest)
+		return
+	}
+
+	id := uuid.New()
+	var reporte ReporteVarianzas
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		// Serializar la creación de conteos por tienda
+		var tienda uuid.UUID
+		err := tx.QueryRow(`
+            SELECT id FROM catalogos.tiendas WHERE id = $1 AND activo = true FOR UPDATE
+        `, c.StoreID).Scan(&tienda)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: tienda no encontrada", errConteoInvalido)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+            INSERT INTO prueba.conteos (id, storeId, type, status, notes)
+            VALUES ($1, $2, $3, 'OPEN', NULLIF($4, ''))
+        `, id, c.StoreID, c.Type, c.Notes); err != nil {
+			return err
+		}
+
+		// Registrar la existencia del sistema de cada producto a contar
+		if c.Type == ConteoFULL {
+			_, err = tx.Exec(`
+                INSERT INTO prueba.conteoslineas (id, countId, productId, system_quantity)
+                SELECT gen_random_uuid(), $1, productId, quantity
+                FROM prueba.inventarios
+                WHERE storeId = $2 AND activo = true
+            `, id, c.StoreID)
+		} else {
+			err = registrarLineasConteo(tx, id, c.StoreID, c.ProductIDs)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Un producto no puede estar en dos conteos abiertos de la misma tienda
+		var nombre string
+		err = tx.QueryRow(`
+            SELECT p.name
+            FROM prueba.conteoslineas l
+            JOIN prueba.conteos c ON l.countId = c.id
+            JOIN catalogos.productos p ON l.productId = p.id
+            WHERE c.storeId = $1 AND c.status = 'OPEN' AND c.activo = true AND c.id <> $2
+              AND l.productId IN (SELECT productId FROM prueba.conteoslineas WHERE countId = $2)
+            LIMIT 1
+        `, c.StoreID, id).Scan(&nombre)
+		if err == nil {
+			return fmt.Errorf("%w: %s ya está en otro conteo abierto", errConteoInvalido, nombre)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		reporte, err = cargarReporteConteo(tx, id)
+		return err
+	})
+
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reporte)
+}
+
+// RegistrarCapturas godoc
+// @Summary      Capturar conteo
+// @Description  Registra las cantidades contadas por un contador. Varias personas pueden contar el mismo producto; por ubicación prevalece la última captura y las diferencias entre contadores se señalan en el reporte.
+// @Tags         conteos
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID del conteo"
+// @Param        capturas body RegistrarCapturas true "Cantidades contadas"
+// @Success      200  {object}  ReporteVarianzas
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/counts/{id}/entries [post]
+func (h *CountHandler) RegistrarCapturas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var capturas RegistrarCapturas
+	if err := json.NewDecoder(r.Body).Decode(&capturas); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if capturas.Counter == "" {
+		http.Error(w, "El contador es requerido", http.StatusBadRequest)
+		return
+	}
+	if len(capturas.Entries) == 0 {
+		http.Error(w, "Se requiere al menos una captura", http.StatusBadRequest)
+		return
+	}
+
+	var reporte ReporteVarianzas
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		storeID, err := bloquearConteoAbierto(tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range capturas.Entries {
+			if e.Quantity < 0 {
+				return fmt.Errorf("%w: la cantidad contada debe ser no negativa", errConteoInvalido)
+			}
+			var incluido bool
+			if err := tx.QueryRow(`
+                SELECT EXISTS(SELECT 1 FROM prueba.conteoslineas WHERE countId = $1 AND productId = $2)
+            `, id, e.ProductID).Scan(&incluido); err != nil {
+				return err
+			}
+			if !incluido {
+				return fmt.Errorf("%w: el producto %s no forma parte del conteo", errConteoInvalido, e.ProductID)
+			}
+			if e.LocationID != nil {
+				var tienda uuid.UUID
+				err := tx.QueryRow(`
+                    SELECT storeId FROM catalogos.ubicaciones WHERE id = $1 AND activo = true
+                `, *e.LocationID).Scan(&tienda)
+				if err == sql.ErrNoRows || (err == nil && tienda != storeID) {
+					return fmt.Errorf("%w: la ubicación %s no pertenece a la tienda", errUbicacionInvalida, *e.LocationID)
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			if _, err := tx.Exec(`
+                INSERT INTO prueba.conteoscapturas (id, countId, productId, locationId, counter, quantity)
+                VALUES ($1, $2, $3, $4, $5, $6)
+            `, uuid.New(), id, e.ProductID, e.LocationID, capturas.Counter, e.Quantity); err != nil {
+				return err
+			}
+		}
+
+		reporte, err = cargarReporteConteo(tx, id)
+		return err
+	})
+
+	responderConteo(w, reporte, err)
+}
+
+// GetCountVariance godoc
+// @Summary      Reporte de diferencias
+// @Description  Compara lo contado contra la existencia del sistema al iniciar el conteo
+// @Tags         conteos
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID del conteo"
+// @Success      200  {object}  ReporteVarianzas
+// @Failure      404  {object}  map[string]string
+// @Router       /inventory/counts/{id}/variance [get]
+func (h *CountHandler) GetCountVariance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	reporte, err := cargarReporteConteo(h.db, id)
+	responderConteo(w, reporte, err)
+}
+
+// ApproveCount godoc
+// @Summary      Aprobar conteo
+// @Description  Acepta las diferencias de las líneas contadas (salvo las rechazadas), registra un movimiento ADJUSTMENT por cada diferencia aceptada, actualiza las existencias por ubicación contada y libera los productos.
+// @Tags         conteos
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID del conteo"
+// @Param        aprobacion body AprobarConteo true "Datos de la aprobación"
+// @Success      200  {object}  ReporteVarianzas
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/counts/{id}/approve [post]
+func (h *CountHandler) ApproveCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var aprobacion AprobarConteo
+	if err := json.NewDecoder(r.Body).Decode(&aprobacion); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if aprobacion.ApprovedBy == "" {
+		http.Error(w, "Se requiere quién aprueba", http.StatusBadRequest)
+		return
+	}
+	rechazados := make(map[uuid.UUID]bool, len(aprobacion.RejectedProductIDs))
+	for _, p := range aprobacion.RejectedProductIDs {
+		rechazados[p] = true
+	}
+
+	var reporte ReporteVarianzas
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		storeID, err := bloquearConteoAbierto(tx, id)
+		if err != nil {
+			return err
+		}
+
+		actual, err := cargarReporteConteo(tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range actual.Lines {
+			decision := LineaAceptada
+			switch {
+			case l.CountedQuantity == nil:
+				decision = LineaSinContar
+			case rechazados[l.ProductID]:
+				decision = LineaRechazada
+			}
+
+			var movementID *uuid.UUID
+			if decision == LineaAceptada {
+				if movementID, err = aplicarConteo(tx, id, storeID, l); err != nil {
+					return err
+				}
+			}
+
+			if _, err := tx.Exec(`
+                UPDATE prueba.conteoslineas SET decision = $3, movementId = $4
+                WHERE countId = $1 AND productId = $2
+            `, id, l.ProductID, decision, movementID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+            UPDATE prueba.conteos
+            SET status = 'APPROVED', approved_by = $2, approved_at = CURRENT_TIMESTAMP
+            WHERE id = $1
+        `, id, aprobacion.ApprovedBy); err != nil {
+			return err
+		}
+
+		reporte, err = cargarReporteConteo(tx, id)
+		return err
+	})
+
+	responderConteo(w, reporte, err)
+}
+
+// CancelCount godoc
+// @Summary      Cancelar conteo
+// @Description  Cancela un conteo abierto sin ajustar existencias y libera los productos
+// @Tags         conteos
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID del conteo"
+// @Success      200  {object}  ReporteVarianzas
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/counts/{id}/cancel [post]
+func (h *CountHandler) CancelCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var reporte ReporteVarianzas
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		if _, err := bloquearConteoAbierto(tx, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE prueba.conteos SET status = 'CANCELLED' WHERE id = $1`, id); err != nil {
+			return err
+		}
+		reporte, err = cargarReporteConteo(tx, id)
+		return err
+	})
+
+	responderConteo(w, reporte, err)
+}
+
+// responderConteo escribe el reporte del conteo o el error correspondiente
+func responderConteo(w http.ResponseWriter, reporte ReporteVarianzas, err error) {
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conteo no encontrado", http.StatusNotFound)
+		return
+	}
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reporte)
+}
+
+const consultaConteos = `
+        SELECT c.id, c.storeId, t.name, c.type, c.status, COALESCE(c.notes, ''),
+               c.approved_by, c.approved_at, c.created_at, c.updated_at
+        FROM prueba.conteos c
+        JOIN catalogos.tiendas t ON c.storeId = t.id
+        WHERE c.activo = true`
+
+// escanearConteo lee un conteo de consultaConteos
+func escanearConteo(row interface{ Scan(...interface{}) error }) (Conteo, error) {
+	var c Conteo
+	err := row.Scan(&c.ID, &c.StoreID, &c.StoreName, &c.Type, &c.Status, &c.Notes,
+		&c.ApprovedBy, &c.ApprovedAt, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// registrarLineasConteo agrega al conteo los productos seleccionados con su
+// existencia actual (cero si no tienen inventario en la tienda)
+func registrarLineasConteo(tx *sql.Tx, countID, storeID uuid.UUID, productIDs []uuid.UUID) error {
+	for _, productID := range productIDs {
+		var existe bool
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM catalogos.productos WHERE id = $1)`, productID).Scan(&existe)
+		if err != nil {
+			return err
+		}
+		if !existe {
+			return fmt.Errorf("%w: producto %s no encontrado", errConteoInvalido, productID)
+		}
+
+		if _, err := tx.Exec(`
+            INSERT INTO prueba.conteoslineas (id, countId, productId, system_quantity)
+            SELECT $1, $2, $3, COALESCE((SELECT quantity FROM prueba.inventarios
+                                         WHERE productId = $3 AND storeId = $4 AND activo = true), 0)
+            ON CONFLICT (countId, productId) DO NOTHING
+        `, uuid.New(), countID, productID, storeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bloquearConteoAbierto bloquea el conteo y verifica que siga abierto
+func bloquearConteoAbierto(tx *sql.Tx, countID uuid.UUID) (uuid.UUID, error) {
+	var storeID uuid.UUID
+	var status string
+	err := tx.QueryRow(`
+        SELECT storeId, status FROM prueba.conteos WHERE id = $1 AND activo = true FOR UPDATE
+    `, countID).Scan(&storeID, &status)
+	if err != nil {
+		return storeID, err
+	}
+	if status != ConteoAbierto {
+		return storeID, fmt.Errorf("%w: el conteo está %s", errConteoInvalido, status)
+	}
+	return storeID, nil
+}
+
+// cargarReporteConteo obtiene el conteo con las cantidades contadas y sus diferencias
+func cargarReporteConteo(q utils.Querier, countID uuid.UUID) (ReporteVarianzas, error) {
+	var reporte ReporteVarianzas
+	var err error
+	reporte.Conteo, err = escanearConteo(q.QueryRow(consultaConteos+` AND c.id = $1`, countID))
+	if err != nil {
+		return reporte, err
+	}
+
+	rows, err := q.Query(`
+        SELECT cc.productId, cc.locationId, u.code, cc.counter, cc.quantity
+        FROM prueba.conteoscapturas cc
+        LEFT JOIN catalogos.ubicaciones u ON cc.locationId = u.id
+        WHERE cc.countId = $1
+        ORDER BY cc.counted_at, cc.id`, countID)
+	if err != nil {
+		return reporte, err
+	}
+	var capturas []capturaConteo
+	for rows.Next() {
+		var c capturaConteo
+		if err := rows.Scan(&c.ProductID, &c.LocationID, &c.LocationCode, &c.Counter, &c.Quantity); err != nil {
+			rows.Close()
+			return reporte, err
+		}
+		capturas = append(capturas, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return reporte, err
+	}
+	contado := consolidarCapturas(capturas)
+
+	rows, err = q.Query(`
+        SELECT l.productId, p.name, l.system_quantity, l.decision, l.movementId
+        FROM prueba.conteoslineas l
+        JOIN catalogos.productos p ON l.productId = p.id
+        WHERE l.countId = $1
+        ORDER BY p.name`, countID)
+	if err != nil {
+		return reporte, err
+	}
+	defer rows.Close()
+
+	reporte.Lines = []VarianzaLinea{}
+	for rows.Next() {
+		var l VarianzaLinea
+		if err := rows.Scan(&l.ProductID, &l.ProductName, &l.SystemQuantity,
+			&l.Decision, &l.MovementID); err != nil {
+			return reporte, err
+		}
+		if ubicaciones, ok := contado[l.ProductID]; ok {
+			l.Locations = ubicaciones
+			total := 0
+			for _, u := range ubicaciones {
+				total += u.Quantity
+				l.Discrepancy = l.Discrepancy || u.Discrepancy
+			}
+			diferencia := total - l.SystemQuantity
+			l.CountedQuantity, l.Variance = &total, &diferencia
+			reporte.Counted++
+			reporte.NetVariance += diferencia
+		} else {
+			reporte.NotCounted++
+		}
+		reporte.Lines = append(reporte.Lines, l)
+	}
+	return reporte, rows.Err()
+}
+
+// consolidarCapturas agrupa las capturas (en orden cronológico) por producto y
+// ubicación. En cada ubicación prevalece la última captura; si la última
+// captura de cada contador no coincide se marca la discrepancia.
+func consolidarCapturas(capturas []capturaConteo) map[uuid.UUID][]ConteoUbicacion {
+	type clave struct {
+		producto  uuid.UUID
+		ubicacion uuid.UUID
+	}
+	indice := map[clave]int{}
+	porContador := map[clave]map[string]int{}
+	resultado := map[uuid.UUID][]ConteoUbicacion{}
+
+	for _, c := range capturas {
+		k := clave{producto: c.ProductID}
+		if c.LocationID != nil {
+			k.ubicacion = *c.LocationID
+		}
+		i, ok := indice[k]
+		if !ok {
+			i = len(resultado[c.ProductID])
+			indice[k] = i
+			porContador[k] = map[string]int{}
+			resultado[c.ProductID] = append(resultado[c.ProductID],
+				ConteoUbicacion{LocationID: c.LocationID, LocationCode: c.LocationCode})
+		}
+		u := &resultado[c.ProductID][i]
+		u.Quantity = c.Quantity
+		if _, visto := porContador[k][c.Counter]; !visto {
+			u.Counters = append(u.Counters, c.Counter)
+		}
+		porContador[k][c.Counter] = c.Quantity
+
+		u.Discrepancy = false
+		for _, cantidad := range porContador[k] {
+			if cantidad != c.Quantity {
+				u.Discrepancy = true
+			}
+		}
+	}
+	return resultado
+}
+
+// aplicarConteo lleva la existencia de la tienda y de las ubicaciones contadas
+// a lo contado, registrando un ajuste si hay diferencia con la existencia actual
+func aplicarConteo(tx *sql.Tx, countID, storeID uuid.UUID, l VarianzaLinea) (*uuid.UUID, error) {
+	for _, u := range l.Locations {
+		if u.LocationID == nil {
+			continue
+		}
+		var actual int
+		err := tx.QueryRow(`
+            SELECT COALESCE((SELECT quantity FROM prueba.inventariosubicaciones
+                             WHERE productId = $1 AND locationId = $2 FOR UPDATE), 0)
+        `, l.ProductID, *u.LocationID).Scan(&actual)
+		if err != nil {
+			return nil, err
+		}
+		if u.Quantity != actual {
+			if err := ajustarUbicacion(tx, l.ProductID, storeID, *u.LocationID, u.Quantity-actual); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var actual int
+	err := tx.QueryRow(`
+        SELECT COALESCE((SELECT quantity FROM prueba.inventarios
+                         WHERE productId = $1 AND storeId = $2 AND activo = true FOR UPDATE), 0)
+    `, l.ProductID, storeID).Scan(&actual)
+	if err != nil {
+		return nil, err
+	}
+
+	var movementID *uuid.UUID
+	if diferencia := *l.CountedQuantity - actual; diferencia != 0 {
+		id, err := registrarAjuste(tx, l.ProductID, storeID, countID, diferencia)
+		if err != nil {
+			return nil, err
+		}
+		movementID = &id
+	}
+
+	return movementID, verificarSinUbicar(tx, l.ProductID, storeID)
+}
+
+// registrarAjuste registra un movimiento ADJUSTMENT por la diferencia de un
+// conteo y lo aplica a existencias, lotes y capas de costo. Los sobrantes
+// entran con el último costo conocido del producto; los faltantes consumen FIFO.
+func registrarAjuste(tx *sql.Tx, productID, storeID, countID uuid.UUID, diferencia int) (uuid.UUID, error) {
+	controlaSeries, err := productoControlaSeries(tx, productID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if controlaSeries {
+		return uuid.Nil, fmt.Errorf("%w: el producto %s controla números de serie; rechace la diferencia y registre las series con movimientos IN u OUT",
+			errConteoInvalido, productID)
+	}
+
+	cantidad, sentido := diferencia, 1
+	if diferencia < 0 {
+		cantidad, sentido = -diferencia, -1
+	}
+
+	var id uuid.UUID
+	var fecha time.Time
+	err = tx.QueryRow(`
+        INSERT INTO prueba.movimientos (
+            id, productId, sourceStoreId, targetStoreId,
+            quantity, type, direction, countId, timestamp
+        ) VALUES ($1, $2, $3, $3, $4, 'ADJUSTMENT', $5, $6, CURRENT_TIMESTAMP)
+        RETURNING id, timestamp
+    `, uuid.New(), productID, storeID, cantidad, sentido, countID).Scan(&id, &fecha)
+	if err != nil {
+		return id, err
+	}
+
+	if err := ajustarExistencia(tx, productID, storeID, diferencia); err != nil {
+		return id, err
+	}
+
+	controlaLotes, err := productoControlaLotes(tx, productID)
+	if err != nil {
+		return id, err
+	}
+
+	if sentido < 0 {
+		if controlaLotes {
+			if _, err := registrarSalidaLotes(tx, productID, storeID, id, "", cantidad); err != nil {
+				return id, err
+			}
+		}
+		_, err = consumirCapas(tx, productID, storeID, &id, cantidad)
+		return id, err
+	}
+
+	if controlaLotes {
+		if _, err := registrarEntradaLote(tx, productID, storeID, id,
+			"AJUSTE-"+countID.String()[:8], nil, cantidad); err != nil {
+			return id, err
+		}
+	}
+	costo, err := ultimoCosto(tx, productID, storeID)
+	if err != nil || costo == nil {
+		return id, err
+	}
+	return id, registrarCapa(tx, productID, storeID, &id, cantidad, *costo, fecha)
+}
+
+// ultimoCosto obtiene el costo de la entrada más reciente del producto,
+// prefiriendo la tienda indicada
+func ultimoCosto(q utils.Querier, productID, storeID uuid.UUID) (*money.Money, error) {
+	var costo money.Money
+	err := q.QueryRow(`
+        SELECT unit_cost, currency FROM prueba.capascosto
+        WHERE productId = $1 AND activo = true
+        ORDER BY (storeId = $2) DESC, received_at DESC
+        LIMIT 1
+    `, productID, storeID).Scan(&costo.Amount, &costo.Currency)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &costo, nil
+}
+
+// verificarSinConteo rechaza movimientos de un producto congelado por un
+// conteo abierto en la tienda
+func verificarSinConteo(q utils.Querier, productID, storeID uuid.UUID) error {
+	var congelado bool
+	err := q.QueryRow(`
+        SELECT EXISTS(
+            SELECT 1 FROM prueba.conteoslineas l
+            JOIN prueba.conteos c ON l.countId = c.id
+            WHERE l.productId = $1 AND c.storeId = $2 AND c.status = 'OPEN' AND c.activo = true)
+    `, productID, storeID).Scan(&congelado)
+	if err != nil {
+		return err
+	}
+	if congelado {
+		return errProductoEnConteo
+	}
+	return nil
+}
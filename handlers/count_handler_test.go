@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestConsolidarCapturas(t *testing.T) {
+	producto := uuid.New()
+	anaquel, bodega := uuid.New(), uuid.New()
+
+	resultado := consolidarCapturas([]capturaConteo{
+		{ProductID: producto, LocationID: &anaquel, Counter: "ana", Quantity: 10},
+		{ProductID: producto, LocationID: &bodega, Counter: "ana", Quantity: 5},
+		{ProductID: producto, LocationID: &anaquel, Counter: "luis", Quantity: 12},
+		{ProductID: producto, LocationID: &bodega, Counter: "luis", Quantity: 5},
+		// Reconteo de ana en el anaquel coincide con luis
+		{ProductID: producto, LocationID: &anaquel, Counter: "ana", Quantity: 12},
+	})
+
+	ubicaciones := resultado[producto]
+	if len(ubicaciones) != 2 {
+		t.Fatalf("se esperaban 2 ubicaciones, se obtuvieron %d", len(ubicaciones))
+	}
+	if u := ubicaciones[0]; u.Quantity != 12 || u.Discrepancy || len(u.Counters) != 2 {
+		t.Errorf("anaquel = %+v, se esperaba 12 sin discrepancia y 2 contadores", u)
+	}
+	if u := ubicaciones[1]; u.Quantity != 5 || u.Discrepancy {
+		t.Errorf("bodega = %+v, se esperaba 5 sin discrepancia", u)
+	}
+
+	resultado = consolidarCapturas([]capturaConteo{
+		{ProductID: producto, Counter: "ana", Quantity: 7},
+		{ProductID: producto, Counter: "luis", Quantity: 8},
+	})
+	if u := resultado[producto][0]; u.Quantity != 8 || !u.Discrepancy {
+		t.Errorf("sin ubicación = %+v, se esperaba 8 con discrepancia", u)
+	}
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-project/events"
+	"go-project/tenancy"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Máximo de eventos pendientes que se reenvían al reanudar una transmisión;
+// si hay más se envía un aviso RESET
+const limiteReanudacion = 1000
+
+// Intervalo de los latidos que mantienen viva la conexión
+const intervaloLatido = 25 * time.Second
+
+type EventsHandler struct {
+	hub *events.Hub
+}
+
+func NewEventsHandler(hub *events.Hub) *EventsHandler {
+	return &EventsHandler{hub: hub}
+}
+
+// filtroEventos lee store_id y product_id de la consulta y limita los
+// eventos a la empresa de la petición
+func filtroEventos(r *http.Request) (events.Filter, error) {
+	var f events.Filter
+	empresa := tenancy.TenantFromContext(r.Context())
+	if empresa == uuid.Nil {
+		return f, fmt.Errorf("Empresa no identificada")
+	}
+	f.TenantID = &empresa
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return f, fmt.Errorf("ID de tienda inválido")
+		}
+		f.StoreID = &id
+	}
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return f, fmt.Errorf("ID de producto inválido")
+		}
+		f.ProductID = &id
+	}
+	return f, nil
+}
+
+// ultimoEventoRecibido toma el punto de reanudación del encabezado
+// Last-Event-ID (que el navegador envía al reconectar) o de last_event_id
+func ultimoEventoRecibido(r *http.Request) (int64, bool, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("ID de último evento inválido")
+	}
+	return id, true, nil
+}
+
+// suscribir se suscribe a los eventos en vivo y, si el cliente reanuda,
+// obtiene los eventos que se perdió. La suscripción se hace antes de consultar
+// para no perder eventos intermedios; los eventos en vivo hasta enviado ya
+// van entre los pendientes y se descartan.
+func (h *EventsHandler) suscribir(r *http.Request) (sub *events.Subscription, pendientes []events.Event, enviado int64, status int, err error) {
+	filtro, err := filtroEventos(r)
+	if err != nil {
+		return nil, nil, 0, http.StatusBadRequest, err
+	}
+	desde, reanudar, err := ultimoEventoRecibido(r)
+	if err != nil {
+		return nil, nil, 0, http.StatusBadRequest, err
+	}
+
+	sub = h.hub.Subscribe(filtro)
+	if !reanudar {
+		return sub, nil, 0, http.StatusOK, nil
+	}
+	pendientes, err = h.hub.Replay(filtro, desde, limiteReanudacion+1)
+	if err != nil {
+		sub.Close()
+		return nil, nil, 0, http.StatusInternalServerError, err
+	}
+	if len(pendientes) > limiteReanudacion {
+		// Demasiados eventos perdidos: el cliente recarga su estado y sigue
+		// con los eventos en vivo
+		return sub, []events.Event{events.Reset(sub.Desde)}, sub.Desde, http.StatusOK, nil
+	}
+	if n := len(pendientes); n > 0 {
+		enviado = pendientes[n-1].ID
+	}
+	return sub, pendientes, enviado, http.StatusOK, nil
+}
+
+// StreamEvents godoc
+// @Summary      Eventos de inventario (SSE)
+// @Description  Transmite los cambios de existencias y movimientos como Server-Sent Events. Con Last-Event-ID o last_event_id reenvía los eventos posteriores a ese ID antes de continuar en vivo; si son más de 1000 envía un evento RESET y el cliente debe recargar el estado
+// @Tags         eventos
+// @Produce      text/event-stream
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        product_id query string false "Filtrar por producto"
+// @Param        last_event_id query int false "Reanudar después de este evento"
+// @Param        Last-Event-ID header int false "Reanudar después de este evento"
+// @Success      200  {object}  events.Event
+// @Failure      400  {object}  map[string]string
+// @Router       /events/stream [get]
+func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "El servidor no soporta transmisión de eventos", http.StatusInternalServerError)
+		return
+	}
+
+	sub, pendientes, enviado, status, err := h.suscribir(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	enviar := func(e events.Event) bool {
+		datos, err := json.Marshal(e)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, datos); err != nil {
+			return false
+		}
+		return true
+	}
+
+	for _, e := range pendientes {
+		if !enviar(e) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	latido := time.NewTicker(intervaloLatido)
+	defer latido.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Desconectado por lento; el cliente reconecta con Last-Event-ID
+				return
+			}
+			if e.ID <= enviado {
+				continue
+			}
+			if !enviar(e) {
+				return
+			}
+			flusher.Flush()
+		case <-latido.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// StreamEventsWS godoc
+// @Summary      Eventos de inventario (WebSocket)
+// @Description  Igual que /events/stream pero sobre WebSocket; cada mensaje es un evento en JSON. Para reanudar se usa last_event_id
+// @Tags         eventos
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        product_id query string false "Filtrar por producto"
+// @Param        last_event_id query int false "Reanudar después de este evento"
+// @Success      101  {string}  string  "Cambio a WebSocket"
+// @Failure      400  {object}  map[string]string
+// @Router       /events/ws [get]
+func (h *EventsHandler) StreamEventsWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sub, pendientes, enviado, status, err := h.suscribir(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer sub.Close()
+
+	conn, err := events.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// El cliente no envía datos; leer sólo para atender ping y detectar el cierre
+	cerrado := make(chan struct{})
+	go func() {
+		defer close(cerrado)
+		for {
+			if _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	enviar := func(e events.Event) bool {
+		datos, err := json.Marshal(e)
+		if err != nil {
+			return false
+		}
+		if err := conn.WriteText(datos); err != nil {
+			log.Printf("Error al enviar evento por websocket: %v", err)
+			return false
+		}
+		return true
+	}
+
+	for _, e := range pendientes {
+		if !enviar(e) {
+			return
+		}
+	}
+
+	latido := time.NewTicker(intervaloLatido)
+	defer latido.Stop()
+	for {
+		select {
+		case <-cerrado:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if e.ID <= enviado {
+				continue
+			}
+			if !enviar(e) {
+				return
+			}
+		case <-latido.C:
+			if err := conn.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
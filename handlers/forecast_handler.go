@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-project/forecast"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PronosticoDemanda pronóstico de demanda diaria de un producto
+type PronosticoDemanda struct {
+	ProductID uuid.UUID  `json:"product_id"`
+	StoreID   *uuid.UUID `json:"store_id,omitempty"`
+	// moving_average o exp_smoothing
+	Model string `json:"model" example:"exp_smoothing"`
+	// Días de historia usados para ajustar el modelo
+	HistoryDays int `json:"history_days" example:"120"`
+	// Salidas totales dentro de la historia
+	HistoryTotal float64          `json:"history_total"`
+	Confidence   float64          `json:"confidence" example:"0.95"`
+	Points       []forecast.Point `json:"points"`
+	// Total pronosticado en el horizonte
+	Total float64 `json:"total"`
+}
+
+// BacktestDemanda error del modelo pronosticando días ya conocidos
+type BacktestDemanda struct {
+	ProductID   uuid.UUID         `json:"product_id"`
+	StoreID     *uuid.UUID        `json:"store_id,omitempty"`
+	Model       string            `json:"model" example:"moving_average"`
+	HistoryDays int               `json:"history_days" example:"120"`
+	HoldoutDays int               `json:"holdout_days" example:"28"`
+	Horizon     int               `json:"horizon" example:"7"`
+	Accuracy    forecast.Accuracy `json:"accuracy"`
+}
+
+type ForecastHandler struct {
+	db *sql.DB
+}
+
+func NewForecastHandler(db *sql.DB) *ForecastHandler {
+	return &ForecastHandler{db: db}
+}
+
+// parametrosPronostico parámetros comunes del pronóstico y del backtest
+type parametrosPronostico struct {
+	productID uuid.UUID
+	storeID   *uuid.UUID
+	model     forecast.Model
+	horizon   int
+	history   int
+}
+
+func leerEntero(r *http.Request, nombre string, predeterminado, min, max int) (int, error) {
+	v := r.URL.Query().Get(nombre)
+	if v == "" {
+		return predeterminado, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < min || n > max {
+		return 0, errors.New(nombre + " inválido, use un valor entre " + strconv.Itoa(min) + " y " + strconv.Itoa(max))
+	}
+	return n, nil
+}
+
+func leerFraccion(r *http.Request, nombre string, predeterminado float64) (float64, error) {
+	v := r.URL.Query().Get(nombre)
+	if v == "" {
+		return predeterminado, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 || f >= 1 {
+		return 0, errors.New(nombre + " inválido, use un valor entre 0 y 1")
+	}
+	return f, nil
+}
+
+func leerParametrosPronostico(r *http.Request) (parametrosPronostico, error) {
+	var p parametrosPronostico
+	var err error
+	if p.productID, err = uuid.Parse(r.URL.Query().Get("product_id")); err != nil {
+		return p, errors.New("ID de producto inválido")
+	}
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return p, errors.New("ID de tienda inválido")
+		}
+		p.storeID = &id
+	}
+	if p.horizon, err = leerEntero(r, "horizon", 14, 1, 90); err != nil {
+		return p, err
+	}
+	if p.history, err = leerEntero(r, "history", 120, 14, 730); err != nil {
+		return p, err
+	}
+
+	switch r.URL.Query().Get("model") {
+	case "", forecast.ModelExpSmoothing:
+		m := forecast.SeasonalSmoothing{Period: 7}
+		if m.Alpha, err = leerFraccion(r, "alpha", 0.3); err != nil {
+			return p, err
+		}
+		if m.Gamma, err = leerFraccion(r, "gamma", 0.2); err != nil {
+			return p, err
+		}
+		p.model = m
+	case forecast.ModelMovingAverage:
+		m := forecast.MovingAverage{}
+		if m.Window, err = leerEntero(r, "window", 7, 1, 90); err != nil {
+			return p, err
+		}
+		p.model = m
+	default:
+		return p, errors.New("Modelo inválido, use moving_average o exp_smoothing")
+	}
+	return p, nil
+}
+
+// GetDemandForecast godoc
+// @Summary      Pronóstico de demanda
+// @Description  Pronostica las salidas diarias de un producto (en una tienda o en todas) a partir de
+// @Description  la historia de movimientos OUT, con promedio móvil o suavizado exponencial con
+// @Description  estacionalidad semanal, y bandas de confianza
+// @Tags         pronosticos
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string true "ID del producto"
+// @Param        store_id query string false "ID de la tienda (todas si se omite)"
+// @Param        model query string false "moving_average o exp_smoothing (predeterminado)"
+// @Param        horizon query int false "Días a pronosticar (predeterminado 14)"
+// @Param        history query int false "Días de historia (predeterminado 120)"
+// @Param        window query int false "Ventana del promedio móvil (predeterminado 7)"
+// @Param        alpha query number false "Suavizado del nivel (predeterminado 0.3)"
+// @Param        gamma query number false "Suavizado estacional (predeterminado 0.2)"
+// @Param        confidence query number false "Confianza de la banda (predeterminado 0.95)"
+// @Success      200  {object}  PronosticoDemanda
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/forecast [get]
+func (h *ForecastHandler) GetDemandForecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, err := leerParametrosPronostico(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	confianza, err := leerFraccion(r, "confidence", 0.95)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serie, err := forecast.LoadDemand(h.db, p.productID, p.storeID, p.history, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	puntos, err := forecast.Forecast(p.model, serie, p.horizon, confianza)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := PronosticoDemanda{
+		ProductID:   p.productID,
+		StoreID:     p.storeID,
+		Model:       p.model.Name(),
+		HistoryDays: p.history,
+		Confidence:  confianza,
+		Points:      puntos,
+	}
+	for _, v := range serie.Values {
+		resp.HistoryTotal += v
+	}
+	for _, punto := range puntos {
+		resp.Total += punto.Value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetForecastBacktest godoc
+// @Summary      Backtest del pronóstico de demanda
+// @Description  Pronostica con origen móvil los últimos holdout días de la historia y reporta el error
+// @Description  contra las salidas reales (MAE, RMSE, MAPE y sesgo)
+// @Tags         pronosticos
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string true "ID del producto"
+// @Param        store_id query string false "ID de la tienda (todas si se omite)"
+// @Param        model query string false "moving_average o exp_smoothing (predeterminado)"
+// @Param        horizon query int false "Días pronosticados desde cada origen (predeterminado 14)"
+// @Param        history query int false "Días de historia (predeterminado 120)"
+// @Param        holdout query int false "Días evaluados al final de la historia (predeterminado 28)"
+// @Param        window query int false "Ventana del promedio móvil (predeterminado 7)"
+// @Param        alpha query number false "Suavizado del nivel (predeterminado 0.3)"
+// @Param        gamma query number false "Suavizado estacional (predeterminado 0.2)"
+// @Success      200  {object}  BacktestDemanda
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/forecast/backtest [get]
+func (h *ForecastHandler) GetForecastBacktest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p, err := leerParametrosPronostico(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	holdout, err := leerEntero(r, "holdout", 28, 1, p.history-1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serie, err := forecast.LoadDemand(h.db, p.productID, p.storeID, p.history, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	precision, err := forecast.Backtest(p.model, serie, holdout, p.horizon)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BacktestDemanda{
+		ProductID:   p.productID,
+		StoreID:     p.storeID,
+		Model:       p.model.Name(),
+		HistoryDays: p.history,
+		HoldoutDays: holdout,
+		Horizon:     p.horizon,
+		Accuracy:    precision,
+	})
+}
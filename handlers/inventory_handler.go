@@ -42,6 +42,8 @@ type InventarioDetalle struct {
 	// Campos adicionales para mostrar información relacionada
 	ProductName string `json:"product_name"`
 	StoreName   string `json:"store_name"`
+	// Instante al que corresponde la existencia cuando se consulta con as_of
+	AsOf *time.Time `json:"as_of,omitempty"`
 }
 
 type InventoryHandler struct {
@@ -54,11 +56,14 @@ func NewInventoryHandler(db *sql.DB) *InventoryHandler {
 
 // ListarInventarios godoc
 // @Summary      Listar inventarios
-// @Description  Obtiene la lista de todos los inventarios activos
+// @Description  Obtiene la lista de todos los inventarios activos. Con as_of devuelve la existencia
+// @Description  de cada inventario en esa fecha, incluidos los que se eliminaron después.
 // @Tags         inventarios
 // @Accept       json
 // @Produce      json
+// @Param        as_of query string false "Fecha (cierre del día) o instante RFC3339"
 // @Success      200  {array}   InventarioDetalle
+// @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /ListarInventarios [get]
 func (h *InventoryHandler) ListarInventarios(w http.ResponseWriter, r *http.Request) {
@@ -67,17 +72,25 @@ func (h *InventoryHandler) ListarInventarios(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	query := `
         SELECT 
-            i.id, i.productId, i.storeId, i.quantity, i.minStock,
+            i.id, i.productId, i.storeId, COALESCE(e.quantity, i.quantity), i.minStock,
             i.activo, i.created_at, i.updated_at,
             p.name as product_name, t.name as store_name
         FROM prueba.inventarios i
         JOIN catalogos.productos p ON i.productId = p.id
         JOIN catalogos.tiendas t ON i.storeId = t.id
-        WHERE i.activo = true`
+        LEFT JOIN prueba.existencias_al($1::timestamp) e
+            ON e.productId = i.productId AND e.storeId = i.storeId
+        WHERE CASE WHEN $1::timestamp IS NULL THEN i.activo = true ELSE e.productId IS NOT NULL END`
 
-	rows, err := h.db.Query(query)
+	rows, err := h.db.Query(query, asOf)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -96,6 +109,7 @@ func (h *InventoryHandler) ListarInventarios(w http.ResponseWriter, r *http.Requ
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		i.AsOf = asOf
 		inventarios = append(inventarios, i)
 	}
 
@@ -246,6 +260,24 @@ func (h *InventoryHandler) ActualizarInventario(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	// Las existencias en conteo físico sólo se corrigen al aprobar el conteo
+	var enConteo bool
+	err = h.db.QueryRow(`
+        SELECT EXISTS(
+            SELECT 1 FROM prueba.inventarios i
+            JOIN prueba.conteos c ON c.storeId = i.storeId AND c.status = 'OPEN' AND c.activo = true
+            JOIN prueba.conteoslineas l ON l.countId = c.id AND l.productId = i.productId
+            WHERE i.id = $1)
+    `, id).Scan(&enConteo)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if enConteo {
+		http.Error(w, errProductoEnConteo.Error(), http.StatusBadRequest)
+		return
+	}
+
 	result, err := h.db.Exec(`
         UPDATE prueba.inventarios 
         SET quantity = $1, minStock = $2, updated_at = CURRENT_TIMESTAMP
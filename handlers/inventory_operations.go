@@ -1,8 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"fmt"
+	"go-project/alerts"
+	"go-project/outbox"
+	"go-project/utils"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
@@ -10,10 +16,19 @@ import (
 
 // StockTransfer modelo para transferencia de stock
 type StockTransfer struct {
-	ProductID     uuid.UUID `json:"product_id" binding:"required"`
+	ProductID uuid.UUID `json:"product_id"`
+	// Código de barras escaneado; puede usarse en lugar de product_id
+	Barcode       string    `json:"barcode,omitempty" example:"7501031311309"`
 	SourceStoreID uuid.UUID `json:"source_store_id" binding:"required"`
 	TargetStoreID uuid.UUID `json:"target_store_id" binding:"required"`
 	Quantity      int       `json:"quantity" binding:"required,gt=0"`
+	// Lote específico a transferir; si se omite se aplica FEFO
+	LotNumber string `json:"lot_number,omitempty"`
+	// Números de serie transferidos; requeridos en productos con control por serie
+	Serials []string `json:"serials,omitempty"`
+	// Ubicaciones de origen y destino dentro de cada tienda
+	SourceLocationID *uuid.UUID `json:"source_location_id,omitempty"`
+	TargetLocationID *uuid.UUID `json:"target_location_id,omitempty"`
 }
 
 // StockAlert modelo para alertas de stock
@@ -25,15 +40,27 @@ type StockAlert struct {
 	Quantity    int       `json:"current_quantity"`
 	MinStock    int       `json:"min_stock"`
 	Alert_Type  string    `json:"alert_type"`
+	// INFO, WARNING o CRITICAL
+	Severity string `json:"severity" example:"WARNING"`
+	// Regla configurable que generó la alerta (vacío en STOCK_BAJO y SIN_STOCK)
+	RuleID   *uuid.UUID `json:"rule_id,omitempty"`
+	RuleName string     `json:"rule_name,omitempty"`
+	Message  string     `json:"message,omitempty"`
+	// Valor medido por la regla (días de cobertura, unidades, días sin movimiento o factor)
+	Value *float64 `json:"value,omitempty"`
 }
 
 // GetStoreInventory godoc
 // @Summary      Listar inventario por tienda
-// @Description  Obtiene el inventario completo de una tienda específica
+// @Description  Obtiene el inventario completo de una tienda específica. Con breakdown=location
+// @Description  devuelve la existencia de cada producto por ubicación, incluyendo la mercancía sin ubicar.
+// @Description  Con as_of devuelve la existencia en esa fecha a partir de la fotografía diaria más cercana.
 // @Tags         inventario
 // @Accept       json
 // @Produce      json
 // @Param        id path string true "ID de la tienda"
+// @Param        breakdown query string false "location para desglosar por ubicación"
+// @Param        as_of query string false "Fecha (cierre del día) o instante RFC3339"
 // @Success      200  {array}   InventarioDetalle
 // @Failure      404  {object}  map[string]string
 // @Router       /stores/{id}/inventory [get]
@@ -53,19 +80,48 @@ func (h *InventoryHandler) GetStoreInventory(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	asOf, err := parseAsOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.URL.Query().Get("breakdown") {
+	case "":
+	case "location":
+		if asOf != nil {
+			http.Error(w, "El desglose por ubicación no admite as_of", http.StatusBadRequest)
+			return
+		}
+		detalle, err := existenciasPorUbicacion(h.db, storeUUID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(detalle)
+		return
+	default:
+		http.Error(w, "Desglose inválido", http.StatusBadRequest)
+		return
+	}
+
 	query := `
         SELECT 
-            i.id, i.productId, i.storeId, i.quantity, i.minStock,
+            i.id, i.productId, i.storeId, COALESCE(e.quantity, i.quantity) AS quantity, i.minStock,
             i.activo, i.created_at, i.updated_at,
             p.name as product_name,
             t.name as store_name
         FROM prueba.inventarios i
         JOIN catalogos.productos p ON i.productId = p.id
         JOIN catalogos.tiendas t ON i.storeId = t.id
-        WHERE t.Id = $1 AND i.activo = true
-        ORDER BY i.quantity ASC`
+        LEFT JOIN prueba.existencias_al($2::timestamp) e
+            ON e.productId = i.productId AND e.storeId = i.storeId
+        WHERE t.Id = $1
+          AND CASE WHEN $2::timestamp IS NULL THEN i.activo = true ELSE e.productId IS NOT NULL END
+        ORDER BY quantity ASC`
 
-	rows, err := h.db.Query(query, storeUUID)
+	rows, err := h.db.Query(query, storeUUID, asOf)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -84,6 +140,7 @@ func (h *InventoryHandler) GetStoreInventory(w http.ResponseWriter, r *http.Requ
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		i.AsOf = asOf
 		inventarios = append(inventarios, i)
 	}
 
@@ -108,15 +165,23 @@ func (h *InventoryHandler) TransferInventory(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Llamar a la función de la BD
-	var result bool
-	err := h.db.QueryRow(`
-        SELECT transfer_inventory($1, $2, $3, $4)
-    `, transfer.ProductID, transfer.SourceStoreID,
-		transfer.TargetStoreID, transfer.Quantity).Scan(&result)
+	// En productos con número de serie la cantidad se deriva de las series
+	if transfer.Quantity == 0 && len(transfer.Serials) > 0 {
+		transfer.Quantity = len(transfer.Serials)
+	}
+
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		_, err := ejecutarTransferencia(tx, &transfer)
+		return err
+	})
+
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responderErrorInterno(w, err)
 		return
 	}
 
@@ -126,27 +191,161 @@ func (h *InventoryHandler) TransferInventory(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// ejecutarTransferencia aplica una transferencia dentro de la transacción:
+// existencias, ubicaciones, lotes, series, capas de costo y evento de dominio
+func ejecutarTransferencia(tx *sql.Tx, transfer *StockTransfer) (uuid.UUID, error) {
+	// Resolver el producto a partir del código de barras escaneado
+	productID, err := resolverProducto(tx, transfer.ProductID, transfer.Barcode)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	transfer.ProductID = productID
+
+	// Los productos en conteo físico no admiten movimientos
+	for _, tienda := range []uuid.UUID{transfer.SourceStoreID, transfer.TargetStoreID} {
+		if err := verificarSinConteo(tx, transfer.ProductID, tienda); err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	// Llamar a la función de la BD
+	var movementID uuid.UUID
+	err = tx.QueryRow(`
+        SELECT transfer_inventory($1, $2, $3, $4)
+    `, transfer.ProductID, transfer.SourceStoreID,
+		transfer.TargetStoreID, transfer.Quantity).Scan(&movementID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// Mover entre ubicaciones de las tiendas origen y destino
+	mov := CrearMovimiento{
+		ProductID: transfer.ProductID, SourceStoreID: transfer.SourceStoreID,
+		TargetStoreID: transfer.TargetStoreID, Quantity: transfer.Quantity,
+		SourceLocationID: transfer.SourceLocationID, TargetLocationID: transfer.TargetLocationID,
+	}
+	if err := aplicarUbicaciones(tx, mov); err != nil {
+		return uuid.Nil, err
+	}
+	if err := verificarSinUbicar(tx, transfer.ProductID, transfer.SourceStoreID); err != nil {
+		return uuid.Nil, err
+	}
+	if _, err := tx.Exec(`
+        UPDATE prueba.movimientos SET sourceLocationId = $2, targetLocationId = $3 WHERE id = $1
+    `, movementID, transfer.SourceLocationID, transfer.TargetLocationID); err != nil {
+		return uuid.Nil, err
+	}
+
+	// Trasladar los lotes de productos con caducidad
+	controlaLotes, err := productoControlaLotes(tx, transfer.ProductID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if controlaLotes {
+		if _, err := trasladarLotes(tx, transfer.ProductID, transfer.SourceStoreID,
+			transfer.TargetStoreID, movementID, transfer.LotNumber, transfer.Quantity); err != nil {
+			return uuid.Nil, err
+		}
+	} else if transfer.LotNumber != "" {
+		return uuid.Nil, fmt.Errorf("%w: el producto no controla lotes", errLoteInvalido)
+	}
+
+	// Cambiar de tienda las unidades con número de serie
+	controlaSeries, err := productoControlaSeries(tx, transfer.ProductID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if controlaSeries {
+		if err := validarSeries(transfer.Serials, transfer.Quantity); err != nil {
+			return uuid.Nil, err
+		}
+		if err := moverSeries(tx, EfectoTRANSFER, transfer.ProductID, transfer.SourceStoreID,
+			transfer.TargetStoreID, movementID, transfer.Serials); err != nil {
+			return uuid.Nil, err
+		}
+	} else if len(transfer.Serials) > 0 {
+		return uuid.Nil, fmt.Errorf("%w: el producto no controla números de serie", errSerieInvalida)
+	}
+
+	// Trasladar el costo de la mercancía a la tienda destino
+	if err := trasladarCapas(tx, transfer.ProductID, transfer.SourceStoreID,
+		transfer.TargetStoreID, &movementID, transfer.Quantity, time.Now()); err != nil {
+		return uuid.Nil, err
+	}
+
+	// Evento de dominio de la transferencia completa (lotes y series incluidos)
+	return movementID, outbox.Record(tx, "movement", movementID, "inventory.transferred", map[string]interface{}{
+		"movement_id":     movementID,
+		"product_id":      transfer.ProductID,
+		"source_store_id": transfer.SourceStoreID,
+		"target_store_id": transfer.TargetStoreID,
+		"quantity":        transfer.Quantity,
+		"lot_number":      transfer.LotNumber,
+		"serials":         transfer.Serials,
+	})
+}
+
 // GetStockAlerts godoc
-// @Summary      Listar alertas de stock bajo
-// @Description  Obtiene una lista de productos que están por debajo del stock mínimo
+// @Summary      Listar alertas de stock
+// @Description  Obtiene los productos por debajo del stock mínimo junto con las alertas activas
+// @Description  de las reglas configurables (cobertura, sobrestock, stock sin movimiento y picos de salidas)
 // @Tags         inventario
 // @Accept       json
 // @Produce      json
+// @Param        min_severity query string false "Severidad mínima: INFO, WARNING o CRITICAL"
+// @Param        store_id query string false "Filtrar por tienda"
 // @Success      200  {array}   StockAlert
+// @Failure      400  {object}  map[string]string
 // @Router       /inventory/alerts [get]
 func (h *InventoryHandler) GetStockAlerts(w http.ResponseWriter, r *http.Request) {
-	query := `SELECT * FROM prueba.vw_inventory_alerts ORDER BY quantity ASC`
+	minSeveridad := 0
+	if v := r.URL.Query().Get("min_severity"); v != "" {
+		if minSeveridad = alerts.SeverityRank(v); minSeveridad == 0 {
+			http.Error(w, "Severidad inválida, use INFO, WARNING o CRITICAL", http.StatusBadRequest)
+			return
+		}
+	}
+	var storeID *uuid.UUID
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	query := `
+        SELECT * FROM (
+            SELECT v.productId, v.storeId, v.product_name, v.store_name, v.quantity, v.minStock, v.alert_type,
+                   CASE WHEN v.alert_type = 'SIN_STOCK' THEN 'CRITICAL' ELSE 'WARNING' END AS severity,
+                   NULL::uuid AS rule_id, NULL::varchar AS rule_name, NULL::text AS message, NULL::numeric AS value
+            FROM prueba.vw_inventory_alerts v
+            UNION ALL
+            SELECT res.productId, res.storeId, p.name, t.name, i.quantity, i.minStock, g.type,
+                   g.severity, g.id, g.name, res.message, res.value
+            FROM prueba.reglasalertasresultados res
+                JOIN prueba.reglasalertas g ON g.id = res.ruleId
+                JOIN catalogos.productos p ON p.id = res.productId
+                JOIN catalogos.tiendas t ON t.id = res.storeId
+                JOIN prueba.inventarios i ON i.productId = res.productId AND i.storeId = res.storeId
+            WHERE res.status = 'ACTIVE' AND g.activo = true
+        ) a
+        WHERE ($1::uuid IS NULL OR a.storeId = $1)
+        ORDER BY CASE a.severity WHEN 'CRITICAL' THEN 3 WHEN 'WARNING' THEN 2 ELSE 1 END DESC,
+                 a.quantity ASC`
 
-	rows, err := h.db.Query(query)
+	rows, err := h.db.Query(query, storeID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var alerts []StockAlert
+	var lista []StockAlert
 	for rows.Next() {
 		var alert StockAlert
+		var ruleName, message sql.NullString
 		err := rows.Scan(
 			&alert.ProductID,
 			&alert.StoreID,
@@ -155,14 +354,23 @@ func (h *InventoryHandler) GetStockAlerts(w http.ResponseWriter, r *http.Request
 			&alert.Quantity,
 			&alert.MinStock,
 			&alert.Alert_Type,
+			&alert.Severity,
+			&alert.RuleID,
+			&ruleName,
+			&message,
+			&alert.Value,
 		)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		alerts = append(alerts, alert)
+		if alerts.SeverityRank(alert.Severity) < minSeveridad {
+			continue
+		}
+		alert.RuleName, alert.Message = ruleName.String, message.String
+		lista = append(lista, alert)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(alerts)
+	json.NewEncoder(w).Encode(lista)
 }
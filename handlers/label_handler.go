@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go-project/barcode"
+	"go-project/money"
+	"go-project/pdf"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Simbologías disponibles para etiquetas
+const (
+	SimbologiaCode128 = "code128"
+	SimbologiaQR      = "qr"
+)
+
+// Hoja de etiquetas tamaño carta de 3 x 10 (2.625" x 1"), en puntos
+const (
+	etiquetaColumnas = 3
+	etiquetaFilas    = 10
+	etiquetaAncho    = 189.0
+	etiquetaAlto     = 72.0
+	etiquetaPasoX    = 198.0
+	etiquetaMargenX  = 13.5
+	etiquetaMargenY  = 36.0
+	etiquetaRelleno  = 6.0
+)
+
+// HojaEtiquetas solicitud de etiquetas de anaquel
+type HojaEtiquetas struct {
+	// Productos a etiquetar; si se omiten se etiqueta todo el inventario de la tienda
+	ProductIDs []uuid.UUID `json:"product_ids,omitempty"`
+	// Tienda cuyos precios se imprimen (lista propia de la tienda o menudeo)
+	StoreID *uuid.UUID `json:"store_id,omitempty"`
+	// code128 (predeterminado) o qr
+	Symbology string `json:"symbology,omitempty" example:"code128"`
+	// Copias por producto
+	Copies int `json:"copies,omitempty" example:"1"`
+}
+
+// etiquetaProducto datos impresos en una etiqueta
+type etiquetaProducto struct {
+	Name  string
+	SKU   string
+	Price money.Money
+}
+
+type LabelHandler struct {
+	db *sql.DB
+}
+
+func NewLabelHandler(db *sql.DB) *LabelHandler {
+	return &LabelHandler{db: db}
+}
+
+// GetBarcodeImage godoc
+// @Summary      Imagen de código de barras
+// @Description  Genera el código Code 128 o QR del SKU de un producto como PNG o SVG
+// @Tags         etiquetas
+// @Produce      png
+// @Produce      image/svg+xml
+// @Param        sku query string false "SKU del producto"
+// @Param        product_id query string false "ID del producto (alternativa al SKU)"
+// @Param        symbology query string false "code128 (predeterminado) o qr"
+// @Param        format query string false "png (predeterminado) o svg"
+// @Param        scale query int false "Pixeles por módulo (predeterminado 2 en Code 128, 6 en QR)"
+// @Param        height query int false "Alto de las barras en pixeles para Code 128 (predeterminado 80)"
+// @Success      200  {file}  file
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /labels/barcode [get]
+func (h *LabelHandler) GetBarcodeImage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var sku string
+	var err error
+	switch {
+	case q.Get("sku") != "":
+		err = h.db.QueryRow(`SELECT sku FROM catalogos.productos WHERE sku = $1`, q.Get("sku")).Scan(&sku)
+	case q.Get("product_id") != "":
+		id, errID := uuid.Parse(q.Get("product_id"))
+		if errID != nil {
+			http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+			return
+		}
+		err = h.db.QueryRow(`SELECT sku FROM catalogos.productos WHERE id = $1`, id).Scan(&sku)
+	default:
+		http.Error(w, "Se requiere sku o product_id", http.StatusBadRequest)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Producto no encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	simbologia := q.Get("symbology")
+	if simbologia == "" {
+		simbologia = SimbologiaCode128
+	}
+	simbolo, err := generarSimbolo(simbologia, sku)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	escala := 2
+	if !simbolo.Linear() {
+		escala = 6
+	}
+	alto := 80
+	for param, destino := range map[string]*int{"scale": &escala, "height": &alto} {
+		if v := q.Get(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 1000 {
+				http.Error(w, fmt.Sprintf("Parámetro %s inválido", param), http.StatusBadRequest)
+				return
+			}
+			*destino = n
+		}
+	}
+
+	switch q.Get("format") {
+	case "", "png":
+		w.Header().Set("Content-Type", "image/png")
+		err = barcode.WritePNG(w, simbolo, escala, alto)
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		err = barcode.WriteSVG(w, simbolo, escala, alto)
+	default:
+		http.Error(w, "Formato inválido", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CreateLabelSheet godoc
+// @Summary      Hoja de etiquetas PDF
+// @Description  Genera una hoja tamaño carta de 30 etiquetas (3 x 10) con nombre, SKU, precio vigente y código de barras, para una lista de productos o todo el inventario de una tienda
+// @Tags         etiquetas
+// @Accept       json
+// @Produce      application/pdf
+// @Param        hoja body HojaEtiquetas true "Productos a etiquetar"
+// @Success      200  {file}  file
+// @Failure      400  {object}  map[string]string
+// @Router       /labels/sheet [post]
+func (h *LabelHandler) CreateLabelSheet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var hoja HojaEtiquetas
+	if err := json.NewDecoder(r.Body).Decode(&hoja); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if len(hoja.ProductIDs) == 0 && hoja.StoreID == nil {
+		http.Error(w, "Se requieren product_ids o store_id", http.StatusBadRequest)
+		return
+	}
+	if hoja.Symbology == "" {
+		hoja.Symbology = SimbologiaCode128
+	}
+	if hoja.Symbology != SimbologiaCode128 && hoja.Symbology != SimbologiaQR {
+		http.Error(w, "Simbología inválida", http.StatusBadRequest)
+		return
+	}
+	if hoja.Copies <= 0 {
+		hoja.Copies = 1
+	}
+
+	etiquetas, err := h.cargarEtiquetas(hoja)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Producto no encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(etiquetas) == 0 {
+		http.Error(w, "No hay productos para etiquetar", http.StatusBadRequest)
+		return
+	}
+
+	doc := pdf.New(pdf.Letter)
+	for i, e := range etiquetas {
+		posicion := i % (etiquetaColumnas * etiquetaFilas)
+		if posicion == 0 {
+			doc.AddPage()
+		}
+		x := etiquetaMargenX + float64(posicion%etiquetaColumnas)*etiquetaPasoX
+		y := etiquetaMargenY + float64(posicion/etiquetaColumnas)*etiquetaAlto
+		if err := dibujarEtiqueta(doc, x, y, hoja.Symbology, e); err != nil {
+			http.Error(w, fmt.Sprintf("SKU %s: %v", e.SKU, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="etiquetas.pdf"`)
+	doc.WriteTo(w)
+}
+
+// cargarEtiquetas obtiene nombre, SKU y precio vigente de cada producto,
+// repetidos según el número de copias
+func (h *LabelHandler) cargarEtiquetas(hoja HojaEtiquetas) ([]etiquetaProducto, error) {
+	ids := hoja.ProductIDs
+	if len(ids) == 0 {
+		rows, err := h.db.Query(`
+            SELECT i.productId
+            FROM prueba.inventarios i
+            JOIN catalogos.productos p ON i.productId = p.id
+            WHERE i.storeId = $1 AND i.activo = true AND p.activo = true
+            ORDER BY p.category, p.name`, *hoja.StoreID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	ahora := time.Now()
+	var etiquetas []etiquetaProducto
+	for _, id := range ids {
+		var e etiquetaProducto
+		err := h.db.QueryRow(`
+            SELECT name, sku, price, currency FROM catalogos.productos WHERE id = $1
+        `, id).Scan(&e.Name, &e.SKU, &e.Price.Amount, &e.Price.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		precio, err := precioVigente(h.db, id, uuid.Nil, hoja.StoreID, ahora)
+		if err == nil {
+			e.Price = precio.Price
+		} else if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		for c := 0; c < hoja.Copies; c++ {
+			etiquetas = append(etiquetas, e)
+		}
+	}
+	return etiquetas, nil
+}
+
+// generarSimbolo codifica el texto en la simbología indicada
+func generarSimbolo(simbologia, texto string) (*barcode.Symbol, error) {
+	switch simbologia {
+	case SimbologiaCode128:
+		return barcode.Code128(texto)
+	case SimbologiaQR:
+		return barcode.QR(texto)
+	}
+	return nil, fmt.Errorf("simbología inválida: %s", simbologia)
+}
+
+// dibujarEtiqueta dibuja una etiqueta con esquina superior izquierda en (x, y).
+// Con Code 128 el código ocupa la parte inferior; con QR va a la izquierda.
+func dibujarEtiqueta(doc *pdf.Document, x, y float64, simbologia string, e etiquetaProducto) error {
+	simbolo, err := generarSimbolo(simbologia, e.SKU)
+	if err != nil {
+		return err
+	}
+
+	doc.StrokeRect(x, y, etiquetaAncho, etiquetaAlto, 0.25)
+	interiorX, interiorY := x+etiquetaRelleno, y+etiquetaRelleno
+	ancho := etiquetaAncho - 2*etiquetaRelleno
+	alto := etiquetaAlto - 2*etiquetaRelleno
+
+	textoX, textoAncho := interiorX, ancho
+	if !simbolo.Linear() {
+		lado := alto
+		modulo := lado / float64(simbolo.Cols+2*simbolo.QuietZone)
+		dibujarModulos(doc, simbolo, interiorX, interiorY, modulo, modulo)
+		textoX += lado + etiquetaRelleno
+		textoAncho -= lado + etiquetaRelleno
+	}
+
+	precio := e.Price.String()
+	doc.Text(textoX, interiorY+8, 8, true, pdf.Fit(e.Name, 8, true, textoAncho))
+	doc.Text(textoX, interiorY+18, 7, false, pdf.Fit("SKU "+e.SKU, 7, false, textoAncho/2))
+	doc.Text(textoX+textoAncho-pdf.TextWidth(precio, 10, true), interiorY+19, 10, true, precio)
+
+	if simbolo.Linear() {
+		barrasY := interiorY + 24
+		modulo := ancho / float64(simbolo.Cols+2*simbolo.QuietZone)
+		if modulo > 1 {
+			modulo = 1
+		}
+		// Centrar el código en la etiqueta
+		margen := (ancho - modulo*float64(simbolo.Cols)) / 2
+		dibujarModulos(doc, simbolo, interiorX+margen-modulo*float64(simbolo.QuietZone), barrasY,
+			modulo, alto-24)
+	}
+	return nil
+}
+
+// dibujarModulos dibuja los módulos oscuros del símbolo (incluida su zona de
+// silencio a partir de x, y), uniendo los contiguos de cada fila
+func dibujarModulos(doc *pdf.Document, s *barcode.Symbol, x, y, modulo, altoFila float64) {
+	if !s.Linear() {
+		altoFila = modulo
+		y += modulo * float64(s.QuietZone)
+	}
+	x += modulo * float64(s.QuietZone)
+	for fila := 0; fila < s.Rows; fila++ {
+		for col := 0; col < s.Cols; {
+			if !s.Dark(col, fila) {
+				col++
+				continue
+			}
+			inicio := col
+			for col < s.Cols && s.Dark(col, fila) {
+				col++
+			}
+			doc.Rect(x+float64(inicio)*modulo, y+float64(fila)*altoFila, float64(col-inicio)*modulo, altoFila)
+		}
+	}
+}
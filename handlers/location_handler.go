@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errUbicacionInvalida indica un error en las ubicaciones de un movimiento
+var errUbicacionInvalida = errors.New("Ubicación inválida")
+
+// TipoUbicacion nivel de una ubicación dentro de la tienda
+type TipoUbicacion string
+
+const (
+	UbicacionZONE  TipoUbicacion = "ZONE"
+	UbicacionAISLE TipoUbicacion = "AISLE"
+	UbicacionBIN   TipoUbicacion = "BIN"
+)
+
+// padreEsperado tipo de ubicación que debe contener a cada nivel
+var padreEsperado = map[TipoUbicacion]TipoUbicacion{
+	UbicacionAISLE: UbicacionZONE,
+	UbicacionBIN:   UbicacionAISLE,
+}
+
+// Ubicacion zona, pasillo o contenedor dentro de una tienda
+// @Description Ubicación dentro de una tienda
+type Ubicacion struct {
+	ID        uuid.UUID     `json:"id"`
+	StoreID   uuid.UUID     `json:"store_id"`
+	ParentID  *uuid.UUID    `json:"parent_id,omitempty"`
+	Type      TipoUbicacion `json:"type" example:"BIN"`
+	Code      string        `json:"code" example:"BOD-A-03"`
+	Name      string        `json:"name" example:"Bodega, pasillo A, contenedor 3"`
+	Activo    bool          `json:"activo"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// CrearUbicacion modelo para crear una ubicación
+type CrearUbicacion struct {
+	StoreID  uuid.UUID     `json:"store_id" binding:"required"`
+	ParentID *uuid.UUID    `json:"parent_id,omitempty"`
+	Type     TipoUbicacion `json:"type" example:"BIN" binding:"required"`
+	Code     string        `json:"code" example:"BOD-A-03" binding:"required"`
+	Name     string        `json:"name" example:"Bodega, pasillo A, contenedor 3"`
+}
+
+// InventarioUbicacionDetalle existencia de un producto en una ubicación; sin
+// ubicación corresponde a la mercancía de la tienda aún no ubicada
+type InventarioUbicacionDetalle struct {
+	ProductID    uuid.UUID      `json:"product_id"`
+	ProductName  string         `json:"product_name"`
+	StoreID      uuid.UUID      `json:"store_id"`
+	LocationID   *uuid.UUID     `json:"location_id,omitempty"`
+	LocationCode *string        `json:"location_code,omitempty" example:"BOD-A-03"`
+	LocationType *TipoUbicacion `json:"location_type,omitempty" example:"BIN"`
+	Quantity     int            `json:"quantity" example:"12"`
+}
+
+type LocationHandler struct {
+	db *sql.DB
+}
+
+func NewLocationHandler(db *sql.DB) *LocationHandler {
+	return &LocationHandler{db: db}
+}
+
+// ListarUbicaciones godoc
+// @Summary      Listar ubicaciones
+// @Description  Obtiene las ubicaciones activas de una tienda
+// @Tags         ubicaciones
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string true "ID de la tienda"
+// @Success      200  {array}   Ubicacion
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarUbicaciones [get]
+func (h *LocationHandler) ListarUbicaciones(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	storeID, err := uuid.Parse(r.URL.Query().Get("store_id"))
+	if err != nil {
+		http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, storeId, parentId, type, code, COALESCE(name, ''), activo, created_at, updated_at
+        FROM catalogos.ubicaciones
+        WHERE storeId = $1 AND activo = true
+        ORDER BY code
+    `, storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var ubicaciones []Ubicacion
+	for rows.Next() {
+		var u Ubicacion
+		err := rows.Scan(&u.ID, &u.StoreID, &u.ParentID, &u.Type, &u.Code, &u.Name,
+			&u.Activo, &u.CreatedAt, &u.UpdatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ubicaciones = append(ubicaciones, u)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ubicaciones)
+}
+
+// CrearUbicacion godoc
+// @Summary      Crear ubicación
+// @Description  Crea una zona, un pasillo dentro de una zona o un contenedor dentro de un pasillo
+// @Tags         ubicaciones
+// @Accept       json
+// @Produce      json
+// @Param        ubicacion body CrearUbicacion true "Datos de la ubicación"
+// @Success      201  {object}  Ubicacion
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearUbicacion [post]
+func (h *LocationHandler) CrearUbicacion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var u CrearUbicacion
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+
+	if u.Code == "" {
+		http.Error(w, "El código es requerido", http.StatusBadRequest)
+		return
+	}
+	if u.Type != UbicacionZONE && u.Type != UbicacionAISLE && u.Type != UbicacionBIN {
+		http.Error(w, "Tipo de ubicación inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Validar la jerarquía zona / pasillo / contenedor
+	var padre *Ubicacion
+	if u.ParentID != nil {
+		var p Ubicacion
+		err := h.db.QueryRow(`
+            SELECT id, storeId, type FROM catalogos.ubicaciones WHERE id = $1 AND activo = true
+        `, *u.ParentID).Scan(&p.ID, &p.StoreID, &p.Type)
+		if err != nil && err != sql.ErrNoRows {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err == nil {
+			padre = &p
+		}
+	}
+	if err := validarJerarquia(u, padre); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ubicacion Ubicacion
+	err := h.db.QueryRow(`
+        INSERT INTO catalogos.ubicaciones (id, storeId, parentId, type, code, name, activo)
+        VALUES ($1, $2, $3, $4, $5, $6, true)
+        RETURNING id, storeId, parentId, type, code, COALESCE(name, ''), activo, created_at, updated_at
+    `, uuid.New(), u.StoreID, u.ParentID, u.Type, u.Code, u.Name).Scan(
+		&ubicacion.ID, &ubicacion.StoreID, &ubicacion.ParentID, &ubicacion.Type,
+		&ubicacion.Code, &ubicacion.Name, &ubicacion.Activo,
+		&ubicacion.CreatedAt, &ubicacion.UpdatedAt)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ubicacion)
+}
+
+// EliminarUbicacion godoc
+// @Summary      Eliminar ubicación
+// @Description  Desactiva una ubicación sin existencias
+// @Tags         ubicaciones
+// @Accept       json
+// @Produce      json
+// @Param        id query string true "ID de la ubicación"
+// @Success      204  "No Content"
+// @Failure      404  {object}  map[string]string
+// @Router       /EliminarUbicacion [delete]
+func (h *LocationHandler) EliminarUbicacion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var ocupada bool
+	err = h.db.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM prueba.inventariosubicaciones WHERE locationId = $1 AND quantity > 0)
+    `, id).Scan(&ocupada)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ocupada {
+		http.Error(w, "La ubicación tiene existencias", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+        UPDATE catalogos.ubicaciones SET activo = false, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND activo = true
+    `, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		http.Error(w, "Ubicación no encontrada", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validarJerarquia verifica que la ubicación cuelgue del nivel que le
+// corresponde: las zonas no tienen padre, los pasillos van dentro de una zona
+// y los contenedores dentro de un pasillo de la misma tienda. padre es nil si
+// no se indicó o no existe.
+func validarJerarquia(u CrearUbicacion, padre *Ubicacion) error {
+	esperado, requierePadre := padreEsperado[u.Type]
+	if !requierePadre {
+		if u.ParentID != nil {
+			return fmt.Errorf("%w: las zonas no tienen ubicación padre", errUbicacionInvalida)
+		}
+		return nil
+	}
+	if u.ParentID == nil {
+		return fmt.Errorf("%w: una ubicación %s requiere una ubicación padre %s", errUbicacionInvalida, u.Type, esperado)
+	}
+	if padre == nil || padre.Type != esperado || padre.StoreID != u.StoreID {
+		return fmt.Errorf("%w: la ubicación padre debe ser %s de la misma tienda", errUbicacionInvalida, esperado)
+	}
+	return nil
+}
+
+// ajustarUbicacion suma delta a la existencia de un producto en una ubicación,
+// verificando que la ubicación pertenezca a la tienda
+func ajustarUbicacion(tx *sql.Tx, productID, storeID, locationID uuid.UUID, delta int) error {
+	var tienda uuid.UUID
+	err := tx.QueryRow(`
+        SELECT storeId FROM catalogos.ubicaciones WHERE id = $1 AND activo = true
+    `, locationID).Scan(&tienda)
+	if err == sql.ErrNoRows || (err == nil && tienda != storeID) {
+		return fmt.Errorf("%w: la ubicación %s no pertenece a la tienda", errUbicacionInvalida, locationID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if delta >= 0 {
+		_, err := tx.Exec(`
+            INSERT INTO prueba.inventariosubicaciones (id, productId, storeId, locationId, quantity)
+            VALUES ($1, $2, $3, $4, $5)
+            ON CONFLICT (productId, locationId) DO
+            UPDATE SET quantity = prueba.inventariosubicaciones.quantity + EXCLUDED.quantity
+        `, uuid.New(), productID, storeID, locationID, delta)
+		return err
+	}
+
+	result, err := tx.Exec(`
+        UPDATE prueba.inventariosubicaciones SET quantity = quantity + $3
+        WHERE productId = $1 AND locationId = $2 AND quantity + $3 >= 0
+    `, productID, locationID, delta)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("%w en la ubicación de origen", errStockInsuficiente)
+	}
+	return nil
+}
+
+// verificarSinUbicar comprueba que lo ubicado no exceda la existencia de la
+// tienda, lo que ocurre si una salida sin ubicación toma mercancía ya ubicada
+func verificarSinUbicar(tx *sql.Tx, productID, storeID uuid.UUID) error {
+	var excede bool
+	err := tx.QueryRow(`
+        SELECT COALESCE((SELECT SUM(quantity) FROM prueba.inventariosubicaciones
+                         WHERE productId = $1 AND storeId = $2), 0)
+             > COALESCE((SELECT quantity FROM prueba.inventarios
+                         WHERE productId = $1 AND storeId = $2 AND activo = true), 0)
+    `, productID, storeID).Scan(&excede)
+	if err != nil {
+		return err
+	}
+	if excede {
+		return fmt.Errorf("%w: la mercancía sin ubicar no es suficiente, indique la ubicación", errUbicacionInvalida)
+	}
+	return nil
+}
+
+// existenciasPorUbicacion obtiene el desglose por ubicación del inventario de una tienda
+func existenciasPorUbicacion(db *sql.DB, storeID uuid.UUID) ([]InventarioUbicacionDetalle, error) {
+	rows, err := db.Query(`
+        SELECT iu.productId, p.name, iu.storeId, iu.locationId, u.code, u.type, iu.quantity
+        FROM prueba.inventariosubicaciones iu
+        JOIN catalogos.productos p ON iu.productId = p.id
+        JOIN catalogos.ubicaciones u ON iu.locationId = u.id
+        WHERE iu.storeId = $1 AND iu.quantity > 0
+        UNION ALL
+        SELECT i.productId, p.name, i.storeId, NULL, NULL, NULL,
+               i.quantity - COALESCE(SUM(iu.quantity), 0)
+        FROM prueba.inventarios i
+        JOIN catalogos.productos p ON i.productId = p.id
+        LEFT JOIN prueba.inventariosubicaciones iu
+               ON iu.productId = i.productId AND iu.storeId = i.storeId
+        WHERE i.storeId = $1 AND i.activo = true
+        GROUP BY i.productId, p.name, i.storeId, i.quantity
+        HAVING i.quantity - COALESCE(SUM(iu.quantity), 0) > 0
+        ORDER BY 2, 5 NULLS LAST`, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var detalle []InventarioUbicacionDetalle
+	for rows.Next() {
+		var d InventarioUbicacionDetalle
+		if err := rows.Scan(&d.ProductID, &d.ProductName, &d.StoreID, &d.LocationID,
+			&d.LocationCode, &d.LocationType, &d.Quantity); err != nil {
+			return nil, err
+		}
+		detalle = append(detalle, d)
+	}
+	return detalle, rows.Err()
+}
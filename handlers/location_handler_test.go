@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidarJerarquia(t *testing.T) {
+	tienda, otraTienda := uuid.New(), uuid.New()
+	zona := Ubicacion{ID: uuid.New(), StoreID: tienda, Type: UbicacionZONE}
+	pasillo := Ubicacion{ID: uuid.New(), StoreID: tienda, Type: UbicacionAISLE}
+	pasilloAjeno := Ubicacion{ID: uuid.New(), StoreID: otraTienda, Type: UbicacionAISLE}
+	nueva := func(tipo TipoUbicacion, padre *Ubicacion) CrearUbicacion {
+		u := CrearUbicacion{StoreID: tienda, Type: tipo, Code: "BOD-A-03"}
+		if padre != nil {
+			u.ParentID = &padre.ID
+		}
+		return u
+	}
+
+	// Zona sin padre, pasillo en zona y contenedor en pasillo de la misma tienda
+	if err := validarJerarquia(nueva(UbicacionZONE, nil), nil); err != nil {
+		t.Errorf("zona: err = %v", err)
+	}
+	if err := validarJerarquia(nueva(UbicacionAISLE, &zona), &zona); err != nil {
+		t.Errorf("pasillo: err = %v", err)
+	}
+	if err := validarJerarquia(nueva(UbicacionBIN, &pasillo), &pasillo); err != nil {
+		t.Errorf("contenedor: err = %v", err)
+	}
+
+	if err := validarJerarquia(nueva(UbicacionZONE, &zona), &zona); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("zona con padre: err = %v", err)
+	}
+	if err := validarJerarquia(nueva(UbicacionBIN, nil), nil); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("contenedor sin padre: err = %v", err)
+	}
+	// Un contenedor no puede ir directo en una zona
+	if err := validarJerarquia(nueva(UbicacionBIN, &zona), &zona); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("contenedor en zona: err = %v", err)
+	}
+	if err := validarJerarquia(nueva(UbicacionBIN, &pasilloAjeno), &pasilloAjeno); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("pasillo de otra tienda: err = %v", err)
+	}
+	// Padre inexistente o inactivo
+	if err := validarJerarquia(nueva(UbicacionBIN, &pasillo), nil); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("padre inexistente: err = %v", err)
+	}
+}
+
+func TestValidarUbicacionesMovimiento(t *testing.T) {
+	tienda, otraTienda := uuid.New(), uuid.New()
+	anaquel, bodega := uuid.New(), uuid.New()
+	mov := func(destinoTienda uuid.UUID, origen, destino *uuid.UUID) CrearMovimiento {
+		return CrearMovimiento{SourceStoreID: tienda, TargetStoreID: destinoTienda,
+			SourceLocationID: origen, TargetLocationID: destino, Quantity: 1}
+	}
+
+	// Reubicación dentro de la tienda, o ubicar mercancía aún sin ubicación
+	if err := validarUbicacionesMovimiento(EfectoRELOCATE, mov(tienda, &bodega, &anaquel)); err != nil {
+		t.Errorf("reubicación: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoRELOCATE, mov(tienda, nil, &anaquel)); err != nil {
+		t.Errorf("ubicar: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoRELOCATE, mov(otraTienda, &bodega, &anaquel)); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("reubicación entre tiendas: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoRELOCATE, mov(tienda, nil, nil)); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("reubicación sin ubicaciones: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoRELOCATE, mov(tienda, &anaquel, &anaquel)); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("misma ubicación: err = %v", err)
+	}
+
+	// Entradas sólo con destino, salidas sólo con origen; traspasos con ambas
+	if err := validarUbicacionesMovimiento(EfectoIN, mov(tienda, nil, &anaquel)); err != nil {
+		t.Errorf("entrada: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoIN, mov(tienda, &bodega, nil)); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("entrada con origen: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoOUT, mov(tienda, &bodega, nil)); err != nil {
+		t.Errorf("salida: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoOUT, mov(tienda, nil, &anaquel)); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("salida con destino: err = %v", err)
+	}
+	if err := validarUbicacionesMovimiento(EfectoTRANSFER, mov(otraTienda, &bodega, &anaquel)); err != nil {
+		t.Errorf("traspaso: err = %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errLoteInvalido indica un error en los datos de lote de un movimiento
+var errLoteInvalido = errors.New("Lote inválido")
+
+// Lote existencias de un lote en una tienda
+// @Description Lote con fecha de caducidad
+type Lote struct {
+	ID         uuid.UUID  `json:"id"`
+	ProductID  uuid.UUID  `json:"product_id"`
+	StoreID    uuid.UUID  `json:"store_id"`
+	LotNumber  string     `json:"lot_number" example:"L2024-118"`
+	ExpiryDate *time.Time `json:"expiry_date,omitempty" example:"2025-03-31T00:00:00Z"`
+	Quantity   int        `json:"quantity" example:"40"`
+	Activo     bool       `json:"activo"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// MovimientoLote cantidad de un lote afectada por un movimiento
+type MovimientoLote struct {
+	LotID      uuid.UUID  `json:"lot_id"`
+	LotNumber  string     `json:"lot_number" example:"L2024-118"`
+	ExpiryDate *time.Time `json:"expiry_date,omitempty"`
+	Quantity   int        `json:"quantity" example:"5"`
+}
+
+// AlertaCaducidad lote caducado o próximo a caducar
+type AlertaCaducidad struct {
+	LotID       uuid.UUID `json:"lot_id"`
+	ProductID   uuid.UUID `json:"product_id"`
+	StoreID     uuid.UUID `json:"store_id"`
+	ProductName string    `json:"product_name"`
+	StoreName   string    `json:"store_name"`
+	LotNumber   string    `json:"lot_number"`
+	ExpiryDate  time.Time `json:"expiry_date"`
+	DaysLeft    int       `json:"days_left"`
+	Quantity    int       `json:"quantity"`
+	Alert_Type  string    `json:"alert_type" example:"POR_CADUCAR"`
+}
+
+// ListarLotes godoc
+// @Summary      Listar lotes
+// @Description  Obtiene los lotes con existencia de un producto, opcionalmente filtrados por tienda, en orden FEFO
+// @Tags         inventario
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string true "ID del producto"
+// @Param        store_id query string false "ID de la tienda"
+// @Success      200  {array}   Lote
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarLotes [get]
+func (h *InventoryHandler) ListarLotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	productID, err := uuid.Parse(r.URL.Query().Get("product_id"))
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	var storeID *uuid.UUID
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, productId, storeId, lot_number, expiry_date, quantity,
+               activo, created_at, updated_at
+        FROM prueba.lotes
+        WHERE productId = $1 AND ($2::uuid IS NULL OR storeId = $2)
+          AND activo = true AND quantity > 0
+        ORDER BY storeId, expiry_date NULLS LAST, created_at
+    `, productID, storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var lotes []Lote
+	for rows.Next() {
+		var l Lote
+		err := rows.Scan(&l.ID, &l.ProductID, &l.StoreID, &l.LotNumber, &l.ExpiryDate,
+			&l.Quantity, &l.Activo, &l.CreatedAt, &l.UpdatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lotes = append(lotes, l)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lotes)
+}
+
+// GetExpiryAlerts godoc
+// @Summary      Listar alertas de caducidad
+// @Description  Obtiene los lotes con existencia que ya caducaron o caducan dentro de los próximos días
+// @Tags         inventario
+// @Accept       json
+// @Produce      json
+// @Param        days query int false "Días de anticipación" default(30)
+// @Success      200  {array}   AlertaCaducidad
+// @Failure      400  {object}  map[string]string
+// @Router       /inventory/expiry-alerts [get]
+func (h *InventoryHandler) GetExpiryAlerts(w http.ResponseWriter, r *http.Request) {
+	dias := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Número de días inválido", http.StatusBadRequest)
+			return
+		}
+		dias = n
+	}
+
+	rows, err := h.db.Query(`
+        SELECT l.id, l.productId, l.storeId, p.name, t.name, l.lot_number,
+               l.expiry_date, l.expiry_date - CURRENT_DATE, l.quantity,
+               CASE
+                   WHEN l.expiry_date < CURRENT_DATE THEN 'CADUCADO'
+                   ELSE 'POR_CADUCAR'
+               END
+        FROM prueba.lotes l
+        JOIN catalogos.productos p ON l.productId = p.id
+        JOIN catalogos.tiendas t ON l.storeId = t.id
+        WHERE l.activo = true AND l.quantity > 0
+          AND l.expiry_date <= CURRENT_DATE + $1::int
+        ORDER BY l.expiry_date ASC`, dias)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var alerts []AlertaCaducidad
+	for rows.Next() {
+		var a AlertaCaducidad
+		err := rows.Scan(&a.LotID, &a.ProductID, &a.StoreID, &a.ProductName, &a.StoreName,
+			&a.LotNumber, &a.ExpiryDate, &a.DaysLeft, &a.Quantity, &a.Alert_Type)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		alerts = append(alerts, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
+
+// productoControlaLotes indica si el producto lleva existencias por lote
+func productoControlaLotes(q utils.Querier, productID uuid.UUID) (bool, error) {
+	var controla bool
+	err := q.QueryRow(`SELECT track_lots FROM catalogos.productos WHERE id = $1`, productID).Scan(&controla)
+	return controla, err
+}
+
+// registrarEntradaLote suma una entrada al lote indicado, creándolo si no existe
+func registrarEntradaLote(tx *sql.Tx, productID, storeID, movementID uuid.UUID, numero string, caducidad *time.Time, cantidad int) (MovimientoLote, error) {
+	lote := MovimientoLote{LotNumber: numero, ExpiryDate: caducidad, Quantity: cantidad}
+	if numero == "" {
+		return lote, fmt.Errorf("%w: el número de lote es requerido", errLoteInvalido)
+	}
+
+	lotID, err := sumarLote(tx, productID, storeID, numero, caducidad, cantidad)
+	if err != nil {
+		return lote, err
+	}
+	lote.LotID = lotID
+
+	_, err = tx.Exec(`
+        INSERT INTO prueba.movimientoslotes (id, movementId, lotId, quantity)
+        VALUES ($1, $2, $3, $4)
+    `, uuid.New(), movementID, lotID, cantidad)
+	return lote, err
+}
+
+// registrarEntradaLotes suma una entrada repartida en varios lotes
+func registrarEntradaLotes(tx *sql.Tx, productID, storeID, movementID uuid.UUID, lotes []MovimientoLote) ([]MovimientoLote, error) {
+	registrados := make([]MovimientoLote, 0, len(lotes))
+	for _, l := range lotes {
+		lote, err := registrarEntradaLote(tx, productID, storeID, movementID, l.LotNumber, l.ExpiryDate, l.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		registrados = append(registrados, lote)
+	}
+	return registrados, nil
+}
+
+// registrarSalidaLotes descuenta una salida de los lotes de la tienda. Si se
+// indica número de lote se toma de ése; de lo contrario se aplica FEFO.
+func registrarSalidaLotes(tx *sql.Tx, productID, storeID, movementID uuid.UUID, numero string, cantidad int) ([]MovimientoLote, error) {
+	tomados, err := tomarLotes(tx, productID, storeID, numero, cantidad)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range tomados {
+		if _, err := tx.Exec(`
+            INSERT INTO prueba.movimientoslotes (id, movementId, lotId, quantity)
+            VALUES ($1, $2, $3, $4)
+        `, uuid.New(), movementID, l.LotID, l.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	return tomados, nil
+}
+
+// trasladarLotes descuenta los lotes en la tienda origen y los suma en la
+// tienda destino conservando número de lote y caducidad
+func trasladarLotes(tx *sql.Tx, productID, sourceStoreID, targetStoreID, movementID uuid.UUID, numero string, cantidad int) ([]MovimientoLote, error) {
+	tomados, err := tomarLotes(tx, productID, sourceStoreID, numero, cantidad)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range tomados {
+		destino, err := sumarLote(tx, productID, targetStoreID, l.LotNumber, l.ExpiryDate, l.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+            INSERT INTO prueba.movimientoslotes (id, movementId, lotId, targetLotId, quantity)
+            VALUES ($1, $2, $3, $4, $5)
+        `, uuid.New(), movementID, l.LotID, destino, l.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	return tomados, nil
+}
+
+// lotesDeMovimiento obtiene los lotes afectados por un movimiento
+func lotesDeMovimiento(q utils.Querier, movementID uuid.UUID) ([]MovimientoLote, error) {
+	rows, err := q.Query(`
+        SELECT l.id, l.lot_number, l.expiry_date, ml.quantity
+        FROM prueba.movimientoslotes ml
+        JOIN prueba.lotes l ON ml.lotId = l.id
+        WHERE ml.movementId = $1
+        ORDER BY l.expiry_date NULLS LAST`, movementID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lotes []MovimientoLote
+	for rows.Next() {
+		var l MovimientoLote
+		if err := rows.Scan(&l.LotID, &l.LotNumber, &l.ExpiryDate, &l.Quantity); err != nil {
+			return nil, err
+		}
+		lotes = append(lotes, l)
+	}
+	return lotes, rows.Err()
+}
+
+// sumarLote incrementa la existencia de un lote en una tienda, creándolo si no existe
+func sumarLote(tx *sql.Tx, productID, storeID uuid.UUID, numero string, caducidad *time.Time, cantidad int) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := tx.QueryRow(`
+        INSERT INTO prueba.lotes (id, productId, storeId, lot_number, expiry_date, quantity)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (productId, storeId, lot_number) DO
+        UPDATE SET quantity = prueba.lotes.quantity + EXCLUDED.quantity, activo = true
+        RETURNING id
+    `, uuid.New(), productID, storeID, numero, caducidad, cantidad).Scan(&id)
+	return id, err
+}
+
+// loteDisponible lote con existencia candidato a surtir una salida
+type loteDisponible struct {
+	MovimientoLote
+	// Caducado según la fecha de la base de datos
+	caducado bool
+	creado   time.Time
+}
+
+// tomarLotes descuenta la cantidad de los lotes de una tienda. Sin número de
+// lote se eligen primero los que caducan antes (FEFO), omitiendo los caducados.
+func tomarLotes(tx *sql.Tx, productID, storeID uuid.UUID, numero string, cantidad int) ([]MovimientoLote, error) {
+	rows, err := tx.Query(`
+        SELECT id, lot_number, expiry_date, quantity, expiry_date < CURRENT_DATE, created_at
+        FROM prueba.lotes
+        WHERE productId = $1 AND storeId = $2 AND activo = true AND quantity > 0
+          AND ($3 = '' OR lot_number = $3)
+        ORDER BY id
+        FOR UPDATE
+    `, productID, storeID, numero)
+	if err != nil {
+		return nil, err
+	}
+
+	var disponibles []loteDisponible
+	for rows.Next() {
+		var l loteDisponible
+		var caducado sql.NullBool
+		if err := rows.Scan(&l.LotID, &l.LotNumber, &l.ExpiryDate, &l.Quantity, &caducado, &l.creado); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		l.caducado = caducado.Bool
+		disponibles = append(disponibles, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tomados, err := elegirLotes(disponibles, numero, cantidad)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range tomados {
+		if _, err := tx.Exec(`
+            UPDATE prueba.lotes SET quantity = quantity - $2 WHERE id = $1
+        `, l.LotID, l.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	return tomados, nil
+}
+
+// elegirLotes reparte la cantidad entre los lotes disponibles. Con número de
+// lote se toma sólo de ése aunque haya caducado; sin él se surten primero los
+// que caducan antes, después los que no caducan y al final los más antiguos.
+func elegirLotes(disponibles []loteDisponible, numero string, cantidad int) ([]MovimientoLote, error) {
+	candidatos := make([]loteDisponible, 0, len(disponibles))
+	for _, l := range disponibles {
+		if numero != "" && l.LotNumber != numero {
+			continue
+		}
+		if numero == "" && l.caducado {
+			continue
+		}
+		candidatos = append(candidatos, l)
+	}
+	sort.SliceStable(candidatos, func(i, j int) bool {
+		a, b := candidatos[i], candidatos[j]
+		switch {
+		case a.ExpiryDate != nil && b.ExpiryDate != nil && !a.ExpiryDate.Equal(*b.ExpiryDate):
+			return a.ExpiryDate.Before(*b.ExpiryDate)
+		case (a.ExpiryDate == nil) != (b.ExpiryDate == nil):
+			return a.ExpiryDate != nil
+		}
+		return a.creado.Before(b.creado)
+	})
+
+	var tomados []MovimientoLote
+	pendiente := cantidad
+	for _, l := range candidatos {
+		if pendiente == 0 {
+			break
+		}
+		tomado := l.MovimientoLote
+		if tomado.Quantity > pendiente {
+			tomado.Quantity = pendiente
+		}
+		tomados = append(tomados, tomado)
+		pendiente -= tomado.Quantity
+	}
+	if pendiente > 0 {
+		return nil, fmt.Errorf("%w en lotes vigentes", errStockInsuficiente)
+	}
+	return tomados, nil
+}
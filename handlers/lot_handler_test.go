@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestElegirLotes(t *testing.T) {
+	inicio := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	caduca := func(mes int) *time.Time { f := inicio.AddDate(0, mes, 0); return &f }
+	lote := func(numero string, caducidad *time.Time, cantidad, dia int) loteDisponible {
+		return loteDisponible{MovimientoLote: MovimientoLote{LotID: uuid.New(), LotNumber: numero,
+			ExpiryDate: caducidad, Quantity: cantidad}, creado: inicio.AddDate(0, 0, dia)}
+	}
+	vencido := lote("L-0", caduca(-1), 50, 0)
+	vencido.caducado = true
+	disponibles := []loteDisponible{
+		lote("L-SIN", nil, 10, 0),
+		lote("L-MAR", caduca(3), 4, 5),
+		vencido,
+		lote("L-FEB", caduca(2), 3, 9),
+		lote("L-MAR2", caduca(3), 6, 1),
+	}
+	numeros := func(tomados []MovimientoLote) []string {
+		var n []string
+		for _, l := range tomados {
+			n = append(n, l.LotNumber)
+		}
+		return n
+	}
+
+	// FEFO: primero el que caduca antes, en empate el más antiguo; el caducado se omite
+	tomados, err := elegirLotes(disponibles, "", 12)
+	if err != nil {
+		t.Fatalf("FEFO: %v", err)
+	}
+	esperados := []MovimientoLote{{LotNumber: "L-FEB", Quantity: 3}, {LotNumber: "L-MAR2", Quantity: 6}, {LotNumber: "L-MAR", Quantity: 3}}
+	if len(tomados) != len(esperados) {
+		t.Fatalf("FEFO: lotes = %v", numeros(tomados))
+	}
+	for i, e := range esperados {
+		if tomados[i].LotNumber != e.LotNumber || tomados[i].Quantity != e.Quantity {
+			t.Errorf("FEFO: lote %d = %s x%d, se esperaba %s x%d", i, tomados[i].LotNumber, tomados[i].Quantity, e.LotNumber, e.Quantity)
+		}
+	}
+
+	// Los lotes sin caducidad se surten al final
+	tomados, err = elegirLotes(disponibles, "", 20)
+	if err != nil || len(tomados) != 4 || tomados[3].LotNumber != "L-SIN" || tomados[3].Quantity != 7 {
+		t.Errorf("sin caducidad: lotes = %v %v", numeros(tomados), err)
+	}
+
+	// Con número de lote se toma de ése aunque haya caducado
+	tomados, err = elegirLotes(disponibles, "L-0", 5)
+	if err != nil || len(tomados) != 1 || tomados[0].LotID != vencido.LotID || tomados[0].Quantity != 5 {
+		t.Errorf("lote indicado: %+v %v", tomados, err)
+	}
+
+	// El caducado no cuenta como existencia para FEFO
+	if _, err := elegirLotes(disponibles, "", 24); !errors.Is(err, errStockInsuficiente) {
+		t.Errorf("insuficiente: err = %v", err)
+	}
+	if _, err := elegirLotes(disponibles, "L-FEB", 4); !errors.Is(err, errStockInsuficiente) {
+		t.Errorf("lote insuficiente: err = %v", err)
+	}
+}
@@ -3,19 +3,28 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"go-project/money"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// MovimientoTipo tipo de movimiento
+// MovimientoTipo código de un tipo del catálogo de movimientos
+// (catalogos.TiposMovimiento); su efecto en la existencia lo define el catálogo
 type MovimientoTipo string
 
+// Códigos de los tipos base que registran los procesos internos (ventas,
+// devoluciones y RMA). Son de uso interno: las consultas y reportes no
+// filtran por código sino por el efecto del tipo en el catálogo.
 const (
-	MovimientoIN       MovimientoTipo = "IN"
-	MovimientoOUT      MovimientoTipo = "OUT"
-	MovimientoTRANSFER MovimientoTipo = "TRANSFER"
+	movimientoIN  MovimientoTipo = "IN"
+	movimientoOUT MovimientoTipo = "OUT"
+	// Reingreso de mercancía devuelta por el cliente; exige motivo
+	movimientoRETURN MovimientoTipo = "RETURN"
+	// Baja de mercancía que sale del inventario sin venderse
+	movimientoWRITEOFF MovimientoTipo = "WRITE_OFF"
 )
 
 // Movimiento modelo básico
@@ -31,11 +40,35 @@ type Movimiento struct {
 
 // CrearMovimiento modelo para crear movimiento
 type CrearMovimiento struct {
-	ProductID     uuid.UUID      `json:"product_id" binding:"required"`
+	ProductID uuid.UUID `json:"product_id"`
+	// Código de barras escaneado; puede usarse en lugar de product_id
+	Barcode       string         `json:"barcode,omitempty" example:"7501031311309"`
 	SourceStoreID uuid.UUID      `json:"source_store_id" binding:"required"`
 	TargetStoreID uuid.UUID      `json:"target_store_id" binding:"required"`
 	Quantity      int            `json:"quantity" binding:"required,gt=0"`
 	Type          MovimientoTipo `json:"type" binding:"required"`
+	// Código de motivo, requerido en los tipos que lo exigen (WRITE_OFF, DAMAGE, THEFT...)
+	ReasonCode string `json:"reason_code,omitempty" example:"BREAKAGE"`
+	// Costo unitario de adquisición en movimientos de entrada; si se omite se
+	// usa el de la última entrada del producto. Es requerido en la primera
+	// entrada con costo del producto.
+	UnitCost *money.Money `json:"unit_cost,omitempty"`
+	// Lote de la entrada, o lote específico a descontar (si se omite se aplica FEFO)
+	LotNumber string `json:"lot_number,omitempty" example:"L2024-118"`
+	// Fecha de caducidad del lote en entradas (YYYY-MM-DD)
+	ExpiryDate string `json:"expiry_date,omitempty" example:"2025-03-31"`
+	// Números de serie movidos; requeridos en productos con control por serie
+	Serials []string `json:"serials,omitempty"`
+	// Ubicación de origen dentro de la tienda (OUT, TRANSFER, RELOCATE)
+	SourceLocationID *uuid.UUID `json:"source_location_id,omitempty"`
+	// Ubicación de destino dentro de la tienda (IN, TRANSFER, RELOCATE)
+	TargetLocationID *uuid.UUID `json:"target_location_id,omitempty"`
+
+	// Entrada cuyas capas de costo consume primero una salida (uso interno)
+	entradaID *uuid.UUID
+	// Reparto explícito por lote que sustituye a LotNumber y ExpiryDate; lo
+	// usan las devoluciones para regresar cada unidad a su lote (uso interno)
+	lotes []MovimientoLote
 }
 
 // MovimientoDetalle modelo completo
@@ -46,14 +79,25 @@ type MovimientoDetalle struct {
 	TargetStoreID uuid.UUID      `json:"target_store_id"`
 	Quantity      int            `json:"quantity"`
 	Type          MovimientoTipo `json:"type"`
-	Timestamp     time.Time      `json:"timestamp"`
-	Activo        bool           `json:"activo"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
+	ReasonCode    *string        `json:"reason_code,omitempty"`
+	UnitCost      *money.Money   `json:"unit_cost,omitempty"`
+	// La entrada no indicó costo y se tomó el de la última entrada del producto
+	UnitCostDefaulted bool `json:"unit_cost_defaulted,omitempty"`
+	// Ubicaciones dentro de la tienda, si se indicaron
+	SourceLocationID *uuid.UUID `json:"source_location_id,omitempty"`
+	TargetLocationID *uuid.UUID `json:"target_location_id,omitempty"`
+	// Sentido de un ajuste: 1 sobrante, -1 faltante
+	Direction *int      `json:"direction,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Activo    bool      `json:"activo"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 	// Campos adicionales para información relacionada
-	ProductName     string `json:"product_name"`
-	SourceStoreName string `json:"source_store_name"`
-	TargetStoreName string `json:"target_store_name"`
+	ProductName     string           `json:"product_name"`
+	SourceStoreName string           `json:"source_store_name"`
+	TargetStoreName string           `json:"target_store_name"`
+	Lots            []MovimientoLote `json:"lots,omitempty"`
+	Serials         []string         `json:"serials,omitempty"`
 }
 
 type MovementHandler struct {
@@ -77,7 +121,8 @@ func (h *MovementHandler) ListarMovimientos(w http.ResponseWriter, r *http.Reque
 	query := `
         SELECT 
             m.id, m.productId, m.sourceStoreId, m.targetStoreId,
-            m.quantity, m.type, m.timestamp, m.activo,
+            m.quantity, m.type, mm.code, m.unit_cost, m.currency,
+            m.sourceLocationId, m.targetLocationId, m.direction, m.timestamp, m.activo,
             m.created_at, m.updated_at,
             p.name as product_name,
             s1.name as source_store_name,
@@ -86,6 +131,7 @@ func (h *MovementHandler) ListarMovimientos(w http.ResponseWriter, r *http.Reque
         JOIN catalogos.productos p ON m.productId = p.id
         JOIN catalogos.tiendas s1 ON m.sourceStoreId = s1.id
         JOIN catalogos.tiendas s2 ON m.targetStoreId = s2.id
+        LEFT JOIN catalogos.motivosmovimiento mm ON m.reasonId = mm.id
         WHERE m.activo = true
         ORDER BY m.timestamp DESC`
 
@@ -99,9 +145,12 @@ func (h *MovementHandler) ListarMovimientos(w http.ResponseWriter, r *http.Reque
 	var movimientos []MovimientoDetalle
 	for rows.Next() {
 		var m MovimientoDetalle
+		var costo *money.Decimal
+		var moneda string
 		err := rows.Scan(
 			&m.ID, &m.ProductID, &m.SourceStoreID, &m.TargetStoreID,
-			&m.Quantity, &m.Type, &m.Timestamp, &m.Activo,
+			&m.Quantity, &m.Type, &m.ReasonCode, &costo, &moneda,
+			&m.SourceLocationID, &m.TargetLocationID, &m.Direction, &m.Timestamp, &m.Activo,
 			&m.CreatedAt, &m.UpdatedAt,
 			&m.ProductName, &m.SourceStoreName, &m.TargetStoreName,
 		)
@@ -109,6 +158,7 @@ func (h *MovementHandler) ListarMovimientos(w http.ResponseWriter, r *http.Reque
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		m.UnitCost = costoUnitario(costo, moneda)
 		movimientos = append(movimientos, m)
 	}
 
@@ -118,7 +168,11 @@ func (h *MovementHandler) ListarMovimientos(w http.ResponseWriter, r *http.Reque
 
 // CrearMovimiento godoc
 // @Summary      Crear movimiento
-// @Description  Registra un nuevo movimiento de inventario
+// @Description  Registra un nuevo movimiento de inventario y lo aplica en la misma transacción: actualiza las
+// @Description  existencias de prueba.inventarios (las entradas crean el registro si no existe y las salidas
+// @Description  fallan con stock insuficiente), los lotes, las series, las ubicaciones y las capas de costo.
+// @Description  Una entrada sin unit_cost toma el costo de la última entrada del producto y lo indica con
+// @Description  unit_cost_defaulted; si el producto no tiene entradas con costo se rechaza.
 // @Tags         movimientos
 // @Accept       json
 // @Produce      json
@@ -129,15 +183,34 @@ func (h *MovementHandler) ListarMovimientos(w http.ResponseWriter, r *http.Reque
 func (h *MovementHandler) CrearMovimiento(w http.ResponseWriter, r *http.Request) {
 	var mov CrearMovimiento
 	if err := json.NewDecoder(r.Body).Decode(&mov); err != nil {
-		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		responderDatosInvalidos(w, err)
 		return
 	}
 
-	// Validar tipo de movimiento
-	if mov.Type != MovimientoIN && mov.Type != MovimientoOUT && mov.Type != MovimientoTRANSFER {
-		http.Error(w, "Tipo de movimiento inválido", http.StatusBadRequest)
+	// Validar tipo de movimiento contra el catálogo y su motivo
+	tipo, err := cargarTipoMovimiento(h.db, mov.Type)
+	if err != nil {
+		if esErrorDeNegocio(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	motivoID, err := validarMovimientoManual(tipo, mov)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if tipo.Effect == EfectoTRANSFER && mov.SourceStoreID == mov.TargetStoreID {
+		http.Error(w, "Una transferencia debe ser entre tiendas distintas", http.StatusBadRequest)
+		return
+	}
+
+	// En productos con número de serie la cantidad se deriva de las series
+	if mov.Quantity == 0 && len(mov.Serials) > 0 {
+		mov.Quantity = len(mov.Serials)
+	}
 
 	// Validar cantidad positiva
 	if mov.Quantity <= 0 {
@@ -145,9 +218,37 @@ func (h *MovementHandler) CrearMovimiento(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Validar costo unitario: se captura en las entradas para valuar el inventario
+	if tipo.Effect != EfectoIN && mov.UnitCost != nil {
+		http.Error(w, "El costo unitario solo aplica a movimientos de entrada", http.StatusBadRequest)
+		return
+	}
+	var costo *money.Decimal
+	moneda := money.DefaultCurrency
+	if mov.UnitCost != nil {
+		if mov.UnitCost.IsNegative() {
+			http.Error(w, "El costo unitario debe ser no negativo", http.StatusBadRequest)
+			return
+		}
+		costo = &mov.UnitCost.Amount
+		moneda = mov.UnitCost.Currency
+	}
+
+	// Resolver el producto a partir del código de barras escaneado
+	productID, err := resolverProducto(h.db, mov.ProductID, mov.Barcode)
+	if err != nil {
+		if esErrorDeNegocio(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	mov.ProductID = productID
+
 	// Verificar existencia de producto y tiendas
 	var exists bool
-	err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM catalogos.productos WHERE id = $1)", mov.ProductID).Scan(&exists)
+	err = h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM catalogos.productos WHERE id = $1)", mov.ProductID).Scan(&exists)
 	if err != nil || !exists {
 		http.Error(w, "Producto no encontrado", http.StatusBadRequest)
 		return
@@ -156,29 +257,63 @@ func (h *MovementHandler) CrearMovimiento(w http.ResponseWriter, r *http.Request
 	// Iniciar transacción
 	tx, err := h.db.Begin()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responderErrorInterno(w, err)
 		return
 	}
 	defer tx.Rollback()
 
+	// Entrada sin costo capturado: tomar el de la última entrada del producto
+	costoEstimado := false
+	if tipo.Effect == EfectoIN && costo == nil {
+		ultimo, err := ultimoCosto(tx, mov.ProductID, mov.TargetStoreID)
+		if err != nil {
+			responderErrorInterno(w, err)
+			return
+		}
+		if ultimo == nil {
+			http.Error(w, "Indique el costo unitario: el producto no tiene entradas previas con costo", http.StatusBadRequest)
+			return
+		}
+		costo, moneda, costoEstimado = &ultimo.Amount, ultimo.Currency, true
+	}
+
 	// Insertar movimiento
 	var movimiento MovimientoDetalle
 	err = tx.QueryRow(`
         INSERT INTO prueba.movimientos (
             id, productId, sourceStoreId, targetStoreId,
-            quantity, type, timestamp
-        ) VALUES ($1, $2, $3, $4, $5, $6, CURRENT_TIMESTAMP)
+            quantity, type, reasonId, unit_cost, currency,
+            sourceLocationId, targetLocationId, timestamp
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, CURRENT_TIMESTAMP)
         RETURNING id, productId, sourceStoreId, targetStoreId,
-                  quantity, type, timestamp, activo, created_at, updated_at
+                  quantity, type, sourceLocationId, targetLocationId,
+                  timestamp, activo, created_at, updated_at
     `, uuid.New(), mov.ProductID, mov.SourceStoreID, mov.TargetStoreID,
-		mov.Quantity, mov.Type).Scan(
+		mov.Quantity, mov.Type, motivoID, costo, moneda,
+		mov.SourceLocationID, mov.TargetLocationID).Scan(
 		&movimiento.ID, &movimiento.ProductID, &movimiento.SourceStoreID,
 		&movimiento.TargetStoreID, &movimiento.Quantity, &movimiento.Type,
+		&movimiento.SourceLocationID, &movimiento.TargetLocationID,
 		&movimiento.Timestamp, &movimiento.Activo, &movimiento.CreatedAt,
 		&movimiento.UpdatedAt)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responderErrorInterno(w, err)
+		return
+	}
+	movimiento.UnitCost = costoUnitario(costo, moneda)
+	movimiento.UnitCostDefaulted = costoEstimado
+	if motivoID != nil {
+		movimiento.ReasonCode = &mov.ReasonCode
+	}
+
+	// Aplicar el movimiento a existencias, lotes y capas de costo
+	if err = aplicarMovimiento(tx, tipo, mov, &movimiento); err != nil {
+		if esErrorDeNegocio(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responderErrorInterno(w, err)
 		return
 	}
 
@@ -212,7 +347,8 @@ func (h *MovementHandler) ObtenerMovimiento(w http.ResponseWriter, r *http.Reque
 	query := `
         SELECT 
             m.id, m.productId, m.sourceStoreId, m.targetStoreId,
-            m.quantity, m.type, m.timestamp, m.activo,
+            m.quantity, m.type, mm.code, m.unit_cost, m.currency,
+            m.sourceLocationId, m.targetLocationId, m.direction, m.timestamp, m.activo,
             m.created_at, m.updated_at,
             p.name as product_name,
             s1.name as source_store_name,
@@ -221,12 +357,16 @@ func (h *MovementHandler) ObtenerMovimiento(w http.ResponseWriter, r *http.Reque
         JOIN catalogos.productos p ON m.productId = p.id
         JOIN catalogos.tiendas s1 ON m.sourceStoreId = s1.id
         JOIN catalogos.tiendas s2 ON m.targetStoreId = s2.id
+        LEFT JOIN catalogos.motivosmovimiento mm ON m.reasonId = mm.id
         WHERE m.id = $1`
 
 	var mov MovimientoDetalle
+	var costo *money.Decimal
+	var moneda string
 	err = h.db.QueryRow(query, id).Scan(
 		&mov.ID, &mov.ProductID, &mov.SourceStoreID, &mov.TargetStoreID,
-		&mov.Quantity, &mov.Type, &mov.Timestamp, &mov.Activo,
+		&mov.Quantity, &mov.Type, &mov.ReasonCode, &costo, &moneda,
+		&mov.SourceLocationID, &mov.TargetLocationID, &mov.Direction, &mov.Timestamp, &mov.Activo,
 		&mov.CreatedAt, &mov.UpdatedAt,
 		&mov.ProductName, &mov.SourceStoreName, &mov.TargetStoreName,
 	)
@@ -239,7 +379,219 @@ func (h *MovementHandler) ObtenerMovimiento(w http.ResponseWriter, r *http.Reque
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	mov.UnitCost = costoUnitario(costo, moneda)
+
+	if mov.Lots, err = lotesDeMovimiento(h.db, mov.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if mov.Serials, err = seriesDeMovimiento(h.db, mov.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(mov)
 }
+
+// aplicarMovimiento actualiza las existencias, los lotes y las capas de costo
+// afectadas por un movimiento ya registrado según el efecto de su tipo: las
+// entradas suman en la tienda destino, las salidas descuentan en la tienda
+// origen, los traspasos mueven de origen a destino y las reubicaciones sólo
+// cambian la ubicación dentro de la tienda.
+func aplicarMovimiento(tx *sql.Tx, tipo TipoMovimiento, mov CrearMovimiento, m *MovimientoDetalle) error {
+	if tipo.Effect == EfectoRELOCATE && (len(mov.Serials) > 0 || mov.LotNumber != "") {
+		return fmt.Errorf("%w: las reubicaciones no registran lotes ni series", errUbicacionInvalida)
+	}
+
+	// Los productos en conteo físico no admiten movimientos
+	if tipo.Effect != EfectoIN {
+		if err := verificarSinConteo(tx, mov.ProductID, mov.SourceStoreID); err != nil {
+			return err
+		}
+	}
+	if tipo.Effect != EfectoOUT {
+		if err := verificarSinConteo(tx, mov.ProductID, mov.TargetStoreID); err != nil {
+			return err
+		}
+	}
+	if err := aplicarUbicaciones(tx, mov); err != nil {
+		return err
+	}
+	if tipo.Effect != EfectoRELOCATE {
+		if err := aplicarExistencias(tx, tipo.Effect, mov, m); err != nil {
+			return err
+		}
+	}
+
+	// Lo ubicado no puede exceder la existencia que queda en la tienda
+	if tipo.Effect != EfectoIN {
+		return verificarSinUbicar(tx, mov.ProductID, mov.SourceStoreID)
+	}
+	if mov.TargetLocationID != nil {
+		return verificarSinUbicar(tx, mov.ProductID, mov.TargetStoreID)
+	}
+	return nil
+}
+
+// aplicarExistencias ajusta existencias, series, lotes y capas de costo
+func aplicarExistencias(tx *sql.Tx, efecto EfectoMovimiento, mov CrearMovimiento, m *MovimientoDetalle) error {
+	controlaLotes, err := productoControlaLotes(tx, mov.ProductID)
+	if err != nil {
+		return err
+	}
+	if !controlaLotes && (mov.LotNumber != "" || mov.ExpiryDate != "") {
+		return fmt.Errorf("%w: el producto no controla lotes", errLoteInvalido)
+	}
+
+	controlaSeries, err := productoControlaSeries(tx, mov.ProductID)
+	if err != nil {
+		return err
+	}
+	if controlaSeries {
+		if err := validarSeries(mov.Serials, mov.Quantity); err != nil {
+			return err
+		}
+		if err := moverSeries(tx, efecto, mov.ProductID, mov.SourceStoreID,
+			mov.TargetStoreID, m.ID, mov.Serials); err != nil {
+			return err
+		}
+		m.Serials = mov.Serials
+	} else if len(mov.Serials) > 0 {
+		return fmt.Errorf("%w: el producto no controla números de serie", errSerieInvalida)
+	}
+
+	switch efecto {
+	case EfectoIN:
+		if err := ajustarExistencia(tx, mov.ProductID, mov.TargetStoreID, mov.Quantity); err != nil {
+			return err
+		}
+		if controlaLotes && len(mov.lotes) > 0 {
+			if m.Lots, err = registrarEntradaLotes(tx, mov.ProductID, mov.TargetStoreID, m.ID, mov.lotes); err != nil {
+				return err
+			}
+		} else if controlaLotes {
+			var caducidad *time.Time
+			if mov.ExpiryDate != "" {
+				fecha, err := parseFecha(mov.ExpiryDate)
+				if err != nil {
+					return fmt.Errorf("%w: fecha de caducidad inválida", errLoteInvalido)
+				}
+				caducidad = &fecha
+			}
+			lote, err := registrarEntradaLote(tx, mov.ProductID, mov.TargetStoreID, m.ID,
+				mov.LotNumber, caducidad, mov.Quantity)
+			if err != nil {
+				return err
+			}
+			m.Lots = []MovimientoLote{lote}
+		}
+		if m.UnitCost == nil {
+			// Entrada sin costo: las unidades se reportan sin valuar
+			return nil
+		}
+		return registrarCapa(tx, mov.ProductID, mov.TargetStoreID, &m.ID,
+			mov.Quantity, *m.UnitCost, m.Timestamp)
+
+	case EfectoOUT:
+		if err := ajustarExistencia(tx, mov.ProductID, mov.SourceStoreID, -mov.Quantity); err != nil {
+			return err
+		}
+		if controlaLotes && len(mov.lotes) > 0 {
+			for _, l := range mov.lotes {
+				tomados, err := registrarSalidaLotes(tx, mov.ProductID, mov.SourceStoreID, m.ID,
+					l.LotNumber, l.Quantity)
+				if err != nil {
+					return err
+				}
+				m.Lots = append(m.Lots, tomados...)
+			}
+		} else if controlaLotes {
+			if m.Lots, err = registrarSalidaLotes(tx, mov.ProductID, mov.SourceStoreID, m.ID,
+				mov.LotNumber, mov.Quantity); err != nil {
+				return err
+			}
+		}
+		_, err = consumirCapasDe(tx, mov.ProductID, mov.SourceStoreID, &m.ID, mov.entradaID, mov.Quantity)
+		return err
+
+	case EfectoTRANSFER:
+		if err := ajustarExistencia(tx, mov.ProductID, mov.SourceStoreID, -mov.Quantity); err != nil {
+			return err
+		}
+		if err := ajustarExistencia(tx, mov.ProductID, mov.TargetStoreID, mov.Quantity); err != nil {
+			return err
+		}
+		if controlaLotes {
+			if m.Lots, err = trasladarLotes(tx, mov.ProductID, mov.SourceStoreID, mov.TargetStoreID,
+				m.ID, mov.LotNumber, mov.Quantity); err != nil {
+				return err
+			}
+		}
+		return trasladarCapas(tx, mov.ProductID, mov.SourceStoreID, mov.TargetStoreID,
+			&m.ID, mov.Quantity, m.Timestamp)
+	}
+
+	return nil
+}
+
+// validarMovimientoManual verifica que el tipo admita captura manual, que el
+// motivo sea del tipo y que las ubicaciones correspondan a su efecto;
+// devuelve el ID del motivo
+func validarMovimientoManual(tipo TipoMovimiento, mov CrearMovimiento) (*uuid.UUID, error) {
+	if !tipo.AllowManual {
+		return nil, fmt.Errorf("%w: %s no se puede registrar manualmente", errTipoMovimientoInvalido, tipo.Code)
+	}
+	motivoID, err := motivoDeTipo(tipo, mov.ReasonCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := validarUbicacionesMovimiento(tipo.Effect, mov); err != nil {
+		return nil, err
+	}
+	return motivoID, nil
+}
+
+// validarUbicacionesMovimiento verifica que las ubicaciones correspondan al
+// efecto: las reubicaciones mueven entre dos ubicaciones distintas de la
+// misma tienda, las entradas sólo llevan destino y las salidas sólo origen
+func validarUbicacionesMovimiento(efecto EfectoMovimiento, mov CrearMovimiento) error {
+	origen, destino := mov.SourceLocationID, mov.TargetLocationID
+	switch {
+	case efecto == EfectoRELOCATE && mov.SourceStoreID != mov.TargetStoreID:
+		return fmt.Errorf("%w: una reubicación debe ser dentro de la misma tienda", errUbicacionInvalida)
+	case efecto == EfectoRELOCATE && origen == nil && destino == nil:
+		return fmt.Errorf("%w: una reubicación requiere ubicación de origen o destino", errUbicacionInvalida)
+	case efecto == EfectoRELOCATE && origen != nil && destino != nil && *origen == *destino:
+		return fmt.Errorf("%w: las ubicaciones de origen y destino deben ser distintas", errUbicacionInvalida)
+	case efecto == EfectoIN && origen != nil:
+		return fmt.Errorf("%w: las entradas solo admiten ubicación de destino", errUbicacionInvalida)
+	case efecto == EfectoOUT && destino != nil:
+		return fmt.Errorf("%w: las salidas solo admiten ubicación de origen", errUbicacionInvalida)
+	}
+	return nil
+}
+
+// aplicarUbicaciones descuenta la ubicación de origen y suma a la de destino
+func aplicarUbicaciones(tx *sql.Tx, mov CrearMovimiento) error {
+	if mov.SourceLocationID != nil {
+		if err := ajustarUbicacion(tx, mov.ProductID, mov.SourceStoreID, *mov.SourceLocationID, -mov.Quantity); err != nil {
+			return err
+		}
+	}
+	if mov.TargetLocationID != nil {
+		if err := ajustarUbicacion(tx, mov.ProductID, mov.TargetStoreID, *mov.TargetLocationID, mov.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// costoUnitario arma el costo de un movimiento a partir de columnas opcionales
+func costoUnitario(costo *money.Decimal, moneda string) *money.Money {
+	if costo == nil {
+		return nil
+	}
+	m := money.New(*costo, moneda)
+	return &m
+}
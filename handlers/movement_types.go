@@ -0,0 +1,579 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/money"
+	"go-project/utils"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errTipoMovimientoInvalido tipo o motivo de movimiento inexistente o mal indicado
+var errTipoMovimientoInvalido = errors.New("Tipo de movimiento inválido")
+
+// EfectoMovimiento forma en que un tipo de movimiento afecta la existencia
+type EfectoMovimiento string
+
+const (
+	// Suma en la tienda destino
+	EfectoIN EfectoMovimiento = "IN"
+	// Resta en la tienda origen
+	EfectoOUT EfectoMovimiento = "OUT"
+	// Resta en la tienda origen y suma en la destino
+	EfectoTRANSFER EfectoMovimiento = "TRANSFER"
+	// Cambia de ubicación dentro de la tienda
+	EfectoRELOCATE EfectoMovimiento = "RELOCATE"
+	// Ajuste por conteo físico con sentido propio
+	EfectoADJUSTMENT EfectoMovimiento = "ADJUSTMENT"
+)
+
+// codigoCatalogo códigos de tipos y motivos: mayúsculas, números y guion bajo
+var codigoCatalogo = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// MotivoMovimiento código de motivo de un tipo de movimiento
+type MotivoMovimiento struct {
+	ID     uuid.UUID `json:"id"`
+	Code   string    `json:"code" example:"BREAKAGE"`
+	Name   string    `json:"name" example:"Rotura"`
+	Activo bool      `json:"activo"`
+}
+
+// TipoMovimiento tipo del catálogo de movimientos
+// @Description Tipo de movimiento con sus motivos
+type TipoMovimiento struct {
+	ID             uuid.UUID          `json:"id"`
+	Code           MovimientoTipo     `json:"code" example:"DAMAGE"`
+	Name           string             `json:"name" example:"Daño"`
+	Effect         EfectoMovimiento   `json:"effect" example:"OUT"`
+	RequiresReason bool               `json:"requires_reason"`
+	IsShrinkage    bool               `json:"is_shrinkage"`
+	IsSale         bool               `json:"is_sale"`
+	AllowManual    bool               `json:"allow_manual"`
+	IsSystem       bool               `json:"is_system"`
+	Activo         bool               `json:"activo"`
+	Reasons        []MotivoMovimiento `json:"reasons"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// CrearMotivo motivo de un tipo nuevo
+type CrearMotivo struct {
+	Code string `json:"code" example:"FLOOD" binding:"required"`
+	Name string `json:"name" example:"Inundación" binding:"required"`
+}
+
+// CrearTipoMovimiento modelo para registrar un tipo de movimiento
+type CrearTipoMovimiento struct {
+	Code string `json:"code" example:"SAMPLE" binding:"required"`
+	Name string `json:"name" example:"Muestra para degustación" binding:"required"`
+	// IN, OUT, TRANSFER o RELOCATE
+	Effect         EfectoMovimiento `json:"effect" example:"OUT" binding:"required"`
+	RequiresReason bool             `json:"requires_reason"`
+	IsShrinkage    bool             `json:"is_shrinkage"`
+	// Las salidas del tipo cuentan como venta (demanda y costo de lo vendido)
+	IsSale  bool          `json:"is_sale"`
+	Reasons []CrearMotivo `json:"reasons,omitempty"`
+}
+
+// CrearMotivoMovimiento modelo para agregar un motivo a un tipo existente
+type CrearMotivoMovimiento struct {
+	MovementType MovimientoTipo `json:"movement_type" example:"DAMAGE" binding:"required"`
+	CrearMotivo
+}
+
+// LineaMerma salidas por merma de un tipo y motivo en una tienda
+type LineaMerma struct {
+	Type       MovimientoTipo `json:"type" example:"DAMAGE"`
+	TypeName   string         `json:"type_name" example:"Daño"`
+	ReasonCode *string        `json:"reason_code,omitempty" example:"BREAKAGE"`
+	ReasonName *string        `json:"reason_name,omitempty" example:"Rotura"`
+	Movements  int            `json:"movements" example:"3"`
+	Units      int            `json:"units" example:"7"`
+	Cost       money.Money    `json:"cost"`
+}
+
+// MermaTienda merma de una tienda con su desglose por tipo y motivo
+type MermaTienda struct {
+	StoreID   uuid.UUID `json:"store_id"`
+	StoreName string    `json:"store_name"`
+	Units     int       `json:"units" example:"12"`
+	// Costo total por moneda
+	Cost  []money.Money `json:"cost"`
+	Lines []LineaMerma  `json:"lines"`
+}
+
+// filaMerma fila del reporte de merma antes de agrupar por tienda
+type filaMerma struct {
+	storeID   uuid.UUID
+	storeName string
+	LineaMerma
+}
+
+type MovementTypeHandler struct {
+	db *sql.DB
+}
+
+func NewMovementTypeHandler(db *sql.DB) *MovementTypeHandler {
+	return &MovementTypeHandler{db: db}
+}
+
+// ListarTiposMovimiento godoc
+// @Summary      Listar tipos de movimiento
+// @Description  Obtiene el catálogo de tipos de movimiento con su efecto en la existencia y sus motivos
+// @Tags         movimientos
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   TipoMovimiento
+// @Failure      500  {object}  map[string]string
+// @Router       /ListarTiposMovimiento [get]
+func (h *MovementTypeHandler) ListarTiposMovimiento(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tipos, err := cargarTiposMovimiento(h.db, "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tipos)
+}
+
+// CrearTipoMovimiento godoc
+// @Summary      Crear tipo de movimiento
+// @Description  Registra un tipo de movimiento con su efecto en la existencia y, opcionalmente, sus motivos.
+// @Description  Los tipos que requieren motivo necesitan al menos uno.
+// @Tags         movimientos
+// @Accept       json
+// @Produce      json
+// @Param        tipo body CrearTipoMovimiento true "Datos del tipo"
+// @Success      201  {object}  TipoMovimiento
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /CrearTipoMovimiento [post]
+func (h *MovementTypeHandler) CrearTipoMovimiento(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearTipoMovimiento
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if err := validarTipoMovimiento(&c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var existe bool
+	if err := h.db.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM catalogos.tiposmovimiento WHERE code = $1)
+    `, c.Code).Scan(&existe); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existe {
+		http.Error(w, "Ya existe un tipo de movimiento con ese código", http.StatusConflict)
+		return
+	}
+
+	var tipo TipoMovimiento
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		id := uuid.New()
+		if _, err := tx.Exec(`
+            INSERT INTO catalogos.tiposmovimiento (id, code, name, effect, requiresReason, isShrinkage, isSale)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+        `, id, c.Code, c.Name, c.Effect, c.RequiresReason, c.IsShrinkage, c.IsSale); err != nil {
+			return err
+		}
+		for _, m := range c.Reasons {
+			if _, err := tx.Exec(`
+                INSERT INTO catalogos.motivosmovimiento (id, movementTypeId, code, name)
+                VALUES ($1, $2, $3, $4)
+            `, uuid.New(), id, m.Code, m.Name); err != nil {
+				return err
+			}
+		}
+
+		tipos, err := cargarTiposMovimiento(tx, MovimientoTipo(c.Code))
+		if err != nil {
+			return err
+		}
+		tipo = tipos[0]
+		return nil
+	})
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tipo)
+}
+
+// CrearMotivoMovimiento godoc
+// @Summary      Agregar motivo a un tipo de movimiento
+// @Description  Agrega un código de motivo a un tipo de movimiento existente
+// @Tags         movimientos
+// @Accept       json
+// @Produce      json
+// @Param        motivo body CrearMotivoMovimiento true "Datos del motivo"
+// @Success      201  {object}  TipoMovimiento
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /CrearMotivoMovimiento [post]
+func (h *MovementTypeHandler) CrearMotivoMovimiento(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearMotivoMovimiento
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	c.Code, c.Name = strings.TrimSpace(c.Code), strings.TrimSpace(c.Name)
+	if !codigoCatalogo.MatchString(c.Code) || len(c.Code) > 30 || c.Name == "" {
+		http.Error(w, "Indique un código de hasta 30 mayúsculas, números o guion bajo y un nombre", http.StatusBadRequest)
+		return
+	}
+
+	tipos, err := cargarTiposMovimiento(h.db, c.MovementType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(tipos) == 0 {
+		http.Error(w, "Tipo de movimiento no encontrado", http.StatusNotFound)
+		return
+	}
+	for _, m := range tipos[0].Reasons {
+		if m.Code == c.Code {
+			http.Error(w, "El tipo ya tiene un motivo con ese código", http.StatusConflict)
+			return
+		}
+	}
+
+	if _, err := h.db.Exec(`
+        INSERT INTO catalogos.motivosmovimiento (id, movementTypeId, code, name)
+        VALUES ($1, $2, $3, $4)
+    `, uuid.New(), tipos[0].ID, c.Code, c.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if tipos, err = cargarTiposMovimiento(h.db, c.MovementType); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tipos[0])
+}
+
+// ToggleTipoMovimientoEstado godoc
+// @Summary      Activar/Desactivar tipo de movimiento
+// @Description  Cambia el estado de un tipo de movimiento; los tipos base no se pueden desactivar
+// @Tags         movimientos
+// @Accept       json
+// @Produce      json
+// @Param        code query string true "Código del tipo"
+// @Param        activate query boolean true "true para activar, false para desactivar"
+// @Success      200  {object}  TipoMovimiento
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /ActivarDesactivarTipoMovimiento [patch]
+func (h *MovementTypeHandler) ToggleTipoMovimientoEstado(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	code := MovimientoTipo(r.URL.Query().Get("code"))
+	activate := r.URL.Query().Get("activate") == "true"
+
+	var sistema bool
+	err := h.db.QueryRow(`
+        SELECT isSystem FROM catalogos.tiposmovimiento WHERE code = $1
+    `, code).Scan(&sistema)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Tipo de movimiento no encontrado", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sistema && !activate {
+		http.Error(w, "Los tipos base no se pueden desactivar", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.Exec(`
+        UPDATE catalogos.tiposmovimiento SET activo = $1 WHERE code = $2
+    `, activate, code); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tipos, err := cargarTiposMovimiento(h.db, code)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tipos[0])
+}
+
+// GetShrinkageReport godoc
+// @Summary      Reporte de merma por tienda
+// @Description  Unidades y costo que salieron por tipos de merma (bajas, daños, robos, descomposición y
+// @Description  faltantes de conteo) por tienda, con el desglose por tipo y motivo. El costo es el de las
+// @Description  capas consumidas por cada salida.
+// @Tags         reportes
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        from query string false "Desde (YYYY-MM-DD o RFC3339, por defecto hace 30 días)"
+// @Param        to query string false "Hasta, exclusivo (YYYY-MM-DD o RFC3339, por defecto ahora)"
+// @Success      200  {array}   MermaTienda
+// @Failure      400  {object}  map[string]string
+// @Router       /reports/shrinkage [get]
+func (h *MovementTypeHandler) GetShrinkageReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+	hasta := time.Now()
+	desde := hasta.AddDate(0, 0, -30)
+	for nombre, destino := range map[string]*time.Time{"from": &desde, "to": &hasta} {
+		if v := q.Get(nombre); v != "" {
+			fecha, err := parseFecha(v)
+			if err != nil {
+				http.Error(w, "Fecha inválida en "+nombre, http.StatusBadRequest)
+				return
+			}
+			*destino = fecha
+		}
+	}
+
+	rows, err := h.db.Query(`
+        SELECT m.sourceStoreId, s.name, m.type, t.name, mm.code, mm.name,
+               COUNT(*), SUM(m.quantity), COALESCE(SUM(c.costo), 0), COALESCE(c.currency, $4)
+        FROM prueba.movimientos m
+        JOIN catalogos.tiposmovimiento t ON t.tenantId = m.tenantId AND t.code = m.type
+        JOIN catalogos.tiendas s ON s.id = m.sourceStoreId
+        LEFT JOIN catalogos.motivosmovimiento mm ON mm.id = m.reasonId
+        LEFT JOIN LATERAL (
+            SELECT SUM(cc.quantity * cc.unit_cost) AS costo, MIN(cc.currency) AS currency
+            FROM prueba.consumoscosto cc WHERE cc.movementId = m.id
+        ) c ON true
+        WHERE m.activo = true AND t.isShrinkage = true
+          AND (t.effect = 'OUT' OR (t.effect = 'ADJUSTMENT' AND m.direction = -1))
+          AND m.timestamp >= $1 AND m.timestamp < $2
+          AND ($3::uuid IS NULL OR m.sourceStoreId = $3)
+        GROUP BY m.sourceStoreId, s.name, m.type, t.name, mm.code, mm.name, COALESCE(c.currency, $4)
+        ORDER BY s.name, SUM(m.quantity) DESC`, desde, hasta, storeID, money.DefaultCurrency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var filas []filaMerma
+	for rows.Next() {
+		var f filaMerma
+		if err := rows.Scan(&f.storeID, &f.storeName, &f.Type, &f.TypeName, &f.ReasonCode, &f.ReasonName,
+			&f.Movements, &f.Units, &f.Cost.Amount, &f.Cost.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		filas = append(filas, f)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agruparMerma(filas))
+}
+
+// validarTipoMovimiento normaliza y revisa un tipo nuevo; el efecto
+// ADJUSTMENT queda reservado a los conteos físicos
+func validarTipoMovimiento(c *CrearTipoMovimiento) error {
+	c.Code, c.Name = strings.TrimSpace(c.Code), strings.TrimSpace(c.Name)
+	if !codigoCatalogo.MatchString(c.Code) || len(c.Code) > 20 {
+		return fmt.Errorf("%w: el código debe tener hasta 20 mayúsculas, números o guion bajo", errTipoMovimientoInvalido)
+	}
+	if c.Name == "" {
+		return fmt.Errorf("%w: el nombre es requerido", errTipoMovimientoInvalido)
+	}
+	switch c.Effect {
+	case EfectoIN, EfectoOUT, EfectoTRANSFER, EfectoRELOCATE:
+	default:
+		return fmt.Errorf("%w: efecto inválido, use IN, OUT, TRANSFER o RELOCATE", errTipoMovimientoInvalido)
+	}
+	if c.IsShrinkage && c.Effect != EfectoOUT {
+		return fmt.Errorf("%w: sólo las salidas (OUT) cuentan como merma", errTipoMovimientoInvalido)
+	}
+	if c.IsSale && (c.Effect != EfectoOUT || c.IsShrinkage) {
+		return fmt.Errorf("%w: sólo las salidas (OUT) que no son merma cuentan como venta", errTipoMovimientoInvalido)
+	}
+	if c.RequiresReason && len(c.Reasons) == 0 {
+		return fmt.Errorf("%w: indique al menos un motivo", errTipoMovimientoInvalido)
+	}
+	vistos := make(map[string]bool, len(c.Reasons))
+	for i := range c.Reasons {
+		m := &c.Reasons[i]
+		m.Code, m.Name = strings.TrimSpace(m.Code), strings.TrimSpace(m.Name)
+		if !codigoCatalogo.MatchString(m.Code) || len(m.Code) > 30 || m.Name == "" {
+			return fmt.Errorf("%w: motivo %d: indique un código de hasta 30 mayúsculas, números o guion bajo y un nombre",
+				errTipoMovimientoInvalido, i+1)
+		}
+		if vistos[m.Code] {
+			return fmt.Errorf("%w: el motivo %s está repetido", errTipoMovimientoInvalido, m.Code)
+		}
+		vistos[m.Code] = true
+	}
+	return nil
+}
+
+// cargarTiposMovimiento obtiene los tipos del catálogo con sus motivos; con
+// code obtiene sólo ese tipo (lista vacía si no existe)
+func cargarTiposMovimiento(q utils.Querier, code MovimientoTipo) ([]TipoMovimiento, error) {
+	rows, err := q.Query(`
+        SELECT t.id, t.code, t.name, t.effect, t.requiresReason, t.isShrinkage, t.isSale, t.allowManual,
+               t.isSystem, t.activo, t.created_at, t.updated_at,
+               m.id, m.code, m.name, m.activo
+        FROM catalogos.tiposmovimiento t
+        LEFT JOIN catalogos.motivosmovimiento m ON m.movementTypeId = t.id
+        WHERE ($1 = '' OR t.code = $1)
+        ORDER BY t.isSystem DESC, t.code, m.code
+    `, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tipos := []TipoMovimiento{}
+	for rows.Next() {
+		var t TipoMovimiento
+		var motivoID *uuid.UUID
+		var motivoCodigo, motivoNombre *string
+		var motivoActivo *bool
+		if err := rows.Scan(&t.ID, &t.Code, &t.Name, &t.Effect, &t.RequiresReason, &t.IsShrinkage,
+			&t.IsSale, &t.AllowManual, &t.IsSystem, &t.Activo, &t.CreatedAt, &t.UpdatedAt,
+			&motivoID, &motivoCodigo, &motivoNombre, &motivoActivo); err != nil {
+			return nil, err
+		}
+		if n := len(tipos); n == 0 || tipos[n-1].ID != t.ID {
+			t.Reasons = []MotivoMovimiento{}
+			tipos = append(tipos, t)
+		}
+		if motivoID != nil {
+			ultimo := &tipos[len(tipos)-1]
+			ultimo.Reasons = append(ultimo.Reasons, MotivoMovimiento{ID: *motivoID, Code: *motivoCodigo,
+				Name: *motivoNombre, Activo: *motivoActivo})
+		}
+	}
+	return tipos, rows.Err()
+}
+
+// cargarTipoMovimiento obtiene un tipo activo del catálogo
+func cargarTipoMovimiento(q utils.Querier, code MovimientoTipo) (TipoMovimiento, error) {
+	tipos, err := cargarTiposMovimiento(q, code)
+	if err != nil {
+		return TipoMovimiento{}, err
+	}
+	if len(tipos) == 0 || !tipos[0].Activo {
+		return TipoMovimiento{}, fmt.Errorf("%w: %q no existe o está inactivo", errTipoMovimientoInvalido, code)
+	}
+	return tipos[0], nil
+}
+
+// motivoDeTipo valida el motivo indicado para el tipo y devuelve su ID; los
+// tipos que exigen motivo lo rechazan vacío
+func motivoDeTipo(t TipoMovimiento, code string) (*uuid.UUID, error) {
+	if code == "" {
+		if t.RequiresReason {
+			return nil, fmt.Errorf("%w: el tipo %s requiere motivo (%s)", errTipoMovimientoInvalido,
+				t.Code, strings.Join(codigosMotivo(t), ", "))
+		}
+		return nil, nil
+	}
+	for _, m := range t.Reasons {
+		if m.Code == code && m.Activo {
+			return &m.ID, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: motivo %s inválido para %s, use %s", errTipoMovimientoInvalido,
+		code, t.Code, strings.Join(codigosMotivo(t), ", "))
+}
+
+func codigosMotivo(t TipoMovimiento) []string {
+	var codigos []string
+	for _, m := range t.Reasons {
+		if m.Activo {
+			codigos = append(codigos, m.Code)
+		}
+	}
+	return codigos
+}
+
+// agruparMerma agrupa las filas del reporte por tienda conservando su orden y
+// suma unidades y costo (por moneda)
+func agruparMerma(filas []filaMerma) []MermaTienda {
+	tiendas := []MermaTienda{}
+	indice := make(map[uuid.UUID]int)
+	for _, f := range filas {
+		i, ok := indice[f.storeID]
+		if !ok {
+			i = len(tiendas)
+			indice[f.storeID] = i
+			tiendas = append(tiendas, MermaTienda{StoreID: f.storeID, StoreName: f.storeName})
+		}
+		t := &tiendas[i]
+		t.Units += f.Units
+		t.Lines = append(t.Lines, f.LineaMerma)
+
+		sumado := false
+		for j := range t.Cost {
+			if t.Cost[j].Currency == f.Cost.Currency {
+				t.Cost[j] = t.Cost[j].Add(f.Cost)
+				sumado = true
+			}
+		}
+		if !sumado {
+			t.Cost = append(t.Cost, f.Cost)
+		}
+	}
+	return tiendas
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"go-project/money"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMotivoDeTipo(t *testing.T) {
+	rotura, otro := uuid.New(), uuid.New()
+	dano := TipoMovimiento{Code: "DAMAGE", RequiresReason: true, Reasons: []MotivoMovimiento{
+		{ID: rotura, Code: "BREAKAGE", Activo: true},
+		{ID: otro, Code: "PESTS", Activo: false},
+	}}
+
+	if id, err := motivoDeTipo(dano, "BREAKAGE"); err != nil || id == nil || *id != rotura {
+		t.Errorf("motivo válido = %v %v", id, err)
+	}
+	for _, code := range []string{"", "PESTS", "THEFT"} {
+		if _, err := motivoDeTipo(dano, code); !errors.Is(err, errTipoMovimientoInvalido) {
+			t.Errorf("motivo %q: err = %v", code, err)
+		}
+	}
+
+	if id, err := motivoDeTipo(TipoMovimiento{Code: movimientoIN}, ""); err != nil || id != nil {
+		t.Errorf("tipo sin motivo = %v %v", id, err)
+	}
+}
+
+func TestValidarTipoMovimiento(t *testing.T) {
+	// Un tipo propio de merma se normaliza con sus motivos
+	muestra := CrearTipoMovimiento{Code: " SAMPLE ", Name: " Muestra para degustación ", Effect: EfectoOUT,
+		RequiresReason: true, IsShrinkage: true,
+		Reasons: []CrearMotivo{{Code: " TASTING ", Name: " Degustación "}, {Code: "PROMO", Name: "Promoción"}}}
+	if err := validarTipoMovimiento(&muestra); err != nil {
+		t.Fatalf("tipo de merma: %v", err)
+	}
+	if muestra.Code != "SAMPLE" || muestra.Name != "Muestra para degustación" ||
+		muestra.Reasons[0].Code != "TASTING" || muestra.Reasons[0].Name != "Degustación" {
+		t.Errorf("tipo normalizado = %+v", muestra)
+	}
+
+	// Una salida puede contar como venta (consignación), pero no si es merma
+	consignacion := CrearTipoMovimiento{Code: "CONSIGNMENT", Name: "Venta en consignación", Effect: EfectoOUT, IsSale: true}
+	if err := validarTipoMovimiento(&consignacion); err != nil {
+		t.Errorf("venta en consignación: err = %v", err)
+	}
+	consignacion.IsShrinkage = true
+	if err := validarTipoMovimiento(&consignacion); err == nil || !strings.Contains(err.Error(), "no son merma") {
+		t.Errorf("venta y merma: err = %v", err)
+	}
+
+	// Merma y venta sólo descuentan existencia: una entrada no puede ser ninguna
+	for _, entrada := range []CrearTipoMovimiento{
+		{Code: "FOUND", Name: "Sobrante", Effect: EfectoIN, IsShrinkage: true},
+		{Code: "FOUND", Name: "Sobrante", Effect: EfectoIN, IsSale: true},
+	} {
+		if err := validarTipoMovimiento(&entrada); !errors.Is(err, errTipoMovimientoInvalido) {
+			t.Errorf("entrada %+v: err = %v", entrada, err)
+		}
+	}
+
+	// ADJUSTMENT queda para los conteos físicos
+	ajuste := CrearTipoMovimiento{Code: "REAJUSTE", Name: "Reajuste", Effect: EfectoADJUSTMENT}
+	if err := validarTipoMovimiento(&ajuste); err == nil || !strings.Contains(err.Error(), "IN, OUT, TRANSFER o RELOCATE") {
+		t.Errorf("efecto de conteo: err = %v", err)
+	}
+
+	// Un tipo que exige motivo necesita al menos uno, sin repetir códigos tras normalizar
+	sinMotivos := CrearTipoMovimiento{Code: "SAMPLE", Name: "Muestra", Effect: EfectoOUT, RequiresReason: true}
+	if err := validarTipoMovimiento(&sinMotivos); err == nil || !strings.Contains(err.Error(), "al menos un motivo") {
+		t.Errorf("sin motivos: err = %v", err)
+	}
+	repetido := CrearTipoMovimiento{Code: "SAMPLE", Name: "Muestra", Effect: EfectoOUT,
+		Reasons: []CrearMotivo{{Code: "PROMO", Name: "Promoción"}, {Code: " PROMO", Name: "Otra promoción"}}}
+	if err := validarTipoMovimiento(&repetido); err == nil || !strings.Contains(err.Error(), "PROMO está repetido") {
+		t.Errorf("motivo repetido: err = %v", err)
+	}
+
+	// El código cabe en la columna del catálogo
+	largo := CrearTipoMovimiento{Code: "SAMPLE_WITH_A_LONG_CODE", Name: "Muestra", Effect: EfectoOUT}
+	if err := validarTipoMovimiento(&largo); !errors.Is(err, errTipoMovimientoInvalido) {
+		t.Errorf("código largo: err = %v", err)
+	}
+}
+
+func TestValidarMovimientoManual(t *testing.T) {
+	tienda, anaquel := uuid.New(), uuid.New()
+	rotura := uuid.New()
+	dano := TipoMovimiento{Code: "DAMAGE", Effect: EfectoOUT, RequiresReason: true, IsShrinkage: true, AllowManual: true,
+		Reasons: []MotivoMovimiento{{ID: rotura, Code: "BREAKAGE", Activo: true}, {ID: uuid.New(), Code: "OTHER", Activo: true}}}
+	ajuste := TipoMovimiento{Code: "ADJUSTMENT", Effect: EfectoADJUSTMENT, IsShrinkage: true, IsSystem: true}
+	salida := func(motivo string) CrearMovimiento {
+		return CrearMovimiento{ProductID: uuid.New(), SourceStoreID: tienda, TargetStoreID: tienda, Quantity: 2,
+			Type: dano.Code, ReasonCode: motivo, SourceLocationID: &anaquel}
+	}
+
+	// El daño se registra con su motivo desde la ubicación de origen
+	if id, err := validarMovimientoManual(dano, salida("BREAKAGE")); err != nil || id == nil || *id != rotura {
+		t.Errorf("daño = %v %v", id, err)
+	}
+
+	// Sin motivo el error lista los motivos del tipo
+	if _, err := validarMovimientoManual(dano, salida("")); err == nil || !strings.Contains(err.Error(), "BREAKAGE, OTHER") {
+		t.Errorf("daño sin motivo: err = %v", err)
+	}
+
+	// Una salida no lleva ubicación de destino
+	mov := salida("BREAKAGE")
+	mov.TargetLocationID = &anaquel
+	if _, err := validarMovimientoManual(dano, mov); !errors.Is(err, errUbicacionInvalida) {
+		t.Errorf("daño con destino: err = %v", err)
+	}
+
+	// Los ajustes sólo los generan los conteos físicos
+	mov = salida("")
+	mov.Type = ajuste.Code
+	if _, err := validarMovimientoManual(ajuste, mov); err == nil || !strings.Contains(err.Error(), "ADJUSTMENT no se puede registrar manualmente") {
+		t.Errorf("ajuste manual: err = %v", err)
+	}
+}
+
+func TestAgruparMerma(t *testing.T) {
+	centro, norte := uuid.New(), uuid.New()
+	fila := func(tienda uuid.UUID, tipo MovimientoTipo, unidades int, costo string, moneda string) filaMerma {
+		return filaMerma{storeID: tienda, storeName: tienda.String(), LineaMerma: LineaMerma{
+			Type: tipo, Units: unidades, Movements: 1, Cost: money.MustParse(costo, moneda)}}
+	}
+
+	tiendas := agruparMerma([]filaMerma{
+		fila(centro, "DAMAGE", 5, "100.50", "MXN"),
+		fila(norte, "THEFT", 2, "40", "MXN"),
+		fila(centro, "THEFT", 3, "20.25", "MXN"),
+		fila(centro, "SPOILAGE", 1, "10", "USD"),
+	})
+	if len(tiendas) != 2 || tiendas[0].StoreID != centro || tiendas[1].StoreID != norte {
+		t.Fatalf("tiendas = %+v", tiendas)
+	}
+	c := tiendas[0]
+	if c.Units != 9 || len(c.Lines) != 3 || len(c.Cost) != 2 {
+		t.Fatalf("centro = %+v", c)
+	}
+	if !c.Cost[0].Equal(money.MustParse("120.75", "MXN")) || !c.Cost[1].Equal(money.MustParse("10", "USD")) {
+		t.Errorf("costo centro = %v", c.Cost)
+	}
+	if len(agruparMerma(nil)) != 0 {
+		t.Error("sin filas debe devolver lista vacía")
+	}
+}
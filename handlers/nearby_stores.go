@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"go-project/stores"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TiendaCercana tienda con existencia de un producto cerca de un punto
+type TiendaCercana struct {
+	StoreID   uuid.UUID `json:"store_id"`
+	Name      string    `json:"name" example:"Tienda Central"`
+	Address   string    `json:"address" example:"Av. Principal 123"`
+	Phone     string    `json:"phone" example:"555-0123"`
+	Latitude  float64   `json:"latitude" example:"19.432608"`
+	Longitude float64   `json:"longitude" example:"-99.133209"`
+	Timezone  string    `json:"timezone" example:"America/Mexico_City"`
+	// Distancia en línea recta desde el punto de búsqueda
+	DistanceKm float64 `json:"distance_km" example:"2.4"`
+	// Existencia del producto en la tienda
+	Quantity int  `json:"quantity" example:"12"`
+	OpenNow  bool `json:"open_now" example:"true"`
+	// Hora local a la que cierra si está abierta
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+	// Próxima apertura si está cerrada
+	NextOpening *time.Time `json:"next_opening,omitempty"`
+}
+
+// busquedaTiendas criterios de la búsqueda de tiendas cercanas
+type busquedaTiendas struct {
+	latitude    float64
+	longitude   float64
+	radiusKm    float64
+	minQuantity int
+	openOnly    bool
+	limit       int
+}
+
+// candidatoTienda tienda activa con coordenadas y existencia del producto
+type candidatoTienda struct {
+	TiendaCercana
+	horario stores.Hours
+}
+
+// GetNearbyStores godoc
+// @Summary      Tiendas cercanas con existencia
+// @Description  Busca las tiendas activas con coordenadas que tienen al menos min_quantity unidades del
+// @Description  producto dentro de radius_km del punto indicado, ordenadas por distancia (haversine).
+// @Description  De forma predeterminada sólo se incluyen las tiendas abiertas en este momento según su
+// @Description  horario y zona horaria; con open_now=false se incluyen también las cerradas.
+// @Tags         tiendas
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string false "ID del producto"
+// @Param        barcode query string false "Código de barras del producto"
+// @Param        lat query number true "Latitud del punto de búsqueda"
+// @Param        lng query number true "Longitud del punto de búsqueda"
+// @Param        radius_km query number false "Radio de búsqueda en km (predeterminado 25)"
+// @Param        min_quantity query int false "Existencia mínima (predeterminado 1)"
+// @Param        open_now query boolean false "Sólo tiendas abiertas (predeterminado true)"
+// @Param        limit query int false "Máximo de tiendas (predeterminado 10)"
+// @Success      200  {array}   TiendaCercana
+// @Failure      400  {object}  map[string]string
+// @Router       /stores/nearby [get]
+func (h *ShopHandler) GetNearbyStores(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	busqueda, err := leerBusquedaTiendas(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var productID uuid.UUID
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		if productID, err = uuid.Parse(v); err != nil {
+			http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+			return
+		}
+	}
+	productID, err = resolverProducto(h.db, productID, r.URL.Query().Get("barcode"))
+	if errors.Is(err, errCodigoBarrasInvalido) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT t.id, t.name, COALESCE(t.address, ''), COALESCE(t.phone, ''),
+               t.latitude, t.longitude, t.timezone, i.quantity
+        FROM catalogos.tiendas t
+        JOIN prueba.inventarios i ON i.storeId = t.id
+        WHERE i.productId = $1 AND i.activo = true AND i.quantity >= $2
+          AND t.activo = true AND t.latitude IS NOT NULL
+    `, productID, busqueda.minQuantity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var candidatos []candidatoTienda
+	var storeIDs []uuid.UUID
+	for rows.Next() {
+		var c candidatoTienda
+		if err := rows.Scan(&c.StoreID, &c.Name, &c.Address, &c.Phone,
+			&c.Latitude, &c.Longitude, &c.Timezone, &c.Quantity); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		candidatos = append(candidatos, c)
+		storeIDs = append(storeIDs, c.StoreID)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	horarios, err := cargarHorarios(h.db, storeIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range candidatos {
+		candidatos[i].horario = horarios[candidatos[i].StoreID]
+	}
+
+	tiendas := clasificarTiendasCercanas(candidatos, busqueda, time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tiendas)
+}
+
+func leerBusquedaTiendas(r *http.Request) (busquedaTiendas, error) {
+	q := r.URL.Query()
+	b := busquedaTiendas{radiusKm: 25, openOnly: true}
+
+	lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+	lng, errLng := strconv.ParseFloat(q.Get("lng"), 64)
+	if errLat != nil || errLng != nil || !stores.ValidCoordinates(lat, lng) {
+		return b, errors.New("Coordenadas inválidas: use lat entre -90 y 90 y lng entre -180 y 180")
+	}
+	b.latitude, b.longitude = lat, lng
+
+	if v := q.Get("radius_km"); v != "" {
+		radio, err := strconv.ParseFloat(v, 64)
+		if err != nil || radio <= 0 || radio > 1000 {
+			return b, errors.New("radius_km inválido, use un valor entre 0 y 1000")
+		}
+		b.radiusKm = radio
+	}
+	if v := q.Get("open_now"); v != "" {
+		abiertas, err := strconv.ParseBool(v)
+		if err != nil {
+			return b, errors.New("open_now inválido, use true o false")
+		}
+		b.openOnly = abiertas
+	}
+
+	var err error
+	if b.minQuantity, err = leerEntero(r, "min_quantity", 1, 1, 1000000); err != nil {
+		return b, err
+	}
+	if b.limit, err = leerEntero(r, "limit", 10, 1, 100); err != nil {
+		return b, err
+	}
+	return b, nil
+}
+
+// clasificarTiendasCercanas calcula la distancia y el estado de cada tienda
+// en su zona horaria, descarta las que quedan fuera del radio (o cerradas si
+// se pidieron sólo abiertas) y las ordena de la más cercana a la más lejana
+func clasificarTiendasCercanas(candidatos []candidatoTienda, b busquedaTiendas, ahora time.Time) []TiendaCercana {
+	tiendas := []TiendaCercana{}
+	for _, c := range candidatos {
+		t := c.TiendaCercana
+		t.DistanceKm = stores.DistanceKm(b.latitude, b.longitude, t.Latitude, t.Longitude)
+		if t.DistanceKm > b.radiusKm {
+			continue
+		}
+
+		loc, err := time.LoadLocation(t.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		local := ahora.In(loc)
+		if len(c.horario) == 0 {
+			t.OpenNow = true
+		} else if cierra, ok := c.horario.OpenUntil(local); ok {
+			t.OpenNow = true
+			t.ClosesAt = &cierra
+		} else if abre, ok := c.horario.NextOpening(local); ok {
+			t.NextOpening = &abre
+		}
+		if b.openOnly && !t.OpenNow {
+			continue
+		}
+		t.DistanceKm = float64(int(t.DistanceKm*100+0.5)) / 100
+		tiendas = append(tiendas, t)
+	}
+
+	sort.SliceStable(tiendas, func(i, j int) bool {
+		if tiendas[i].DistanceKm != tiendas[j].DistanceKm {
+			return tiendas[i].DistanceKm < tiendas[j].DistanceKm
+		}
+		return tiendas[i].Quantity > tiendas[j].Quantity
+	})
+	if len(tiendas) > b.limit {
+		tiendas = tiendas[:b.limit]
+	}
+	return tiendas
+}
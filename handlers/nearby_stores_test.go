@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"go-project/stores"
+	"testing"
+	"time"
+)
+
+func TestClasificarTiendasCercanas(t *testing.T) {
+	horario := stores.Hours{{Day: time.Monday, Opens: "09:00", Closes: "21:00"}}
+	candidato := func(nombre string, lat, lng float64, cantidad int, h stores.Hours) candidatoTienda {
+		c := candidatoTienda{horario: h}
+		c.Name, c.Latitude, c.Longitude, c.Quantity = nombre, lat, lng, cantidad
+		c.Timezone = "America/Mexico_City"
+		return c
+	}
+	candidatos := []candidatoTienda{
+		candidato("Lejana", 19.50, -99.13, 5, nil),
+		candidato("Cercana", 19.44, -99.13, 3, horario),
+		candidato("Fuera de radio", 20.60, -100.40, 9, nil),
+		candidato("Cerrada", 19.43, -99.14, 7, stores.Hours{{Day: time.Sunday, Opens: "10:00", Closes: "14:00"}}),
+	}
+	b := busquedaTiendas{latitude: 19.4326, longitude: -99.1332, radiusKm: 25, openOnly: true, limit: 10}
+	// Lunes 2024-03-04 12:00 en la Ciudad de México (UTC-6)
+	ahora := time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC)
+
+	tiendas := clasificarTiendasCercanas(candidatos, b, ahora)
+	if len(tiendas) != 2 || tiendas[0].Name != "Cercana" || tiendas[1].Name != "Lejana" {
+		t.Fatalf("tiendas = %+v", tiendas)
+	}
+	if tiendas[0].ClosesAt == nil || tiendas[0].ClosesAt.Hour() != 21 {
+		t.Errorf("closes_at = %v", tiendas[0].ClosesAt)
+	}
+
+	// Incluyendo las cerradas aparece la próxima apertura
+	b.openOnly = false
+	tiendas = clasificarTiendasCercanas(candidatos, b, ahora)
+	if len(tiendas) != 3 || tiendas[0].Name != "Cerrada" || tiendas[0].OpenNow || tiendas[0].NextOpening == nil {
+		t.Fatalf("tiendas = %+v", tiendas)
+	}
+	if tiendas[0].NextOpening.Weekday() != time.Sunday {
+		t.Errorf("next_opening = %v", tiendas[0].NextOpening)
+	}
+
+	b.limit = 1
+	if tiendas = clasificarTiendasCercanas(candidatos, b, ahora); len(tiendas) != 1 {
+		t.Errorf("limit no aplicado: %d tiendas", len(tiendas))
+	}
+}
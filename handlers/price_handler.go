@@ -0,0 +1,613 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/money"
+	"go-project/utils"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errReferenciaPrecio el producto o la lista del precio no existe
+var errReferenciaPrecio = errors.New("Referencia de precio inválida")
+
+// TipoLista tipo de lista de precios
+type TipoLista string
+
+const (
+	ListaRETAIL    TipoLista = "RETAIL"
+	ListaWHOLESALE TipoLista = "WHOLESALE"
+	ListaSTORE     TipoLista = "STORE"
+)
+
+// ListaPrecios modelo de lista de precios
+// @Description Lista de precios (menudeo, mayoreo o especial por tienda)
+type ListaPrecios struct {
+	ID        uuid.UUID  `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name      string     `json:"name" example:"Mayoreo"`
+	Type      TipoLista  `json:"type" example:"WHOLESALE"`
+	StoreID   *uuid.UUID `json:"store_id,omitempty"`
+	Activo    bool       `json:"activo" example:"true"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// CrearListaPrecios modelo para crear una lista de precios
+type CrearListaPrecios struct {
+	Name    string     `json:"name" example:"Precios Sucursal Norte" binding:"required"`
+	Type    TipoLista  `json:"type" example:"STORE" binding:"required"`
+	StoreID *uuid.UUID `json:"store_id,omitempty"`
+}
+
+// Precio registro del historial de precios de un producto en una lista
+type Precio struct {
+	ID            uuid.UUID   `json:"id"`
+	ProductID     uuid.UUID   `json:"product_id"`
+	ListID        uuid.UUID   `json:"list_id"`
+	Price         money.Money `json:"price"`
+	EffectiveFrom time.Time   `json:"effective_from"`
+	EffectiveTo   *time.Time  `json:"effective_to,omitempty"`
+	Activo        bool        `json:"activo"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// ProgramarPrecio modelo para registrar un precio actual o futuro
+type ProgramarPrecio struct {
+	ProductID     uuid.UUID   `json:"product_id" binding:"required"`
+	ListID        uuid.UUID   `json:"list_id" binding:"required"`
+	Price         money.Money `json:"price" binding:"required"`
+	EffectiveFrom time.Time   `json:"effective_from" example:"2024-12-01T00:00:00Z" binding:"required"`
+	EffectiveTo   *time.Time  `json:"effective_to,omitempty" example:"2024-12-31T23:59:59Z"`
+}
+
+// PrecioVigente precio aplicable a un producto en una fecha
+type PrecioVigente struct {
+	ProductID     uuid.UUID   `json:"product_id"`
+	ListID        uuid.UUID   `json:"list_id"`
+	ListType      TipoLista   `json:"list_type"`
+	Price         money.Money `json:"price"`
+	EffectiveFrom time.Time   `json:"effective_from"`
+	EffectiveTo   *time.Time  `json:"effective_to,omitempty"`
+	Date          time.Time   `json:"date"`
+}
+
+type PriceHandler struct {
+	db *sql.DB
+}
+
+func NewPriceHandler(db *sql.DB) *PriceHandler {
+	return &PriceHandler{db: db}
+}
+
+// ListarListasPrecios godoc
+// @Summary      Listar listas de precios
+// @Description  Obtiene las listas de precios activas
+// @Tags         precios
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   ListaPrecios
+// @Failure      500  {object}  map[string]string
+// @Router       /ListarListasPrecios [get]
+func (h *PriceHandler) ListarListasPrecios(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, name, type, storeId, activo, created_at, updated_at
+        FROM catalogos.listasprecios
+        WHERE activo = true
+        ORDER BY type, name
+    `)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var listas []ListaPrecios
+	for rows.Next() {
+		var l ListaPrecios
+		err := rows.Scan(&l.ID, &l.Name, &l.Type, &l.StoreID, &l.Activo, &l.CreatedAt, &l.UpdatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		listas = append(listas, l)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listas)
+}
+
+// CrearListaPrecios godoc
+// @Summary      Crear lista de precios
+// @Description  Crea una lista de precios de mayoreo o especial para una tienda
+// @Tags         precios
+// @Accept       json
+// @Produce      json
+// @Param        lista body CrearListaPrecios true "Datos de la lista"
+// @Success      201  {object}  ListaPrecios
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearListaPrecios [post]
+func (h *PriceHandler) CrearListaPrecios(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var l CrearListaPrecios
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		responderDatosInvalidos(w, err)
+		return
+	}
+
+	if l.Name == "" {
+		http.Error(w, "El nombre es requerido", http.StatusBadRequest)
+		return
+	}
+	if l.Type != ListaRETAIL && l.Type != ListaWHOLESALE && l.Type != ListaSTORE {
+		http.Error(w, "Tipo de lista inválido", http.StatusBadRequest)
+		return
+	}
+	if (l.Type == ListaSTORE) != (l.StoreID != nil) {
+		http.Error(w, "Las listas STORE requieren store_id y las demás no lo admiten", http.StatusBadRequest)
+		return
+	}
+
+	var lista ListaPrecios
+	err := h.db.QueryRow(`
+        INSERT INTO catalogos.listasprecios (id, name, type, storeId, activo)
+        VALUES ($1, $2, $3, $4, true)
+        RETURNING id, name, type, storeId, activo, created_at, updated_at
+    `, uuid.New(), l.Name, l.Type, l.StoreID).Scan(
+		&lista.ID, &lista.Name, &lista.Type, &lista.StoreID,
+		&lista.Activo, &lista.CreatedAt, &lista.UpdatedAt)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(lista)
+}
+
+// HistorialPrecios godoc
+// @Summary      Historial de precios
+// @Description  Obtiene el historial de precios (pasados, vigentes y programados) de un producto
+// @Tags         precios
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string true "ID del producto"
+// @Param        list_id query string false "ID de la lista de precios"
+// @Success      200  {array}   Precio
+// @Failure      400  {object}  map[string]string
+// @Router       /HistorialPrecios [get]
+func (h *PriceHandler) HistorialPrecios(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	productID, err := uuid.Parse(r.URL.Query().Get("product_id"))
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	var listID *uuid.UUID
+	if v := r.URL.Query().Get("list_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de lista inválido", http.StatusBadRequest)
+			return
+		}
+		listID = &id
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, productId, listId, price, currency, effective_from, effective_to, activo, created_at
+        FROM catalogos.precios
+        WHERE productId = $1 AND ($2::uuid IS NULL OR listId = $2) AND activo = true
+        ORDER BY listId, effective_from DESC
+    `, productID, listID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var precios []Precio
+	for rows.Next() {
+		var p Precio
+		err := rows.Scan(&p.ID, &p.ProductID, &p.ListID, &p.Price.Amount, &p.Price.Currency,
+			&p.EffectiveFrom, &p.EffectiveTo, &p.Activo, &p.CreatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		precios = append(precios, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(precios)
+}
+
+// ProgramarPrecio godoc
+// @Summary      Programar precio
+// @Description  Registra un precio para un periodo; si inicia en el futuro se activa automáticamente al llegar la fecha
+// @Tags         precios
+// @Accept       json
+// @Produce      json
+// @Param        precio body ProgramarPrecio true "Datos del precio"
+// @Success      201  {object}  ProgramarPrecio
+// @Failure      400  {object}  map[string]string
+// @Router       /ProgramarPrecio [post]
+func (h *PriceHandler) ProgramarPrecio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var p ProgramarPrecio
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		responderDatosInvalidos(w, err)
+		return
+	}
+
+	if p.Price.IsNegative() {
+		http.Error(w, "El precio debe ser no negativo", http.StatusBadRequest)
+		return
+	}
+	p.Price = money.New(p.Price.Amount, p.Price.Currency)
+	if p.EffectiveFrom.IsZero() {
+		http.Error(w, "La fecha de inicio es requerida", http.StatusBadRequest)
+		return
+	}
+	if p.EffectiveTo != nil && !p.EffectiveTo.After(p.EffectiveFrom) {
+		http.Error(w, "La fecha de fin debe ser posterior a la de inicio", http.StatusBadRequest)
+		return
+	}
+
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var producto, lista bool
+		if err := tx.QueryRow(`
+            SELECT EXISTS(SELECT 1 FROM catalogos.productos WHERE id = $1),
+                   EXISTS(SELECT 1 FROM catalogos.listasprecios WHERE id = $2 AND activo = true)
+        `, p.ProductID, p.ListID).Scan(&producto, &lista); err != nil {
+			return err
+		}
+		if !producto {
+			return fmt.Errorf("%w: producto no encontrado", errReferenciaPrecio)
+		}
+		if !lista {
+			return fmt.Errorf("%w: lista de precios no encontrada o inactiva", errReferenciaPrecio)
+		}
+		if err := programarPrecio(tx, p.ProductID, p.ListID, p.Price, p.EffectiveFrom, p.EffectiveTo); err != nil {
+			return err
+		}
+		// Si el precio ya está vigente se refleja en el catálogo en la misma
+		// transacción, así un error no deja el precio registrado a medias
+		_, err := activarPrecios(tx, &p.ProductID)
+		return err
+	})
+	if err != nil {
+		if esErrorDeNegocio(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+// ObtenerPrecio godoc
+// @Summary      Obtener precio a una fecha
+// @Description  Obtiene el precio vigente de un producto en una fecha: el precio especial de la tienda si existe,
+// @Description  luego el de la lista indicada y, sin precio en ella, el de menudeo
+// @Tags         precios
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string true "ID del producto"
+// @Param        list_id query string false "ID de la lista (por defecto menudeo)"
+// @Param        store_id query string false "ID de la tienda"
+// @Param        date query string false "Fecha (YYYY-MM-DD o RFC3339, por defecto ahora)"
+// @Success      200  {object}  PrecioVigente
+// @Failure      404  {object}  map[string]string
+// @Router       /ObtenerPrecio [get]
+func (h *PriceHandler) ObtenerPrecio(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	productID, err := uuid.Parse(q.Get("product_id"))
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	var listID uuid.UUID
+	if v := q.Get("list_id"); v != "" {
+		if listID, err = uuid.Parse(v); err != nil {
+			http.Error(w, "ID de lista inválido", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	fecha := time.Now()
+	if v := q.Get("date"); v != "" {
+		if fecha, err = parseFecha(v); err != nil {
+			http.Error(w, "Fecha inválida", http.StatusBadRequest)
+			return
+		}
+	}
+
+	precio, err := precioVigente(h.db, productID, listID, storeID, fecha)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No existe precio vigente para la fecha indicada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(precio)
+}
+
+// ActivarPreciosProgramados copia al catálogo de productos el precio de menudeo
+// vigente, de modo que los precios programados entran en vigor sin intervención
+func (h *PriceHandler) ActivarPreciosProgramados() (int64, error) {
+	return activarPrecios(h.db, nil)
+}
+
+// activarPrecios copia el precio de menudeo vigente al catálogo de productos;
+// con productID sólo actualiza ese producto
+func activarPrecios(q utils.Querier, productID *uuid.UUID) (int64, error) {
+	result, err := q.Exec(`
+        UPDATE catalogos.productos p
+        SET price = pr.price, currency = pr.currency, updated_at = CURRENT_TIMESTAMP
+        FROM catalogos.precios pr
+        JOIN catalogos.listasprecios l ON pr.listId = l.id
+        WHERE pr.productId = p.id
+          AND l.type = 'RETAIL'
+          AND pr.activo = true
+          AND pr.effective_from <= CURRENT_TIMESTAMP
+          AND (pr.effective_to IS NULL OR pr.effective_to > CURRENT_TIMESTAMP)
+          AND ($1::uuid IS NULL OR p.id = $1)
+          AND (p.price <> pr.price OR p.currency <> pr.currency)`, productID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// listaMenudeo obtiene el ID de la lista de precios de menudeo general
+func listaMenudeo(q utils.Querier) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := q.QueryRow(`
+        SELECT id FROM catalogos.listasprecios
+        WHERE type = 'RETAIL' AND activo = true`).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("no existe lista de precios de menudeo")
+	}
+	return id, err
+}
+
+// periodoPrecio periodo [from, to) de un precio en una lista; sin to queda abierto
+type periodoPrecio struct {
+	id    uuid.UUID
+	price money.Money
+	from  time.Time
+	to    *time.Time
+}
+
+// planPrecio cambios sobre los periodos de una lista al programar un precio
+type planPrecio struct {
+	// Periodos existentes con sus fechas recortadas o recorridas
+	ajustar []periodoPrecio
+	// Periodos existentes cubiertos por el nuevo
+	descartar []uuid.UUID
+	// El periodo nuevo y, si partió uno existente, el resto de éste
+	insertar []periodoPrecio
+}
+
+// programarPrecio inserta un precio para el periodo [desde, hasta) en una lista,
+// recortando los periodos existentes que se traslapan. Si hasta es nil el precio
+// queda vigente hasta el siguiente precio programado.
+func programarPrecio(tx *sql.Tx, productID, listID uuid.UUID, price money.Money, desde time.Time, hasta *time.Time) error {
+	// Sólo los periodos que siguen vigentes al inicio pueden traslaparse
+	rows, err := tx.Query(`
+        SELECT id, price, currency, effective_from, effective_to FROM catalogos.precios
+        WHERE productId = $1 AND listId = $2 AND activo = true
+          AND (effective_to IS NULL OR effective_to > $3)
+        ORDER BY effective_from
+        FOR UPDATE
+    `, productID, listID, desde)
+	if err != nil {
+		return err
+	}
+	var periodos []periodoPrecio
+	for rows.Next() {
+		var p periodoPrecio
+		if err := rows.Scan(&p.id, &p.price.Amount, &p.price.Currency, &p.from, &p.to); err != nil {
+			rows.Close()
+			return err
+		}
+		periodos = append(periodos, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	plan := planificarPrecio(periodos, price, desde, hasta)
+	for _, id := range plan.descartar {
+		if _, err := tx.Exec(`UPDATE catalogos.precios SET activo = false WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+	for _, p := range plan.ajustar {
+		if _, err := tx.Exec(`
+            UPDATE catalogos.precios SET effective_from = $2, effective_to = $3 WHERE id = $1
+        `, p.id, p.from, p.to); err != nil {
+			return err
+		}
+	}
+	for _, p := range plan.insertar {
+		if _, err := tx.Exec(`
+            INSERT INTO catalogos.precios (id, productId, listId, price, currency, effective_from, effective_to)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+        `, p.id, productID, listID, p.price.Amount, p.price.Currency, p.from, p.to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planificarPrecio calcula los cambios para programar price en [desde, hasta)
+// sobre los periodos activos de la lista. El periodo que contiene el inicio se
+// corta y, si además rebasa el fin, su resto se conserva después del nuevo;
+// los que inician dentro del nuevo se descartan o se recorren a su fin.
+func planificarPrecio(periodos []periodoPrecio, price money.Money, desde time.Time, hasta *time.Time) planPrecio {
+	if hasta == nil {
+		for _, p := range periodos {
+			if p.from.After(desde) && (hasta == nil || p.from.Before(*hasta)) {
+				siguiente := p.from
+				hasta = &siguiente
+			}
+		}
+	}
+
+	var plan planPrecio
+	for _, p := range periodos {
+		switch {
+		case p.from.Before(desde):
+			if p.to != nil && !p.to.After(desde) {
+				continue
+			}
+			if hasta != nil && (p.to == nil || p.to.After(*hasta)) {
+				plan.insertar = append(plan.insertar, periodoPrecio{id: uuid.New(), price: p.price, from: *hasta, to: p.to})
+			}
+			corte := desde
+			p.to = &corte
+			plan.ajustar = append(plan.ajustar, p)
+		case hasta == nil || (p.to != nil && !p.to.After(*hasta)):
+			plan.descartar = append(plan.descartar, p.id)
+		case p.from.Before(*hasta):
+			p.from = *hasta
+			plan.ajustar = append(plan.ajustar, p)
+		}
+	}
+	plan.insertar = append(plan.insertar, periodoPrecio{id: uuid.New(), price: price, from: desde, to: hasta})
+	return plan
+}
+
+// precioLista precio vigente con la tienda dueña de su lista
+type precioLista struct {
+	PrecioVigente
+	storeID *uuid.UUID
+}
+
+// precioVigente obtiene el precio de un producto en una fecha según la
+// precedencia de elegirPrecio; sql.ErrNoRows si ninguna lista tiene precio
+func precioVigente(q utils.Querier, productID, listID uuid.UUID, storeID *uuid.UUID, fecha time.Time) (PrecioVigente, error) {
+	rows, err := q.Query(`
+        SELECT pr.listId, l.type, l.storeId, pr.price, pr.currency, pr.effective_from, pr.effective_to
+        FROM catalogos.precios pr
+        JOIN catalogos.listasprecios l ON pr.listId = l.id
+        WHERE pr.productId = $1 AND pr.activo = true AND l.activo = true
+          AND (l.id = $2 OR l.type = 'RETAIL' OR (l.type = 'STORE' AND l.storeId = $3))
+          AND pr.effective_from <= $4
+          AND (pr.effective_to IS NULL OR pr.effective_to > $4)
+    `, productID, listID, storeID, fecha)
+	if err != nil {
+		return PrecioVigente{}, err
+	}
+	defer rows.Close()
+
+	var vigentes []precioLista
+	for rows.Next() {
+		p := precioLista{PrecioVigente: PrecioVigente{ProductID: productID, Date: fecha}}
+		if err := rows.Scan(&p.ListID, &p.ListType, &p.storeID, &p.Price.Amount, &p.Price.Currency,
+			&p.EffectiveFrom, &p.EffectiveTo); err != nil {
+			return PrecioVigente{}, err
+		}
+		vigentes = append(vigentes, p)
+	}
+	if err := rows.Err(); err != nil {
+		return PrecioVigente{}, err
+	}
+
+	precio, ok := elegirPrecio(vigentes, listID, storeID)
+	if !ok {
+		return PrecioVigente{ProductID: productID, Date: fecha}, sql.ErrNoRows
+	}
+	return precio, nil
+}
+
+// elegirPrecio aplica la precedencia entre los precios vigentes: la lista
+// propia de la tienda, luego la lista indicada y al final la de menudeo.
+// Dentro de una lista gana el de inicio más reciente.
+func elegirPrecio(vigentes []precioLista, listID uuid.UUID, storeID *uuid.UUID) (PrecioVigente, bool) {
+	var tienda, lista, menudeo *PrecioVigente
+	for i := range vigentes {
+		p := &vigentes[i]
+		switch {
+		case storeID != nil && p.ListType == ListaSTORE && p.storeID != nil && *p.storeID == *storeID:
+			tienda = masReciente(tienda, &p.PrecioVigente)
+		case p.ListID == listID:
+			lista = masReciente(lista, &p.PrecioVigente)
+		}
+		if p.ListType == ListaRETAIL {
+			menudeo = masReciente(menudeo, &p.PrecioVigente)
+		}
+	}
+	for _, p := range []*PrecioVigente{tienda, lista, menudeo} {
+		if p != nil {
+			return *p, true
+		}
+	}
+	return PrecioVigente{}, false
+}
+
+// masReciente el precio de inicio más reciente; actual puede ser nil
+func masReciente(actual, p *PrecioVigente) *PrecioVigente {
+	if actual == nil || p.EffectiveFrom.After(actual.EffectiveFrom) {
+		return p
+	}
+	return actual
+}
+
+// parseFecha interpreta una fecha en formato YYYY-MM-DD o RFC3339
+func parseFecha(valor string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, valor); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", valor)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"go-project/money"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPlanificarPrecio(t *testing.T) {
+	dia := func(mes, d int) time.Time { return time.Date(2024, time.Month(mes), d, 0, 0, 0, 0, time.UTC) }
+	hasta := func(mes, d int) *time.Time { f := dia(mes, d); return &f }
+	mxn := func(pesos int64) money.Money { return money.New(money.Decimal(pesos*100), "MXN") }
+	periodo := func(precio int64, desde time.Time, hasta *time.Time) periodoPrecio {
+		return periodoPrecio{id: uuid.New(), price: mxn(precio), from: desde, to: hasta}
+	}
+	igual := func(p periodoPrecio, precio int64, desde time.Time, hasta *time.Time) bool {
+		return p.price.Equal(mxn(precio)) && p.from.Equal(desde) &&
+			(p.to == nil) == (hasta == nil) && (hasta == nil || p.to.Equal(*hasta))
+	}
+
+	// El nuevo periodo parte al vigente y su resto sigue después del fin
+	vigente := periodo(10, dia(1, 1), nil)
+	plan := planificarPrecio([]periodoPrecio{vigente}, mxn(12), dia(2, 1), hasta(3, 1))
+	if len(plan.descartar) != 0 || len(plan.ajustar) != 1 || plan.ajustar[0].id != vigente.id ||
+		!igual(plan.ajustar[0], 10, dia(1, 1), hasta(2, 1)) {
+		t.Errorf("partir: ajustar = %+v, descartar = %v", plan.ajustar, plan.descartar)
+	}
+	if len(plan.insertar) != 2 || !igual(plan.insertar[0], 10, dia(3, 1), nil) ||
+		!igual(plan.insertar[1], 12, dia(2, 1), hasta(3, 1)) {
+		t.Errorf("partir: insertar = %+v", plan.insertar)
+	}
+
+	// Sin fin el precio dura hasta el siguiente programado
+	actual, programado := periodo(10, dia(1, 1), hasta(4, 1)), periodo(15, dia(4, 1), nil)
+	plan = planificarPrecio([]periodoPrecio{actual, programado}, mxn(12), dia(2, 1), nil)
+	if len(plan.descartar) != 0 || len(plan.ajustar) != 1 || !igual(plan.ajustar[0], 10, dia(1, 1), hasta(2, 1)) {
+		t.Errorf("sin fin: ajustar = %+v, descartar = %v", plan.ajustar, plan.descartar)
+	}
+	if len(plan.insertar) != 1 || !igual(plan.insertar[0], 12, dia(2, 1), hasta(4, 1)) {
+		t.Errorf("sin fin: insertar = %+v", plan.insertar)
+	}
+
+	// Los periodos cubiertos se descartan y el que rebasa el fin se recorre
+	anterior := periodo(10, dia(1, 1), hasta(2, 1))
+	cubierto := periodo(11, dia(2, 1), hasta(3, 1))
+	siguiente := periodo(12, dia(3, 1), nil)
+	plan = planificarPrecio([]periodoPrecio{anterior, cubierto, siguiente}, mxn(20), dia(2, 1), hasta(3, 15))
+	if len(plan.descartar) != 1 || plan.descartar[0] != cubierto.id {
+		t.Errorf("traslape: descartar = %v", plan.descartar)
+	}
+	if len(plan.ajustar) != 1 || plan.ajustar[0].id != siguiente.id || !igual(plan.ajustar[0], 12, dia(3, 15), nil) {
+		t.Errorf("traslape: ajustar = %+v", plan.ajustar)
+	}
+	if len(plan.insertar) != 1 || !igual(plan.insertar[0], 20, dia(2, 1), hasta(3, 15)) {
+		t.Errorf("traslape: insertar = %+v", plan.insertar)
+	}
+
+	// Un precio con el mismo inicio reemplaza al vigente
+	plan = planificarPrecio([]periodoPrecio{vigente}, mxn(12), dia(1, 1), nil)
+	if len(plan.descartar) != 1 || plan.descartar[0] != vigente.id || len(plan.ajustar) != 0 ||
+		len(plan.insertar) != 1 || !igual(plan.insertar[0], 12, dia(1, 1), nil) {
+		t.Errorf("reemplazo: plan = %+v", plan)
+	}
+}
+
+func TestElegirPrecio(t *testing.T) {
+	centro, norte, sur := uuid.New(), uuid.New(), uuid.New()
+	menudeo, mayoreo, listaCentro, listaNorte := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	inicio := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	precio := func(lista uuid.UUID, tipo TipoLista, tienda *uuid.UUID, pesos int64, dias int) precioLista {
+		return precioLista{storeID: tienda, PrecioVigente: PrecioVigente{ListID: lista, ListType: tipo,
+			Price: money.New(money.Decimal(pesos*100), "MXN"), EffectiveFrom: inicio.AddDate(0, 0, dias)}}
+	}
+	vigentes := []precioLista{
+		precio(menudeo, ListaRETAIL, nil, 100, 0),
+		precio(menudeo, ListaRETAIL, nil, 105, 10),
+		precio(mayoreo, ListaWHOLESALE, nil, 80, 0),
+		precio(listaCentro, ListaSTORE, &centro, 95, 0),
+		precio(listaNorte, ListaSTORE, &norte, 98, 0),
+	}
+
+	casos := []struct {
+		nombre string
+		lista  uuid.UUID
+		tienda *uuid.UUID
+		precio int64
+	}{
+		{"la tienda tiene prioridad sobre la lista", mayoreo, &centro, 95},
+		{"la lista indicada", mayoreo, nil, 80},
+		{"menudeo por defecto, el más reciente", uuid.Nil, nil, 105},
+		{"tienda sin lista propia usa menudeo", uuid.Nil, &sur, 105},
+		{"lista sin precio usa menudeo", uuid.New(), nil, 105},
+	}
+	for _, c := range casos {
+		p, ok := elegirPrecio(vigentes, c.lista, c.tienda)
+		if !ok || !p.Price.Equal(money.New(money.Decimal(c.precio*100), "MXN")) {
+			t.Errorf("%s: precio = %s %v", c.nombre, p.Price, ok)
+		}
+	}
+
+	if _, ok := elegirPrecio(vigentes[2:3], uuid.Nil, &norte); ok {
+		t.Error("sin precio de menudeo ni de la tienda no debe haber precio vigente")
+	}
+}
@@ -3,7 +3,9 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-project/money"
 	"go-project/utils"
 	"net/http"
 	"time"
@@ -14,39 +16,53 @@ import (
 // Product es el modelo de producto para la documentación
 // @Description Modelo de producto
 type Producto struct {
-	ID          uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Name        string    `json:"name" example:"Laptop HP" binding:"required"`
-	Description string    `json:"description" example:"Laptop HP con procesador Intel i5"`
-	Category    string    `json:"category" example:"Electrónicos"`
-	Price       float64   `json:"price" example:"999.99" binding:"required"`
-	SKU         string    `json:"sku" example:"LAP-001" binding:"required"`
+	ID           uuid.UUID   `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name         string      `json:"name" example:"Laptop HP" binding:"required"`
+	Description  string      `json:"description" example:"Laptop HP con procesador Intel i5"`
+	Category     string      `json:"category" example:"Electrónicos"`
+	Price        money.Money `json:"price" binding:"required"`
+	SKU          string      `json:"sku" example:"LAP-001" binding:"required"`
+	TrackLots    bool        `json:"track_lots" example:"false"`
+	TrackSerials bool        `json:"track_serials" example:"false"`
 }
 
 type CrearProducto struct {
-	Name        string  `json:"name" example:"Laptop HP" binding:"required"`
-	Description string  `json:"description" example:"Laptop HP con procesador Intel i5"`
-	Category    string  `json:"category" example:"Electrónicos"`
-	Price       float64 `json:"price" example:"999.99" binding:"required"`
-	SKU         string  `json:"sku" example:"LAP-001" binding:"required"`
+	Name         string      `json:"name" example:"Laptop HP" binding:"required"`
+	Description  string      `json:"description" example:"Laptop HP con procesador Intel i5"`
+	Category     string      `json:"category" example:"Electrónicos"`
+	Price        money.Money `json:"price" binding:"required"`
+	SKU          string      `json:"sku" example:"LAP-001" binding:"required"`
+	TrackLots    bool        `json:"track_lots" example:"false"`
+	TrackSerials bool        `json:"track_serials" example:"false"`
 }
 type ActualizarProducto struct {
-	Name        string  `json:"name" example:"Laptop HP Actualizada"`
-	Description string  `json:"description" example:"Laptop HP con procesador Intel i7"`
-	Category    string  `json:"category" example:"Electrónicos"`
-	Price       float64 `json:"price" example:"1299.99"`
-	SKU         string  `json:"sku" example:"LAP-002"`
+	Name        string      `json:"name" example:"Laptop HP Actualizada"`
+	Description string      `json:"description" example:"Laptop HP con procesador Intel i7"`
+	Category    string      `json:"category" example:"Electrónicos"`
+	Price       money.Money `json:"price"`
+	SKU         string      `json:"sku" example:"LAP-002"`
+	// Control por lote y por serie; si se omiten se conservan. Sólo pueden
+	// cambiar mientras el producto no tenga existencias.
+	TrackLots    *bool `json:"track_lots,omitempty" example:"false"`
+	TrackSerials *bool `json:"track_serials,omitempty" example:"false"`
 }
 
+// errControlConExistencias cambio del control por lote o serie de un producto
+// que tiene existencias que no quedarían respaldadas
+var errControlConExistencias = errors.New("No se puede cambiar el control por lote o serie de un producto con existencias")
+
 type ProductoDetalle struct {
-	ID          uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Name        string    `json:"name" example:"Laptop HP" binding:"required"`
-	Description string    `json:"description" example:"Laptop HP con procesador Intel i5"`
-	Category    string    `json:"category" example:"Electrónicos"`
-	Price       float64   `json:"price" example:"999.99" binding:"required"`
-	SKU         string    `json:"sku" example:"LAP-001" binding:"required"`
-	Activo      bool      `json:"activo" example:"true"`
-	CreatedAt   time.Time `json:"created_at,omitempty"`
-	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	ID           uuid.UUID   `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name         string      `json:"name" example:"Laptop HP" binding:"required"`
+	Description  string      `json:"description" example:"Laptop HP con procesador Intel i5"`
+	Category     string      `json:"category" example:"Electrónicos"`
+	Price        money.Money `json:"price" binding:"required"`
+	SKU          string      `json:"sku" example:"LAP-001" binding:"required"`
+	TrackLots    bool        `json:"track_lots" example:"false"`
+	TrackSerials bool        `json:"track_serials" example:"false"`
+	Activo       bool        `json:"activo" example:"true"`
+	CreatedAt    time.Time   `json:"created_at,omitempty"`
+	UpdatedAt    time.Time   `json:"updated_at,omitempty"`
 }
 
 type ProductHandler struct {
@@ -90,7 +106,7 @@ func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request)
 // @Router       /ListarProductos [get]
 func (h *ProductHandler) ListarProductos(w http.ResponseWriter, r *http.Request) {
 	rows, err := h.db.Query(`
-         SELECT id, name, description, category, price, sku
+         SELECT id, name, description, category, price, currency, sku, track_lots, track_serials
         FROM catalogos.productos 
         WHERE activo = true
         ORDER BY category, name  
@@ -106,7 +122,7 @@ func (h *ProductHandler) ListarProductos(w http.ResponseWriter, r *http.Request)
 		var p Producto
 		err := rows.Scan(
 			&p.ID, &p.Name, &p.Description, &p.Category,
-			&p.Price, &p.SKU,
+			&p.Price.Amount, &p.Price.Currency, &p.SKU, &p.TrackLots, &p.TrackSerials,
 		)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -132,9 +148,15 @@ func (h *ProductHandler) ListarProductos(w http.ResponseWriter, r *http.Request)
 func (h *ProductHandler) CrearProducto(w http.ResponseWriter, r *http.Request) {
 	var p CrearProducto
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		responderDatosInvalidos(w, err)
+		return
+	}
+
+	if p.Price.IsNegative() {
+		http.Error(w, "El precio debe ser no negativo", http.StatusBadRequest)
 		return
 	}
+	p.Price = money.New(p.Price.Amount, p.Price.Currency)
 
 	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
 		// Verificar SKU único
@@ -149,17 +171,29 @@ func (h *ProductHandler) CrearProducto(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Insertar producto
+		id := uuid.New()
+		ahora := time.Now()
 		_, err = tx.Exec(`
             INSERT INTO catalogos.productos (
-                id, name, description, category, price, sku, activo, created_at
-            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-        `, uuid.New(), p.Name, p.Description, p.Category, p.Price,
-			p.SKU, true, time.Now())
-		return err
+                id, name, description, category, price, currency, sku,
+                track_lots, track_serials, activo, created_at
+            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+        `, id, p.Name, p.Description, p.Category, p.Price.Amount, p.Price.Currency,
+			p.SKU, p.TrackLots, p.TrackSerials, true, ahora)
+		if err != nil {
+			return err
+		}
+
+		// Registrar el precio inicial en la lista de menudeo
+		listID, err := listaMenudeo(tx)
+		if err != nil {
+			return err
+		}
+		return programarPrecio(tx, id, listID, p.Price, ahora, nil)
 	})
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responderErrorInterno(w, err)
 		return
 	}
 
@@ -187,12 +221,13 @@ func (h *ProductHandler) ObtenerProducto(w http.ResponseWriter, r *http.Request)
 
 	var producto ProductoDetalle
 	err = h.db.QueryRow(`
-        SELECT id, name, description, category, price, sku, activo, created_at, updated_at
+        SELECT id, name, description, category, price, currency, sku, track_lots, track_serials,
+               activo, created_at, updated_at
         FROM catalogos.productos 
         WHERE id = $1
     `, id).Scan(
 		&producto.ID, &producto.Name, &producto.Description, &producto.Category,
-		&producto.Price, &producto.SKU, &producto.Activo, &producto.CreatedAt, &producto.UpdatedAt)
+		&producto.Price.Amount, &producto.Price.Currency, &producto.SKU, &producto.TrackLots, &producto.TrackSerials, &producto.Activo, &producto.CreatedAt, &producto.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Producto no encontrado", http.StatusNotFound)
@@ -209,7 +244,7 @@ func (h *ProductHandler) ObtenerProducto(w http.ResponseWriter, r *http.Request)
 
 // ActualizarProducto godoc
 // @Summary      Actualizar producto
-// @Description  Actualiza los datos de un producto existente
+// @Description  Actualiza los datos de un producto existente. track_lots y track_serials se conservan si se omiten y sólo pueden cambiar mientras el producto no tenga existencias.
 // @Tags         productos
 // @Accept       json
 // @Produce      json
@@ -227,26 +262,84 @@ func (h *ProductHandler) ActualizarProducto(w http.ResponseWriter, r *http.Reque
 
 	var p ActualizarProducto
 	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
-		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		responderDatosInvalidos(w, err)
 		return
 	}
 
-	result, err := h.db.Exec(`
-        UPDATE catalogos.productos 
-        SET name = $1, description = $2, category = $3, price = $4, sku = $5, updated_at = CURRENT_TIMESTAMP
-        WHERE id = $6 AND activo = true
-    `, p.Name, p.Description, p.Category, p.Price, p.SKU, id)
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if p.Price.IsNegative() {
+		http.Error(w, "El precio debe ser no negativo", http.StatusBadRequest)
 		return
 	}
+	p.Price = money.New(p.Price.Amount, p.Price.Currency)
+
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var precioAnterior money.Money
+		var lotes, series bool
+		err := tx.QueryRow(`
+            SELECT price, currency, track_lots, track_serials FROM catalogos.productos
+            WHERE id = $1 AND activo = true
+            FOR UPDATE
+        `, id).Scan(&precioAnterior.Amount, &precioAnterior.Currency, &lotes, &series)
+		if err != nil {
+			return err
+		}
 
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
+		// Las existencias sin lote o sin serie quedarían sin poder salir
+		cambia := (p.TrackLots != nil && *p.TrackLots != lotes) ||
+			(p.TrackSerials != nil && *p.TrackSerials != series)
+		if cambia {
+			var existencia int
+			if err := tx.QueryRow(`
+                SELECT COALESCE(SUM(quantity), 0) FROM prueba.inventarios
+                WHERE productId = $1 AND activo = true
+            `, id).Scan(&existencia); err != nil {
+				return err
+			}
+			if existencia > 0 {
+				return fmt.Errorf("%w: tiene %d unidades", errControlConExistencias, existencia)
+			}
+		}
+		if p.TrackLots == nil {
+			p.TrackLots = &lotes
+		}
+		if p.TrackSerials == nil {
+			p.TrackSerials = &series
+		}
+
+		_, err = tx.Exec(`
+            UPDATE catalogos.productos 
+            SET name = $1, description = $2, category = $3, price = $4, currency = $5, sku = $6,
+                track_lots = $7, track_serials = $8, updated_at = CURRENT_TIMESTAMP
+            WHERE id = $9 AND activo = true
+        `, p.Name, p.Description, p.Category, p.Price.Amount, p.Price.Currency, p.SKU,
+			*p.TrackLots, *p.TrackSerials, id)
+		if err != nil {
+			return err
+		}
+
+		// Conservar el historial: el precio anterior se cierra y el nuevo inicia ahora
+		if p.Price.Equal(precioAnterior) {
+			return nil
+		}
+		listID, err := listaMenudeo(tx)
+		if err != nil {
+			return err
+		}
+		return programarPrecio(tx, id, listID, p.Price, time.Now(), nil)
+	})
+
+	if err == sql.ErrNoRows {
 		http.Error(w, "Producto no encontrado o inactivo", http.StatusNotFound)
 		return
 	}
+	if errors.Is(err, errControlConExistencias) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(p)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"go-project/money"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -49,7 +50,7 @@ func TestCrearProducto(t *testing.T) {
 		Name:        "Test Product",
 		Description: "Test Description",
 		Category:    "Test Category",
-		Price:       99.99,
+		Price:       money.MustParse("99.99", "MXN"),
 		SKU:         "TEST-001",
 	}
 
@@ -0,0 +1,1060 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/money"
+	"go-project/outbox"
+	"go-project/utils"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// errRMAInvalida indica una operación no válida sobre una devolución de cliente
+var errRMAInvalida = errors.New("RMA inválida")
+
+// Estados de una devolución de cliente (RMA), decisiones de inspección y
+// resultados de sus líneas
+const (
+	RMASolicitada    = "REQUESTED"
+	RMARecibida      = "RECEIVED"
+	RMAInspeccionada = "INSPECTED"
+	RMACompletada    = "COMPLETED"
+	RMACancelada     = "CANCELLED"
+
+	DisposicionReingreso = "RESTOCK"
+	DisposicionBaja      = "WRITE_OFF"
+	DisposicionProveedor = "RETURN_TO_SUPPLIER"
+
+	RMALineaPendiente   = "PENDING"
+	RMALineaNoRecibida  = "NOT_RECEIVED"
+	RMALineaReingresada = "RESTOCKED"
+	RMALineaBaja        = "WRITTEN_OFF"
+	RMALineaProveedor   = "RETURNED_TO_SUPPLIER"
+)
+
+// motivosRMA motivos de devolución declarados por el cliente; son también
+// motivos del tipo RETURN con que se reingresa la mercancía
+var motivosRMA = []string{"DEFECTIVE", "DAMAGED_IN_TRANSIT", "WRONG_ITEM", "NOT_AS_DESCRIBED", "NO_LONGER_NEEDED", "OTHER"}
+
+// motivosDisposicion motivos de la decisión tomada en la inspección
+var motivosDisposicion = []string{"RESELLABLE", "DAMAGED", "DEFECTIVE", "EXPIRED", "MISSING_PARTS", "WARRANTY_CLAIM", "OTHER"}
+
+// LineaSolicitudRMA producto que el cliente devuelve
+type LineaSolicitudRMA struct {
+	ProductID uuid.UUID `json:"product_id"`
+	// Código de barras escaneado; puede usarse en lugar de product_id
+	Barcode string `json:"barcode,omitempty" example:"7501031311309"`
+	// Número de línea de la venta original (requerido si se indica sale_id)
+	SaleLine int `json:"sale_line,omitempty" example:"1"`
+	Quantity int `json:"quantity" example:"1"`
+	// Lote devuelto (por defecto el de la venta original)
+	LotNumber string `json:"lot_number,omitempty"`
+	// Caducidad del lote (YYYY-MM-DD)
+	ExpiryDate string `json:"expiry_date,omitempty" example:"2025-03-31"`
+	// Números de serie devueltos; requeridos en productos con control por serie
+	Serials []string `json:"serials,omitempty"`
+}
+
+// CrearRMA solicitud de devolución de un cliente
+type CrearRMA struct {
+	StoreID uuid.UUID `json:"store_id" binding:"required"`
+	// Folio de la autorización (por defecto se genera)
+	RMANumber string `json:"rma_number,omitempty" example:"RMA-20240304-0001"`
+	// Venta original; limita las líneas a lo vendido y no devuelto
+	SaleID       *uuid.UUID          `json:"sale_id,omitempty"`
+	CustomerName string              `json:"customer_name,omitempty" example:"Juan Pérez"`
+	Reason       string              `json:"reason" example:"DEFECTIVE" binding:"required"`
+	Notes        string              `json:"notes,omitempty"`
+	Lines        []LineaSolicitudRMA `json:"lines" binding:"required"`
+}
+
+// RecepcionLineaRMA cantidad recibida de una línea
+type RecepcionLineaRMA struct {
+	Line     int `json:"line" example:"1"`
+	Quantity int `json:"quantity" example:"1"`
+	// Series recibidas cuando llegan menos unidades de las autorizadas
+	Serials []string `json:"serials,omitempty"`
+}
+
+// RecibirRMA recepción de la mercancía; las líneas omitidas se reciben completas
+type RecibirRMA struct {
+	Lines []RecepcionLineaRMA `json:"lines,omitempty"`
+}
+
+// InspeccionLineaRMA decisión sobre una línea recibida
+type InspeccionLineaRMA struct {
+	Line int `json:"line" example:"1"`
+	// RESTOCK, WRITE_OFF o RETURN_TO_SUPPLIER
+	Disposition string `json:"disposition" example:"RESTOCK"`
+	// RESELLABLE, DAMAGED, DEFECTIVE, EXPIRED, MISSING_PARTS, WARRANTY_CLAIM u OTHER
+	Reason string `json:"reason" example:"RESELLABLE"`
+	Notes  string `json:"notes,omitempty"`
+}
+
+// InspeccionarRMA resultado de la inspección; todas las líneas recibidas
+// requieren decisión
+type InspeccionarRMA struct {
+	InspectedBy string               `json:"inspected_by" example:"almacen" binding:"required"`
+	Lines       []InspeccionLineaRMA `json:"lines" binding:"required"`
+}
+
+// LineaRMA línea registrada de una devolución
+type LineaRMA struct {
+	ID                uuid.UUID  `json:"id"`
+	Line              int        `json:"line" example:"1"`
+	ProductID         uuid.UUID  `json:"product_id"`
+	ProductName       string     `json:"product_name"`
+	SaleLineID        *uuid.UUID `json:"sale_line_id,omitempty"`
+	Quantity          int        `json:"quantity" example:"1"`
+	ReceivedQuantity  *int       `json:"received_quantity,omitempty"`
+	LotNumber         *string    `json:"lot_number,omitempty"`
+	ExpiryDate        *time.Time `json:"expiry_date,omitempty"`
+	Serials           []string   `json:"serials,omitempty"`
+	InspectionNotes   *string    `json:"inspection_notes,omitempty"`
+	Disposition       *string    `json:"disposition,omitempty" example:"RESTOCK"`
+	DispositionReason *string    `json:"disposition_reason,omitempty" example:"RESELLABLE"`
+	Status            string     `json:"status" example:"PENDING"`
+	// Movimiento RETURN que reingresó la mercancía
+	MovementID *uuid.UUID `json:"movement_id,omitempty"`
+	// Movimiento WRITE_OFF de la baja
+	WriteOffMovementID *uuid.UUID `json:"write_off_movement_id,omitempty"`
+}
+
+// RMA devolución de cliente con sus líneas
+// @Description Autorización de devolución de mercancía
+type RMA struct {
+	ID           uuid.UUID  `json:"id"`
+	RMANumber    string     `json:"rma_number" example:"RMA-20240304-0001"`
+	StoreID      uuid.UUID  `json:"store_id"`
+	StoreName    string     `json:"store_name"`
+	SaleID       *uuid.UUID `json:"sale_id,omitempty"`
+	CustomerName *string    `json:"customer_name,omitempty"`
+	Reason       string     `json:"reason" example:"DEFECTIVE"`
+	Notes        *string    `json:"notes,omitempty"`
+	Status       string     `json:"status" example:"REQUESTED"`
+	ReceivedAt   *time.Time `json:"received_at,omitempty"`
+	InspectedAt  *time.Time `json:"inspected_at,omitempty"`
+	InspectedBy  *string    `json:"inspected_by,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Lines        []LineaRMA `json:"lines,omitempty"`
+}
+
+// ResumenMotivosRMA unidades devueltas por motivo del cliente y decisión
+type ResumenMotivosRMA struct {
+	Reason            string  `json:"reason" example:"DEFECTIVE"`
+	Disposition       *string `json:"disposition,omitempty" example:"WRITE_OFF"`
+	DispositionReason *string `json:"disposition_reason,omitempty" example:"DAMAGED"`
+	RMAs              int     `json:"rmas" example:"4"`
+	Units             int     `json:"units" example:"6"`
+	// Costo de la mercancía dada de baja
+	WrittenOffCost money.Money `json:"written_off_cost"`
+}
+
+type RMAHandler struct {
+	db *sql.DB
+}
+
+func NewRMAHandler(db *sql.DB) *RMAHandler {
+	return &RMAHandler{db: db}
+}
+
+// ListarRMAs godoc
+// @Summary      Listar devoluciones de clientes
+// @Description  Obtiene las autorizaciones de devolución (RMA), de la más reciente a la más antigua
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string false "ID de la tienda"
+// @Param        status query string false "REQUESTED, RECEIVED, INSPECTED, COMPLETED o CANCELLED"
+// @Success      200  {array}   RMA
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarRMAs [get]
+func (h *RMAHandler) ListarRMAs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var storeID *uuid.UUID
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+	status := r.URL.Query().Get("status")
+
+	rows, err := h.db.Query(consultaRMAs+`
+        AND ($1::uuid IS NULL OR r.storeId = $1) AND ($2 = '' OR r.status = $2)
+        ORDER BY r.created_at DESC`, storeID, status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	rmas := []RMA{}
+	for rows.Next() {
+		rma, err := escanearRMA(rows)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rmas = append(rmas, rma)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rmas)
+}
+
+// CrearRMA godoc
+// @Summary      Solicitar devolución de cliente
+// @Description  Registra una autorización de devolución (RMA) con el motivo del cliente. Si se indica la venta
+// @Description  original, cada línea referencia una línea de la venta y no puede exceder lo vendido menos lo ya
+// @Description  devuelto. No modifica el inventario hasta completarse.
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        rma body CrearRMA true "Datos de la devolución"
+// @Success      201  {object}  RMA
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearRMA [post]
+func (h *RMAHandler) CrearRMA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var solicitud CrearRMA
+	if err := json.NewDecoder(r.Body).Decode(&solicitud); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if solicitud.StoreID == uuid.Nil {
+		http.Error(w, "Se requiere la tienda", http.StatusBadRequest)
+		return
+	}
+	if !contiene(motivosRMA, solicitud.Reason) {
+		http.Error(w, "Motivo inválido, use "+strings.Join(motivosRMA, ", "), http.StatusBadRequest)
+		return
+	}
+	if len(solicitud.Lines) == 0 || len(solicitud.Lines) > maxLineasTicket {
+		http.Error(w, fmt.Sprintf("Indique entre 1 y %d líneas", maxLineasTicket), http.StatusBadRequest)
+		return
+	}
+
+	id := uuid.New()
+	numero := strings.TrimSpace(solicitud.RMANumber)
+	if numero == "" {
+		numero = fmt.Sprintf("RMA-%s-%s", time.Now().Format("20060102"), strings.ToUpper(id.String()[:8]))
+	}
+
+	var rma RMA
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var existe bool
+		if err := tx.QueryRow(`
+            SELECT EXISTS(SELECT 1 FROM catalogos.tiendas WHERE id = $1 AND activo = true)
+        `, solicitud.StoreID).Scan(&existe); err != nil {
+			return err
+		}
+		if !existe {
+			return fmt.Errorf("%w: tienda no encontrada o inactiva", errRMAInvalida)
+		}
+		if err := tx.QueryRow(`
+            SELECT EXISTS(SELECT 1 FROM prueba.rmas WHERE rmaNumber = $1)
+        `, numero).Scan(&existe); err != nil {
+			return err
+		}
+		if existe {
+			return fmt.Errorf("%w: el folio %s ya fue registrado", errRMAInvalida, numero)
+		}
+
+		var vendidas map[int]*lineaOriginal
+		if solicitud.SaleID != nil {
+			// Bloquear la venta serializa las devoluciones sobre sus líneas
+			err := tx.QueryRow(`
+                SELECT id FROM prueba.ventas WHERE id = $1 AND type = 'SALE' AND activo = true FOR UPDATE
+            `, *solicitud.SaleID).Scan(new(uuid.UUID))
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: venta no encontrada", errRMAInvalida)
+			}
+			if err != nil {
+				return err
+			}
+			if vendidas, err = lineasOriginales(tx, *solicitud.SaleID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+            INSERT INTO prueba.rmas (id, rmaNumber, storeId, saleId, customerName, reason, notes)
+            VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''))
+        `, id, numero, solicitud.StoreID, solicitud.SaleID, solicitud.CustomerName,
+			solicitud.Reason, solicitud.Notes); err != nil {
+			return err
+		}
+		for i, l := range solicitud.Lines {
+			if err := registrarLineaRMA(tx, id, i+1, l, vendidas); err != nil {
+				return fmt.Errorf("línea %d: %w", i+1, err)
+			}
+		}
+
+		var err error
+		if rma, err = cargarRMA(tx, id); err != nil {
+			return err
+		}
+		return outbox.Record(tx, "rma", id, "rma.requested", rma)
+	})
+	if err != nil {
+		responderRMA(w, rma, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rma)
+}
+
+// GetRMA godoc
+// @Summary      Obtener devolución de cliente
+// @Description  Obtiene una autorización de devolución con sus líneas, decisiones y movimientos
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la RMA"
+// @Success      200  {object}  RMA
+// @Failure      404  {object}  map[string]string
+// @Router       /rmas/{id} [get]
+func (h *RMAHandler) GetRMA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	rma, err := cargarRMA(h.db, id)
+	responderRMA(w, rma, err)
+}
+
+// ReceiveRMA godoc
+// @Summary      Recibir devolución de cliente
+// @Description  Registra la llegada de la mercancía a la tienda. Las líneas omitidas se reciben completas; una
+// @Description  línea recibida en cero no requiere inspección. La mercancía recibida aún no es vendible.
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la RMA"
+// @Param        recepcion body RecibirRMA false "Cantidades recibidas"
+// @Success      200  {object}  RMA
+// @Failure      400  {object}  map[string]string
+// @Router       /rmas/{id}/receive [post]
+func (h *RMAHandler) ReceiveRMA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var recepcion RecibirRMA
+	if err := json.NewDecoder(r.Body).Decode(&recepcion); err != nil && err != io.EOF {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+
+	var rma RMA
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		if _, err := bloquearRMA(tx, id, RMASolicitada); err != nil {
+			return err
+		}
+		actual, err := cargarRMA(tx, id)
+		if err != nil {
+			return err
+		}
+
+		numeros := make(map[int]bool, len(actual.Lines))
+		for _, l := range actual.Lines {
+			numeros[l.Line] = true
+		}
+		recibidas := make(map[int]RecepcionLineaRMA, len(recepcion.Lines))
+		for _, l := range recepcion.Lines {
+			if !numeros[l.Line] {
+				return fmt.Errorf("%w: la RMA no tiene la línea %d", errRMAInvalida, l.Line)
+			}
+			recibidas[l.Line] = l
+		}
+
+		for _, l := range actual.Lines {
+			cantidad, series := l.Quantity, l.Serials
+			if rec, ok := recibidas[l.Line]; ok {
+				if cantidad, series, err = validarRecepcionRMA(l, rec); err != nil {
+					return err
+				}
+			}
+			if series == nil {
+				series = []string{}
+			}
+			if _, err := tx.Exec(`
+                UPDATE prueba.rmaslineas SET receivedQuantity = $2, serials = $3 WHERE id = $1
+            `, l.ID, cantidad, pq.Array(series)); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+            UPDATE prueba.rmas SET status = 'RECEIVED', received_at = CURRENT_TIMESTAMP WHERE id = $1
+        `, id); err != nil {
+			return err
+		}
+		if rma, err = cargarRMA(tx, id); err != nil {
+			return err
+		}
+		return outbox.Record(tx, "rma", id, "rma.received", rma)
+	})
+
+	responderRMA(w, rma, err)
+}
+
+// InspectRMA godoc
+// @Summary      Inspeccionar devolución de cliente
+// @Description  Registra la decisión sobre cada línea recibida: reingresar a venta (RESTOCK), dar de baja
+// @Description  (WRITE_OFF) o devolver al proveedor (RETURN_TO_SUPPLIER), con el motivo de la decisión.
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la RMA"
+// @Param        inspeccion body InspeccionarRMA true "Decisiones por línea"
+// @Success      200  {object}  RMA
+// @Failure      400  {object}  map[string]string
+// @Router       /rmas/{id}/inspect [post]
+func (h *RMAHandler) InspectRMA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var inspeccion InspeccionarRMA
+	if err := json.NewDecoder(r.Body).Decode(&inspeccion); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(inspeccion.InspectedBy) == "" {
+		http.Error(w, "Se requiere quién inspecciona", http.StatusBadRequest)
+		return
+	}
+
+	var rma RMA
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		if _, err := bloquearRMA(tx, id, RMARecibida); err != nil {
+			return err
+		}
+		actual, err := cargarRMA(tx, id)
+		if err != nil {
+			return err
+		}
+
+		decisiones, err := validarInspeccionRMA(actual.Lines, inspeccion.Lines)
+		if err != nil {
+			return err
+		}
+		for _, l := range actual.Lines {
+			d, ok := decisiones[l.Line]
+			if !ok {
+				continue
+			}
+			if _, err := tx.Exec(`
+                UPDATE prueba.rmaslineas
+                SET disposition = $2, dispositionReason = $3, inspectionNotes = NULLIF($4, '')
+                WHERE id = $1
+            `, l.ID, d.Disposition, d.Reason, d.Notes); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`
+            UPDATE prueba.rmas SET status = 'INSPECTED', inspected_at = CURRENT_TIMESTAMP, inspected_by = $2
+            WHERE id = $1
+        `, id, inspeccion.InspectedBy); err != nil {
+			return err
+		}
+		if rma, err = cargarRMA(tx, id); err != nil {
+			return err
+		}
+		return outbox.Record(tx, "rma", id, "rma.inspected", rma)
+	})
+
+	responderRMA(w, rma, err)
+}
+
+// CompleteRMA godoc
+// @Summary      Completar devolución de cliente
+// @Description  Aplica las decisiones de la inspección. RESTOCK reingresa la mercancía a la existencia vendible
+// @Description  con un movimiento RETURN al costo de la venta original; WRITE_OFF la reingresa y la da de baja con
+// @Description  un movimiento WRITE_OFF, de modo que su costo queda registrado como merma; RETURN_TO_SUPPLIER
+// @Description  no afecta la existencia porque la mercancía nunca volvió a estar a la venta.
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la RMA"
+// @Success      200  {object}  RMA
+// @Failure      400  {object}  map[string]string
+// @Router       /rmas/{id}/complete [post]
+func (h *RMAHandler) CompleteRMA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var rma RMA
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		storeID, err := bloquearRMA(tx, id, RMAInspeccionada)
+		if err != nil {
+			return err
+		}
+		actual, err := cargarRMA(tx, id)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range actual.Lines {
+			if err := aplicarLineaRMA(tx, storeID, actual.Reason, l); err != nil {
+				return fmt.Errorf("línea %d: %w", l.Line, err)
+			}
+		}
+
+		if _, err := tx.Exec(`
+            UPDATE prueba.rmas SET status = 'COMPLETED', completed_at = CURRENT_TIMESTAMP WHERE id = $1
+        `, id); err != nil {
+			return err
+		}
+		if rma, err = cargarRMA(tx, id); err != nil {
+			return err
+		}
+		return outbox.Record(tx, "rma", id, "rma.completed", rma)
+	})
+
+	responderRMA(w, rma, err)
+}
+
+// CancelRMA godoc
+// @Summary      Cancelar devolución de cliente
+// @Description  Cancela una RMA que aún no se completa; sus unidades vuelven a estar disponibles para devolverse
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la RMA"
+// @Success      200  {object}  RMA
+// @Failure      400  {object}  map[string]string
+// @Router       /rmas/{id}/cancel [post]
+func (h *RMAHandler) CancelRMA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var rma RMA
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		if _, err := bloquearRMA(tx, id, RMASolicitada, RMARecibida, RMAInspeccionada); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE prueba.rmas SET status = 'CANCELLED' WHERE id = $1`, id); err != nil {
+			return err
+		}
+		var err error
+		if rma, err = cargarRMA(tx, id); err != nil {
+			return err
+		}
+		return outbox.Record(tx, "rma", id, "rma.cancelled", rma)
+	})
+
+	responderRMA(w, rma, err)
+}
+
+// GetRMAReasons godoc
+// @Summary      Motivos de devolución
+// @Description  Unidades devueltas por motivo del cliente y decisión de la inspección, con el costo dado de
+// @Description  baja. Considera las RMA no canceladas solicitadas en el periodo.
+// @Tags         devoluciones
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        from query string false "Desde (YYYY-MM-DD o RFC3339, por defecto hace 30 días)"
+// @Param        to query string false "Hasta, exclusivo (YYYY-MM-DD o RFC3339, por defecto ahora)"
+// @Success      200  {array}   ResumenMotivosRMA
+// @Failure      400  {object}  map[string]string
+// @Router       /reports/rma/reasons [get]
+func (h *RMAHandler) GetRMAReasons(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+	hasta := time.Now()
+	desde := hasta.AddDate(0, 0, -30)
+	for nombre, destino := range map[string]*time.Time{"from": &desde, "to": &hasta} {
+		if v := q.Get(nombre); v != "" {
+			fecha, err := parseFecha(v)
+			if err != nil {
+				http.Error(w, "Fecha inválida en "+nombre, http.StatusBadRequest)
+				return
+			}
+			*destino = fecha
+		}
+	}
+
+	rows, err := h.db.Query(`
+        SELECT r.reason, l.disposition, l.dispositionReason,
+               COUNT(DISTINCT r.id), SUM(COALESCE(l.receivedQuantity, l.quantity)),
+               COALESCE(SUM(c.costo), 0), COALESCE(MIN(c.currency), $4)
+        FROM prueba.rmas r
+        JOIN prueba.rmaslineas l ON l.rmaId = r.id
+        LEFT JOIN LATERAL (
+            SELECT SUM(cc.quantity * cc.unit_cost) AS costo, MIN(cc.currency) AS currency
+            FROM prueba.consumoscosto cc WHERE cc.movementId = l.writeOffMovementId
+        ) c ON true
+        WHERE r.activo = true AND r.status <> 'CANCELLED'
+          AND r.created_at >= $1 AND r.created_at < $2
+          AND ($3::uuid IS NULL OR r.storeId = $3)
+        GROUP BY r.reason, l.disposition, l.dispositionReason
+        ORDER BY 5 DESC, r.reason`, desde, hasta, storeID, money.DefaultCurrency)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	resumen := []ResumenMotivosRMA{}
+	for rows.Next() {
+		var m ResumenMotivosRMA
+		if err := rows.Scan(&m.Reason, &m.Disposition, &m.DispositionReason, &m.RMAs, &m.Units,
+			&m.WrittenOffCost.Amount, &m.WrittenOffCost.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resumen = append(resumen, m)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resumen)
+}
+
+// responderRMA escribe la RMA o el error correspondiente
+func responderRMA(w http.ResponseWriter, rma RMA, err error) {
+	if err == sql.ErrNoRows {
+		http.Error(w, "RMA no encontrada", http.StatusNotFound)
+		return
+	}
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		responderErrorInterno(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rma)
+}
+
+const consultaRMAs = `
+        SELECT r.id, r.rmaNumber, r.storeId, t.name, r.saleId, r.customerName, r.reason, r.notes,
+               r.status, r.received_at, r.inspected_at, r.inspected_by, r.completed_at,
+               r.created_at, r.updated_at
+        FROM prueba.rmas r
+        JOIN catalogos.tiendas t ON r.storeId = t.id
+        WHERE r.activo = true`
+
+// escanearRMA lee una RMA de consultaRMAs
+func escanearRMA(row interface{ Scan(...interface{}) error }) (RMA, error) {
+	var r RMA
+	err := row.Scan(&r.ID, &r.RMANumber, &r.StoreID, &r.StoreName, &r.SaleID, &r.CustomerName,
+		&r.Reason, &r.Notes, &r.Status, &r.ReceivedAt, &r.InspectedAt, &r.InspectedBy,
+		&r.CompletedAt, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// cargarRMA obtiene una RMA con sus líneas
+func cargarRMA(q utils.Querier, id uuid.UUID) (RMA, error) {
+	rma, err := escanearRMA(q.QueryRow(consultaRMAs+` AND r.id = $1`, id))
+	if err != nil {
+		return rma, err
+	}
+
+	rows, err := q.Query(`
+        SELECT l.id, l.lineNumber, l.productId, p.name, l.saleLineId, l.quantity, l.receivedQuantity,
+               l.lotNumber, l.expiryDate, l.serials, l.inspectionNotes, l.disposition,
+               l.dispositionReason, l.status, l.movementId, l.writeOffMovementId
+        FROM prueba.rmaslineas l
+        JOIN catalogos.productos p ON l.productId = p.id
+        WHERE l.rmaId = $1
+        ORDER BY l.lineNumber
+    `, id)
+	if err != nil {
+		return rma, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l LineaRMA
+		if err := rows.Scan(&l.ID, &l.Line, &l.ProductID, &l.ProductName, &l.SaleLineID, &l.Quantity,
+			&l.ReceivedQuantity, &l.LotNumber, &l.ExpiryDate, pq.Array(&l.Serials), &l.InspectionNotes,
+			&l.Disposition, &l.DispositionReason, &l.Status, &l.MovementID, &l.WriteOffMovementID); err != nil {
+			return rma, err
+		}
+		rma.Lines = append(rma.Lines, l)
+	}
+	return rma, rows.Err()
+}
+
+// bloquearRMA bloquea la RMA y verifica que esté en alguno de los estados
+// indicados; devuelve la tienda que recibe la devolución
+func bloquearRMA(tx *sql.Tx, id uuid.UUID, estados ...string) (uuid.UUID, error) {
+	var storeID uuid.UUID
+	var status string
+	err := tx.QueryRow(`
+        SELECT storeId, status FROM prueba.rmas WHERE id = $1 AND activo = true FOR UPDATE
+    `, id).Scan(&storeID, &status)
+	if err != nil {
+		return storeID, err
+	}
+	if !contiene(estados, status) {
+		return storeID, fmt.Errorf("%w: la RMA está %s", errRMAInvalida, status)
+	}
+	return storeID, nil
+}
+
+// registrarLineaRMA valida una línea de la solicitud y la registra. Con venta
+// original el producto y las series se toman de la línea vendida; si no se
+// indica lote, al completar se reingresa a los lotes de los que salió.
+func registrarLineaRMA(tx *sql.Tx, rmaID uuid.UUID, numero int, l LineaSolicitudRMA, vendidas map[int]*lineaOriginal) error {
+	if l.Quantity == 0 && len(l.Serials) > 0 {
+		l.Quantity = len(l.Serials)
+	}
+	if l.Quantity <= 0 {
+		return fmt.Errorf("%w: la cantidad debe ser positiva", errRMAInvalida)
+	}
+	if len(l.Serials) > 0 {
+		if err := validarSeries(l.Serials, l.Quantity); err != nil {
+			return err
+		}
+	}
+	var caducidad *time.Time
+	if l.ExpiryDate != "" {
+		fecha, err := parseFecha(l.ExpiryDate)
+		if err != nil {
+			return fmt.Errorf("%w: fecha de caducidad inválida", errLoteInvalido)
+		}
+		caducidad = &fecha
+	}
+
+	var saleLineID *uuid.UUID
+	if vendidas != nil {
+		orig, ok := vendidas[l.SaleLine]
+		if !ok {
+			return fmt.Errorf("%w: la venta no tiene la línea %d", errRMAInvalida, l.SaleLine)
+		}
+		if orig.devuelto+l.Quantity > orig.quantity {
+			return fmt.Errorf("%w: se pueden devolver hasta %d unidades de la línea %d",
+				errRMAInvalida, orig.quantity-orig.devuelto, l.SaleLine)
+		}
+		orig.devuelto += l.Quantity
+		saleLineID, l.ProductID = &orig.id, orig.productID
+
+		if orig.movementID != nil {
+			vendidasSeries, err := seriesDeMovimiento(tx, *orig.movementID)
+			if err != nil {
+				return err
+			}
+			for _, s := range l.Serials {
+				if !contiene(vendidasSeries, s) {
+					return fmt.Errorf("%w: la serie %s no se vendió en la línea %d", errSerieInvalida, s, l.SaleLine)
+				}
+			}
+		}
+	} else {
+		productID, err := resolverProducto(tx, l.ProductID, l.Barcode)
+		if err != nil {
+			return err
+		}
+		l.ProductID = productID
+	}
+
+	var existe bool
+	if err := tx.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM catalogos.productos WHERE id = $1)
+    `, l.ProductID).Scan(&existe); err != nil {
+		return err
+	}
+	if !existe {
+		return fmt.Errorf("%w: producto no encontrado", errRMAInvalida)
+	}
+
+	series := l.Serials
+	if series == nil {
+		series = []string{}
+	}
+	_, err := tx.Exec(`
+        INSERT INTO prueba.rmaslineas (id, rmaId, lineNumber, productId, saleLineId, quantity,
+                                       lotNumber, expiryDate, serials)
+        VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9)
+    `, uuid.New(), rmaID, numero, l.ProductID, saleLineID, l.Quantity, l.LotNumber, caducidad, pq.Array(series))
+	return err
+}
+
+// validarRecepcionRMA cantidad y series recibidas de una línea; con menos
+// unidades de las autorizadas deben indicarse las series que llegaron
+func validarRecepcionRMA(l LineaRMA, rec RecepcionLineaRMA) (int, []string, error) {
+	if rec.Quantity == 0 && len(rec.Serials) > 0 {
+		rec.Quantity = len(rec.Serials)
+	}
+	if rec.Quantity < 0 || rec.Quantity > l.Quantity {
+		return 0, nil, fmt.Errorf("%w: línea %d: se pueden recibir entre 0 y %d unidades",
+			errRMAInvalida, l.Line, l.Quantity)
+	}
+	if len(l.Serials) == 0 {
+		if len(rec.Serials) > 0 {
+			return 0, nil, fmt.Errorf("%w: línea %d: la línea no registra números de serie", errRMAInvalida, l.Line)
+		}
+		return rec.Quantity, l.Serials, nil
+	}
+	if rec.Quantity == l.Quantity && len(rec.Serials) == 0 {
+		return rec.Quantity, l.Serials, nil
+	}
+	if err := validarSeries(rec.Serials, rec.Quantity); err != nil {
+		return 0, nil, err
+	}
+	for _, s := range rec.Serials {
+		if !contiene(l.Serials, s) {
+			return 0, nil, fmt.Errorf("%w: línea %d: la serie %s no está en la RMA", errSerieInvalida, l.Line, s)
+		}
+	}
+	return rec.Quantity, rec.Serials, nil
+}
+
+// validarInspeccionRMA revisa que cada línea recibida tenga exactamente una
+// decisión válida con su motivo
+func validarInspeccionRMA(lineas []LineaRMA, inspeccion []InspeccionLineaRMA) (map[int]InspeccionLineaRMA, error) {
+	recibidas := make(map[int]bool, len(lineas))
+	for _, l := range lineas {
+		recibidas[l.Line] = l.ReceivedQuantity != nil && *l.ReceivedQuantity > 0
+	}
+
+	decisiones := make(map[int]InspeccionLineaRMA, len(inspeccion))
+	for _, d := range inspeccion {
+		recibida, ok := recibidas[d.Line]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%w: la RMA no tiene la línea %d", errRMAInvalida, d.Line)
+		case !recibida:
+			return nil, fmt.Errorf("%w: la línea %d no se recibió", errRMAInvalida, d.Line)
+		case decisiones[d.Line].Disposition != "":
+			return nil, fmt.Errorf("%w: la línea %d está repetida", errRMAInvalida, d.Line)
+		case d.Disposition != DisposicionReingreso && d.Disposition != DisposicionBaja && d.Disposition != DisposicionProveedor:
+			return nil, fmt.Errorf("%w: línea %d: decisión inválida, use RESTOCK, WRITE_OFF o RETURN_TO_SUPPLIER",
+				errRMAInvalida, d.Line)
+		case !contiene(motivosDisposicion, d.Reason):
+			return nil, fmt.Errorf("%w: línea %d: motivo inválido, use %s",
+				errRMAInvalida, d.Line, strings.Join(motivosDisposicion, ", "))
+		}
+		decisiones[d.Line] = d
+	}
+
+	for _, l := range lineas {
+		if recibidas[l.Line] && decisiones[l.Line].Disposition == "" {
+			return nil, fmt.Errorf("%w: falta la decisión de la línea %d", errRMAInvalida, l.Line)
+		}
+	}
+	return decisiones, nil
+}
+
+// aplicarLineaRMA aplica al inventario la decisión de una línea inspeccionada;
+// el reingreso lleva el motivo del cliente
+func aplicarLineaRMA(tx *sql.Tx, storeID uuid.UUID, motivo string, l LineaRMA) error {
+	status, reingresa, baja := disposicionLineaRMA(l)
+	if !reingresa {
+		_, err := tx.Exec(`UPDATE prueba.rmaslineas SET status = $2 WHERE id = $1`, l.ID, status)
+		return err
+	}
+
+	costo, err := costoLineaRMA(tx, storeID, l)
+	if err != nil {
+		return err
+	}
+	mov := movimientoReingresoRMA(storeID, motivo, l)
+	if l.LotNumber == nil && l.SaleLineID != nil {
+		movementID, err := movimientoLineaVenta(tx, *l.SaleLineID)
+		if err != nil {
+			return err
+		}
+		if movementID != nil {
+			lotes, err := lotesDevolubles(tx, *movementID, *l.SaleLineID)
+			if err != nil {
+				return err
+			}
+			if len(lotes) > 0 {
+				if mov.lotes, err = repartirDevolucion(lotes, mov.Quantity); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	entrada, err := insertarMovimiento(tx, mov, nil, &costo)
+	if err != nil {
+		return err
+	}
+
+	var bajaID *uuid.UUID
+	if baja {
+		salida, err := insertarMovimiento(tx, movimientoBajaRMA(mov, entrada.ID), nil, nil)
+		if err != nil {
+			return err
+		}
+		bajaID = &salida.ID
+	}
+
+	_, err = tx.Exec(`
+        UPDATE prueba.rmaslineas SET status = $2, movementId = $3, writeOffMovementId = $4 WHERE id = $1
+    `, l.ID, status, entrada.ID, bajaID)
+	return err
+}
+
+// disposicionLineaRMA estado final de una línea al completar la RMA y si
+// reingresa unidades o además las da de baja. Las líneas no recibidas y las
+// que van al proveedor no tocan la existencia.
+func disposicionLineaRMA(l LineaRMA) (status string, reingresa, baja bool) {
+	switch {
+	case l.ReceivedQuantity == nil || *l.ReceivedQuantity == 0 || l.Disposition == nil:
+		return RMALineaNoRecibida, false, false
+	case *l.Disposition == DisposicionProveedor:
+		return RMALineaProveedor, false, false
+	case *l.Disposition == DisposicionBaja:
+		return RMALineaBaja, true, true
+	}
+	return RMALineaReingresada, true, false
+}
+
+// movimientoBajaRMA baja de lo reingresado: sale de los mismos lotes y de la
+// capa de costo de la entrada, así la merma queda al costo de la devolución
+func movimientoBajaRMA(reingreso CrearMovimiento, entradaID uuid.UUID) CrearMovimiento {
+	baja := reingreso
+	baja.Type, baja.ReasonCode, baja.ExpiryDate = movimientoWRITEOFF, "CUSTOMER_RETURN", ""
+	baja.entradaID = &entradaID
+	return baja
+}
+
+// movimientoReingresoRMA movimiento RETURN de las unidades recibidas de una
+// línea, con el motivo del cliente y el lote declarado en la recepción
+func movimientoReingresoRMA(storeID uuid.UUID, motivo string, l LineaRMA) CrearMovimiento {
+	mov := CrearMovimiento{ProductID: l.ProductID, SourceStoreID: storeID, TargetStoreID: storeID,
+		Quantity: *l.ReceivedQuantity, Type: movimientoRETURN, ReasonCode: motivo, Serials: l.Serials}
+	if l.LotNumber != nil {
+		mov.LotNumber = *l.LotNumber
+	}
+	if l.ExpiryDate != nil {
+		mov.ExpiryDate = l.ExpiryDate.Format("2006-01-02")
+	}
+	return mov
+}
+
+// movimientoLineaVenta salida de inventario de una línea vendida, si la tiene
+func movimientoLineaVenta(q utils.Querier, saleLineID uuid.UUID) (*uuid.UUID, error) {
+	var movementID *uuid.UUID
+	err := q.QueryRow(`
+        SELECT movementId FROM prueba.ventaslineas WHERE id = $1
+    `, saleLineID).Scan(&movementID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return movementID, err
+}
+
+// costoLineaRMA costo de reingreso: el que consumió la venta original o, sin
+// venta, el último costo conocido del producto
+func costoLineaRMA(q utils.Querier, storeID uuid.UUID, l LineaRMA) (money.Money, error) {
+	var costoVenta, ultimo *money.Money
+	if l.SaleLineID != nil {
+		movementID, err := movimientoLineaVenta(q, *l.SaleLineID)
+		if err != nil {
+			return money.Money{}, err
+		}
+		if movementID != nil {
+			if costoVenta, err = costoDevolucion(q, *movementID, l.ProductID, storeID); err != nil {
+				return money.Money{}, err
+			}
+		}
+	}
+	if costoVenta == nil {
+		var err error
+		if ultimo, err = ultimoCosto(q, l.ProductID, storeID); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return elegirCostoRMA(costoVenta, ultimo), nil
+}
+
+// elegirCostoRMA prefiere el costo de la venta, luego el último conocido y,
+// sin ninguno, reingresa a costo cero en la moneda por defecto
+func elegirCostoRMA(costoVenta, ultimo *money.Money) money.Money {
+	switch {
+	case costoVenta != nil:
+		return *costoVenta
+	case ultimo != nil:
+		return *ultimo
+	}
+	return money.New(0, money.DefaultCurrency)
+}
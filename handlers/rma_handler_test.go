@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"go-project/money"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestValidarInspeccionRMA(t *testing.T) {
+	dos, cero := 2, 0
+	lineas := []LineaRMA{
+		{Line: 1, Quantity: 2, ReceivedQuantity: &dos},
+		{Line: 2, Quantity: 1, ReceivedQuantity: &cero},
+		{Line: 3, Quantity: 2, ReceivedQuantity: &dos},
+		{Line: 4, Quantity: 1},
+	}
+
+	// Sólo las líneas recibidas llevan decisión, en cualquier orden
+	decisiones, err := validarInspeccionRMA(lineas, []InspeccionLineaRMA{
+		{Line: 3, Disposition: DisposicionProveedor, Reason: "WARRANTY_CLAIM"},
+		{Line: 1, Disposition: DisposicionReingreso, Reason: "RESELLABLE"},
+	})
+	if err != nil {
+		t.Fatalf("validarInspeccionRMA: %v", err)
+	}
+	if len(decisiones) != 2 || decisiones[1].Disposition != DisposicionReingreso ||
+		decisiones[3].Disposition != DisposicionProveedor || decisiones[3].Reason != "WARRANTY_CLAIM" {
+		t.Errorf("decisiones = %+v", decisiones)
+	}
+
+	// Una inspección parcial indica qué línea recibida quedó sin decidir
+	_, err = validarInspeccionRMA(lineas, []InspeccionLineaRMA{{Line: 1, Disposition: DisposicionBaja, Reason: "DAMAGED"}})
+	if !errors.Is(err, errRMAInvalida) || !strings.Contains(err.Error(), "falta la decisión de la línea 3") {
+		t.Errorf("inspección parcial: err = %v", err)
+	}
+
+	// Lo que no llegó no se inspecciona, ni en cero ni pendiente de recibir
+	for _, linea := range []int{2, 4} {
+		_, err = validarInspeccionRMA(lineas, []InspeccionLineaRMA{
+			{Line: 1, Disposition: DisposicionBaja, Reason: "DAMAGED"},
+			{Line: 3, Disposition: DisposicionBaja, Reason: "DAMAGED"},
+			{Line: linea, Disposition: DisposicionBaja, Reason: "DAMAGED"},
+		})
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("la línea %d no se recibió", linea)) {
+			t.Errorf("línea %d sin recibir: err = %v", linea, err)
+		}
+	}
+
+	// El motivo de la decisión es del catálogo de inspección, no del cliente
+	_, err = validarInspeccionRMA(lineas, []InspeccionLineaRMA{
+		{Line: 1, Disposition: DisposicionReingreso, Reason: "WRONG_ITEM"},
+		{Line: 3, Disposition: DisposicionBaja, Reason: "DAMAGED"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "línea 1: motivo inválido") {
+		t.Errorf("motivo del cliente: err = %v", err)
+	}
+}
+
+func TestValidarRecepcionRMA(t *testing.T) {
+	l := LineaRMA{Line: 1, Quantity: 2, Serials: []string{"SN-1", "SN-2"}}
+
+	cantidad, series, err := validarRecepcionRMA(l, RecepcionLineaRMA{Line: 1, Serials: []string{"SN-2"}})
+	if err != nil || cantidad != 1 || len(series) != 1 || series[0] != "SN-2" {
+		t.Errorf("recepción parcial = %d %v %v", cantidad, series, err)
+	}
+	if cantidad, series, err = validarRecepcionRMA(l, RecepcionLineaRMA{Line: 1, Quantity: 2}); err != nil ||
+		cantidad != 2 || len(series) != 2 {
+		t.Errorf("recepción completa = %d %v %v", cantidad, series, err)
+	}
+	if _, _, err := validarRecepcionRMA(l, RecepcionLineaRMA{Line: 1, Quantity: 1}); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("parcial sin series: err = %v", err)
+	}
+	if _, _, err := validarRecepcionRMA(l, RecepcionLineaRMA{Line: 1, Serials: []string{"SN-9"}}); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("serie ajena: err = %v", err)
+	}
+	if _, _, err := validarRecepcionRMA(l, RecepcionLineaRMA{Line: 1, Quantity: 3}); !errors.Is(err, errRMAInvalida) {
+		t.Errorf("excedente: err = %v", err)
+	}
+}
+
+func TestMovimientoReingresoRMA(t *testing.T) {
+	tienda, recibidas := uuid.New(), 2
+	lote, caducidad := "L-7", time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	l := LineaRMA{ProductID: uuid.New(), Quantity: 3, ReceivedQuantity: &recibidas,
+		LotNumber: &lote, ExpiryDate: &caducidad}
+
+	mov := movimientoReingresoRMA(tienda, "WRONG_ITEM", l)
+	if mov.Type != movimientoRETURN || mov.ReasonCode != "WRONG_ITEM" || mov.Quantity != 2 ||
+		mov.TargetStoreID != tienda || mov.LotNumber != "L-7" || mov.ExpiryDate != "2025-03-31" {
+		t.Errorf("movimiento = %+v", mov)
+	}
+}
+
+func TestCompletarLineaRMA(t *testing.T) {
+	tienda, recibidas, cero := uuid.New(), 3, 0
+	decision := func(d string) *string { return &d }
+	linea := func(recibido *int, disposicion *string) LineaRMA {
+		return LineaRMA{ID: uuid.New(), ProductID: uuid.New(), Quantity: 3, ReceivedQuantity: recibido,
+			Disposition: disposicion, Serials: []string{"SN-1", "SN-2", "SN-3"}}
+	}
+
+	// Reingreso: sólo un RETURN con el motivo del cliente
+	if status, reingresa, baja := disposicionLineaRMA(linea(&recibidas, decision(DisposicionReingreso))); status != RMALineaReingresada || !reingresa || baja {
+		t.Errorf("reingreso: %s %v %v", status, reingresa, baja)
+	}
+
+	// Baja: RETURN y después WRITE_OFF de las mismas unidades desde la capa del reingreso
+	l := linea(&recibidas, decision(DisposicionBaja))
+	status, reingresa, baja := disposicionLineaRMA(l)
+	if status != RMALineaBaja || !reingresa || !baja {
+		t.Errorf("baja: %s %v %v", status, reingresa, baja)
+	}
+	caducidad := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	lote := "L-7"
+	l.LotNumber, l.ExpiryDate = &lote, &caducidad
+	entrada := movimientoReingresoRMA(tienda, "DEFECTIVE", l)
+	entradaID := uuid.New()
+	salida := movimientoBajaRMA(entrada, entradaID)
+	if salida.Type != movimientoWRITEOFF || salida.ReasonCode != "CUSTOMER_RETURN" || salida.Quantity != 3 ||
+		salida.SourceStoreID != tienda || salida.LotNumber != "L-7" || salida.ExpiryDate != "" || len(salida.Serials) != 3 {
+		t.Errorf("baja = %+v", salida)
+	}
+	if salida.entradaID == nil || *salida.entradaID != entradaID {
+		t.Errorf("la baja debe consumir la capa del reingreso, entradaID = %v", salida.entradaID)
+	}
+	if entrada.Type != movimientoRETURN || entrada.ReasonCode != "DEFECTIVE" || entrada.entradaID != nil {
+		t.Errorf("la baja no debe modificar el reingreso: %+v", entrada)
+	}
+
+	// Al proveedor o sin recibir no hay movimientos
+	if status, reingresa, _ := disposicionLineaRMA(linea(&recibidas, decision(DisposicionProveedor))); status != RMALineaProveedor || reingresa {
+		t.Errorf("proveedor: %s %v", status, reingresa)
+	}
+	if status, reingresa, _ := disposicionLineaRMA(linea(&cero, nil)); status != RMALineaNoRecibida || reingresa {
+		t.Errorf("recibida en cero: %s %v", status, reingresa)
+	}
+	if status, reingresa, _ := disposicionLineaRMA(linea(nil, nil)); status != RMALineaNoRecibida || reingresa {
+		t.Errorf("sin recibir: %s %v", status, reingresa)
+	}
+
+	// El reingreso va al costo de la venta, luego al último costo y si no a cero
+	venta, ultimo := money.MustParse("410.00", "MXN"), money.MustParse("395.50", "MXN")
+	if c := elegirCostoRMA(&venta, &ultimo); !c.Equal(venta) {
+		t.Errorf("costo con venta = %s", c)
+	}
+	if c := elegirCostoRMA(nil, &ultimo); !c.Equal(ultimo) {
+		t.Errorf("costo sin venta = %s", c)
+	}
+	if c := elegirCostoRMA(nil, nil); !c.Equal(money.New(0, money.DefaultCurrency)) {
+		t.Errorf("costo sin historial = %s", c)
+	}
+}
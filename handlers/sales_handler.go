@@ -0,0 +1,1006 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/money"
+	"go-project/outbox"
+	"go-project/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// VentaTipo tipo de ticket
+type VentaTipo string
+
+const (
+	VentaSALE   VentaTipo = "SALE"
+	VentaRETURN VentaTipo = "RETURN"
+)
+
+// Máximo de líneas de un ticket
+const maxLineasTicket = 500
+
+// Tolerancia para tickets con reloj adelantado en el punto de venta
+const toleranciaRelojPOS = 5 * time.Minute
+
+// errVentaInvalida ticket o devolución que no cumple las validaciones
+var errVentaInvalida = errors.New("Venta inválida")
+
+// errPrecioInvalido precio del ticket distinto del vigente en el catálogo
+var errPrecioInvalido = errors.New("Precio inválido")
+
+// errVentaDuplicada el folio del ticket ya se registró en la tienda
+var errVentaDuplicada = errors.New("El ticket ya fue registrado")
+
+// LineaTicket línea de un ticket del punto de venta
+type LineaTicket struct {
+	ProductID uuid.UUID `json:"product_id"`
+	// Código de barras escaneado; puede usarse en lugar de product_id
+	Barcode  string `json:"barcode,omitempty" example:"7501031311309"`
+	Quantity int    `json:"quantity" example:"2"`
+	// Precio unitario cobrado; debe coincidir con el vigente en la tienda
+	UnitPrice money.Money `json:"unit_price"`
+	// Lote específico vendido (si se omite se aplica FEFO)
+	LotNumber string `json:"lot_number,omitempty"`
+	// Números de serie vendidos; requeridos en productos con control por serie
+	Serials []string `json:"serials,omitempty"`
+}
+
+// TicketVenta ticket de venta enviado por el punto de venta
+type TicketVenta struct {
+	StoreID uuid.UUID `json:"store_id"`
+	// Folio del ticket, único por tienda
+	TicketNumber string `json:"ticket_number" example:"POS1-000123"`
+	// Fecha y hora de la venta (por defecto ahora)
+	SoldAt time.Time `json:"sold_at" example:"2024-03-04T12:30:00-06:00"`
+	// Total cobrado; debe ser igual a la suma de las líneas
+	PaymentTotal money.Money   `json:"payment_total"`
+	Lines        []LineaTicket `json:"lines"`
+}
+
+// LineaDevolucion unidades devueltas de una línea de la venta original
+type LineaDevolucion struct {
+	// Número de línea en la venta original
+	Line     int `json:"line" example:"1"`
+	Quantity int `json:"quantity" example:"1"`
+	// Números de serie devueltos; deben haberse vendido en la línea
+	Serials []string `json:"serials,omitempty"`
+	// Motivo del cliente, del catálogo del tipo RETURN (por defecto OTHER)
+	ReasonCode string `json:"reason_code,omitempty" example:"DEFECTIVE"`
+}
+
+// DevolucionVenta devolución de una venta registrada
+type DevolucionVenta struct {
+	// Folio del ticket de devolución (por defecto se deriva del original)
+	TicketNumber string            `json:"ticket_number,omitempty" example:"POS1-000123-D1"`
+	ReturnedAt   time.Time         `json:"returned_at" example:"2024-03-05T10:00:00-06:00"`
+	Lines        []LineaDevolucion `json:"lines"`
+}
+
+// LineaVenta línea registrada de un ticket
+type LineaVenta struct {
+	ID          uuid.UUID   `json:"id"`
+	Line        int         `json:"line" example:"1"`
+	ProductID   uuid.UUID   `json:"product_id"`
+	ProductName string      `json:"product_name,omitempty"`
+	Quantity    int         `json:"quantity" example:"2"`
+	UnitPrice   money.Money `json:"unit_price"`
+	LineTotal   money.Money `json:"line_total"`
+	// Movimiento OUT (venta) o IN (devolución) que afectó el inventario
+	MovementID *uuid.UUID `json:"movement_id,omitempty"`
+	// Línea de la venta original (devoluciones)
+	OriginalLineID *uuid.UUID `json:"original_line_id,omitempty"`
+	// Unidades ya devueltas (ventas)
+	ReturnedQuantity int              `json:"returned_quantity"`
+	Lots             []MovimientoLote `json:"lots,omitempty"`
+	Serials          []string         `json:"serials,omitempty"`
+}
+
+// Venta ticket de venta o devolución registrado
+type Venta struct {
+	ID             uuid.UUID    `json:"id"`
+	StoreID        uuid.UUID    `json:"store_id"`
+	StoreName      string       `json:"store_name,omitempty"`
+	TicketNumber   string       `json:"ticket_number" example:"POS1-000123"`
+	Type           VentaTipo    `json:"type" example:"SALE"`
+	OriginalSaleID *uuid.UUID   `json:"original_sale_id,omitempty"`
+	SoldAt         time.Time    `json:"sold_at"`
+	Total          money.Money  `json:"total"`
+	PaymentTotal   money.Money  `json:"payment_total"`
+	Lines          []LineaVenta `json:"lines,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+}
+
+// ResumenVentasDia ventas de una tienda en un día de su zona horaria
+type ResumenVentasDia struct {
+	StoreID       uuid.UUID   `json:"store_id"`
+	StoreName     string      `json:"store_name"`
+	Date          string      `json:"date" example:"2024-03-04"`
+	Tickets       int         `json:"tickets" example:"42"`
+	Returns       int         `json:"returns" example:"2"`
+	UnitsSold     int         `json:"units_sold" example:"118"`
+	UnitsReturned int         `json:"units_returned" example:"3"`
+	GrossSales    money.Money `json:"gross_sales"`
+	ReturnsTotal  money.Money `json:"returns_total"`
+	NetSales      money.Money `json:"net_sales"`
+}
+
+type SalesHandler struct {
+	db *sql.DB
+}
+
+func NewSalesHandler(db *sql.DB) *SalesHandler {
+	return &SalesHandler{db: db}
+}
+
+// RegistrarVenta godoc
+// @Summary      Registrar ticket de venta
+// @Description  Registra un ticket del punto de venta: valida cada precio contra el vigente en la tienda
+// @Description  a la hora de la venta y que el total cobrado sea la suma de las líneas, y descuenta el
+// @Description  inventario con un movimiento OUT por línea (lotes FEFO, series y capas de costo). Un folio
+// @Description  ya registrado en la tienda responde 409.
+// @Tags         ventas
+// @Accept       json
+// @Produce      json
+// @Param        ticket body TicketVenta true "Ticket de venta"
+// @Success      201  {object}  Venta
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /sales [post]
+func (h *SalesHandler) RegistrarVenta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ticket TicketVenta
+	if err := json.NewDecoder(r.Body).Decode(&ticket); err != nil {
+		responderDatosInvalidos(w, err)
+		return
+	}
+	total, err := validarTicket(&ticket, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	venta := Venta{ID: uuid.New(), StoreID: ticket.StoreID, TicketNumber: ticket.TicketNumber,
+		Type: VentaSALE, SoldAt: ticket.SoldAt, Total: total, PaymentTotal: ticket.PaymentTotal}
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		if err := insertarVenta(tx, &venta); err != nil {
+			return err
+		}
+		for i, l := range ticket.Lines {
+			linea, err := registrarLineaVenta(tx, venta, i+1, l)
+			if err != nil {
+				return fmt.Errorf("línea %d: %w", i+1, err)
+			}
+			venta.Lines = append(venta.Lines, linea)
+		}
+		return outbox.Record(tx, "sale", venta.ID, "sale.recorded", venta)
+	})
+	if err != nil {
+		responderErrorVenta(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(venta)
+}
+
+// RegistrarDevolucion godoc
+// @Summary      Registrar devolución de una venta
+// @Description  Registra un ticket de devolución que referencia la venta original y reingresa las unidades
+// @Description  a la tienda con un movimiento RETURN por línea (con el motivo indicado u OTHER), al precio
+// @Description  de la venta y con el costo que consumió la salida original. No se puede devolver más de lo
+// @Description  vendido en cada línea.
+// @Tags         ventas
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la venta original"
+// @Param        devolucion body DevolucionVenta true "Líneas devueltas"
+// @Success      201  {object}  Venta
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /sales/{id}/returns [post]
+func (h *SalesHandler) RegistrarDevolucion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	saleID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID de venta inválido", http.StatusBadRequest)
+		return
+	}
+	var dev DevolucionVenta
+	if err := json.NewDecoder(r.Body).Decode(&dev); err != nil {
+		responderDatosInvalidos(w, err)
+		return
+	}
+	if len(dev.Lines) == 0 || len(dev.Lines) > maxLineasTicket {
+		http.Error(w, fmt.Sprintf("Indique entre 1 y %d líneas", maxLineasTicket), http.StatusBadRequest)
+		return
+	}
+	if dev.ReturnedAt.IsZero() {
+		dev.ReturnedAt = time.Now()
+	}
+
+	var devolucion Venta
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var err error
+		devolucion, err = registrarDevolucion(tx, saleID, dev)
+		return err
+	})
+	if err == sql.ErrNoRows {
+		http.Error(w, "Venta no encontrada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		responderErrorVenta(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(devolucion)
+}
+
+// GetSale godoc
+// @Summary      Obtener ticket
+// @Description  Obtiene un ticket de venta o devolución con sus líneas, movimientos, lotes y series
+// @Tags         ventas
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID del ticket"
+// @Success      200  {object}  Venta
+// @Failure      404  {object}  map[string]string
+// @Router       /sales/{id} [get]
+func (h *SalesHandler) GetSale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID de venta inválido", http.StatusBadRequest)
+		return
+	}
+
+	venta, err := cargarVenta(h.db, id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Venta no encontrada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(venta)
+}
+
+// ListarVentas godoc
+// @Summary      Listar tickets
+// @Description  Obtiene los tickets de venta y devolución, del más reciente al más antiguo
+// @Tags         ventas
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        type query string false "SALE o RETURN"
+// @Param        from query string false "Desde (YYYY-MM-DD o RFC3339)"
+// @Param        to query string false "Hasta, exclusivo (YYYY-MM-DD o RFC3339)"
+// @Success      200  {array}   Venta
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarVentas [get]
+func (h *SalesHandler) ListarVentas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+	var tipo *string
+	if v := q.Get("type"); v != "" {
+		if VentaTipo(v) != VentaSALE && VentaTipo(v) != VentaRETURN {
+			http.Error(w, "Tipo inválido, use SALE o RETURN", http.StatusBadRequest)
+			return
+		}
+		tipo = &v
+	}
+	var desde, hasta *time.Time
+	for nombre, destino := range map[string]**time.Time{"from": &desde, "to": &hasta} {
+		if v := q.Get(nombre); v != "" {
+			fecha, err := parseFecha(v)
+			if err != nil {
+				http.Error(w, "Fecha inválida en "+nombre, http.StatusBadRequest)
+				return
+			}
+			fecha = fecha.UTC()
+			*destino = &fecha
+		}
+	}
+
+	rows, err := h.db.Query(`
+        SELECT v.id, v.storeId, t.name, v.ticketNumber, v.type, v.originalSaleId, v.soldAt,
+               v.total, v.paymentTotal, v.currency, v.created_at
+        FROM prueba.ventas v
+        JOIN catalogos.tiendas t ON v.storeId = t.id
+        WHERE v.activo = true
+          AND ($1::uuid IS NULL OR v.storeId = $1)
+          AND ($2::varchar IS NULL OR v.type = $2)
+          AND ($3::timestamp IS NULL OR v.soldAt >= $3)
+          AND ($4::timestamp IS NULL OR v.soldAt < $4)
+        ORDER BY v.soldAt DESC
+        LIMIT 1000
+    `, storeID, tipo, desde, hasta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	ventas := []Venta{}
+	for rows.Next() {
+		v, err := escanearVenta(rows)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ventas = append(ventas, v)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ventas)
+}
+
+// GetDailySales godoc
+// @Summary      Resumen diario de ventas
+// @Description  Tickets, unidades e importes vendidos y devueltos por tienda y día. Los días se cuentan en
+// @Description  la zona horaria de cada tienda; la venta neta descuenta las devoluciones del día.
+// @Tags         ventas
+// @Accept       json
+// @Produce      json
+// @Param        store_id query string false "Filtrar por tienda"
+// @Param        from query string false "Primer día (YYYY-MM-DD, por defecto hace 30 días)"
+// @Param        to query string false "Último día incluido (YYYY-MM-DD, por defecto hoy)"
+// @Success      200  {array}   ResumenVentasDia
+// @Failure      400  {object}  map[string]string
+// @Router       /reports/sales/daily [get]
+func (h *SalesHandler) GetDailySales(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+	hoy := time.Now()
+	hasta := hoy.Format("2006-01-02")
+	desde := hoy.AddDate(0, 0, -30).Format("2006-01-02")
+	for nombre, destino := range map[string]*string{"from": &desde, "to": &hasta} {
+		if v := q.Get(nombre); v != "" {
+			if _, err := time.Parse("2006-01-02", v); err != nil {
+				http.Error(w, "Fecha inválida en "+nombre+", use YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+			*destino = v
+		}
+	}
+	if desde > hasta {
+		http.Error(w, "from debe ser anterior o igual a to", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT v.storeId, t.name, d.dia, v.currency,
+               COUNT(*) FILTER (WHERE v.type = 'SALE'),
+               COUNT(*) FILTER (WHERE v.type = 'RETURN'),
+               COALESCE(SUM(u.unidades) FILTER (WHERE v.type = 'SALE'), 0),
+               COALESCE(SUM(u.unidades) FILTER (WHERE v.type = 'RETURN'), 0),
+               COALESCE(SUM(v.total) FILTER (WHERE v.type = 'SALE'), 0),
+               COALESCE(SUM(v.total) FILTER (WHERE v.type = 'RETURN'), 0)
+        FROM prueba.ventas v
+        JOIN catalogos.tiendas t ON v.storeId = t.id
+        CROSS JOIN LATERAL (
+            SELECT to_char((v.soldAt AT TIME ZONE 'UTC') AT TIME ZONE t.timezone, 'YYYY-MM-DD') AS dia
+        ) d
+        CROSS JOIN LATERAL (
+            SELECT SUM(quantity) AS unidades FROM prueba.ventaslineas WHERE saleId = v.id
+        ) u
+        WHERE v.activo = true
+          AND ($1::uuid IS NULL OR v.storeId = $1)
+          AND d.dia BETWEEN $2 AND $3
+        GROUP BY v.storeId, t.name, d.dia, v.currency
+        ORDER BY d.dia, t.name
+    `, storeID, desde, hasta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	resumen := []ResumenVentasDia{}
+	for rows.Next() {
+		var d ResumenVentasDia
+		var moneda string
+		if err := rows.Scan(&d.StoreID, &d.StoreName, &d.Date, &moneda, &d.Tickets, &d.Returns,
+			&d.UnitsSold, &d.UnitsReturned, &d.GrossSales.Amount, &d.ReturnsTotal.Amount); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		d.GrossSales.Currency, d.ReturnsTotal.Currency = moneda, moneda
+		d.NetSales = d.GrossSales.Sub(d.ReturnsTotal)
+		resumen = append(resumen, d)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resumen)
+}
+
+// validarTicket revisa el encabezado y las líneas del ticket y devuelve la
+// suma de las líneas, que debe coincidir con el total cobrado
+func validarTicket(t *TicketVenta, ahora time.Time) (money.Money, error) {
+	t.TicketNumber = strings.TrimSpace(t.TicketNumber)
+	if t.StoreID == uuid.Nil || t.TicketNumber == "" {
+		return money.Money{}, fmt.Errorf("%w: indique store_id y ticket_number", errVentaInvalida)
+	}
+	if len(t.Lines) == 0 || len(t.Lines) > maxLineasTicket {
+		return money.Money{}, fmt.Errorf("%w: indique entre 1 y %d líneas", errVentaInvalida, maxLineasTicket)
+	}
+	if t.SoldAt.IsZero() {
+		t.SoldAt = ahora
+	}
+	if t.SoldAt.After(ahora.Add(toleranciaRelojPOS)) {
+		return money.Money{}, fmt.Errorf("%w: la fecha del ticket está en el futuro", errVentaInvalida)
+	}
+	t.SoldAt = t.SoldAt.UTC()
+
+	moneda := t.PaymentTotal.Currency
+	total := money.New(0, moneda)
+	for i := range t.Lines {
+		l := &t.Lines[i]
+		if l.Quantity == 0 && len(l.Serials) > 0 {
+			l.Quantity = len(l.Serials)
+		}
+		if l.Quantity <= 0 {
+			return money.Money{}, fmt.Errorf("%w: línea %d: la cantidad debe ser positiva", errVentaInvalida, i+1)
+		}
+		if l.UnitPrice.IsNegative() {
+			return money.Money{}, fmt.Errorf("%w: línea %d: el precio no puede ser negativo", errVentaInvalida, i+1)
+		}
+		if l.UnitPrice.Currency != moneda {
+			return money.Money{}, fmt.Errorf("%w: línea %d: moneda %s distinta de la del ticket %s",
+				errVentaInvalida, i+1, l.UnitPrice.Currency, moneda)
+		}
+		total = total.Add(l.UnitPrice.Mul(int64(l.Quantity)))
+	}
+	if !total.Equal(t.PaymentTotal) {
+		return money.Money{}, fmt.Errorf("%w: el total cobrado %s no coincide con la suma de las líneas %s",
+			errVentaInvalida, t.PaymentTotal, total)
+	}
+	return total, nil
+}
+
+// insertarVenta registra el encabezado del ticket; un folio repetido en la
+// tienda devuelve errVentaDuplicada
+func insertarVenta(tx *sql.Tx, v *Venta) error {
+	var existe bool
+	if err := tx.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM catalogos.tiendas WHERE id = $1 AND activo = true)
+    `, v.StoreID).Scan(&existe); err != nil {
+		return err
+	}
+	if !existe {
+		return fmt.Errorf("%w: tienda no encontrada o inactiva", errVentaInvalida)
+	}
+	if err := tx.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM prueba.ventas WHERE storeId = $1 AND ticketNumber = $2)
+    `, v.StoreID, v.TicketNumber).Scan(&existe); err != nil {
+		return err
+	}
+	if existe {
+		return fmt.Errorf("%w: %s", errVentaDuplicada, v.TicketNumber)
+	}
+
+	// Dos envíos concurrentes del mismo folio pasan la verificación anterior;
+	// el perdedor choca con idx_ventas_ticket y también es un duplicado
+	err := tx.QueryRow(`
+        INSERT INTO prueba.ventas (id, storeId, ticketNumber, type, originalSaleId, soldAt, total, paymentTotal, currency)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING created_at
+    `, v.ID, v.StoreID, v.TicketNumber, v.Type, v.OriginalSaleID, v.SoldAt,
+		v.Total.Amount, v.PaymentTotal.Amount, v.Total.Currency).Scan(&v.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", errVentaDuplicada, v.TicketNumber)
+	}
+	return err
+}
+
+// registrarLineaVenta valida el precio de la línea contra el vigente en la
+// tienda y descuenta el inventario con un movimiento OUT
+func registrarLineaVenta(tx *sql.Tx, v Venta, numero int, l LineaTicket) (LineaVenta, error) {
+	linea := LineaVenta{ID: uuid.New(), Line: numero, Quantity: l.Quantity, UnitPrice: l.UnitPrice,
+		LineTotal: l.UnitPrice.Mul(int64(l.Quantity))}
+
+	productID, err := resolverProducto(tx, l.ProductID, l.Barcode)
+	if err != nil {
+		return linea, err
+	}
+	linea.ProductID = productID
+
+	vigente, err := precioCatalogo(tx, productID, v.StoreID, v.SoldAt)
+	if err != nil {
+		return linea, err
+	}
+	if !vigente.Equal(l.UnitPrice) {
+		return linea, fmt.Errorf("%w: se cobró %s y el precio vigente es %s", errPrecioInvalido, l.UnitPrice, vigente)
+	}
+
+	mov := CrearMovimiento{ProductID: productID, SourceStoreID: v.StoreID, TargetStoreID: v.StoreID,
+		Quantity: l.Quantity, Type: movimientoOUT, LotNumber: l.LotNumber, Serials: l.Serials}
+	m, err := insertarMovimiento(tx, mov, &v.ID, nil)
+	if err != nil {
+		return linea, err
+	}
+	linea.MovementID, linea.Lots, linea.Serials = &m.ID, m.Lots, m.Serials
+
+	_, err = tx.Exec(`
+        INSERT INTO prueba.ventaslineas (id, saleId, lineNumber, productId, quantity, unit_price, currency, movementId)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `, linea.ID, v.ID, numero, productID, l.Quantity, l.UnitPrice.Amount, l.UnitPrice.Currency, m.ID)
+	return linea, err
+}
+
+// precioCatalogo precio vigente del producto en la tienda a la fecha; sin
+// precio en las listas se usa el precio del catálogo de productos
+func precioCatalogo(q utils.Querier, productID, storeID uuid.UUID, fecha time.Time) (money.Money, error) {
+	vigente, err := precioVigente(q, productID, uuid.Nil, &storeID, fecha)
+	if err == nil {
+		return vigente.Price, nil
+	}
+	if err != sql.ErrNoRows {
+		return money.Money{}, err
+	}
+
+	var precio money.Money
+	err = q.QueryRow(`
+        SELECT price, currency FROM catalogos.productos WHERE id = $1 AND activo = true
+    `, productID).Scan(&precio.Amount, &precio.Currency)
+	if err == sql.ErrNoRows {
+		return precio, fmt.Errorf("%w: producto no encontrado o inactivo", errVentaInvalida)
+	}
+	return precio, err
+}
+
+// insertarMovimiento inserta un movimiento generado por un documento (ticket
+// o RMA) y lo aplica a existencias, lotes, series y capas de costo
+func insertarMovimiento(tx *sql.Tx, mov CrearMovimiento, saleID *uuid.UUID, costo *money.Money) (MovimientoDetalle, error) {
+	var m MovimientoDetalle
+	tipo, err := cargarTipoMovimiento(tx, mov.Type)
+	if err != nil {
+		return m, err
+	}
+	motivoID, err := motivoDeTipo(tipo, mov.ReasonCode)
+	if err != nil {
+		return m, err
+	}
+
+	var importe *money.Decimal
+	moneda := money.DefaultCurrency
+	if costo != nil {
+		importe, moneda = &costo.Amount, costo.Currency
+	}
+	err = tx.QueryRow(`
+        INSERT INTO prueba.movimientos (
+            id, productId, sourceStoreId, targetStoreId,
+            quantity, type, reasonId, unit_cost, currency, saleId, timestamp
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP)
+        RETURNING id, productId, sourceStoreId, targetStoreId, quantity, type,
+                  timestamp, activo, created_at, updated_at
+    `, uuid.New(), mov.ProductID, mov.SourceStoreID, mov.TargetStoreID,
+		mov.Quantity, mov.Type, motivoID, importe, moneda, saleID).Scan(
+		&m.ID, &m.ProductID, &m.SourceStoreID, &m.TargetStoreID, &m.Quantity, &m.Type,
+		&m.Timestamp, &m.Activo, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return m, err
+	}
+	m.UnitCost = costo
+	if motivoID != nil {
+		m.ReasonCode = &mov.ReasonCode
+	}
+	return m, aplicarMovimiento(tx, tipo, mov, &m)
+}
+
+// motivoDevolucionOtro motivo de las devoluciones que no indican uno
+const motivoDevolucionOtro = "OTHER"
+
+// lineaOriginal línea de la venta original con lo ya devuelto
+type lineaOriginal struct {
+	id         uuid.UUID
+	productID  uuid.UUID
+	quantity   int
+	devuelto   int
+	unitPrice  money.Money
+	movementID *uuid.UUID
+}
+
+// registrarDevolucion registra el ticket de devolución de una venta y
+// reingresa cada línea con un movimiento RETURN
+func registrarDevolucion(tx *sql.Tx, saleID uuid.UUID, dev DevolucionVenta) (Venta, error) {
+	var original Venta
+	err := tx.QueryRow(`
+        SELECT id, storeId, ticketNumber, currency FROM prueba.ventas
+        WHERE id = $1 AND type = 'SALE' AND activo = true
+        FOR UPDATE
+    `, saleID).Scan(&original.ID, &original.StoreID, &original.TicketNumber, &original.Total.Currency)
+	if err != nil {
+		return original, err
+	}
+
+	lineas, err := lineasOriginales(tx, saleID)
+	if err != nil {
+		return original, err
+	}
+
+	devolucion := Venta{ID: uuid.New(), StoreID: original.StoreID, TicketNumber: strings.TrimSpace(dev.TicketNumber),
+		Type: VentaRETURN, OriginalSaleID: &original.ID, SoldAt: dev.ReturnedAt.UTC(),
+		Total: money.New(0, original.Total.Currency)}
+	if devolucion.TicketNumber == "" {
+		var previas int
+		if err := tx.QueryRow(`
+            SELECT COUNT(*) FROM prueba.ventas WHERE originalSaleId = $1
+        `, saleID).Scan(&previas); err != nil {
+			return devolucion, err
+		}
+		devolucion.TicketNumber = fmt.Sprintf("%s-D%d", original.TicketNumber, previas+1)
+	}
+
+	// Validar cantidades contra lo vendido y no devuelto antes de mover
+	if devolucion.Total, err = validarDevolucion(lineas, dev.Lines, original.Total.Currency); err != nil {
+		return devolucion, err
+	}
+	devolucion.PaymentTotal = devolucion.Total
+	if err := insertarVenta(tx, &devolucion); err != nil {
+		return devolucion, err
+	}
+
+	for i, l := range dev.Lines {
+		orig := lineas[l.Line]
+		linea, err := registrarLineaDevolucion(tx, devolucion, i+1, orig, l)
+		if err != nil {
+			return devolucion, fmt.Errorf("línea %d: %w", i+1, err)
+		}
+		devolucion.Lines = append(devolucion.Lines, linea)
+	}
+	return devolucion, outbox.Record(tx, "sale", devolucion.ID, "sale.returned", devolucion)
+}
+
+// validarDevolucion verifica que cada línea devuelta exista en la venta y no
+// exceda lo vendido menos lo ya devuelto, incluidas las líneas anteriores de
+// la misma devolución, y devuelve el importe al precio de la venta. Sin
+// cantidad se devuelven tantas unidades como series.
+func validarDevolucion(lineas map[int]*lineaOriginal, devueltas []LineaDevolucion, moneda string) (money.Money, error) {
+	total := money.New(0, moneda)
+	for i := range devueltas {
+		l := &devueltas[i]
+		orig, ok := lineas[l.Line]
+		if !ok {
+			return money.Money{}, fmt.Errorf("%w: línea %d: la venta no tiene la línea %d", errVentaInvalida, i+1, l.Line)
+		}
+		if l.Quantity == 0 && len(l.Serials) > 0 {
+			l.Quantity = len(l.Serials)
+		}
+		if l.Quantity <= 0 || orig.devuelto+l.Quantity > orig.quantity {
+			return money.Money{}, fmt.Errorf("%w: línea %d: se pueden devolver hasta %d unidades",
+				errVentaInvalida, i+1, orig.quantity-orig.devuelto)
+		}
+		orig.devuelto += l.Quantity
+		total = total.Add(orig.unitPrice.Mul(int64(l.Quantity)))
+	}
+	return total, nil
+}
+
+// sqlCantidadDevuelta suma lo devuelto de la línea l por tickets de
+// devolución activos y por RMAs no canceladas
+const sqlCantidadDevuelta = `COALESCE((SELECT SUM(d.quantity) FROM prueba.ventaslineas d
+                         JOIN prueba.ventas v ON d.saleId = v.id
+                         WHERE d.originalLineId = l.id AND v.activo = true), 0)
+             + COALESCE((SELECT SUM(rl.quantity) FROM prueba.rmaslineas rl
+                         JOIN prueba.rmas r ON rl.rmaId = r.id
+                         WHERE rl.saleLineId = l.id AND r.status <> 'CANCELLED' AND r.activo = true), 0)`
+
+// lineasOriginales líneas de una venta por número de línea
+func lineasOriginales(q utils.Querier, saleID uuid.UUID) (map[int]*lineaOriginal, error) {
+	rows, err := q.Query(`
+        SELECT l.lineNumber, l.id, l.productId, l.quantity, l.unit_price, l.currency, l.movementId,
+               `+sqlCantidadDevuelta+`
+        FROM prueba.ventaslineas l
+        WHERE l.saleId = $1
+    `, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lineas := make(map[int]*lineaOriginal)
+	for rows.Next() {
+		var numero int
+		var l lineaOriginal
+		if err := rows.Scan(&numero, &l.id, &l.productID, &l.quantity, &l.unitPrice.Amount,
+			&l.unitPrice.Currency, &l.movementID, &l.devuelto); err != nil {
+			return nil, err
+		}
+		lineas[numero] = &l
+	}
+	return lineas, rows.Err()
+}
+
+// movimientoDevolucion movimiento RETURN que reingresa a la tienda una línea
+// devuelta con el motivo del cliente
+func movimientoDevolucion(productID, storeID uuid.UUID, l LineaDevolucion) CrearMovimiento {
+	mov := CrearMovimiento{ProductID: productID, SourceStoreID: storeID, TargetStoreID: storeID,
+		Quantity: l.Quantity, Type: movimientoRETURN, ReasonCode: l.ReasonCode, Serials: l.Serials}
+	if mov.ReasonCode == "" {
+		mov.ReasonCode = motivoDevolucionOtro
+	}
+	return mov
+}
+
+// registrarLineaDevolucion reingresa las unidades devueltas con el costo y los
+// lotes de la salida original
+func registrarLineaDevolucion(tx *sql.Tx, v Venta, numero int, orig *lineaOriginal, l LineaDevolucion) (LineaVenta, error) {
+	linea := LineaVenta{ID: uuid.New(), Line: numero, ProductID: orig.productID, Quantity: l.Quantity,
+		UnitPrice: orig.unitPrice, LineTotal: orig.unitPrice.Mul(int64(l.Quantity)), OriginalLineID: &orig.id}
+
+	mov := movimientoDevolucion(orig.productID, v.StoreID, l)
+	costo := money.New(0, orig.unitPrice.Currency)
+	if orig.movementID != nil {
+		vendidas, err := seriesDeMovimiento(tx, *orig.movementID)
+		if err != nil {
+			return linea, err
+		}
+		for _, s := range l.Serials {
+			if !contiene(vendidas, s) {
+				return linea, fmt.Errorf("%w: la serie %s no se vendió en la línea %d", errSerieInvalida, s, l.Line)
+			}
+		}
+		lotes, err := lotesDevolubles(tx, *orig.movementID, orig.id)
+		if err != nil {
+			return linea, err
+		}
+		if len(lotes) > 0 {
+			if mov.lotes, err = repartirDevolucion(lotes, l.Quantity); err != nil {
+				return linea, err
+			}
+		}
+		if c, err := costoDevolucion(tx, *orig.movementID, orig.productID, v.StoreID); err != nil {
+			return linea, err
+		} else if c != nil {
+			costo = *c
+		}
+	}
+
+	m, err := insertarMovimiento(tx, mov, &v.ID, &costo)
+	if err != nil {
+		return linea, err
+	}
+	linea.MovementID, linea.Lots, linea.Serials = &m.ID, m.Lots, m.Serials
+
+	_, err = tx.Exec(`
+        INSERT INTO prueba.ventaslineas (id, saleId, lineNumber, productId, quantity, unit_price, currency,
+                                         movementId, originalLineId)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `, linea.ID, v.ID, numero, orig.productID, l.Quantity, orig.unitPrice.Amount, orig.unitPrice.Currency,
+		m.ID, orig.id)
+	return linea, err
+}
+
+// lotesDevolubles lotes que tomó la salida de una línea vendida con lo que aún
+// se puede devolver a cada uno: lo vendido menos lo ya reingresado por
+// devoluciones de venta o RMA de esa línea
+func lotesDevolubles(q utils.Querier, movementID, saleLineID uuid.UUID) ([]MovimientoLote, error) {
+	rows, err := q.Query(`
+        SELECT l.id, l.lot_number, l.expiry_date, SUM(ml.quantity) - COALESCE(MAX(d.devuelto), 0)
+        FROM prueba.movimientoslotes ml
+        JOIN prueba.lotes l ON ml.lotId = l.id
+        LEFT JOIN LATERAL (
+            SELECT SUM(dl.quantity) AS devuelto
+            FROM prueba.movimientoslotes dl
+            JOIN prueba.lotes x ON dl.lotId = x.id
+            WHERE x.productId = l.productId AND x.lot_number = l.lot_number
+              AND dl.movementId IN (
+                  SELECT vl.movementId FROM prueba.ventaslineas vl
+                  JOIN prueba.ventas v ON vl.saleId = v.id
+                  WHERE vl.originalLineId = $2 AND v.activo = true
+                  UNION ALL
+                  SELECT rl.movementId FROM prueba.rmaslineas rl
+                  WHERE rl.saleLineId = $2 AND rl.movementId IS NOT NULL)
+        ) d ON true
+        WHERE ml.movementId = $1
+        GROUP BY l.id, l.lot_number, l.expiry_date
+        ORDER BY l.expiry_date NULLS LAST, l.lot_number`, movementID, saleLineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lotes []MovimientoLote
+	for rows.Next() {
+		var l MovimientoLote
+		if err := rows.Scan(&l.LotID, &l.LotNumber, &l.ExpiryDate, &l.Quantity); err != nil {
+			return nil, err
+		}
+		lotes = append(lotes, l)
+	}
+	return lotes, rows.Err()
+}
+
+// repartirDevolucion reparte las unidades devueltas entre los lotes de la
+// venta en el orden en que se tomaron (FEFO), hasta lo devolvible de cada uno
+func repartirDevolucion(disponibles []MovimientoLote, cantidad int) ([]MovimientoLote, error) {
+	var reparto []MovimientoLote
+	pendiente := cantidad
+	for _, l := range disponibles {
+		if pendiente == 0 {
+			break
+		}
+		if l.Quantity <= 0 {
+			continue
+		}
+		tomar := l.Quantity
+		if tomar > pendiente {
+			tomar = pendiente
+		}
+		l.Quantity = tomar
+		reparto = append(reparto, l)
+		pendiente -= tomar
+	}
+	if pendiente > 0 {
+		return nil, fmt.Errorf("%w: los lotes vendidos sólo admiten %d unidades más de devolución",
+			errLoteInvalido, cantidad-pendiente)
+	}
+	return reparto, nil
+}
+
+// costoDevolucion costo unitario promedio que consumió la salida original; si
+// no consumió capas se usa el último costo conocido del producto
+func costoDevolucion(q utils.Querier, movementID, productID, storeID uuid.UUID) (*money.Money, error) {
+	var total, unidades sql.NullInt64
+	var moneda sql.NullString
+	err := q.QueryRow(`
+        SELECT ROUND(SUM(quantity * unit_cost) * 100)::bigint, SUM(quantity), MIN(currency)
+        FROM prueba.consumoscosto WHERE movementId = $1
+    `, movementID).Scan(&total, &unidades, &moneda)
+	if err != nil {
+		return nil, err
+	}
+	if unidades.Valid && unidades.Int64 > 0 {
+		costo := money.New(money.Decimal(total.Int64), moneda.String).DivRound(unidades.Int64)
+		return &costo, nil
+	}
+	return ultimoCosto(q, productID, storeID)
+}
+
+// cargarVenta obtiene un ticket con sus líneas
+func cargarVenta(q utils.Querier, id uuid.UUID) (Venta, error) {
+	venta, err := escanearVenta(q.QueryRow(`
+        SELECT v.id, v.storeId, t.name, v.ticketNumber, v.type, v.originalSaleId, v.soldAt,
+               v.total, v.paymentTotal, v.currency, v.created_at
+        FROM prueba.ventas v
+        JOIN catalogos.tiendas t ON v.storeId = t.id
+        WHERE v.id = $1 AND v.activo = true
+    `, id))
+	if err != nil {
+		return venta, err
+	}
+
+	rows, err := q.Query(`
+        SELECT l.id, l.lineNumber, l.productId, p.name, l.quantity, l.unit_price, l.currency,
+               l.movementId, l.originalLineId, `+sqlCantidadDevuelta+`
+        FROM prueba.ventaslineas l
+        JOIN catalogos.productos p ON l.productId = p.id
+        WHERE l.saleId = $1
+        ORDER BY l.lineNumber
+    `, id)
+	if err != nil {
+		return venta, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l LineaVenta
+		if err := rows.Scan(&l.ID, &l.Line, &l.ProductID, &l.ProductName, &l.Quantity,
+			&l.UnitPrice.Amount, &l.UnitPrice.Currency, &l.MovementID, &l.OriginalLineID,
+			&l.ReturnedQuantity); err != nil {
+			return venta, err
+		}
+		l.LineTotal = l.UnitPrice.Mul(int64(l.Quantity))
+		venta.Lines = append(venta.Lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return venta, err
+	}
+
+	for i := range venta.Lines {
+		l := &venta.Lines[i]
+		if l.MovementID == nil {
+			continue
+		}
+		if l.Lots, err = lotesDeMovimiento(q, *l.MovementID); err != nil {
+			return venta, err
+		}
+		if l.Serials, err = seriesDeMovimiento(q, *l.MovementID); err != nil {
+			return venta, err
+		}
+	}
+	return venta, nil
+}
+
+func escanearVenta(row interface{ Scan(...interface{}) error }) (Venta, error) {
+	var v Venta
+	err := row.Scan(&v.ID, &v.StoreID, &v.StoreName, &v.TicketNumber, &v.Type, &v.OriginalSaleID,
+		&v.SoldAt, &v.Total.Amount, &v.PaymentTotal.Amount, &v.Total.Currency, &v.CreatedAt)
+	v.PaymentTotal.Currency = v.Total.Currency
+	return v, err
+}
+
+// responderErrorVenta traduce los errores de registro de tickets
+func responderErrorVenta(w http.ResponseWriter, err error) {
+	if errors.Is(err, errVentaDuplicada) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if esErrorDeNegocio(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	responderErrorInterno(w, err)
+}
+
+func contiene(lista []string, valor string) bool {
+	for _, v := range lista {
+		if v == valor {
+			return true
+		}
+	}
+	return false
+}
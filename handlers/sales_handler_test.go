@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"go-project/money"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestValidarTicket(t *testing.T) {
+	ahora := time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC)
+	cafe, laptop := uuid.New(), uuid.New()
+
+	// Las líneas con series toman la cantidad de las series y el folio se limpia
+	tk := TicketVenta{StoreID: uuid.New(), TicketNumber: " POS1-000123 ", PaymentTotal: money.New(3100000, "MXN"),
+		Lines: []LineaTicket{
+			{ProductID: cafe, Quantity: 2, UnitPrice: money.New(2500, "MXN")},
+			{ProductID: laptop, Serials: []string{"SN-1", "SN-2"}, UnitPrice: money.New(1547500, "MXN")},
+		}}
+	total, err := validarTicket(&tk, ahora)
+	if err != nil {
+		t.Fatalf("validarTicket: %v", err)
+	}
+	if !total.Equal(money.New(3100000, "MXN")) || tk.TicketNumber != "POS1-000123" || tk.Lines[1].Quantity != 2 {
+		t.Errorf("total = %s, folio = %q, cantidad = %d", total, tk.TicketNumber, tk.Lines[1].Quantity)
+	}
+	// Sin fecha la venta es de ahora
+	if !tk.SoldAt.Equal(ahora) {
+		t.Errorf("fecha = %s, se esperaba %s", tk.SoldAt, ahora)
+	}
+
+	// La fecha del punto de venta se guarda en UTC y se tolera un reloj adelantado
+	tk.SoldAt = ahora.Add(4 * time.Minute).In(time.FixedZone("CST", -6*3600))
+	if _, err := validarTicket(&tk, ahora); err != nil || tk.SoldAt.Location() != time.UTC {
+		t.Errorf("reloj adelantado: fecha = %s, err = %v", tk.SoldAt, err)
+	}
+	tk.SoldAt = ahora.Add(10 * time.Minute)
+	if _, err := validarTicket(&tk, ahora); !errors.Is(err, errVentaInvalida) {
+		t.Errorf("fecha en el futuro: err = %v", err)
+	}
+	tk.SoldAt = ahora
+
+	// El total cobrado debe ser la suma exacta de cantidad por precio
+	tk.PaymentTotal = money.New(3099999, "MXN")
+	if _, err := validarTicket(&tk, ahora); !errors.Is(err, errVentaInvalida) ||
+		!strings.Contains(err.Error(), "30999.99") || !strings.Contains(err.Error(), "31000.00") {
+		t.Errorf("total distinto: err = %v", err)
+	}
+	tk.PaymentTotal = money.New(3100000, "MXN")
+
+	// Todas las líneas se cobran en la moneda del total
+	tk.Lines[1].UnitPrice = money.New(1547500, "USD")
+	if _, err := validarTicket(&tk, ahora); !errors.Is(err, errVentaInvalida) || !strings.Contains(err.Error(), "línea 2") {
+		t.Errorf("otra moneda: err = %v", err)
+	}
+	tk.Lines[1].UnitPrice = money.New(1547500, "MXN")
+
+	// Un artículo de regalo a precio cero es válido; un precio negativo no
+	regalo := TicketVenta{StoreID: tk.StoreID, TicketNumber: "POS1-000124", PaymentTotal: money.New(0, "MXN"),
+		Lines: []LineaTicket{{ProductID: cafe, Quantity: 1, UnitPrice: money.New(0, "MXN")}}}
+	if _, err := validarTicket(&regalo, ahora); err != nil {
+		t.Errorf("precio cero: err = %v", err)
+	}
+	regalo.Lines[0].UnitPrice = money.New(-100, "MXN")
+	regalo.PaymentTotal = money.New(-100, "MXN")
+	if _, err := validarTicket(&regalo, ahora); !errors.Is(err, errVentaInvalida) {
+		t.Errorf("precio negativo: err = %v", err)
+	}
+
+	// Sin series ni cantidad la línea no vende nada
+	vacio := TicketVenta{StoreID: tk.StoreID, TicketNumber: "POS1-000125", PaymentTotal: money.New(0, "MXN"),
+		Lines: []LineaTicket{{ProductID: cafe, UnitPrice: money.New(2500, "MXN")}}}
+	if _, err := validarTicket(&vacio, ahora); !errors.Is(err, errVentaInvalida) {
+		t.Errorf("cantidad cero: err = %v", err)
+	}
+}
+
+func TestValidarDevolucion(t *testing.T) {
+	lineas := func() map[int]*lineaOriginal {
+		return map[int]*lineaOriginal{
+			1: {productID: uuid.New(), quantity: 3, devuelto: 1, unitPrice: money.New(2500, "MXN")},
+			2: {productID: uuid.New(), quantity: 2, unitPrice: money.New(1547500, "MXN")},
+		}
+	}
+
+	// Se reembolsa al precio de la venta; las series dan la cantidad
+	devueltas := []LineaDevolucion{{Line: 1, Quantity: 2}, {Line: 2, Serials: []string{"SN-2"}}}
+	originales := lineas()
+	total, err := validarDevolucion(originales, devueltas, "MXN")
+	if err != nil || !total.Equal(money.New(1552500, "MXN")) || devueltas[1].Quantity != 1 {
+		t.Errorf("devolución: total = %s, cantidad = %d, err = %v", total, devueltas[1].Quantity, err)
+	}
+	if originales[1].devuelto != 3 || originales[2].devuelto != 1 {
+		t.Errorf("devuelto = %d y %d, se esperaba 3 y 1", originales[1].devuelto, originales[2].devuelto)
+	}
+
+	// Lo ya devuelto limita la devolución: de la línea 1 quedan 2 unidades
+	_, err = validarDevolucion(lineas(), []LineaDevolucion{{Line: 1, Quantity: 3}}, "MXN")
+	if !errors.Is(err, errVentaInvalida) || !strings.Contains(err.Error(), "hasta 2 unidades") {
+		t.Errorf("excede lo devolvible: err = %v", err)
+	}
+
+	// Dos renglones de la misma línea suman contra lo vendido
+	_, err = validarDevolucion(lineas(), []LineaDevolucion{{Line: 2, Quantity: 1}, {Line: 2, Quantity: 2}}, "MXN")
+	if !errors.Is(err, errVentaInvalida) || !strings.Contains(err.Error(), "línea 2: se pueden devolver hasta 1") {
+		t.Errorf("renglones repetidos: err = %v", err)
+	}
+
+	if _, err := validarDevolucion(lineas(), []LineaDevolucion{{Line: 7, Quantity: 1}}, "MXN"); !errors.Is(err, errVentaInvalida) {
+		t.Errorf("línea inexistente: err = %v", err)
+	}
+}
+
+func TestRepartirDevolucion(t *testing.T) {
+	lotes := []MovimientoLote{
+		{LotNumber: "L1", Quantity: 2},
+		{LotNumber: "L2", Quantity: 0},
+		{LotNumber: "L3", Quantity: 5},
+	}
+
+	reparto, err := repartirDevolucion(lotes, 4)
+	if err != nil || len(reparto) != 2 || reparto[0].LotNumber != "L1" || reparto[0].Quantity != 2 ||
+		reparto[1].LotNumber != "L3" || reparto[1].Quantity != 2 {
+		t.Errorf("reparto = %+v, err = %v", reparto, err)
+	}
+	if lotes[0].Quantity != 2 {
+		t.Error("repartirDevolucion no debe modificar los lotes disponibles")
+	}
+
+	if _, err := repartirDevolucion(lotes, 8); !errors.Is(err, errLoteInvalido) {
+		t.Errorf("devolución mayor a lo vendido: err = %v", err)
+	}
+}
+
+func TestMovimientoDevolucion(t *testing.T) {
+	producto, tienda := uuid.New(), uuid.New()
+
+	mov := movimientoDevolucion(producto, tienda, LineaDevolucion{Line: 1, Quantity: 2, ReasonCode: "DEFECTIVE"})
+	if mov.Type != movimientoRETURN || mov.ReasonCode != "DEFECTIVE" || mov.Quantity != 2 ||
+		mov.ProductID != producto || mov.SourceStoreID != tienda || mov.TargetStoreID != tienda {
+		t.Errorf("movimiento = %+v", mov)
+	}
+	if mov := movimientoDevolucion(producto, tienda, LineaDevolucion{Line: 1, Quantity: 1}); mov.ReasonCode != motivoDevolucionOtro {
+		t.Errorf("sin motivo: motivo = %q", mov.ReasonCode)
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/utils"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// errSerieInvalida indica un error en los números de serie de un movimiento
+var errSerieInvalida = errors.New("Serie inválida")
+
+// Estados de una unidad con número de serie
+const (
+	SerieEnTienda = "EN_TIENDA"
+	SerieFuera    = "FUERA"
+)
+
+// Serie unidad de un producto identificada por número de serie
+// @Description Unidad con número de serie y su ubicación actual
+type Serie struct {
+	ID           uuid.UUID  `json:"id"`
+	ProductID    uuid.UUID  `json:"product_id"`
+	ProductName  string     `json:"product_name" example:"Laptop HP Pavilion"`
+	SerialNumber string     `json:"serial_number" example:"5CD1234XYZ"`
+	StoreID      *uuid.UUID `json:"store_id,omitempty"`
+	StoreName    *string    `json:"store_name,omitempty" example:"Tienda Central"`
+	Status       string     `json:"status" example:"EN_TIENDA"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// MovimientoSerie movimiento en el que participó una unidad
+type MovimientoSerie struct {
+	MovementID      uuid.UUID      `json:"movement_id"`
+	Type            MovimientoTipo `json:"type" example:"TRANSFER"`
+	SourceStoreID   uuid.UUID      `json:"source_store_id"`
+	SourceStoreName string         `json:"source_store_name"`
+	TargetStoreID   uuid.UUID      `json:"target_store_id"`
+	TargetStoreName string         `json:"target_store_name"`
+	Timestamp       time.Time      `json:"timestamp"`
+}
+
+// HistorialSerie unidad con todos sus movimientos
+type HistorialSerie struct {
+	Serie
+	Movements []MovimientoSerie `json:"movements"`
+}
+
+// ListarSeries godoc
+// @Summary      Listar números de serie
+// @Description  Obtiene las unidades de un producto que se encuentran en tienda, opcionalmente filtradas por tienda
+// @Tags         inventario
+// @Accept       json
+// @Produce      json
+// @Param        product_id query string true "ID del producto"
+// @Param        store_id query string false "ID de la tienda"
+// @Success      200  {array}   Serie
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarSeries [get]
+func (h *InventoryHandler) ListarSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	productID, err := uuid.Parse(r.URL.Query().Get("product_id"))
+	if err != nil {
+		http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+		return
+	}
+
+	var storeID *uuid.UUID
+	if v := r.URL.Query().Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	series, err := buscarSeries(h.db, `
+        s.productId = $1 AND ($2::uuid IS NULL OR s.storeId = $2) AND s.status = 'EN_TIENDA'`,
+		productID, storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+// GetSerialHistory godoc
+// @Summary      Historial de un número de serie
+// @Description  Obtiene la ubicación actual y todos los movimientos de una unidad por su número de serie
+// @Tags         inventario
+// @Accept       json
+// @Produce      json
+// @Param        serial path string true "Número de serie"
+// @Param        product_id query string false "ID del producto (si el número se repite entre productos)"
+// @Success      200  {array}   HistorialSerie
+// @Failure      404  {object}  map[string]string
+// @Router       /inventory/serials/{serial} [get]
+func (h *InventoryHandler) GetSerialHistory(w http.ResponseWriter, r *http.Request) {
+	numero := mux.Vars(r)["serial"]
+	if numero == "" {
+		http.Error(w, "Número de serie requerido", http.StatusBadRequest)
+		return
+	}
+
+	var productID *uuid.UUID
+	if v := r.URL.Query().Get("product_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de producto inválido", http.StatusBadRequest)
+			return
+		}
+		productID = &id
+	}
+
+	series, err := buscarSeries(h.db, `
+        s.serial_number = $1 AND ($2::uuid IS NULL OR s.productId = $2)`, numero, productID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(series) == 0 {
+		http.Error(w, "Número de serie no encontrado", http.StatusNotFound)
+		return
+	}
+
+	historial := make([]HistorialSerie, 0, len(series))
+	for _, s := range series {
+		rows, err := h.db.Query(`
+            SELECT m.id, m.type, m.sourceStoreId, s1.name, m.targetStoreId, s2.name, m.timestamp
+            FROM prueba.movimientosseries ms
+            JOIN prueba.movimientos m ON ms.movementId = m.id
+            JOIN catalogos.tiendas s1 ON m.sourceStoreId = s1.id
+            JOIN catalogos.tiendas s2 ON m.targetStoreId = s2.id
+            WHERE ms.serialId = $1
+            ORDER BY m.timestamp ASC`, s.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		hs := HistorialSerie{Serie: s}
+		for rows.Next() {
+			var m MovimientoSerie
+			if err := rows.Scan(&m.MovementID, &m.Type, &m.SourceStoreID, &m.SourceStoreName,
+				&m.TargetStoreID, &m.TargetStoreName, &m.Timestamp); err != nil {
+				rows.Close()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			hs.Movements = append(hs.Movements, m)
+		}
+		rows.Close()
+		historial = append(historial, hs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(historial)
+}
+
+// buscarSeries obtiene las unidades que cumplen la condición indicada
+func buscarSeries(q utils.Querier, condicion string, args ...interface{}) ([]Serie, error) {
+	rows, err := q.Query(`
+        SELECT s.id, s.productId, p.name, s.serial_number, s.storeId, t.name,
+               s.status, s.created_at, s.updated_at
+        FROM prueba.series s
+        JOIN catalogos.productos p ON s.productId = p.id
+        LEFT JOIN catalogos.tiendas t ON s.storeId = t.id
+        WHERE s.activo = true AND `+condicion+`
+        ORDER BY p.name, s.serial_number`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []Serie
+	for rows.Next() {
+		var s Serie
+		if err := rows.Scan(&s.ID, &s.ProductID, &s.ProductName, &s.SerialNumber,
+			&s.StoreID, &s.StoreName, &s.Status, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
+
+// seriesDeMovimiento obtiene los números de serie movidos en un movimiento
+func seriesDeMovimiento(q utils.Querier, movementID uuid.UUID) ([]string, error) {
+	rows, err := q.Query(`
+        SELECT s.serial_number
+        FROM prueba.movimientosseries ms
+        JOIN prueba.series s ON ms.serialId = s.id
+        WHERE ms.movementId = $1
+        ORDER BY s.serial_number`, movementID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, rows.Err()
+}
+
+// productoControlaSeries indica si el producto lleva control por número de serie
+func productoControlaSeries(q utils.Querier, productID uuid.UUID) (bool, error) {
+	var controla bool
+	err := q.QueryRow(`SELECT track_serials FROM catalogos.productos WHERE id = $1`, productID).Scan(&controla)
+	return controla, err
+}
+
+// validarSeries verifica que la lista de series corresponda a la cantidad del
+// movimiento y no tenga números repetidos
+func validarSeries(series []string, cantidad int) error {
+	if len(series) != cantidad {
+		return fmt.Errorf("%w: se esperaban %d números de serie y se recibieron %d",
+			errSerieInvalida, cantidad, len(series))
+	}
+	vistas := make(map[string]bool, len(series))
+	for _, s := range series {
+		if s == "" {
+			return fmt.Errorf("%w: número de serie vacío", errSerieInvalida)
+		}
+		if vistas[s] {
+			return fmt.Errorf("%w: la serie %s está repetida", errSerieInvalida, s)
+		}
+		vistas[s] = true
+	}
+	return nil
+}
+
+// moverSeries registra las unidades de un movimiento según el efecto de su
+// tipo: las entradas las dan de alta (o reingresan) en la tienda destino, las
+// salidas las sacan de la tienda origen y los traspasos las cambian de
+// tienda. Cada unidad queda ligada al movimiento para su historial.
+func moverSeries(tx *sql.Tx, efecto EfectoMovimiento, productID, sourceStoreID, targetStoreID, movementID uuid.UUID, series []string) error {
+	for _, numero := range series {
+		var id uuid.UUID
+		var status string
+		var tienda *uuid.UUID
+		err := tx.QueryRow(`
+            SELECT id, status, storeId FROM prueba.series
+            WHERE productId = $1 AND serial_number = $2 AND activo = true
+            FOR UPDATE
+        `, productID, numero).Scan(&id, &status, &tienda)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		existe := err == nil
+
+		destino, nuevoStatus, err := siguienteEstadoSerie(efecto, numero, existe, status, tienda, sourceStoreID, targetStoreID)
+		if err != nil {
+			return err
+		}
+		if existe {
+			_, err = tx.Exec(`
+                UPDATE prueba.series SET storeId = $2, status = $3 WHERE id = $1
+            `, id, destino, nuevoStatus)
+		} else {
+			id = uuid.New()
+			_, err = tx.Exec(`
+                INSERT INTO prueba.series (id, productId, serial_number, storeId, status)
+                VALUES ($1, $2, $3, $4, $5)
+            `, id, productID, numero, destino, nuevoStatus)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+            INSERT INTO prueba.movimientosseries (id, movementId, serialId)
+            VALUES ($1, $2, $3)
+        `, uuid.New(), movementID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// siguienteEstadoSerie valida que la unidad pueda moverse según el efecto y
+// devuelve su nueva tienda y estado: una entrada sólo admite unidades nuevas o
+// fuera de tienda, y las salidas y traspasos requieren que la unidad esté en
+// la tienda origen.
+func siguienteEstadoSerie(efecto EfectoMovimiento, numero string, existe bool, status string, tienda *uuid.UUID, sourceStoreID, targetStoreID uuid.UUID) (*uuid.UUID, string, error) {
+	if efecto == EfectoIN {
+		if existe && status == SerieEnTienda {
+			return nil, "", fmt.Errorf("%w: la serie %s ya se encuentra en una tienda", errSerieInvalida, numero)
+		}
+		return &targetStoreID, SerieEnTienda, nil
+	}
+	if !existe || status != SerieEnTienda || tienda == nil || *tienda != sourceStoreID {
+		return nil, "", fmt.Errorf("%w: la serie %s no se encuentra en la tienda origen", errSerieInvalida, numero)
+	}
+	if efecto == EfectoTRANSFER {
+		return &targetStoreID, SerieEnTienda, nil
+	}
+	return nil, SerieFuera, nil
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidarSeries(t *testing.T) {
+	if err := validarSeries([]string{"SN-1", "SN-2"}, 2); err != nil {
+		t.Errorf("series válidas: err = %v", err)
+	}
+	if err := validarSeries([]string{"SN-1"}, 2); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("faltan series: err = %v", err)
+	}
+	if err := validarSeries([]string{"SN-1", ""}, 2); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("serie vacía: err = %v", err)
+	}
+	if err := validarSeries([]string{"SN-1", "SN-1"}, 2); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("serie repetida: err = %v", err)
+	}
+}
+
+func TestSiguienteEstadoSerie(t *testing.T) {
+	origen, destino, otra := uuid.New(), uuid.New(), uuid.New()
+
+	// Una entrada da de alta la unidad nueva en la tienda destino
+	tienda, status, err := siguienteEstadoSerie(EfectoIN, "SN-1", false, "", nil, origen, destino)
+	if err != nil || tienda == nil || *tienda != destino || status != SerieEnTienda {
+		t.Errorf("alta: %v %s %v", tienda, status, err)
+	}
+
+	// Una unidad que salió puede reingresar
+	tienda, status, err = siguienteEstadoSerie(EfectoIN, "SN-1", true, SerieFuera, nil, origen, destino)
+	if err != nil || tienda == nil || *tienda != destino || status != SerieEnTienda {
+		t.Errorf("reingreso: %v %s %v", tienda, status, err)
+	}
+
+	// No se puede recibir una unidad que ya está en una tienda
+	if _, _, err := siguienteEstadoSerie(EfectoIN, "SN-1", true, SerieEnTienda, &otra, origen, destino); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("entrada duplicada: err = %v", err)
+	}
+
+	// El traspaso cambia la unidad de tienda
+	tienda, status, err = siguienteEstadoSerie(EfectoTRANSFER, "SN-1", true, SerieEnTienda, &origen, origen, destino)
+	if err != nil || tienda == nil || *tienda != destino || status != SerieEnTienda {
+		t.Errorf("traspaso: %v %s %v", tienda, status, err)
+	}
+
+	// La salida deja la unidad fuera de tienda
+	tienda, status, err = siguienteEstadoSerie(EfectoOUT, "SN-1", true, SerieEnTienda, &origen, origen, origen)
+	if err != nil || tienda != nil || status != SerieFuera {
+		t.Errorf("salida: %v %s %v", tienda, status, err)
+	}
+
+	// Salidas y traspasos requieren la unidad en la tienda origen
+	if _, _, err := siguienteEstadoSerie(EfectoTRANSFER, "SN-1", true, SerieEnTienda, &otra, origen, destino); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("otra tienda: err = %v", err)
+	}
+	if _, _, err := siguienteEstadoSerie(EfectoOUT, "SN-1", true, SerieFuera, nil, origen, origen); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("unidad fuera: err = %v", err)
+	}
+	if _, _, err := siguienteEstadoSerie(EfectoOUT, "SN-9", false, "", nil, origen, origen); !errors.Is(err, errSerieInvalida) {
+		t.Errorf("serie desconocida: err = %v", err)
+	}
+}
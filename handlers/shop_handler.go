@@ -3,19 +3,27 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/stores"
+	"go-project/utils"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // Tienda modelo básico de tienda
 // @Description Modelo de tienda para la API
 type Tienda struct {
-	ID      uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Name    string    `json:"name" example:"Tienda Central"`
-	Address string    `json:"address" example:"Av. Principal 123"`
-	Phone   string    `json:"phone" example:"555-0123"`
+	ID        uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name      string    `json:"name" example:"Tienda Central"`
+	Address   string    `json:"address" example:"Av. Principal 123"`
+	Phone     string    `json:"phone" example:"555-0123"`
+	Latitude  *float64  `json:"latitude,omitempty" example:"19.432608"`
+	Longitude *float64  `json:"longitude,omitempty" example:"-99.133209"`
+	Timezone  string    `json:"timezone" example:"America/Mexico_City"`
 }
 
 // CrearTienda modelo para crear una nueva tienda
@@ -23,19 +31,39 @@ type CrearTienda struct {
 	Name    string `json:"name" example:"Tienda Central"`
 	Address string `json:"address" example:"Av. Principal 123"`
 	Phone   string `json:"phone" example:"555-0123"`
+	// Coordenadas de la tienda; se indican las dos o ninguna
+	Latitude  *float64 `json:"latitude,omitempty" example:"19.432608"`
+	Longitude *float64 `json:"longitude,omitempty" example:"-99.133209"`
+	// Zona horaria IANA de la tienda (predeterminada America/Mexico_City)
+	Timezone string `json:"timezone,omitempty" example:"America/Mexico_City"`
+	// Horario semanal; al actualizar, si se omite se conserva el registrado
+	// y una lista vacía lo elimina
+	Hours stores.Hours `json:"hours,omitempty"`
 }
 
 // TiendaDetalle modelo completo con campos de auditoría
 type TiendaDetalle struct {
-	ID        uuid.UUID `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
-	Name      string    `json:"name" example:"Tienda Central"`
-	Address   string    `json:"address" example:"Av. Principal 123"`
-	Phone     string    `json:"phone" example:"555-0123"`
+	ID        uuid.UUID    `json:"id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name      string       `json:"name" example:"Tienda Central"`
+	Address   string       `json:"address" example:"Av. Principal 123"`
+	Phone     string       `json:"phone" example:"555-0123"`
+	Latitude  *float64     `json:"latitude,omitempty" example:"19.432608"`
+	Longitude *float64     `json:"longitude,omitempty" example:"-99.133209"`
+	Timezone  string       `json:"timezone" example:"America/Mexico_City"`
+	Hours     stores.Hours `json:"hours"`
+	// Indica si la tienda atiende en este momento según su horario
+	OpenNow   bool      `json:"open_now" example:"true"`
 	Activo    bool      `json:"activo" example:"true"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// errTiendaInvalida datos de ubicación u horario de la tienda inválidos
+var errTiendaInvalida = errors.New("datos de tienda inválidos")
+
+// Columnas de catalogos.tiendas que lee escanearTienda
+const columnasTienda = `id, name, address, phone, latitude, longitude, timezone, activo, created_at, updated_at`
+
 type ShopHandler struct {
 	db *sql.DB
 }
@@ -60,7 +88,7 @@ func (h *ShopHandler) ListarTiendas(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rows, err := h.db.Query(`
-        SELECT id, name, address, phone
+        SELECT id, name, address, phone, latitude, longitude, timezone
         FROM catalogos.tiendas 
         WHERE activo = true
     `)
@@ -73,7 +101,7 @@ func (h *ShopHandler) ListarTiendas(w http.ResponseWriter, r *http.Request) {
 	var tiendas []Tienda
 	for rows.Next() {
 		var t Tienda
-		err := rows.Scan(&t.ID, &t.Name, &t.Address, &t.Phone)
+		err := rows.Scan(&t.ID, &t.Name, &t.Address, &t.Phone, &t.Latitude, &t.Longitude, &t.Timezone)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -87,7 +115,7 @@ func (h *ShopHandler) ListarTiendas(w http.ResponseWriter, r *http.Request) {
 
 // CrearTienda godoc
 // @Summary      Crear nueva tienda
-// @Description  Crea una nueva tienda en el sistema
+// @Description  Crea una nueva tienda en el sistema con su ubicación, zona horaria y horario de atención
 // @Tags         tiendas
 // @Accept       json
 // @Produce      json
@@ -106,23 +134,29 @@ func (h *ShopHandler) CrearTienda(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Datos inválidos", http.StatusBadRequest)
 		return
 	}
+	if err := validarTienda(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var tiendaDetalle TiendaDetalle
-	tiendaDetalle.ID = uuid.New()
-
-	err := h.db.QueryRow(`
-        INSERT INTO catalogos.tiendas (id, name, address, phone, activo, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, true, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
-        RETURNING id, name, address, phone, activo, created_at, updated_at
-    `, tiendaDetalle.ID, t.Name, t.Address, t.Phone).Scan(
-		&tiendaDetalle.ID, &tiendaDetalle.Name, &tiendaDetalle.Address,
-		&tiendaDetalle.Phone, &tiendaDetalle.Activo, &tiendaDetalle.CreatedAt,
-		&tiendaDetalle.UpdatedAt)
-
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var err error
+		tiendaDetalle, err = escanearTienda(tx.QueryRow(`
+            INSERT INTO catalogos.tiendas (id, name, address, phone, latitude, longitude, timezone, activo, created_at, updated_at)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, true, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+            RETURNING `+columnasTienda,
+			uuid.New(), t.Name, t.Address, t.Phone, t.Latitude, t.Longitude, t.Timezone))
+		if err != nil {
+			return err
+		}
+		return guardarHorarios(tx, tiendaDetalle.ID, t.Hours)
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responderErrorInterno(w, err)
 		return
 	}
+	completarHorario(&tiendaDetalle, t.Hours, time.Now())
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -131,7 +165,7 @@ func (h *ShopHandler) CrearTienda(w http.ResponseWriter, r *http.Request) {
 
 // ObtenerTienda godoc
 // @Summary      Obtener tienda por ID
-// @Description  Obtiene los detalles de una tienda específica
+// @Description  Obtiene los detalles de una tienda específica, su horario y si está abierta en este momento
 // @Tags         tiendas
 // @Accept       json
 // @Produce      json
@@ -151,14 +185,11 @@ func (h *ShopHandler) ObtenerTienda(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var tienda TiendaDetalle
-	err = h.db.QueryRow(`
-        SELECT id, name, address, phone, activo, created_at, updated_at
+	tienda, err := escanearTienda(h.db.QueryRow(`
+        SELECT `+columnasTienda+`
         FROM catalogos.tiendas 
         WHERE id = $1
-    `, id).Scan(
-		&tienda.ID, &tienda.Name, &tienda.Address, &tienda.Phone,
-		&tienda.Activo, &tienda.CreatedAt, &tienda.UpdatedAt)
+    `, id))
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Tienda no encontrada", http.StatusNotFound)
@@ -168,14 +199,12 @@ func (h *ShopHandler) ObtenerTienda(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tienda)
+	h.responderTienda(w, tienda)
 }
 
 // ActualizarTienda godoc
 // @Summary      Actualizar tienda
-// @Description  Actualiza los datos de una tienda existente
+// @Description  Actualiza los datos de una tienda existente; si se omite hours se conserva el horario registrado
 // @Tags         tiendas
 // @Accept       json
 // @Produce      json
@@ -201,28 +230,36 @@ func (h *ShopHandler) ActualizarTienda(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Datos inválidos", http.StatusBadRequest)
 		return
 	}
+	if err := validarTienda(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var tienda TiendaDetalle
-	err = h.db.QueryRow(`
-        UPDATE catalogos.tiendas 
-        SET name = $1, address = $2, phone = $3, updated_at = CURRENT_TIMESTAMP
-        WHERE id = $4 AND activo = true
-        RETURNING id, name, address, phone, activo, created_at, updated_at
-    `, t.Name, t.Address, t.Phone, id).Scan(
-		&tienda.ID, &tienda.Name, &tienda.Address, &tienda.Phone,
-		&tienda.Activo, &tienda.CreatedAt, &tienda.UpdatedAt)
+	err = utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		var err error
+		tienda, err = escanearTienda(tx.QueryRow(`
+            UPDATE catalogos.tiendas 
+            SET name = $1, address = $2, phone = $3, latitude = $4, longitude = $5, timezone = $6,
+                updated_at = CURRENT_TIMESTAMP
+            WHERE id = $7 AND activo = true
+            RETURNING `+columnasTienda,
+			t.Name, t.Address, t.Phone, t.Latitude, t.Longitude, t.Timezone, id))
+		if err != nil || t.Hours == nil {
+			return err
+		}
+		return guardarHorarios(tx, id, t.Hours)
+	})
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Tienda no encontrada o inactiva", http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		responderErrorInterno(w, err)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tienda)
+	h.responderTienda(w, tienda)
 }
 
 // ToggleTiendaEstado godoc
@@ -250,15 +287,11 @@ func (h *ShopHandler) ToggleTiendaEstado(w http.ResponseWriter, r *http.Request)
 
 	activate := r.URL.Query().Get("activate") == "true"
 
-	var tienda TiendaDetalle
-	err = h.db.QueryRow(`
+	tienda, err := escanearTienda(h.db.QueryRow(`
         UPDATE catalogos.tiendas 
         SET activo = $1, updated_at = CURRENT_TIMESTAMP
         WHERE id = $2
-        RETURNING id, name, address, phone, activo, created_at, updated_at
-    `, activate, id).Scan(
-		&tienda.ID, &tienda.Name, &tienda.Address, &tienda.Phone,
-		&tienda.Activo, &tienda.CreatedAt, &tienda.UpdatedAt)
+        RETURNING `+columnasTienda, activate, id))
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Tienda no encontrada", http.StatusNotFound)
@@ -268,9 +301,7 @@ func (h *ShopHandler) ToggleTiendaEstado(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tienda)
+	h.responderTienda(w, tienda)
 }
 
 // EliminarTienda godoc
@@ -318,3 +349,98 @@ func (h *ShopHandler) EliminarTienda(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// responderTienda agrega el horario registrado a la tienda y la devuelve
+func (h *ShopHandler) responderTienda(w http.ResponseWriter, tienda TiendaDetalle) {
+	horarios, err := cargarHorarios(h.db, []uuid.UUID{tienda.ID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	completarHorario(&tienda, horarios[tienda.ID], time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tienda)
+}
+
+func escanearTienda(row interface{ Scan(...interface{}) error }) (TiendaDetalle, error) {
+	var t TiendaDetalle
+	err := row.Scan(&t.ID, &t.Name, &t.Address, &t.Phone, &t.Latitude, &t.Longitude,
+		&t.Timezone, &t.Activo, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// completarHorario asigna el horario de la tienda e indica si está abierta
+// en el instante ahora de su zona horaria
+func completarHorario(t *TiendaDetalle, horario stores.Hours, ahora time.Time) {
+	if horario == nil {
+		horario = stores.Hours{}
+	}
+	t.Hours = horario
+	if loc, err := time.LoadLocation(t.Timezone); err == nil {
+		t.OpenNow = horario.IsOpen(ahora.In(loc))
+	}
+}
+
+// validarTienda revisa coordenadas, zona horaria y horario, y completa la
+// zona horaria predeterminada
+func validarTienda(t *CrearTienda) error {
+	if (t.Latitude == nil) != (t.Longitude == nil) {
+		return fmt.Errorf("%w: indique latitude y longitude juntas", errTiendaInvalida)
+	}
+	if t.Latitude != nil && !stores.ValidCoordinates(*t.Latitude, *t.Longitude) {
+		return fmt.Errorf("%w: coordenadas fuera de rango", errTiendaInvalida)
+	}
+	if t.Timezone == "" {
+		t.Timezone = stores.DefaultTimezone
+	}
+	if _, err := time.LoadLocation(t.Timezone); err != nil {
+		return fmt.Errorf("%w: zona horaria desconocida %q", errTiendaInvalida, t.Timezone)
+	}
+	if err := t.Hours.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", errTiendaInvalida, err)
+	}
+	return nil
+}
+
+// guardarHorarios reemplaza el horario semanal de la tienda
+func guardarHorarios(tx *sql.Tx, storeID uuid.UUID, horario stores.Hours) error {
+	if _, err := tx.Exec(`DELETE FROM catalogos.tiendashorarios WHERE storeId = $1`, storeID); err != nil {
+		return err
+	}
+	for _, p := range horario {
+		_, err := tx.Exec(`
+            INSERT INTO catalogos.tiendashorarios (id, storeId, dayOfWeek, opensAt, closesAt)
+            VALUES ($1, $2, $3, $4, $5)
+        `, uuid.New(), storeID, int(p.Day), p.Opens, p.Closes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cargarHorarios obtiene el horario semanal de cada tienda indicada
+func cargarHorarios(q utils.Querier, storeIDs []uuid.UUID) (map[uuid.UUID]stores.Hours, error) {
+	rows, err := q.Query(`
+        SELECT storeId, dayOfWeek, to_char(opensAt, 'HH24:MI'), to_char(closesAt, 'HH24:MI')
+        FROM catalogos.tiendashorarios
+        WHERE storeId = ANY($1)
+        ORDER BY storeId, dayOfWeek, opensAt
+    `, pq.Array(storeIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	horarios := make(map[uuid.UUID]stores.Hours)
+	for rows.Next() {
+		var storeID uuid.UUID
+		var p stores.Period
+		if err := rows.Scan(&storeID, &p.Day, &p.Opens, &p.Closes); err != nil {
+			return nil, err
+		}
+		horarios[storeID] = append(horarios[storeID], p)
+	}
+	return horarios, rows.Err()
+}
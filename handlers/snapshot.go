@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Días hacia atrás que se reconstruyen cuando faltan fotografías
+const diasRecuperarSnapshots = 31
+
+// parseAsOf lee el parámetro as_of: una fecha (2006-01-02) se interpreta como
+// el cierre de ese día y una marca RFC3339 como ese instante. Sin parámetro
+// devuelve nil (existencia actual).
+func parseAsOf(r *http.Request) (*time.Time, error) {
+	v := r.URL.Query().Get("as_of")
+	if v == "" {
+		return nil, nil
+	}
+	instante, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		dia, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, errors.New("Fecha as_of inválida")
+		}
+		instante = dia.AddDate(0, 0, 1)
+	}
+	if instante.After(time.Now()) {
+		return nil, errors.New("La fecha as_of no puede ser futura")
+	}
+	// Las marcas de tiempo de la base no tienen zona: se comparan en hora local
+	local := instante.In(time.Local)
+	instante = time.Date(local.Year(), local.Month(), local.Day(),
+		local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+	return &instante, nil
+}
+
+// MaterializarSnapshots fotografía las existencias al cierre de cada día
+// cerrado que aún no tenga fotografía, hasta diasRecuperarSnapshots atrás
+func (h *InventoryHandler) MaterializarSnapshots() (int, error) {
+	rows, err := h.db.Query(`
+        SELECT d::date
+        FROM generate_series(
+            GREATEST(
+                COALESCE((SELECT MAX(snapshotDate) + 1 FROM prueba.inventariossnapshots), CURRENT_DATE - 1),
+                CURRENT_DATE - $1::int
+            ),
+            CURRENT_DATE - 1,
+            INTERVAL '1 day'
+        ) d
+        ORDER BY d
+    `, diasRecuperarSnapshots)
+	if err != nil {
+		return 0, err
+	}
+	var dias []time.Time
+	for rows.Next() {
+		var d time.Time
+		if err := rows.Scan(&d); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		dias = append(dias, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, d := range dias {
+		var filas int
+		if err := h.db.QueryRow(`SELECT prueba.materializar_snapshot($1)`, d).Scan(&filas); err != nil {
+			return total, err
+		}
+		total += filas
+	}
+	return total, nil
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseAsOf(t *testing.T) {
+	if asOf, err := parseAsOf(httptest.NewRequest("GET", "/api/ListarInventarios", nil)); asOf != nil || err != nil {
+		t.Errorf("sin as_of = %v, %v", asOf, err)
+	}
+
+	// Una fecha se interpreta como el cierre del día
+	asOf, err := parseAsOf(httptest.NewRequest("GET", "/api/ListarInventarios?as_of=2024-12-31", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC); !asOf.Equal(want) {
+		t.Errorf("as_of = %v, se esperaba %v", asOf, want)
+	}
+
+	for _, v := range []string{"31/12/2024", "2999-01-01"} {
+		if _, err := parseAsOf(httptest.NewRequest("GET", "/api/ListarInventarios?as_of="+v, nil)); err == nil {
+			t.Errorf("as_of=%s debió rechazarse", v)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"go-project/idempotency"
+	"go-project/money"
+	"go-project/utils"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// errStockInsuficiente indica que la tienda no tiene existencias suficientes
+var errStockInsuficiente = errors.New("Stock insuficiente")
+
+// errSinInventario indica que el producto no tiene inventario en la tienda
+var errSinInventario = errors.New("No existe inventario en la tienda origen")
+
+// ajustarExistencia suma delta (positivo o negativo) a la existencia de un
+// producto en una tienda. Las entradas crean el registro de inventario si no
+// existe, con el mismo stock mínimo por defecto que transfer_inventory.
+func ajustarExistencia(tx *sql.Tx, productID, storeID uuid.UUID, delta int) error {
+	if delta >= 0 {
+		_, err := tx.Exec(`
+            INSERT INTO prueba.inventarios (id, productId, storeId, quantity, minStock, activo)
+            VALUES ($1, $2, $3, $4, 10, true)
+            ON CONFLICT (productId, storeId) DO
+            UPDATE SET quantity = prueba.inventarios.quantity + EXCLUDED.quantity
+        `, uuid.New(), productID, storeID, delta)
+		return err
+	}
+
+	var actual int
+	err := tx.QueryRow(`
+        SELECT quantity FROM prueba.inventarios
+        WHERE productId = $1 AND storeId = $2 AND activo = true
+        FOR UPDATE
+    `, productID, storeID).Scan(&actual)
+	if err == sql.ErrNoRows {
+		return errSinInventario
+	}
+	if err != nil {
+		return err
+	}
+	if actual+delta < 0 {
+		return errStockInsuficiente
+	}
+
+	_, err = tx.Exec(`
+        UPDATE prueba.inventarios SET quantity = quantity + $3
+        WHERE productId = $1 AND storeId = $2 AND activo = true
+    `, productID, storeID, delta)
+	return err
+}
+
+// esErrorDeNegocio indica si el error proviene de una validación de existencias
+// y debe responderse como solicitud inválida
+func esErrorDeNegocio(err error) bool {
+	return errors.Is(err, errStockInsuficiente) || errors.Is(err, errSinInventario) ||
+		errors.Is(err, errLoteInvalido) || errors.Is(err, errSerieInvalida) ||
+		errors.Is(err, errUbicacionInvalida) || errors.Is(err, errConteoInvalido) ||
+		errors.Is(err, errProductoEnConteo) || errors.Is(err, errCodigoBarrasInvalido) ||
+		errors.Is(err, errLineaInvalida) || errors.Is(err, errVentaInvalida) ||
+		errors.Is(err, errPrecioInvalido) || errors.Is(err, errRMAInvalida) ||
+		errors.Is(err, errTipoMovimientoInvalido) || errors.Is(err, errReferenciaPrecio)
+}
+
+// responderErrorInterno responde 500 al error de una transacción. Si ésta se
+// revirtió la respuesta se marca para que la clave de idempotencia se libere;
+// si falló la confirmación no se sabe si se aplicó y la respuesta se guarda.
+func responderErrorInterno(w http.ResponseWriter, err error) {
+	if !errors.Is(err, utils.ErrCommit) {
+		idempotency.NotPersisted(w)
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// responderDatosInvalidos responde 400 a un cuerpo que no se pudo decodificar;
+// una moneda inválida se informa con su propio mensaje
+func responderDatosInvalidos(w http.ResponseWriter, err error) {
+	if errors.Is(err, money.ErrMonedaInvalida) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Datos inválidos", http.StatusBadRequest)
+}
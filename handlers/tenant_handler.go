@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-project/tenancy"
+	"go-project/utils"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// Tenant empresa que comparte el despliegue
+// @Description Empresa aislada del resto
+type Tenant struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name" example:"Franquicias del Norte"`
+	Slug      string    `json:"slug" example:"norte"`
+	Activo    bool      `json:"activo"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CrearTenant modelo para dar de alta una empresa
+type CrearTenant struct {
+	Name string `json:"name" binding:"required" example:"Franquicias del Norte"`
+	Slug string `json:"slug" binding:"required" example:"norte"`
+}
+
+// EmitirTokenTenant datos del token de acceso para una empresa
+type EmitirTokenTenant struct {
+	// Usuario o sistema al que se emite
+	Subject string `json:"subject" binding:"required" example:"pos-sucursal-1"`
+	// Vigencia en horas (predeterminado 24)
+	TTLHours int `json:"ttl_hours,omitempty" example:"720"`
+}
+
+// TokenTenant token de acceso emitido
+type TokenTenant struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var slugValido = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+type TenantHandler struct {
+	db     *sql.DB
+	secret []byte
+	router *tenancy.Router
+}
+
+// NewTenantHandler crea el handler de administración de empresas; db debe
+// ser la conexión del sistema (no sujeta a las políticas de aislamiento)
+func NewTenantHandler(db *sql.DB, secret string, router *tenancy.Router) *TenantHandler {
+	return &TenantHandler{db: db, secret: []byte(secret), router: router}
+}
+
+// ListarTenants godoc
+// @Summary      Listar empresas
+// @Description  Obtiene todas las empresas del despliegue (requiere rol admin)
+// @Tags         empresas
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   Tenant
+// @Failure      403  {object}  map[string]string
+// @Router       /admin/ListarTenants [get]
+func (h *TenantHandler) ListarTenants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, name, slug, activo, created_at, updated_at
+        FROM prueba.tenants
+        ORDER BY name
+    `)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var lista []Tenant
+	for rows.Next() {
+		var t Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.Slug, &t.Activo, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		lista = append(lista, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lista)
+}
+
+// CrearTenant godoc
+// @Summary      Crear empresa
+// @Description  Da de alta una empresa con sus listas de precios de menudeo y mayoreo y los tipos de movimiento base (requiere rol admin)
+// @Tags         empresas
+// @Accept       json
+// @Produce      json
+// @Param        tenant body CrearTenant true "Datos de la empresa"
+// @Success      201  {object}  Tenant
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /admin/CrearTenant [post]
+func (h *TenantHandler) CrearTenant(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearTenant
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if c.Name == "" {
+		http.Error(w, "El nombre es requerido", http.StatusBadRequest)
+		return
+	}
+	if !slugValido.MatchString(c.Slug) {
+		http.Error(w, "Slug inválido: use de 2 a 50 minúsculas, números o guiones", http.StatusBadRequest)
+		return
+	}
+
+	var existe bool
+	if err := h.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM prueba.tenants WHERE slug = $1)`, c.Slug).Scan(&existe); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existe {
+		http.Error(w, "Ya existe una empresa con ese slug", http.StatusConflict)
+		return
+	}
+
+	var t Tenant
+	err := utils.WithTransaction(h.db, func(tx *sql.Tx) error {
+		err := tx.QueryRow(`
+            INSERT INTO prueba.tenants (id, name, slug, activo)
+            VALUES ($1, $2, $3, true)
+            RETURNING id, name, slug, activo, created_at, updated_at
+        `, uuid.New(), c.Name, c.Slug).Scan(&t.ID, &t.Name, &t.Slug, &t.Activo, &t.CreatedAt, &t.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+            INSERT INTO catalogos.listasprecios (id, tenantId, name, type, storeId)
+            VALUES (gen_random_uuid(), $1, 'Menudeo', 'RETAIL', NULL),
+                   (gen_random_uuid(), $1, 'Mayoreo', 'WHOLESALE', NULL)
+        `, t.ID)
+		if err != nil {
+			return err
+		}
+		// Tipos de movimiento base con sus motivos
+		_, err = tx.Exec(`SELECT catalogos.sembrar_tipos_movimiento($1)`, t.ID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// ActivarDesactivarTenant godoc
+// @Summary      Activar o desactivar empresa
+// @Description  Cambia el estado de la empresa; una empresa inactiva no puede operar (requiere rol admin)
+// @Tags         empresas
+// @Accept       json
+// @Produce      json
+// @Param        id query string true "ID de la empresa"
+// @Success      200  {object}  Tenant
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/ActivarDesactivarTenant [patch]
+func (h *TenantHandler) ActivarDesactivarTenant(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+	if id == tenancy.DefaultTenantID {
+		http.Error(w, "La empresa predeterminada no puede desactivarse", http.StatusBadRequest)
+		return
+	}
+
+	var t Tenant
+	err = h.db.QueryRow(`
+        UPDATE prueba.tenants SET activo = NOT activo, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING id, name, slug, activo, created_at, updated_at
+    `, id).Scan(&t.ID, &t.Name, &t.Slug, &t.Activo, &t.CreatedAt, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Empresa no encontrada", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !t.Activo {
+		h.router.Evict(t.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// IssueTenantToken godoc
+// @Summary      Emitir token de empresa
+// @Description  Emite un token de acceso para operar dentro de la empresa (requiere rol admin)
+// @Tags         empresas
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la empresa"
+// @Param        token body EmitirTokenTenant true "Datos del token"
+// @Success      201  {object}  TokenTenant
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/tenants/{id}/token [post]
+func (h *TenantHandler) IssueTenantToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(h.secret) == 0 {
+		http.Error(w, "JWT_SECRET no configurado: la autenticación está desactivada", http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	var e EmitirTokenTenant
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+	if e.Subject == "" {
+		http.Error(w, "El sujeto es requerido", http.StatusBadRequest)
+		return
+	}
+	if e.TTLHours == 0 {
+		e.TTLHours = 24
+	}
+	if e.TTLHours < 0 || e.TTLHours > 24*365 {
+		http.Error(w, "Vigencia inválida", http.StatusBadRequest)
+		return
+	}
+
+	var activa bool
+	err = h.db.QueryRow(`SELECT activo FROM prueba.tenants WHERE id = $1`, id).Scan(&activa)
+	if err == sql.ErrNoRows || (err == nil && !activa) {
+		http.Error(w, "Empresa no encontrada o inactiva", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ahora := time.Now()
+	vence := ahora.Add(time.Duration(e.TTLHours) * time.Hour)
+	token, err := tenancy.Sign(h.secret, tenancy.Claims{
+		Subject:   e.Subject,
+		TenantID:  id.String(),
+		Role:      tenancy.RoleUser,
+		IssuedAt:  ahora.Unix(),
+		ExpiresAt: vence.Unix(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(TokenTenant{Token: token, ExpiresAt: vence})
+}
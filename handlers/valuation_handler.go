@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-project/money"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MetodoValuacion método de costeo del inventario
+type MetodoValuacion string
+
+const (
+	ValuacionFIFO     MetodoValuacion = "FIFO"
+	ValuacionPromedio MetodoValuacion = "AVG"
+)
+
+// ValuacionLinea valor de las existencias de un grupo (tienda, categoría, producto o total)
+// en una moneda; un grupo con capas en varias monedas tiene una línea por moneda
+type ValuacionLinea struct {
+	Key              string      `json:"key" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Name             string      `json:"name" example:"Tienda Central"`
+	Quantity         int         `json:"quantity" example:"120"`
+	UncostedQuantity int         `json:"uncosted_quantity" example:"0"`
+	Value            money.Money `json:"value"`
+}
+
+// ReporteValuacion reporte de valuación del inventario
+type ReporteValuacion struct {
+	Method  MetodoValuacion  `json:"method" example:"FIFO"`
+	GroupBy string           `json:"group_by" example:"store"`
+	Date    time.Time        `json:"date"`
+	Lines   []ValuacionLinea `json:"lines"`
+}
+
+// CostoVentaLinea costo de las salidas de un producto en una tienda
+type CostoVentaLinea struct {
+	ProductID   uuid.UUID   `json:"product_id"`
+	ProductName string      `json:"product_name"`
+	StoreID     uuid.UUID   `json:"store_id"`
+	StoreName   string      `json:"store_name"`
+	Quantity    int         `json:"quantity"`
+	Cost        money.Money `json:"cost"`
+}
+
+// ReporteCostoVenta costo de lo vendido (salidas de tipos marcados como venta) en un periodo
+type ReporteCostoVenta struct {
+	From  time.Time         `json:"from"`
+	To    time.Time         `json:"to"`
+	Lines []CostoVentaLinea `json:"lines"`
+	Total []money.Money     `json:"total"`
+}
+
+type ValuationHandler struct {
+	db *sql.DB
+}
+
+func NewValuationHandler(db *sql.DB) *ValuationHandler {
+	return &ValuationHandler{db: db}
+}
+
+// posicionInventario existencias de un producto en una tienda con sus capas de costo
+type posicionInventario struct {
+	ProductID   uuid.UUID
+	StoreID     uuid.UUID
+	ProductName string
+	Category    string
+	StoreName   string
+	Quantity    int
+	Capas       []capaCosto
+}
+
+// GetInventoryValuation godoc
+// @Summary      Valuación de inventario
+// @Description  Calcula el valor de las existencias por tienda, categoría, producto o total usando FIFO o costo promedio ponderado
+// @Tags         reportes
+// @Accept       json
+// @Produce      json
+// @Param        method query string false "Método de costeo (FIFO, AVG)" default(FIFO)
+// @Param        group_by query string false "Agrupación (store, category, product, total)" default(store)
+// @Param        store_id query string false "Filtrar por tienda"
+// @Success      200  {object}  ReporteValuacion
+// @Failure      400  {object}  map[string]string
+// @Router       /reports/valuation [get]
+func (h *ValuationHandler) GetInventoryValuation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	metodo := MetodoValuacion(q.Get("method"))
+	if metodo == "" {
+		metodo = ValuacionFIFO
+	}
+	if metodo != ValuacionFIFO && metodo != ValuacionPromedio {
+		http.Error(w, "Método de valuación inválido", http.StatusBadRequest)
+		return
+	}
+
+	agrupacion := q.Get("group_by")
+	if agrupacion == "" {
+		agrupacion = "store"
+	}
+	if agrupacion != "store" && agrupacion != "category" && agrupacion != "product" && agrupacion != "total" {
+		http.Error(w, "Agrupación inválida", http.StatusBadRequest)
+		return
+	}
+
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	posiciones, err := h.cargarPosiciones(storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	reporte := ReporteValuacion{
+		Method:  metodo,
+		GroupBy: agrupacion,
+		Date:    time.Now(),
+		Lines:   agruparValuacion(metodo, agrupacion, posiciones),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reporte)
+}
+
+// GetCostOfGoods godoc
+// @Summary      Costo de lo vendido
+// @Description  Obtiene el costo de las salidas de un periodo (tipos con efecto OUT marcados como venta) según las capas FIFO consumidas
+// @Tags         reportes
+// @Accept       json
+// @Produce      json
+// @Param        from query string false "Inicio del periodo (por defecto hace 30 días)"
+// @Param        to query string false "Fin del periodo, exclusivo (por defecto ahora)"
+// @Param        store_id query string false "Filtrar por tienda"
+// @Success      200  {object}  ReporteCostoVenta
+// @Failure      400  {object}  map[string]string
+// @Router       /reports/cogs [get]
+func (h *ValuationHandler) GetCostOfGoods(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	hasta := time.Now()
+	desde := hasta.AddDate(0, 0, -30)
+	var err error
+	if v := q.Get("from"); v != "" {
+		if desde, err = parseFecha(v); err != nil {
+			http.Error(w, "Fecha de inicio inválida", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if hasta, err = parseFecha(v); err != nil {
+			http.Error(w, "Fecha de fin inválida", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var storeID *uuid.UUID
+	if v := q.Get("store_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de tienda inválido", http.StatusBadRequest)
+			return
+		}
+		storeID = &id
+	}
+
+	rows, err := h.db.Query(`
+        SELECT m.productId, p.name, m.sourceStoreId, t.name,
+               SUM(c.quantity), SUM(c.quantity * c.unit_cost), c.currency
+        FROM prueba.consumoscosto c
+        JOIN prueba.movimientos m ON c.movementId = m.id
+        JOIN catalogos.productos p ON m.productId = p.id
+        JOIN catalogos.tiendas t ON m.sourceStoreId = t.id
+        JOIN catalogos.tiposmovimiento tm ON tm.tenantId = m.tenantId AND tm.code = m.type
+        WHERE tm.effect = 'OUT' AND tm.isSale AND m.activo = true
+          AND m.timestamp >= $1 AND m.timestamp < $2
+          AND ($3::uuid IS NULL OR m.sourceStoreId = $3)
+        GROUP BY m.productId, p.name, m.sourceStoreId, t.name, c.currency
+        ORDER BY t.name, p.name`, desde, hasta, storeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	reporte := ReporteCostoVenta{From: desde, To: hasta}
+	totales := map[string]money.Money{}
+	for rows.Next() {
+		var l CostoVentaLinea
+		err := rows.Scan(&l.ProductID, &l.ProductName, &l.StoreID, &l.StoreName,
+			&l.Quantity, &l.Cost.Amount, &l.Cost.Currency)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		totales[l.Cost.Currency] = totales[l.Cost.Currency].Add(l.Cost)
+		reporte.Lines = append(reporte.Lines, l)
+	}
+	reporte.Total = ordenarTotales(totales)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reporte)
+}
+
+// cargarPosiciones obtiene las existencias activas junto con sus capas de costo
+func (h *ValuationHandler) cargarPosiciones(storeID *uuid.UUID) ([]posicionInventario, error) {
+	rows, err := h.db.Query(`
+        SELECT i.productId, i.storeId, p.name, COALESCE(p.category, ''), t.name, i.quantity
+        FROM prueba.inventarios i
+        JOIN catalogos.productos p ON i.productId = p.id
+        JOIN catalogos.tiendas t ON i.storeId = t.id
+        WHERE i.activo = true AND ($1::uuid IS NULL OR i.storeId = $1)`, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type clave struct{ producto, tienda uuid.UUID }
+	indice := map[clave]int{}
+	var posiciones []posicionInventario
+	for rows.Next() {
+		var p posicionInventario
+		if err := rows.Scan(&p.ProductID, &p.StoreID, &p.ProductName,
+			&p.Category, &p.StoreName, &p.Quantity); err != nil {
+			return nil, err
+		}
+		indice[clave{p.ProductID, p.StoreID}] = len(posiciones)
+		posiciones = append(posiciones, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	capas, err := h.db.Query(`
+        SELECT productId, storeId, id, quantity, remaining, unit_cost, currency, received_at
+        FROM prueba.capascosto
+        WHERE activo = true AND ($1::uuid IS NULL OR storeId = $1)
+        ORDER BY received_at, created_at`, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer capas.Close()
+
+	for capas.Next() {
+		var k clave
+		var c capaCosto
+		if err := capas.Scan(&k.producto, &k.tienda, &c.ID, &c.Quantity, &c.Remaining,
+			&c.UnitCost.Amount, &c.UnitCost.Currency, &c.ReceivedAt); err != nil {
+			return nil, err
+		}
+		if i, ok := indice[k]; ok {
+			posiciones[i].Capas = append(posiciones[i].Capas, c)
+		}
+	}
+	return posiciones, capas.Err()
+}
+
+// valorMoneda unidades de una existencia costeadas en una moneda y su valor
+type valorMoneda struct {
+	Quantity int
+	Value    money.Money
+}
+
+// valuarPosicion calcula el valor de una existencia a partir de sus capas de
+// costo ordenadas de la más antigua a la más reciente. Las capas en monedas
+// distintas se valúan por separado: devuelve un valor por moneda (al menos
+// uno) y las unidades no costeadas.
+//
+// FIFO: las unidades en existencia son las de las capas más recientes aún no
+// consumidas. AVG: las unidades se reparten entre monedas en proporción a lo
+// que queda de cada capa y se valúan al costo promedio ponderado por ese
+// remanente, de modo que las capas ya consumidas no cuentan. Las unidades sin
+// capa que las respalde (existencias previas a la captura de costos) se
+// reportan como no costeadas.
+func valuarPosicion(metodo MetodoValuacion, cantidad int, capas []capaCosto) ([]valorMoneda, int) {
+	var valores []valorMoneda
+	pendiente := cantidad
+
+	switch metodo {
+	case ValuacionPromedio:
+		// Remanente por moneda: unidades y su costo total
+		var remanentes []valorMoneda
+		var total int
+		for _, c := range capas {
+			if c.Remaining > 0 {
+				remanentes = sumarMoneda(remanentes, c.Remaining, c.UnitCost.Mul(int64(c.Remaining)))
+				total += c.Remaining
+			}
+		}
+		costeadas := cantidad
+		if costeadas > total {
+			costeadas = total
+		}
+		pendiente -= costeadas
+		for i, e := range remanentes {
+			unidades := costeadas * e.Quantity / total
+			if i == len(remanentes)-1 {
+				unidades = costeadas
+			}
+			costeadas -= unidades
+			valores = sumarMoneda(valores, unidades, e.Value.Mul(int64(unidades)).DivRound(int64(e.Quantity)))
+		}
+	default:
+		for i := len(capas) - 1; i >= 0 && pendiente > 0; i-- {
+			tomar := capas[i].Remaining
+			if tomar > pendiente {
+				tomar = pendiente
+			}
+			if tomar > 0 {
+				valores = sumarMoneda(valores, tomar, capas[i].UnitCost.Mul(int64(tomar)))
+			}
+			pendiente -= tomar
+		}
+	}
+
+	if len(valores) == 0 {
+		valores = []valorMoneda{{Value: money.New(0, "")}}
+	}
+	return valores, pendiente
+}
+
+// sumarMoneda acumula unidades e importe en el valor de su moneda
+func sumarMoneda(valores []valorMoneda, unidades int, importe money.Money) []valorMoneda {
+	for i := range valores {
+		if valores[i].Value.Currency == importe.Currency {
+			valores[i].Quantity += unidades
+			valores[i].Value.Amount += importe.Amount
+			return valores
+		}
+	}
+	return append(valores, valorMoneda{Quantity: unidades, Value: importe})
+}
+
+// agruparValuacion valúa cada posición y acumula por la agrupación solicitada
+// y moneda; las unidades no costeadas se suman a la línea de la primera moneda
+// de la posición
+func agruparValuacion(metodo MetodoValuacion, agrupacion string, posiciones []posicionInventario) []ValuacionLinea {
+	type clave struct{ grupo, moneda string }
+	grupos := map[clave]*ValuacionLinea{}
+	var orden []clave
+
+	for _, p := range posiciones {
+		valores, sinCosto := valuarPosicion(metodo, p.Quantity, p.Capas)
+
+		var key, name string
+		switch agrupacion {
+		case "category":
+			key, name = p.Category, p.Category
+		case "product":
+			key, name = p.ProductID.String(), p.ProductName
+		case "total":
+			key, name = "total", "Total"
+		default:
+			key, name = p.StoreID.String(), p.StoreName
+		}
+
+		for i, v := range valores {
+			k := clave{key, v.Value.Currency}
+			linea, ok := grupos[k]
+			if !ok {
+				linea = &ValuacionLinea{Key: key, Name: name, Value: money.New(0, v.Value.Currency)}
+				grupos[k] = linea
+				orden = append(orden, k)
+			}
+			linea.Quantity += v.Quantity
+			linea.Value.Amount += v.Value.Amount
+			if i == 0 {
+				linea.Quantity += sinCosto
+				linea.UncostedQuantity += sinCosto
+			}
+		}
+	}
+
+	lineas := make([]ValuacionLinea, 0, len(orden))
+	for _, k := range orden {
+		lineas = append(lineas, *grupos[k])
+	}
+	sort.SliceStable(lineas, func(i, j int) bool { return lineas[i].Name < lineas[j].Name })
+	return lineas
+}
+
+// ordenarTotales devuelve los totales por moneda en orden alfabético de moneda
+func ordenarTotales(totales map[string]money.Money) []money.Money {
+	lista := make([]money.Money, 0, len(totales))
+	for _, t := range totales {
+		lista = append(lista, t)
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].Currency < lista[j].Currency })
+	return lista
+}
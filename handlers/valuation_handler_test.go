@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"go-project/money"
+	"testing"
+)
+
+func TestValuarPosicion(t *testing.T) {
+	capas := []capaCosto{
+		{Quantity: 10, Remaining: 0, UnitCost: money.MustParse("100.00", "MXN")},
+		{Quantity: 10, Remaining: 4, UnitCost: money.MustParse("110.00", "MXN")},
+		{Quantity: 5, Remaining: 5, UnitCost: money.MustParse("120.50", "MXN")},
+	}
+
+	valores, sinCosto := valuarPosicion(ValuacionFIFO, 9, capas)
+	valor := valores[0].Value
+	if len(valores) != 1 || valor.String() != "1042.50 MXN" || sinCosto != 0 {
+		t.Errorf("FIFO = %s (%d sin costo), se esperaba 1042.50 MXN", valor, sinCosto)
+	}
+
+	valores, sinCosto = valuarPosicion(ValuacionFIFO, 12, capas)
+	valor = valores[0].Value
+	if len(valores) != 1 || valor.String() != "1042.50 MXN" || sinCosto != 3 {
+		t.Errorf("FIFO con faltante = %s (%d sin costo), se esperaba 1042.50 MXN y 3", valor, sinCosto)
+	}
+
+	// Promedio ponderado por lo que queda de cada capa: la primera ya se
+	// consumió y la segunda sólo conserva 4 de sus 10 unidades, así que el
+	// costo es (4 × 110 + 5 × 120.50) / 9 y no el promedio histórico 108.10
+	valores, sinCosto = valuarPosicion(ValuacionPromedio, 9, capas)
+	valor = valores[0].Value
+	if len(valores) != 1 || valor.String() != "1042.50 MXN" || sinCosto != 0 {
+		t.Errorf("AVG = %s (%d sin costo), se esperaba 1042.50 MXN", valor, sinCosto)
+	}
+	valores, sinCosto = valuarPosicion(ValuacionPromedio, 5, capas)
+	valor = valores[0].Value
+	if len(valores) != 1 || valor.String() != "579.17 MXN" || sinCosto != 0 {
+		t.Errorf("AVG parcial = %s (%d sin costo), se esperaba 579.17 MXN", valor, sinCosto)
+	}
+	valores, sinCosto = valuarPosicion(ValuacionPromedio, 12, capas)
+	valor = valores[0].Value
+	if len(valores) != 1 || valor.String() != "1042.50 MXN" || sinCosto != 3 {
+		t.Errorf("AVG con faltante = %s (%d sin costo), se esperaba 1042.50 MXN y 3", valor, sinCosto)
+	}
+
+	valores, sinCosto = valuarPosicion(ValuacionPromedio, 7, nil)
+	valor = valores[0].Value
+	if len(valores) != 1 || valor.String() != "0.00 MXN" || sinCosto != 7 {
+		t.Errorf("AVG sin capas = %s (%d sin costo), se esperaba 0.00 MXN y 7", valor, sinCosto)
+	}
+
+	// Capas en monedas distintas se valúan por separado
+	mixtas := []capaCosto{
+		{Quantity: 10, Remaining: 6, UnitCost: money.MustParse("100.00", "MXN")},
+		{Quantity: 10, Remaining: 4, UnitCost: money.MustParse("5.00", "USD")},
+	}
+	valores, sinCosto = valuarPosicion(ValuacionFIFO, 8, mixtas)
+	if len(valores) != 2 || valores[0].Value.String() != "20.00 USD" || valores[0].Quantity != 4 ||
+		valores[1].Value.String() != "400.00 MXN" || valores[1].Quantity != 4 || sinCosto != 0 {
+		t.Errorf("FIFO con monedas distintas = %+v (%d sin costo)", valores, sinCosto)
+	}
+	valores, _ = valuarPosicion(ValuacionPromedio, 10, mixtas)
+	if len(valores) != 2 || valores[0].Value.String() != "600.00 MXN" || valores[1].Value.String() != "20.00 USD" {
+		t.Errorf("AVG con monedas distintas = %+v", valores)
+	}
+}
+
+func TestAgruparValuacionMonedas(t *testing.T) {
+	posiciones := []posicionInventario{
+		{StoreName: "Centro", Quantity: 12, Capas: []capaCosto{
+			{Quantity: 5, Remaining: 5, UnitCost: money.MustParse("10.00", "MXN")},
+			{Quantity: 5, Remaining: 5, UnitCost: money.MustParse("1.00", "USD")},
+		}},
+	}
+	lineas := agruparValuacion(ValuacionFIFO, "total", posiciones)
+	if len(lineas) != 2 {
+		t.Fatalf("lineas = %+v", lineas)
+	}
+	if lineas[0].Value.String() != "5.00 USD" || lineas[0].Quantity != 7 || lineas[0].UncostedQuantity != 2 ||
+		lineas[1].Value.String() != "50.00 MXN" || lineas[1].Quantity != 5 {
+		t.Errorf("lineas = %+v", lineas)
+	}
+}
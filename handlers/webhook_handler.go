@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"go-project/webhooks"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// Webhook suscripción de un sistema externo a los eventos de inventario
+// @Description Suscripción de webhook
+type Webhook struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url" example:"https://erp.ejemplo.com/webhooks/inventario"`
+	EventTypes  []string  `json:"event_types" example:"stock.low,transfer.completed"`
+	Description string    `json:"description"`
+	// Secreto de firma; sólo se devuelve al crear la suscripción
+	Secret    string    `json:"secret,omitempty"`
+	Activo    bool      `json:"activo"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CrearWebhook modelo para crear una suscripción
+type CrearWebhook struct {
+	URL string `json:"url" binding:"required" example:"https://erp.ejemplo.com/webhooks/inventario"`
+	// movement.created, stock.low, transfer.completed, product.updated o * para todos
+	EventTypes  []string `json:"event_types" binding:"required" example:"stock.low,transfer.completed"`
+	Description string   `json:"description,omitempty"`
+	// Secreto de firma; si se omite se genera uno
+	Secret string `json:"secret,omitempty"`
+}
+
+// EntregaWebhook intento de entrega de un evento a una suscripción
+type EntregaWebhook struct {
+	ID             uuid.UUID       `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type" example:"stock.low"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" example:"PENDING"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty"`
+	LastError      *string         `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type WebhookHandler struct {
+	db *sql.DB
+}
+
+func NewWebhookHandler(db *sql.DB) *WebhookHandler {
+	return &WebhookHandler{db: db}
+}
+
+// ListarWebhooks godoc
+// @Summary      Listar webhooks
+// @Description  Obtiene las suscripciones de webhooks activas (sin su secreto)
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Success      200  {array}   Webhook
+// @Router       /ListarWebhooks [get]
+func (h *WebhookHandler) ListarWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, url, eventTypes, COALESCE(description, ''), activo, created_at, updated_at
+        FROM prueba.webhooks
+        WHERE activo = true
+        ORDER BY created_at
+    `)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var suscripciones []Webhook
+	for rows.Next() {
+		var s Webhook
+		err := rows.Scan(&s.ID, &s.URL, pq.Array(&s.EventTypes), &s.Description,
+			&s.Activo, &s.CreatedAt, &s.UpdatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		suscripciones = append(suscripciones, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suscripciones)
+}
+
+// CrearWebhook godoc
+// @Summary      Crear webhook
+// @Description  Suscribe una URL a eventos de inventario. Cada entrega se firma con HMAC-SHA256
+// @Description  en el encabezado X-Webhook-Signature (t=<unix>,v1=<hex> sobre "t.cuerpo").
+// @Description  El secreto sólo se devuelve en esta respuesta.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        webhook body CrearWebhook true "Datos de la suscripción"
+// @Success      201  {object}  Webhook
+// @Failure      400  {object}  map[string]string
+// @Router       /CrearWebhook [post]
+func (h *WebhookHandler) CrearWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c CrearWebhook
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Datos inválidos", http.StatusBadRequest)
+		return
+	}
+
+	destino, err := url.Parse(c.URL)
+	if err != nil || (destino.Scheme != "http" && destino.Scheme != "https") || destino.Host == "" {
+		http.Error(w, "La URL debe ser http o https", http.StatusBadRequest)
+		return
+	}
+	if len(c.EventTypes) == 0 {
+		http.Error(w, "Se requiere al menos un tipo de evento", http.StatusBadRequest)
+		return
+	}
+	for _, tipo := range c.EventTypes {
+		if !tipoEventoValido(tipo) {
+			http.Error(w, "Tipo de evento inválido: "+tipo, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if c.Secret == "" {
+		secreto := make([]byte, 24)
+		if _, err := rand.Read(secreto); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.Secret = "whsec_" + hex.EncodeToString(secreto)
+	}
+
+	var s Webhook
+	err = h.db.QueryRow(`
+        INSERT INTO prueba.webhooks (id, url, secret, eventTypes, description, activo)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), true)
+        RETURNING id, url, secret, eventTypes, COALESCE(description, ''), activo, created_at, updated_at
+    `, uuid.New(), c.URL, c.Secret, pq.Array(c.EventTypes), c.Description).Scan(
+		&s.ID, &s.URL, &s.Secret, pq.Array(&s.EventTypes), &s.Description,
+		&s.Activo, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+}
+
+func tipoEventoValido(tipo string) bool {
+	if tipo == webhooks.EventAll {
+		return true
+	}
+	for _, t := range webhooks.EventTypes {
+		if t == tipo {
+			return true
+		}
+	}
+	return false
+}
+
+// EliminarWebhook godoc
+// @Summary      Eliminar webhook
+// @Description  Desactiva una suscripción; sus entregas pendientes ya no se envían
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id query string true "ID de la suscripción"
+// @Success      204  "No Content"
+// @Failure      404  {object}  map[string]string
+// @Router       /EliminarWebhook [delete]
+func (h *WebhookHandler) EliminarWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+        UPDATE prueba.webhooks SET activo = false, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND activo = true
+    `, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		http.Error(w, "Webhook no encontrado", http.StatusNotFound)
+		return
+	}
+
+	// Descartar lo pendiente: no se entrega a suscripciones dadas de baja
+	if _, err := h.db.Exec(`
+        UPDATE prueba.webhooksentregas SET status = 'DEAD', lastError = 'Suscripción eliminada'
+        WHERE webhookId = $1 AND status = 'PENDING'
+    `, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListarEntregasWebhook godoc
+// @Summary      Listar entregas de webhooks
+// @Description  Obtiene las entregas más recientes; con status=DEAD lista la cola de muertos
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        webhook_id query string false "ID de la suscripción"
+// @Param        status query string false "PENDING, DELIVERED o DEAD"
+// @Param        limit query int false "Máximo de entregas (predeterminado 100)"
+// @Success      200  {array}   EntregaWebhook
+// @Failure      400  {object}  map[string]string
+// @Router       /ListarEntregasWebhook [get]
+func (h *WebhookHandler) ListarEntregasWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var webhookID *uuid.UUID
+	if v := r.URL.Query().Get("webhook_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "ID de webhook inválido", http.StatusBadRequest)
+			return
+		}
+		webhookID = &id
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "PENDING" && status != "DELIVERED" && status != "DEAD" {
+		http.Error(w, "Estado inválido", http.StatusBadRequest)
+		return
+	}
+	limite := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Límite inválido", http.StatusBadRequest)
+			return
+		}
+		limite = n
+	}
+
+	rows, err := h.db.Query(`
+        SELECT id, webhookId, eventId, eventType, payload, status, attempts, nextAttemptAt,
+               lastStatusCode, lastError, deliveredAt, created_at
+        FROM prueba.webhooksentregas
+        WHERE ($1::uuid IS NULL OR webhookId = $1)
+          AND ($2 = '' OR status = $2)
+        ORDER BY created_at DESC
+        LIMIT $3
+    `, webhookID, status, limite)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var entregas []EntregaWebhook
+	for rows.Next() {
+		var e EntregaWebhook
+		var payload []byte
+		err := rows.Scan(&e.ID, &e.WebhookID, &e.EventID, &e.EventType, &payload, &e.Status,
+			&e.Attempts, &e.NextAttemptAt, &e.LastStatusCode, &e.LastError, &e.DeliveredAt, &e.CreatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		e.Payload = payload
+		entregas = append(entregas, e)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entregas)
+}
+
+// RetryWebhookDelivery godoc
+// @Summary      Reintentar entrega de webhook
+// @Description  Devuelve a la cola una entrega de la cola de muertos, con los intentos en cero
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "ID de la entrega"
+// @Success      200  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /webhooks/deliveries/{id}/retry [post]
+func (h *WebhookHandler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec(`
+        UPDATE prueba.webhooksentregas e
+        SET status = 'PENDING', attempts = 0, nextAttemptAt = CURRENT_TIMESTAMP
+        FROM prueba.webhooks w
+        WHERE e.id = $1 AND e.status = 'DEAD' AND w.id = e.webhookId AND w.activo = true
+    `, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		http.Error(w, "Entrega no encontrada en la cola de muertos", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Entrega programada para reintento",
+	})
+}
@@ -0,0 +1,198 @@
+// Package idempotency permite repetir sin efectos duplicados las peticiones
+// POST, PUT y PATCH que traen el encabezado Idempotency-Key: la primera
+// ejecución guarda su respuesta y los reintentos con la misma clave y la
+// misma petición la reciben de nuevo sin volver a ejecutar el handler.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Header encabezado con la clave elegida por el cliente
+const Header = "Idempotency-Key"
+
+// ReplayedHeader marca las respuestas repetidas desde el registro
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Longitud máxima de una clave
+const maxLongitudClave = 255
+
+// DefaultTTL tiempo que se conserva cada clave
+const DefaultTTL = 24 * time.Hour
+
+// ErrInProgress otra petición con la misma clave aún no termina
+var ErrInProgress = errors.New("petición con la misma clave en proceso")
+
+// ErrAbandoned la petición con la misma clave no terminó y se desconoce si
+// sus cambios se aplicaron. Es intencional que la clave quede bloqueada hasta
+// vencer su TTL: volver a ejecutarla podría duplicar el movimiento, así que el
+// cliente debe verificar el resultado y, si hace falta, reintentar con otra
+// clave.
+var ErrAbandoned = errors.New("petición con la misma clave interrumpida; verifique su resultado antes de reintentar con otra clave")
+
+// Record respuesta guardada de una clave
+type Record struct {
+	RequestHash string
+	// Completed es falso mientras la primera petición se ejecuta
+	Completed bool
+	// Abandoned marca una petición en proceso que dejó de actualizarse
+	Abandoned bool
+	// ExpiresAt momento en que la clave vence y puede volver a usarse
+	ExpiresAt   time.Time
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Store registra las claves de una empresa
+type Store interface {
+	// Begin reserva la clave para hash; si ya existe devuelve su registro y
+	// false. Sólo se liberan las claves vencidas, nunca las que siguen en
+	// proceso.
+	Begin(key, hash string, ttl time.Duration) (Record, bool, error)
+	// Complete guarda la respuesta de la clave reservada
+	Complete(key string, rec Record) error
+	// Release libera la clave para que un reintento vuelva a ejecutarse
+	Release(key string) error
+}
+
+// Fingerprint resume método, ruta, consulta y cuerpo de la petición; una
+// clave reutilizada con otra huella se rechaza
+func Fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+"\n"+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NotPersisted indica que la respuesta de error no confirmó ningún cambio (la
+// transacción del handler se revirtió), de modo que la clave se libera y un
+// reintento vuelve a ejecutarse. Sin la marca cualquier respuesta, incluso un
+// 5xx, se guarda y se repite a los reintentos. No hace nada fuera del
+// middleware.
+func NotPersisted(w http.ResponseWriter) {
+	for {
+		switch v := w.(type) {
+		case *grabadora:
+			v.sinCambios = true
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// Middleware aplica las claves de idempotencia con store; ttl es el tiempo
+// que se conserva cada clave. Sólo se libera la clave de las respuestas que
+// el handler marcó con NotPersisted; el resto se guarda tal cual.
+func Middleware(store Store, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clave := r.Header.Get(Header)
+			if clave == "" || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(clave) > maxLongitudClave {
+				http.Error(w, "Idempotency-Key demasiado larga", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "No se pudo leer la petición", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			huella := Fingerprint(r, body)
+
+			previo, reservada, err := store.Begin(clave, huella, ttl)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !reservada {
+				responderPrevio(w, previo, huella)
+				return
+			}
+
+			rec := &grabadora{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				// Tras un pánico no se sabe qué se confirmó: el reintento
+				// recibe el error en lugar de ejecutarse de nuevo
+				if p := recover(); p != nil {
+					store.Complete(clave, Record{RequestHash: huella, Completed: true,
+						Status: http.StatusInternalServerError, ContentType: "text/plain; charset=utf-8",
+						Body: []byte("Error interno del servidor\n")})
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+
+			if rec.sinCambios {
+				err = store.Release(clave)
+			} else {
+				err = store.Complete(clave, Record{RequestHash: huella, Completed: true, Status: rec.status,
+					ContentType: rec.Header().Get("Content-Type"), Body: rec.body.Bytes()})
+			}
+			if err != nil {
+				log.Printf("Error al registrar la clave de idempotencia %q: %v", clave, err)
+			}
+		})
+	}
+}
+
+func responderPrevio(w http.ResponseWriter, previo Record, huella string) {
+	switch {
+	case previo.RequestHash != huella:
+		http.Error(w, "Idempotency-Key ya usada con otra petición", http.StatusUnprocessableEntity)
+	case !previo.Completed && previo.Abandoned:
+		mensaje := ErrAbandoned.Error()
+		if !previo.ExpiresAt.IsZero() {
+			mensaje += " (la clave vence el " + previo.ExpiresAt.UTC().Format(time.RFC3339) + ")"
+		}
+		http.Error(w, mensaje, http.StatusConflict)
+	case !previo.Completed:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, ErrInProgress.Error(), http.StatusConflict)
+	default:
+		if previo.ContentType != "" {
+			w.Header().Set("Content-Type", previo.ContentType)
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(previo.Status)
+		w.Write(previo.Body)
+	}
+}
+
+// grabadora copia el estado y el cuerpo de la respuesta mientras la escribe
+type grabadora struct {
+	http.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	escrito bool
+	// sinCambios lo marca el handler con NotPersisted
+	sinCambios bool
+}
+
+func (g *grabadora) WriteHeader(status int) {
+	if !g.escrito {
+		g.status, g.escrito = status, true
+	}
+	g.ResponseWriter.WriteHeader(status)
+}
+
+func (g *grabadora) Write(b []byte) (int, error) {
+	g.escrito = true
+	g.body.Write(b)
+	return g.ResponseWriter.Write(b)
+}
@@ -0,0 +1,152 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoria registro de claves para las pruebas; como SQLStore sólo libera las
+// claves vencidas
+type memoria struct {
+	mu       sync.Mutex
+	registro map[string]Record
+}
+
+func (m *memoria) Begin(key, hash string, ttl time.Duration) (Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rec, ok := m.registro[key]; ok && (rec.ExpiresAt.IsZero() || rec.ExpiresAt.After(time.Now())) {
+		return rec, false, nil
+	}
+	m.registro[key] = Record{RequestHash: hash, ExpiresAt: time.Now().Add(ttl)}
+	return Record{}, true, nil
+}
+
+func (m *memoria) Complete(key string, rec Record) error {
+	m.mu.Lock()
+	m.registro[key] = rec
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoria) Release(key string) error {
+	m.mu.Lock()
+	delete(m.registro, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	store := &memoria{registro: make(map[string]Record)}
+	ejecuciones := 0
+	fallar, revertida := false, false
+	h := Middleware(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ejecuciones++
+		if fallar {
+			if revertida {
+				NotPersisted(w)
+			}
+			http.Error(w, "falla", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"n":1}`))
+	}))
+
+	enviar := func(clave, cuerpo string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/inventory/transfer", strings.NewReader(cuerpo))
+		if clave != "" {
+			req.Header.Set(Header, clave)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	primera := enviar("abc", `{"quantity":5}`)
+	reintento := enviar("abc", `{"quantity":5}`)
+	if ejecuciones != 1 {
+		t.Fatalf("el handler se ejecutó %d veces", ejecuciones)
+	}
+	if reintento.Code != http.StatusCreated || reintento.Body.String() != primera.Body.String() ||
+		reintento.Header().Get(ReplayedHeader) != "true" || reintento.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("reintento = %d %q %v", reintento.Code, reintento.Body.String(), reintento.Header())
+	}
+
+	// La misma clave con otro cuerpo se rechaza
+	if w := enviar("abc", `{"quantity":6}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reutilización = %d", w.Code)
+	}
+
+	// Sin clave cada petición se ejecuta
+	enviar("", `{}`)
+	enviar("", `{}`)
+	if ejecuciones != 3 {
+		t.Errorf("sin clave se ejecutó %d veces", ejecuciones)
+	}
+
+	// Un error sin marcar se guarda: el handler pudo confirmar cambios
+	fallar = true
+	enviar("xyz", `{}`)
+	fallar = false
+	if w := enviar("xyz", `{}`); w.Code != http.StatusInternalServerError ||
+		w.Header().Get(ReplayedHeader) != "true" || ejecuciones != 4 {
+		t.Errorf("reintento tras 500 = %d, ejecuciones %d", w.Code, ejecuciones)
+	}
+
+	// Un error marcado como revertido libera la clave para reintentar
+	fallar, revertida = true, true
+	enviar("rev", `{}`)
+	fallar = false
+	if w := enviar("rev", `{}`); w.Code != http.StatusCreated || ejecuciones != 6 {
+		t.Errorf("reintento tras reversión = %d, ejecuciones %d", w.Code, ejecuciones)
+	}
+
+	// Una petición en proceso responde 409
+	store.registro["lenta"] = Record{RequestHash: Fingerprint(
+		httptest.NewRequest(http.MethodPost, "/api/inventory/transfer", nil), []byte(`{}`))}
+	if w := enviar("lenta", `{}`); w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("en proceso = %d", w.Code)
+	}
+
+	// Una petición interrumpida queda bloqueada hasta vencer la clave: no se
+	// vuelve a ejecutar ni invita a reintentar, e informa cuándo vence
+	vence := time.Now().Add(time.Hour)
+	rec := store.registro["lenta"]
+	rec.Abandoned, rec.ExpiresAt = true, vence
+	store.registro["lenta"] = rec
+	for i := 0; i < 2; i++ {
+		w := enviar("lenta", `{}`)
+		if w.Code != http.StatusConflict || w.Header().Get("Retry-After") != "" || ejecuciones != 6 ||
+			!strings.Contains(w.Body.String(), vence.UTC().Format(time.RFC3339)) {
+			t.Errorf("interrumpida = %d %q, ejecuciones %d", w.Code, w.Body.String(), ejecuciones)
+		}
+	}
+
+	// Vencida la clave, la petición vuelve a ejecutarse
+	rec.ExpiresAt = time.Now().Add(-time.Second)
+	store.registro["lenta"] = rec
+	if w := enviar("lenta", `{}`); w.Code != http.StatusCreated || ejecuciones != 7 {
+		t.Errorf("tras vencer = %d, ejecuciones %d", w.Code, ejecuciones)
+	}
+}
+
+// envoltura escritor de otro middleware montado dentro de las claves
+type envoltura struct{ http.ResponseWriter }
+
+func (e envoltura) Unwrap() http.ResponseWriter { return e.ResponseWriter }
+
+func TestNotPersisted(t *testing.T) {
+	g := &grabadora{ResponseWriter: httptest.NewRecorder()}
+	NotPersisted(envoltura{g})
+	if !g.sinCambios {
+		t.Error("la marca debe llegar a través de los escritores que implementan Unwrap")
+	}
+	// Fuera del middleware no hace nada
+	NotPersisted(httptest.NewRecorder())
+}
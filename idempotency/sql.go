@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Una petición en proceso más antigua se considera abandonada (por ejemplo
+// si el servidor se detuvo). Su clave no se libera y queda bloqueada hasta
+// vencer: la transacción del handler pudo confirmarse antes de Complete y
+// repetirla movería el inventario dos veces.
+const abandonada = 5 * time.Minute
+
+// SQLStore guarda las claves en prueba.clavesidempotencia. Con el pool de
+// una empresa las políticas de aislamiento separan las claves de cada una.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore crea el registro de claves sobre db
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Begin(key, hash string, ttl time.Duration) (Record, bool, error) {
+	var rec Record
+	_, err := s.db.Exec(`
+        DELETE FROM prueba.clavesidempotencia
+        WHERE idempotencyKey = $1 AND expires_at <= CURRENT_TIMESTAMP
+    `, key)
+	if err != nil {
+		return rec, false, err
+	}
+
+	result, err := s.db.Exec(`
+        INSERT INTO prueba.clavesidempotencia (id, idempotencyKey, requestHash, status, expires_at)
+        VALUES ($1, $2, $3, 'IN_PROGRESS', CURRENT_TIMESTAMP + $4 * INTERVAL '1 second')
+        ON CONFLICT (tenantId, idempotencyKey) DO NOTHING
+    `, uuid.New(), key, hash, ttl.Seconds())
+	if err != nil {
+		return rec, false, err
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return rec, true, nil
+	}
+
+	var status string
+	var codigo sql.NullInt64
+	var tipo sql.NullString
+	err = s.db.QueryRow(`
+        SELECT requestHash, status, responseStatus, responseContentType, responseBody,
+               updated_at < CURRENT_TIMESTAMP - $2 * INTERVAL '1 second', expires_at
+        FROM prueba.clavesidempotencia
+        WHERE idempotencyKey = $1
+    `, key, abandonada.Seconds()).Scan(&rec.RequestHash, &status, &codigo, &tipo, &rec.Body, &rec.Abandoned,
+		&rec.ExpiresAt)
+	if err == sql.ErrNoRows {
+		// La clave se liberó entre la inserción y la consulta: intentar de nuevo
+		return s.Begin(key, hash, ttl)
+	}
+	rec.Completed = status == "COMPLETED"
+	rec.Status, rec.ContentType = int(codigo.Int64), tipo.String
+	return rec, false, err
+}
+
+func (s *SQLStore) Complete(key string, rec Record) error {
+	_, err := s.db.Exec(`
+        UPDATE prueba.clavesidempotencia
+        SET status = 'COMPLETED', responseStatus = $2, responseContentType = $3, responseBody = $4,
+            updated_at = CURRENT_TIMESTAMP
+        WHERE idempotencyKey = $1
+    `, key, rec.Status, rec.ContentType, rec.Body)
+	return err
+}
+
+func (s *SQLStore) Release(key string) error {
+	_, err := s.db.Exec(`
+        DELETE FROM prueba.clavesidempotencia WHERE idempotencyKey = $1 AND status = 'IN_PROGRESS'
+    `, key)
+	return err
+}
+
+// Purge elimina las claves vencidas de todas las empresas; se ejecuta con la
+// conexión del sistema
+func Purge(db *sql.DB) (int64, error) {
+	result, err := db.Exec(`DELETE FROM prueba.clavesidempotencia WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
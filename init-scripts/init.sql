@@ -16,8 +16,14 @@ CREATE TABLE IF NOT EXISTS catalogos.Productos (
     -- Categoría del producto
     price DECIMAL(10, 2) NOT NULL,
     -- Precio del producto (hasta 10 dígitos, 2 decimales)
+    currency CHAR(3) NOT NULL DEFAULT 'MXN',
+    -- Moneda del precio (ISO 4217)
     sku VARCHAR(100) UNIQUE NOT NULL,
     -- SKU único para el producto
+    track_lots BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Controla existencias por lote y fecha de caducidad
+    track_serials BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Controla cada unidad por número de serie
     --campos default para control
     activo BOOLEAN NOT NULL DEFAULT TRUE,
     -- Estado activo/inactivo para borrado lógico
@@ -75,8 +81,12 @@ CREATE TABLE IF NOT EXISTS prueba.Movimientos (
     -- Cantidad (debe ser positiva)
     timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     -- Marca de tiempo
-    type VARCHAR(20) NOT NULL CHECK (type IN ('IN', 'OUT', 'TRANSFER')),
-    -- Tipo (IN, OUT, TRANSFER)
+    type VARCHAR(20) NOT NULL,
+    -- Código del tipo en catalogos.TiposMovimiento (IN, OUT, TRANSFER, WRITE_OFF, DAMAGE...)
+    unit_cost DECIMAL(12, 2) CHECK (unit_cost >= 0),
+    -- Costo unitario de adquisición (movimientos IN)
+    currency CHAR(3) NOT NULL DEFAULT 'MXN',
+    -- Moneda del costo (ISO 4217)
     --campos default para control
     activo BOOLEAN NOT NULL DEFAULT TRUE,
     -- Estado activo/inactivo para borrado lógico
@@ -362,7 +372,7 @@ CREATE INDEX idx_productos_category ON catalogos.productos(category);
 CREATE INDEX idx_tiendas_name ON catalogos.tiendas(name);
 CREATE INDEX idx_tiendas_activo ON catalogos.tiendas(activo);
 -- Índices compuestos para inventarios (consultas más frecuentes)
-CREATE INDEX idx_inventarios_producto_tienda ON prueba.inventarios(productId, storeId);
+CREATE UNIQUE INDEX idx_inventarios_producto_tienda ON prueba.inventarios(productId, storeId);
 CREATE INDEX idx_inventarios_tienda_activo ON prueba.inventarios(storeId, activo);
 CREATE INDEX idx_inventarios_stock_bajo ON prueba.inventarios(quantity, minStock)
 WHERE activo = true;
@@ -395,7 +405,7 @@ CREATE OR REPLACE FUNCTION transfer_inventory(
         p_source_store_id UUID,
         p_target_store_id UUID,
         p_quantity INTEGER
-    ) RETURNS BOOLEAN AS $$
+    ) RETURNS UUID AS $$
 DECLARE v_source_quantity INTEGER;
 v_movement_id UUID;
 BEGIN -- Verificar cantidad positiva
@@ -459,8 +469,10 @@ VALUES (
         'TRANSFER',
         CURRENT_TIMESTAMP,
         true
-    );
-RETURN TRUE;
+    )
+RETURNING id INTO v_movement_id;
+-- Devolver el movimiento registrado para enlazar sus capas de costo
+RETURN v_movement_id;
 EXCEPTION
 WHEN OTHERS THEN RAISE;
 END;
@@ -478,7 +490,8 @@ SELECT i.productId,
         WHEN i.quantity = 0 THEN 'SIN_STOCK'
         WHEN i.quantity <= i.minStock THEN 'STOCK_BAJO'
         ELSE 'NORMAL'
-    END as alert_type
+    END as alert_type,
+    i.tenantId
 FROM prueba.inventarios i
     JOIN catalogos.productos p ON i.productId = p.id
     JOIN catalogos.tiendas t ON i.storeId = t.id
@@ -492,6 +505,1589 @@ ADD CONSTRAINT check_different_stores CHECK (
         sourceStoreId != targetStoreId
         OR type != 'TRANSFER'
     );
--- Constraint para tipos de movimiento válidos
+-- Listas de precios e historial de precios
+---------------------------------------------------------------------------------------
+-- Tabla Listas de precios (menudeo, mayoreo y precios especiales por tienda)
+CREATE TABLE IF NOT EXISTS catalogos.ListasPrecios (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    name VARCHAR(100) NOT NULL,
+    -- Nombre de la lista
+    type VARCHAR(20) NOT NULL CHECK (type IN ('RETAIL', 'WHOLESALE', 'STORE')),
+    -- Tipo (RETAIL, WHOLESALE, STORE)
+    storeId UUID REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda a la que aplica (solo listas STORE)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT check_lista_tienda CHECK ((type = 'STORE') = (storeId IS NOT NULL))
+);
+-- Tabla Precios (historial y precios programados por lista)
+CREATE TABLE IF NOT EXISTS catalogos.Precios (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    listId UUID NOT NULL REFERENCES catalogos.ListasPrecios(id) ON DELETE CASCADE,
+    -- Relación con Lista de precios
+    price DECIMAL(10, 2) NOT NULL CHECK (price >= 0),
+    -- Precio vigente en el periodo
+    currency CHAR(3) NOT NULL DEFAULT 'MXN',
+    -- Moneda del precio (ISO 4217)
+    effective_from TIMESTAMP NOT NULL,
+    -- Inicio de vigencia
+    effective_to TIMESTAMP,
+    -- Fin de vigencia (NULL = sin fecha de fin)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT check_vigencia CHECK (
+        effective_to IS NULL
+        OR effective_to > effective_from
+    )
+);
+-- Solo puede existir una lista de menudeo general
+CREATE UNIQUE INDEX idx_listas_precios_retail ON catalogos.listasprecios(type)
+WHERE type = 'RETAIL';
+CREATE UNIQUE INDEX idx_listas_precios_tienda ON catalogos.listasprecios(storeId)
+WHERE type = 'STORE';
+CREATE INDEX idx_precios_producto_lista ON catalogos.precios(productId, listId, effective_from)
+WHERE activo = true;
+-- Trigger para listas de precios
+CREATE TRIGGER update_listas_precios_updated_at BEFORE
+UPDATE ON catalogos.listasprecios FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Trigger para precios
+CREATE TRIGGER update_precios_updated_at BEFORE
+UPDATE ON catalogos.precios FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Listas iniciales
+INSERT INTO catalogos.listasprecios (id, name, type, storeId)
+VALUES (gen_random_uuid(), 'Menudeo', 'RETAIL', NULL),
+    (gen_random_uuid(), 'Mayoreo', 'WHOLESALE', NULL);
+-- Historial inicial a partir del precio actual de cada producto
+INSERT INTO catalogos.precios (id, productId, listId, price, currency, effective_from)
+SELECT gen_random_uuid(),
+    p.id,
+    l.id,
+    p.price,
+    p.currency,
+    p.created_at
+FROM catalogos.productos p
+    CROSS JOIN catalogos.listasprecios l
+WHERE l.type = 'RETAIL';
+
+-- Capas de costo para valuación de inventario (FIFO / costo promedio)
+---------------------------------------------------------------------------------------
+-- Tabla Capas de costo (una por cada entrada de mercancía a una tienda)
+CREATE TABLE IF NOT EXISTS prueba.CapasCosto (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Relación con Tienda
+    movementId UUID REFERENCES prueba.Movimientos(id) ON DELETE SET NULL,
+    -- Movimiento que originó la capa (IN o TRANSFER)
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- Cantidad recibida
+    remaining INTEGER NOT NULL CHECK (
+        remaining >= 0
+        AND remaining <= quantity
+    ),
+    -- Cantidad aún disponible en la capa
+    unit_cost DECIMAL(12, 2) NOT NULL CHECK (unit_cost >= 0),
+    -- Costo unitario de la capa
+    currency CHAR(3) NOT NULL DEFAULT 'MXN',
+    -- Moneda del costo (ISO 4217)
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de recepción (orden FIFO)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Tabla Consumos de costo (costo de las salidas tomado de cada capa)
+CREATE TABLE IF NOT EXISTS prueba.ConsumosCosto (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    movementId UUID REFERENCES prueba.Movimientos(id) ON DELETE SET NULL,
+    -- Movimiento que consumió la capa (OUT o TRANSFER)
+    layerId UUID NOT NULL REFERENCES prueba.CapasCosto(id) ON DELETE CASCADE,
+    -- Capa consumida
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- Cantidad tomada de la capa
+    unit_cost DECIMAL(12, 2) NOT NULL CHECK (unit_cost >= 0),
+    -- Costo unitario aplicado
+    currency CHAR(3) NOT NULL DEFAULT 'MXN',
+    -- Moneda del costo (ISO 4217)
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de creación
+);
+CREATE INDEX idx_capas_costo_producto_tienda ON prueba.capascosto(productId, storeId, received_at)
+WHERE activo = true;
+CREATE INDEX idx_consumos_costo_movimiento ON prueba.consumoscosto(movementId);
+-- Trigger para capas de costo
+CREATE TRIGGER update_capas_costo_updated_at BEFORE
+UPDATE ON prueba.capascosto FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Lotes y fechas de caducidad
+---------------------------------------------------------------------------------------
+-- Tabla Lotes (existencias por lote en cada tienda)
+CREATE TABLE IF NOT EXISTS prueba.Lotes (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Relación con Tienda
+    lot_number VARCHAR(100) NOT NULL,
+    -- Número de lote del proveedor
+    expiry_date DATE,
+    -- Fecha de caducidad
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    -- Cantidad disponible en el lote
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT uq_lote_producto_tienda UNIQUE (productId, storeId, lot_number)
+);
+-- Tabla Movimientos por lote (lotes afectados por cada movimiento)
+CREATE TABLE IF NOT EXISTS prueba.MovimientosLotes (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    movementId UUID NOT NULL REFERENCES prueba.Movimientos(id) ON DELETE CASCADE,
+    -- Relación con Movimiento
+    lotId UUID NOT NULL REFERENCES prueba.Lotes(id) ON DELETE CASCADE,
+    -- Lote afectado (origen en salidas y transferencias)
+    targetLotId UUID REFERENCES prueba.Lotes(id) ON DELETE CASCADE,
+    -- Lote destino (solo transferencias)
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- Cantidad tomada del lote
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de creación
+);
+CREATE INDEX idx_lotes_fefo ON prueba.lotes(productId, storeId, expiry_date)
+WHERE activo = true
+    AND quantity > 0;
+CREATE INDEX idx_lotes_caducidad ON prueba.lotes(expiry_date)
+WHERE activo = true
+    AND quantity > 0;
+CREATE INDEX idx_movimientos_lotes_movimiento ON prueba.movimientoslotes(movementId);
+-- Trigger para lotes
+CREATE TRIGGER update_lotes_updated_at BEFORE
+UPDATE ON prueba.lotes FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Números de serie
+---------------------------------------------------------------------------------------
+-- Tabla Series (ubicación actual de cada unidad con número de serie)
+CREATE TABLE IF NOT EXISTS prueba.Series (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    serial_number VARCHAR(100) NOT NULL,
+    -- Número de serie del fabricante
+    storeId UUID REFERENCES catalogos.Tiendas(id) ON DELETE SET NULL,
+    -- Tienda donde se encuentra (NULL si ya salió)
+    status VARCHAR(20) NOT NULL CHECK (status IN ('EN_TIENDA', 'FUERA')),
+    -- Estado (EN_TIENDA, FUERA)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT uq_serie_producto UNIQUE (productId, serial_number),
+    CONSTRAINT check_serie_tienda CHECK ((status = 'EN_TIENDA') = (storeId IS NOT NULL))
+);
+-- Tabla Movimientos por serie (historial de cada unidad)
+CREATE TABLE IF NOT EXISTS prueba.MovimientosSeries (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    movementId UUID NOT NULL REFERENCES prueba.Movimientos(id) ON DELETE CASCADE,
+    -- Relación con Movimiento
+    serialId UUID NOT NULL REFERENCES prueba.Series(id) ON DELETE CASCADE,
+    -- Unidad movida
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de creación
+);
+CREATE INDEX idx_series_numero ON prueba.series(serial_number);
+CREATE INDEX idx_series_tienda ON prueba.series(productId, storeId)
+WHERE status = 'EN_TIENDA';
+CREATE INDEX idx_movimientos_series_serie ON prueba.movimientosseries(serialId);
+-- Trigger para series
+CREATE TRIGGER update_series_updated_at BEFORE
+UPDATE ON prueba.series FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Ubicaciones dentro de tienda (zona / pasillo / contenedor)
+---------------------------------------------------------------------------------------
+-- Tabla Ubicaciones
+CREATE TABLE IF NOT EXISTS catalogos.Ubicaciones (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda a la que pertenece
+    parentId UUID REFERENCES catalogos.Ubicaciones(id) ON DELETE CASCADE,
+    -- Ubicación padre (NULL para zonas)
+    type VARCHAR(20) NOT NULL CHECK (type IN ('ZONE', 'AISLE', 'BIN')),
+    -- Tipo (ZONE, AISLE, BIN)
+    code VARCHAR(50) NOT NULL,
+    -- Código corto, por ejemplo BOD-A-03
+    name VARCHAR(255),
+    -- Nombre descriptivo
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT uq_ubicacion_codigo UNIQUE (storeId, code)
+);
+-- Tabla Inventarios por ubicación (la existencia total de la tienda sigue en prueba.inventarios;
+-- la diferencia entre ambas es mercancía sin ubicar)
+CREATE TABLE IF NOT EXISTS prueba.InventariosUbicaciones (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Relación con Tienda
+    locationId UUID NOT NULL REFERENCES catalogos.Ubicaciones(id) ON DELETE CASCADE,
+    -- Relación con Ubicación
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    -- Cantidad en la ubicación
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT uq_inventario_ubicacion UNIQUE (productId, locationId)
+);
+-- Ubicaciones de origen y destino de los movimientos
+ALTER TABLE prueba.movimientos
+ADD COLUMN sourceLocationId UUID REFERENCES catalogos.Ubicaciones(id) ON DELETE SET NULL,
+    ADD COLUMN targetLocationId UUID REFERENCES catalogos.Ubicaciones(id) ON DELETE SET NULL;
+-- Las reubicaciones ocurren dentro de la misma tienda
+ALTER TABLE prueba.movimientos
+ADD CONSTRAINT check_relocate_same_store CHECK (
+        type != 'RELOCATE'
+        OR sourceStoreId = targetStoreId
+    );
+CREATE INDEX idx_ubicaciones_tienda ON catalogos.ubicaciones(storeId, parentId);
+CREATE INDEX idx_inventarios_ubicaciones_tienda ON prueba.inventariosubicaciones(storeId, productId);
+-- Trigger para ubicaciones
+CREATE TRIGGER update_ubicaciones_updated_at BEFORE
+UPDATE ON catalogos.ubicaciones FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Trigger para inventarios por ubicación
+CREATE TRIGGER update_inventarios_ubicaciones_updated_at BEFORE
+UPDATE ON prueba.inventariosubicaciones FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Conteos físicos (inventario completo o cíclico) con aprobación de diferencias
+---------------------------------------------------------------------------------------
+-- Tabla Conteos
+CREATE TABLE IF NOT EXISTS prueba.Conteos (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda contada
+    type VARCHAR(20) NOT NULL CHECK (type IN ('FULL', 'CYCLE')),
+    -- Tipo (FULL: toda la tienda, CYCLE: productos seleccionados)
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'APPROVED', 'CANCELLED')),
+    -- Estado; mientras está OPEN los productos contados no admiten movimientos
+    notes TEXT,
+    -- Observaciones
+    approved_by VARCHAR(100),
+    -- Quién aprobó las diferencias
+    approved_at TIMESTAMP,
+    -- Fecha de aprobación
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Tabla Líneas de conteo (producto contado y existencia del sistema al iniciar)
+CREATE TABLE IF NOT EXISTS prueba.ConteosLineas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    countId UUID NOT NULL REFERENCES prueba.Conteos(id) ON DELETE CASCADE,
+    -- Relación con Conteo
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    system_quantity INTEGER NOT NULL,
+    -- Existencia del sistema al iniciar el conteo
+    decision VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (
+        decision IN ('PENDING', 'ACCEPTED', 'REJECTED', 'NOT_COUNTED')
+    ),
+    -- Resultado de la aprobación
+    movementId UUID REFERENCES prueba.Movimientos(id) ON DELETE SET NULL,
+    -- Movimiento de ajuste generado
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT uq_conteo_producto UNIQUE (countId, productId)
+);
+-- Tabla Capturas de conteo (una por contador, producto y ubicación; la última prevalece)
+CREATE TABLE IF NOT EXISTS prueba.ConteosCapturas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    countId UUID NOT NULL REFERENCES prueba.Conteos(id) ON DELETE CASCADE,
+    -- Relación con Conteo
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    locationId UUID REFERENCES catalogos.Ubicaciones(id) ON DELETE SET NULL,
+    -- Ubicación contada (NULL si la tienda no usa ubicaciones)
+    counter VARCHAR(100) NOT NULL,
+    -- Persona que contó
+    quantity INTEGER NOT NULL CHECK (quantity >= 0),
+    -- Cantidad contada
+    counted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Momento de la captura
+);
+-- Ajustes de inventario: sentido y conteo que los origina
+ALTER TABLE prueba.movimientos
+ADD COLUMN direction SMALLINT CHECK (direction IN (-1, 1)),
+    ADD COLUMN countId UUID REFERENCES prueba.Conteos(id) ON DELETE SET NULL;
 ALTER TABLE prueba.movimientos
-ADD CONSTRAINT check_movement_type CHECK (type IN ('IN', 'OUT', 'TRANSFER'));
\ No newline at end of file
+ADD CONSTRAINT check_adjustment_direction CHECK (
+        type != 'ADJUSTMENT'
+        OR (
+            direction IS NOT NULL
+            AND sourceStoreId = targetStoreId
+        )
+    );
+CREATE INDEX idx_conteos_tienda ON prueba.conteos(storeId, status);
+CREATE INDEX idx_conteos_capturas ON prueba.conteoscapturas(countId, productId);
+-- Trigger para conteos
+CREATE TRIGGER update_conteos_updated_at BEFORE
+UPDATE ON prueba.conteos FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Trigger para líneas de conteo
+CREATE TRIGGER update_conteos_lineas_updated_at BEFORE
+UPDATE ON prueba.conteoslineas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Códigos de barras (GTIN) de productos
+---------------------------------------------------------------------------------------
+-- Tabla Códigos de barras
+CREATE TABLE IF NOT EXISTS catalogos.CodigosBarras (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    code CHAR(14) NOT NULL,
+    -- GTIN normalizado a 14 dígitos (UPC-A y EAN-13 equivalentes coinciden)
+    type VARCHAR(10) NOT NULL CHECK (type IN ('EAN8', 'UPCA', 'EAN13', 'GTIN14')),
+    -- Formato en que se capturó
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Código principal del producto (para etiquetas)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Un código activo identifica a un solo producto
+CREATE UNIQUE INDEX idx_codigos_barras_code ON catalogos.codigosbarras(code)
+WHERE activo = true;
+CREATE INDEX idx_codigos_barras_producto ON catalogos.codigosbarras(productId);
+-- Trigger para códigos de barras
+CREATE TRIGGER update_codigos_barras_updated_at BEFORE
+UPDATE ON catalogos.codigosbarras FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Eventos de inventario para notificaciones en tiempo real
+---------------------------------------------------------------------------------------
+-- Tabla Eventos de inventario (bitácora para reanudar desde el último evento recibido)
+CREATE TABLE IF NOT EXISTS prueba.EventosInventario (
+    id BIGSERIAL PRIMARY KEY,
+    -- Identificador secuencial del evento
+    type VARCHAR(30) NOT NULL,
+    -- Tipo (INVENTORY_CHANGED, INVENTORY_DELETED, MOVEMENT)
+    productId UUID NOT NULL,
+    -- Producto afectado
+    storeId UUID NOT NULL,
+    -- Tienda afectada
+    payload JSONB NOT NULL,
+    -- Detalle del cambio
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha del evento
+    seq BIGINT UNIQUE -- Posición en orden de confirmación; la asigna la aplicación y es la que se usa para reanudar
+);
+CREATE INDEX idx_eventos_inventario_tienda ON prueba.eventosinventario(storeId, id);
+CREATE INDEX idx_eventos_inventario_producto ON prueba.eventosinventario(productId, id);
+CREATE INDEX idx_eventos_inventario_sin_orden ON prueba.eventosinventario(id)
+WHERE seq IS NULL;
+CREATE SEQUENCE prueba.eventosinventario_orden_seq;
+-- Avisar a las réplicas de la aplicación; NOTIFY se entrega al confirmar la transacción
+CREATE OR REPLACE FUNCTION notificar_evento_inventario() RETURNS TRIGGER AS $$ BEGIN PERFORM pg_notify('eventos_inventario', NEW.id::text);
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER notificar_evento_inventario
+AFTER
+INSERT ON prueba.eventosinventario FOR EACH ROW EXECUTE FUNCTION notificar_evento_inventario();
+-- Registrar cambios de existencias
+CREATE OR REPLACE FUNCTION registrar_evento_inventario() RETURNS TRIGGER AS $$ BEGIN IF TG_OP = 'DELETE' THEN
+INSERT INTO prueba.eventosinventario (tenantId, type, productId, storeId, payload)
+VALUES (
+        OLD.tenantId,
+        'INVENTORY_DELETED',
+        OLD.productId,
+        OLD.storeId,
+        jsonb_build_object(
+            'inventory_id',
+            OLD.id,
+            'quantity',
+            0,
+            'previous_quantity',
+            OLD.quantity
+        )
+    );
+RETURN OLD;
+END IF;
+IF TG_OP = 'UPDATE'
+AND NEW.quantity = OLD.quantity
+AND NEW.minStock = OLD.minStock
+AND NEW.activo = OLD.activo THEN RETURN NEW;
+END IF;
+INSERT INTO prueba.eventosinventario (tenantId, type, productId, storeId, payload)
+VALUES (
+        NEW.tenantId,
+        'INVENTORY_CHANGED',
+        NEW.productId,
+        NEW.storeId,
+        jsonb_build_object(
+            'inventory_id',
+            NEW.id,
+            'quantity',
+            NEW.quantity,
+            'previous_quantity',
+            CASE
+                WHEN TG_OP = 'INSERT' THEN NULL
+                ELSE OLD.quantity
+            END,
+            'min_stock',
+            NEW.minStock,
+            'activo',
+            NEW.activo
+        )
+    );
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER registrar_evento_inventarios
+AFTER
+INSERT
+    OR
+UPDATE
+    OR DELETE ON prueba.inventarios FOR EACH ROW EXECUTE FUNCTION registrar_evento_inventario();
+-- Registrar movimientos (uno por cada tienda involucrada)
+CREATE OR REPLACE FUNCTION registrar_evento_movimiento() RETURNS TRIGGER AS $$
+DECLARE v_payload JSONB;
+BEGIN v_payload := jsonb_build_object(
+    'movement_id',
+    NEW.id,
+    'movement_type',
+    NEW.type,
+    'quantity',
+    NEW.quantity,
+    'source_store_id',
+    NEW.sourceStoreId,
+    'target_store_id',
+    NEW.targetStoreId,
+    'timestamp',
+    NEW.timestamp
+);
+INSERT INTO prueba.eventosinventario (tenantId, type, productId, storeId, payload)
+VALUES (NEW.tenantId, 'MOVEMENT', NEW.productId, NEW.sourceStoreId, v_payload);
+IF NEW.targetStoreId <> NEW.sourceStoreId THEN
+INSERT INTO prueba.eventosinventario (tenantId, type, productId, storeId, payload)
+VALUES (NEW.tenantId, 'MOVEMENT', NEW.productId, NEW.targetStoreId, v_payload);
+END IF;
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER registrar_evento_movimientos
+AFTER
+INSERT ON prueba.movimientos FOR EACH ROW EXECUTE FUNCTION registrar_evento_movimiento();
+
+-- Webhooks salientes
+---------------------------------------------------------------------------------------
+-- Tabla Suscripciones de webhooks
+CREATE TABLE IF NOT EXISTS prueba.Webhooks (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    url TEXT NOT NULL,
+    -- Dirección que recibe los eventos (POST)
+    secret VARCHAR(100) NOT NULL,
+    -- Secreto para firmar con HMAC-SHA256
+    eventTypes TEXT [] NOT NULL,
+    -- Tipos de evento suscritos ('*' para todos)
+    description TEXT,
+    -- Descripción de la integración
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Tabla Entregas de webhooks (bandeja de salida, se escribe en la misma transacción del cambio)
+CREATE TABLE IF NOT EXISTS prueba.WebhooksEntregas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    webhookId UUID NOT NULL REFERENCES prueba.Webhooks(id) ON DELETE CASCADE,
+    -- Suscripción destino
+    eventId UUID NOT NULL,
+    -- Evento (compartido por las entregas del mismo evento, para deduplicar)
+    eventType VARCHAR(50) NOT NULL,
+    -- Tipo de evento
+    payload JSONB NOT NULL,
+    -- Cuerpo a enviar
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD')),
+    -- Estado de la entrega; DEAD al agotar los reintentos
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- Intentos realizados
+    nextAttemptAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Próximo intento
+    lastStatusCode INTEGER,
+    -- Código HTTP de la última respuesta
+    lastError TEXT,
+    -- Error del último intento
+    deliveredAt TIMESTAMP,
+    -- Fecha de entrega exitosa
+    --campos default para control
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+CREATE INDEX idx_webhooks_entregas_pendientes ON prueba.webhooksentregas(nextAttemptAt)
+WHERE status = 'PENDING';
+CREATE INDEX idx_webhooks_entregas_webhook ON prueba.webhooksentregas(webhookId, created_at);
+-- Trigger para webhooks
+CREATE TRIGGER update_webhooks_updated_at BEFORE
+UPDATE ON prueba.webhooks FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Trigger para entregas de webhooks
+CREATE TRIGGER update_webhooks_entregas_updated_at BEFORE
+UPDATE ON prueba.webhooksentregas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Encolar un evento para cada suscripción activa interesada de la empresa
+CREATE OR REPLACE FUNCTION encolar_webhook(p_tenant UUID, p_type VARCHAR, p_data JSONB) RETURNS VOID AS $$
+DECLARE v_event_id UUID := gen_random_uuid();
+BEGIN
+INSERT INTO prueba.webhooksentregas (id, tenantId, webhookId, eventId, eventType, payload)
+SELECT gen_random_uuid(),
+    p_tenant,
+    w.id,
+    v_event_id,
+    p_type,
+    jsonb_build_object(
+        'id',
+        v_event_id,
+        'type',
+        p_type,
+        'created_at',
+        CURRENT_TIMESTAMP,
+        'data',
+        p_data
+    )
+FROM prueba.webhooks w
+WHERE w.activo = true
+    AND w.tenantId = p_tenant
+    AND (
+        p_type = ANY(w.eventTypes)
+        OR '*' = ANY(w.eventTypes)
+    );
+END;
+$$ LANGUAGE plpgsql;
+-- movement.created y transfer.completed
+CREATE OR REPLACE FUNCTION webhook_movimiento() RETURNS TRIGGER AS $$
+DECLARE v_data JSONB;
+BEGIN v_data := jsonb_build_object(
+    'id',
+    NEW.id,
+    'product_id',
+    NEW.productId,
+    'source_store_id',
+    NEW.sourceStoreId,
+    'target_store_id',
+    NEW.targetStoreId,
+    'quantity',
+    NEW.quantity,
+    'type',
+    NEW.type,
+    'timestamp',
+    NEW.timestamp
+);
+PERFORM encolar_webhook(NEW.tenantId, 'movement.created', v_data);
+IF NEW.type = 'TRANSFER' THEN PERFORM encolar_webhook(NEW.tenantId, 'transfer.completed', v_data);
+END IF;
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER webhook_movimientos
+AFTER
+INSERT ON prueba.movimientos FOR EACH ROW EXECUTE FUNCTION webhook_movimiento();
+-- stock.low al llegar al stock mínimo o bajar de él, igual que
+-- vw_inventory_alerts
+CREATE OR REPLACE FUNCTION webhook_stock_bajo() RETURNS TRIGGER AS $$ BEGIN IF NEW.activo
+AND NEW.quantity <= NEW.minStock
+AND (
+    TG_OP = 'INSERT'
+    OR NOT OLD.activo
+    OR OLD.quantity > OLD.minStock
+) THEN PERFORM encolar_webhook(
+    NEW.tenantId,
+    'stock.low',
+    jsonb_build_object(
+        'inventory_id',
+        NEW.id,
+        'product_id',
+        NEW.productId,
+        'store_id',
+        NEW.storeId,
+        'quantity',
+        NEW.quantity,
+        'min_stock',
+        NEW.minStock
+    )
+);
+END IF;
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER webhook_inventarios
+AFTER
+INSERT
+    OR
+UPDATE ON prueba.inventarios FOR EACH ROW EXECUTE FUNCTION webhook_stock_bajo();
+-- product.updated
+CREATE OR REPLACE FUNCTION webhook_producto() RETURNS TRIGGER AS $$ BEGIN IF (to_jsonb(NEW) - 'updated_at') = (to_jsonb(OLD) - 'updated_at') THEN RETURN NEW;
+END IF;
+PERFORM encolar_webhook(
+    NEW.tenantId,
+    'product.updated',
+    jsonb_build_object(
+        'id',
+        NEW.id,
+        'name',
+        NEW.name,
+        'sku',
+        NEW.sku,
+        'category',
+        NEW.category,
+        'price',
+        NEW.price,
+        'currency',
+        NEW.currency,
+        'activo',
+        NEW.activo
+    )
+);
+RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER webhook_productos
+AFTER
+UPDATE ON catalogos.productos FOR EACH ROW EXECUTE FUNCTION webhook_producto();
+
+-- Bandeja de salida de eventos de dominio para el broker de mensajes
+---------------------------------------------------------------------------------------
+-- Tabla Bandeja de salida (se escribe en la misma transacción del cambio)
+CREATE TABLE IF NOT EXISTS prueba.Outbox (
+    id BIGSERIAL PRIMARY KEY,
+    -- Orden de publicación
+    aggregateType VARCHAR(30) NOT NULL,
+    -- Agregado (product, store, inventory, movement)
+    aggregateId UUID NOT NULL,
+    -- Identificador del agregado; el orden se garantiza por agregado
+    eventType VARCHAR(50) NOT NULL,
+    -- Tipo de evento (product.created, inventory.updated, ...)
+    payload JSONB NOT NULL,
+    -- Estado del agregado después del cambio (antes, si se borró)
+    txId BIGINT NOT NULL DEFAULT txid_current(),
+    -- Transacción que originó el evento (agrupa los eventos de un mismo cambio)
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha del evento
+    publishedAt TIMESTAMP,
+    -- Fecha en que el broker confirmó la recepción
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- Intentos de publicación fallidos
+    lastError TEXT,
+    -- Error del último intento
+    claimedUntil TIMESTAMP -- Vencimiento del lote que un relevo está publicando
+);
+CREATE INDEX idx_outbox_pendientes ON prueba.outbox(id)
+WHERE publishedAt IS NULL;
+-- Registrar cada alta, cambio y baja de productos, tiendas, inventarios y movimientos
+CREATE OR REPLACE FUNCTION registrar_outbox() RETURNS TRIGGER AS $$
+DECLARE v_aggregate VARCHAR;
+v_action VARCHAR;
+v_row JSONB;
+BEGIN v_aggregate := CASE
+    TG_TABLE_NAME
+    WHEN 'productos' THEN 'product'
+    WHEN 'tiendas' THEN 'store'
+    WHEN 'inventarios' THEN 'inventory'
+    WHEN 'movimientos' THEN 'movement'
+END;
+IF TG_OP = 'DELETE' THEN v_action := 'deleted';
+v_row := to_jsonb(OLD);
+ELSIF TG_OP = 'INSERT' THEN v_action := 'created';
+v_row := to_jsonb(NEW);
+ELSE -- Ignorar actualizaciones que sólo tocan updated_at
+IF (to_jsonb(NEW) - 'updated_at') = (to_jsonb(OLD) - 'updated_at') THEN RETURN NEW;
+END IF;
+v_action := 'updated';
+v_row := to_jsonb(NEW);
+END IF;
+INSERT INTO prueba.outbox (tenantId, aggregateType, aggregateId, eventType, payload)
+VALUES (
+        (v_row->>'tenantid')::uuid,
+        v_aggregate,
+        (v_row->>'id')::uuid,
+        v_aggregate || '.' || v_action,
+        v_row
+    );
+RETURN COALESCE(NEW, OLD);
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER outbox_productos
+AFTER
+INSERT
+    OR
+UPDATE
+    OR DELETE ON catalogos.productos FOR EACH ROW EXECUTE FUNCTION registrar_outbox();
+CREATE TRIGGER outbox_tiendas
+AFTER
+INSERT
+    OR
+UPDATE
+    OR DELETE ON catalogos.tiendas FOR EACH ROW EXECUTE FUNCTION registrar_outbox();
+CREATE TRIGGER outbox_inventarios
+AFTER
+INSERT
+    OR
+UPDATE
+    OR DELETE ON prueba.inventarios FOR EACH ROW EXECUTE FUNCTION registrar_outbox();
+CREATE TRIGGER outbox_movimientos
+AFTER
+INSERT
+    OR
+UPDATE
+    OR DELETE ON prueba.movimientos FOR EACH ROW EXECUTE FUNCTION registrar_outbox();
+
+-- Notificaciones de alertas de stock
+---------------------------------------------------------------------------------------
+-- Tabla Destinatarios de alertas
+CREATE TABLE IF NOT EXISTS prueba.AlertasDestinatarios (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    storeId UUID REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda vigilada; NULL para todas las tiendas
+    email VARCHAR(255) NOT NULL,
+    -- Correo del destinatario
+    mode VARCHAR(20) NOT NULL DEFAULT 'IMMEDIATE' CHECK (mode IN ('IMMEDIATE', 'DIGEST')),
+    -- Aviso inmediato o resumen diario
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Tabla Historial de alertas
+CREATE TABLE IF NOT EXISTS prueba.Alertas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Producto en alerta
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda en alerta
+    alertType VARCHAR(20) NOT NULL CHECK (alertType IN ('STOCK_BAJO', 'SIN_STOCK')),
+    -- Tipo de alerta al entrar en ella
+    quantity INTEGER NOT NULL,
+    -- Existencia al detectarse
+    minStock INTEGER NOT NULL,
+    -- Stock mínimo al detectarse
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'ACKNOWLEDGED', 'RESOLVED')),
+    -- Abierta, atendida o resuelta (la existencia se recuperó o empeoró a otra alerta)
+    snoozedUntil TIMESTAMP,
+    -- Pospuesta hasta esta fecha
+    acknowledgedBy VARCHAR(100),
+    -- Quién atendió la alerta
+    acknowledgedAt TIMESTAMP,
+    -- Cuándo se atendió
+    notifiedAt TIMESTAMP,
+    -- Aviso inmediato enviado
+    resolvedAt TIMESTAMP,
+    -- Cuándo se resolvió
+    --campos default para control
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación (entrada en alerta)
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Una sola alerta vigente por producto y tienda (evita avisos duplicados)
+CREATE UNIQUE INDEX idx_alertas_vigentes ON prueba.alertas(productId, storeId)
+WHERE status IN ('OPEN', 'ACKNOWLEDGED');
+CREATE INDEX idx_alertas_tienda ON prueba.alertas(storeId, created_at);
+-- Tabla Resúmenes enviados (una fila por día; evita enviarlo dos veces entre réplicas)
+CREATE TABLE IF NOT EXISTS prueba.AlertasResumenes (
+    digestDate DATE PRIMARY KEY,
+    -- Día del resumen
+    sent_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de envío
+);
+-- Trigger para destinatarios de alertas
+CREATE TRIGGER update_alertas_destinatarios_updated_at BEFORE
+UPDATE ON prueba.alertasdestinatarios FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Trigger para alertas
+CREATE TRIGGER update_alertas_updated_at BEFORE
+UPDATE ON prueba.alertas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Reglas de alertas configurables
+---------------------------------------------------------------------------------------
+-- Tabla Reglas de alertas
+CREATE TABLE IF NOT EXISTS prueba.ReglasAlertas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    name VARCHAR(100) NOT NULL,
+    -- Nombre descriptivo
+    type VARCHAR(30) NOT NULL CHECK (
+        type IN (
+            'DAYS_OF_COVER',
+            'OVERSTOCK',
+            'DEAD_STOCK',
+            'OUT_SPIKE'
+        )
+    ),
+    -- Días de cobertura por debajo del umbral, existencia sobre el máximo,
+    -- sin movimientos en N días o salidas del último día N veces sobre el promedio
+    threshold NUMERIC(12, 2) NOT NULL CHECK (threshold > 0),
+    -- Umbral según el tipo (días, unidades o factor)
+    lookbackDays INTEGER NOT NULL DEFAULT 30 CHECK (lookbackDays >= 2),
+    -- Días de historia para promediar las salidas
+    severity VARCHAR(10) NOT NULL DEFAULT 'WARNING' CHECK (severity IN ('INFO', 'WARNING', 'CRITICAL')),
+    -- Severidad de las alertas que genera
+    productId UUID REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Alcance: producto (NULL para todos)
+    category VARCHAR(100),
+    -- Alcance: categoría (NULL para todas)
+    storeId UUID REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Alcance: tienda (NULL para todas)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Tabla Resultados de reglas (alertas activas y su historial)
+CREATE TABLE IF NOT EXISTS prueba.ReglasAlertasResultados (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    ruleId UUID NOT NULL REFERENCES prueba.ReglasAlertas(id) ON DELETE CASCADE,
+    -- Regla que se cumplió
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Producto en alerta
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda en alerta
+    value NUMERIC(14, 2) NOT NULL,
+    -- Valor medido (días de cobertura, unidades, días sin movimiento o factor)
+    message TEXT NOT NULL,
+    -- Descripción legible
+    status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'CLEARED')),
+    -- Activa mientras la regla se siga cumpliendo
+    last_evaluated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Última evaluación en que se cumplió
+    cleared_at TIMESTAMP,
+    -- Cuándo dejó de cumplirse
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Primera detección
+);
+CREATE UNIQUE INDEX idx_reglas_resultados_activos ON prueba.reglasalertasresultados(ruleId, productId, storeId)
+WHERE status = 'ACTIVE';
+-- Trigger para reglas de alertas
+CREATE TRIGGER update_reglas_alertas_updated_at BEFORE
+UPDATE ON prueba.reglasalertas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Analítica de inventario
+---------------------------------------------------------------------------------------
+-- Vista de movimientos por tienda con su efecto en la existencia (positivo
+-- entra, negativo sale); las transferencias aparecen en ambas tiendas
+CREATE OR REPLACE VIEW prueba.vw_movimientos_tienda AS
+SELECT m.id,
+    m.productId,
+    m.targetStoreId AS storeId,
+    m.type,
+    m.timestamp,
+    m.quantity AS delta
+FROM prueba.movimientos m
+WHERE m.activo = true
+    AND m.type IN ('IN', 'TRANSFER')
+UNION ALL
+SELECT m.id,
+    m.productId,
+    m.sourceStoreId,
+    m.type,
+    m.timestamp,
+    - m.quantity
+FROM prueba.movimientos m
+WHERE m.activo = true
+    AND m.type IN ('OUT', 'TRANSFER', 'WRITE_OFF')
+UNION ALL
+SELECT m.id,
+    m.productId,
+    m.sourceStoreId,
+    m.type,
+    m.timestamp,
+    m.direction * m.quantity
+FROM prueba.movimientos m
+WHERE m.activo = true
+    AND m.type = 'ADJUSTMENT';
+CREATE INDEX idx_movimientos_producto_fecha ON prueba.movimientos(productId, timestamp);
+
+-- Existencias a una fecha
+---------------------------------------------------------------------------------------
+-- Tabla Fotografías diarias de existencias (existencia al cierre de cada día)
+CREATE TABLE IF NOT EXISTS prueba.InventariosSnapshots (
+    snapshotDate DATE NOT NULL,
+    -- Día cuyo cierre se fotografía
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Relación con Producto
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Relación con Tienda
+    quantity INTEGER NOT NULL,
+    -- Existencia al cierre del día
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de materialización
+    PRIMARY KEY (snapshotDate, productId, storeId)
+);
+CREATE INDEX idx_snapshots_producto_tienda ON prueba.inventariossnapshots(productId, storeId, snapshotDate);
+-- Existencia de cada inventario en un instante: parte de la fotografía más
+-- cercana (anterior o posterior) o de la existencia actual y reproduce los
+-- movimientos entre ambos instantes
+CREATE OR REPLACE FUNCTION prueba.existencias_al(p_as_of TIMESTAMP) RETURNS TABLE (productId UUID, storeId UUID, quantity INTEGER) AS $$
+SELECT i.productId,
+    i.storeId,
+    (
+        CASE
+            WHEN antes.t IS NOT NULL
+            AND p_as_of - antes.t <= COALESCE(despues.t, LOCALTIMESTAMP) - p_as_of THEN antes.quantity + COALESCE(
+                (
+                    SELECT SUM(v.delta)
+                    FROM prueba.vw_movimientos_tienda v
+                    WHERE v.productId = i.productId
+                        AND v.storeId = i.storeId
+                        AND v.timestamp >= antes.t
+                        AND v.timestamp < p_as_of
+                ),
+                0
+            )
+            ELSE COALESCE(despues.quantity, i.quantity) - COALESCE(
+                (
+                    SELECT SUM(v.delta)
+                    FROM prueba.vw_movimientos_tienda v
+                    WHERE v.productId = i.productId
+                        AND v.storeId = i.storeId
+                        AND v.timestamp >= p_as_of
+                        AND (
+                            despues.t IS NULL
+                            OR v.timestamp < despues.t
+                        )
+                ),
+                0
+            )
+        END
+    )::INTEGER
+FROM prueba.inventarios i
+    LEFT JOIN LATERAL (
+        SELECT s.quantity,
+            (s.snapshotDate + 1)::TIMESTAMP AS t
+        FROM prueba.inventariossnapshots s
+        WHERE s.productId = i.productId
+            AND s.storeId = i.storeId
+            AND s.snapshotDate + 1 <= p_as_of
+        ORDER BY s.snapshotDate DESC
+        LIMIT 1
+    ) antes ON true
+    LEFT JOIN LATERAL (
+        SELECT s.quantity,
+            (s.snapshotDate + 1)::TIMESTAMP AS t
+        FROM prueba.inventariossnapshots s
+        WHERE s.productId = i.productId
+            AND s.storeId = i.storeId
+            AND s.snapshotDate + 1 > p_as_of
+        ORDER BY s.snapshotDate
+        LIMIT 1
+    ) despues ON true
+WHERE p_as_of IS NOT NULL
+    AND i.created_at <= p_as_of;
+$$ LANGUAGE sql STABLE;
+-- Materializa la fotografía del cierre de un día; devuelve las filas nuevas
+CREATE OR REPLACE FUNCTION prueba.materializar_snapshot(p_fecha DATE) RETURNS INTEGER AS $$
+DECLARE v_filas INTEGER;
+BEGIN
+INSERT INTO prueba.inventariossnapshots (snapshotDate, productId, storeId, tenantId, quantity)
+SELECT p_fecha,
+    e.productId,
+    e.storeId,
+    i.tenantId,
+    e.quantity
+FROM prueba.existencias_al((p_fecha + 1)::TIMESTAMP) e
+    JOIN prueba.inventarios i ON i.productId = e.productId
+    AND i.storeId = e.storeId ON CONFLICT DO NOTHING;
+GET DIAGNOSTICS v_filas = ROW_COUNT;
+RETURN v_filas;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Multiempresa (tenants)
+---------------------------------------------------------------------------------------
+-- Tabla Empresas que comparten el despliegue
+CREATE TABLE IF NOT EXISTS prueba.Tenants (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    name VARCHAR(100) NOT NULL,
+    -- Nombre de la empresa
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    -- Identificador corto (minúsculas, números y guiones)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo; una empresa inactiva no puede operar
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Empresa predeterminada: dueña de los datos existentes
+INSERT INTO prueba.tenants (id, name, slug)
+VALUES (
+        '00000000-0000-0000-0000-000000000001',
+        'Predeterminada',
+        'default'
+    );
+-- Trigger para empresas
+CREATE TRIGGER update_tenants_updated_at BEFORE
+UPDATE ON prueba.tenants FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Empresa de la sesión; la aplicación la fija al conectar (app.tenant_id)
+CREATE OR REPLACE FUNCTION prueba.tenant_actual() RETURNS UUID AS $$
+SELECT NULLIF(current_setting('app.tenant_id', true), '')::UUID;
+$$ LANGUAGE sql STABLE;
+-- Agrega tenantId a una tabla (los registros existentes pasan a la empresa
+-- predeterminada) y la aísla con seguridad a nivel de fila
+CREATE OR REPLACE FUNCTION prueba.habilitar_tenant(p_tabla REGCLASS) RETURNS VOID AS $$ BEGIN EXECUTE format(
+        'ALTER TABLE %s ADD COLUMN IF NOT EXISTS tenantId UUID NOT NULL DEFAULT %L REFERENCES prueba.Tenants(id)',
+        p_tabla,
+        '00000000-0000-0000-0000-000000000001'
+    );
+EXECUTE format(
+    'ALTER TABLE %s ALTER COLUMN tenantId SET DEFAULT prueba.tenant_actual()',
+    p_tabla
+);
+EXECUTE format(
+    'CREATE INDEX IF NOT EXISTS %I ON %s (tenantId)',
+    'idx_' || replace(p_tabla::TEXT, '.', '_') || '_tenant',
+    p_tabla
+);
+EXECUTE format(
+    'ALTER TABLE %s ENABLE ROW LEVEL SECURITY',
+    p_tabla
+);
+EXECUTE format(
+    'DROP POLICY IF EXISTS aislamiento_tenant ON %s',
+    p_tabla
+);
+EXECUTE format(
+    'CREATE POLICY aislamiento_tenant ON %s USING (tenantId = prueba.tenant_actual()) WITH CHECK (tenantId = prueba.tenant_actual())',
+    p_tabla
+);
+END;
+$$ LANGUAGE plpgsql;
+-- Aislar todas las tablas de catálogos y operación
+DO $$
+DECLARE r RECORD;
+BEGIN FOR r IN
+SELECT format('%I.%I', table_schema, table_name) AS tabla
+FROM information_schema.tables
+WHERE table_schema IN ('catalogos', 'prueba')
+    AND table_type = 'BASE TABLE'
+    AND table_name <> 'tenants' LOOP PERFORM prueba.habilitar_tenant(r.tabla::REGCLASS);
+END LOOP;
+END;
+$$;
+-- Cada empresa ve su propio registro
+ALTER TABLE prueba.tenants ENABLE ROW LEVEL SECURITY;
+CREATE POLICY aislamiento_tenant ON prueba.tenants USING (id = prueba.tenant_actual());
+-- Las vistas consultan con los permisos (y las políticas) de quien las usa
+ALTER VIEW prueba.vw_inventory_alerts
+SET (security_invoker = true);
+ALTER VIEW prueba.vw_movimientos_tienda
+SET (security_invoker = true);
+-- Unicidad por empresa
+ALTER TABLE catalogos.productos DROP CONSTRAINT productos_sku_key,
+    ADD CONSTRAINT uq_productos_sku UNIQUE (tenantId, sku);
+DROP INDEX catalogos.idx_listas_precios_retail;
+CREATE UNIQUE INDEX idx_listas_precios_retail ON catalogos.listasprecios(tenantId, type)
+WHERE type = 'RETAIL';
+DROP INDEX catalogos.idx_codigos_barras_code;
+CREATE UNIQUE INDEX idx_codigos_barras_code ON catalogos.codigosbarras(tenantId, code)
+WHERE activo = true;
+ALTER TABLE prueba.alertasresumenes DROP CONSTRAINT alertasresumenes_pkey,
+    ADD PRIMARY KEY (tenantId, digestDate);
+-- Rol de la aplicación para las peticiones de las empresas: al no ser dueño
+-- de las tablas queda sujeto a las políticas de aislamiento. La contraseña se
+-- toma de la variable de entorno DB_TENANT_PASSWORD del contenedor.
+\getenv tenant_password DB_TENANT_PASSWORD
+CREATE ROLE inventario_app LOGIN PASSWORD :'tenant_password';
+GRANT USAGE ON SCHEMA catalogos,
+    prueba TO inventario_app;
+GRANT SELECT,
+    INSERT,
+    UPDATE,
+    DELETE ON ALL TABLES IN SCHEMA catalogos,
+    prueba TO inventario_app;
+GRANT USAGE,
+    SELECT ON ALL SEQUENCES IN SCHEMA catalogos,
+    prueba TO inventario_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA catalogos,
+prueba
+GRANT SELECT,
+    INSERT,
+    UPDATE,
+    DELETE ON TABLES TO inventario_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA catalogos,
+prueba
+GRANT USAGE,
+    SELECT ON SEQUENCES TO inventario_app;
+
+-- Perfil de operación de las tiendas
+---------------------------------------------------------------------------------------
+-- Ubicación geográfica y zona horaria de las tiendas
+ALTER TABLE catalogos.tiendas
+ADD COLUMN latitude NUMERIC(9, 6) CHECK (latitude BETWEEN -90 AND 90),
+    ADD COLUMN longitude NUMERIC(9, 6) CHECK (longitude BETWEEN -180 AND 180),
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'America/Mexico_City',
+    ADD CONSTRAINT check_tiendas_coordenadas CHECK ((latitude IS NULL) = (longitude IS NULL));
+-- Tabla Horarios de atención de las tiendas
+CREATE TABLE IF NOT EXISTS catalogos.TiendasHorarios (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Relación con Tienda
+    dayOfWeek SMALLINT NOT NULL CHECK (dayOfWeek BETWEEN 0 AND 6),
+    -- Día de la semana (0 domingo ... 6 sábado)
+    opensAt TIME NOT NULL,
+    -- Hora de apertura (hora local de la tienda)
+    closesAt TIME NOT NULL,
+    -- Hora de cierre; si es menor o igual a la apertura cierra al día siguiente
+    --campos default para control
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+CREATE INDEX idx_tiendas_horarios_tienda ON catalogos.tiendashorarios(storeId, dayOfWeek);
+-- Trigger para horarios de tiendas
+CREATE TRIGGER update_tiendas_horarios_updated_at BEFORE
+UPDATE ON catalogos.tiendashorarios FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+SELECT prueba.habilitar_tenant('catalogos.tiendashorarios');
+
+-- Claves de idempotencia
+---------------------------------------------------------------------------------------
+-- Tabla Claves de idempotencia de las peticiones POST, PUT y PATCH
+CREATE TABLE IF NOT EXISTS prueba.ClavesIdempotencia (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    idempotencyKey VARCHAR(255) NOT NULL,
+    -- Clave enviada por el cliente en el encabezado Idempotency-Key
+    requestHash CHAR(64) NOT NULL,
+    -- SHA-256 del método, la ruta, la consulta y el cuerpo de la petición
+    status VARCHAR(12) NOT NULL DEFAULT 'IN_PROGRESS' CHECK (status IN ('IN_PROGRESS', 'COMPLETED')),
+    -- Estado de la primera ejecución
+    responseStatus INTEGER,
+    -- Código HTTP de la respuesta guardada
+    responseContentType VARCHAR(255),
+    -- Tipo de contenido de la respuesta guardada
+    responseBody BYTEA,
+    -- Cuerpo de la respuesta guardada
+    expires_at TIMESTAMP NOT NULL,
+    -- Fecha a partir de la cual la clave puede reutilizarse
+    --campos default para control
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+SELECT prueba.habilitar_tenant('prueba.clavesidempotencia');
+CREATE UNIQUE INDEX idx_claves_idempotencia_clave ON prueba.clavesidempotencia(tenantId, idempotencyKey);
+CREATE INDEX idx_claves_idempotencia_vencimiento ON prueba.clavesidempotencia(expires_at);
+
+-- Ventas de punto de venta
+---------------------------------------------------------------------------------------
+-- Tabla Ventas (tickets de venta y de devolución)
+CREATE TABLE IF NOT EXISTS prueba.Ventas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda donde se emitió el ticket
+    ticketNumber VARCHAR(64) NOT NULL,
+    -- Folio del ticket en el punto de venta
+    type VARCHAR(10) NOT NULL DEFAULT 'SALE' CHECK (type IN ('SALE', 'RETURN')),
+    -- Venta o devolución
+    originalSaleId UUID REFERENCES prueba.Ventas(id) ON DELETE CASCADE,
+    -- Venta original de una devolución
+    soldAt TIMESTAMP NOT NULL,
+    -- Fecha y hora del ticket (UTC)
+    total DECIMAL(12, 2) NOT NULL CHECK (total >= 0),
+    -- Suma de las líneas
+    paymentTotal DECIMAL(12, 2) NOT NULL CHECK (paymentTotal >= 0),
+    -- Importe cobrado (o reembolsado en devoluciones)
+    currency CHAR(3) NOT NULL DEFAULT 'MXN',
+    -- Moneda del ticket (ISO 4217)
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, -- Fecha de última modificación
+    CONSTRAINT check_ventas_devolucion CHECK ((type = 'RETURN') = (originalSaleId IS NOT NULL))
+);
+-- Tabla Líneas de venta
+CREATE TABLE IF NOT EXISTS prueba.VentasLineas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    saleId UUID NOT NULL REFERENCES prueba.Ventas(id) ON DELETE CASCADE,
+    -- Ticket al que pertenece la línea
+    lineNumber INTEGER NOT NULL CHECK (lineNumber > 0),
+    -- Número de línea dentro del ticket
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Producto vendido o devuelto
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- Cantidad
+    unit_price DECIMAL(12, 2) NOT NULL CHECK (unit_price >= 0),
+    -- Precio unitario cobrado
+    currency CHAR(3) NOT NULL DEFAULT 'MXN',
+    -- Moneda del precio (ISO 4217)
+    movementId UUID REFERENCES prueba.Movimientos(id) ON DELETE SET NULL,
+    -- Movimiento OUT (venta) o IN (devolución) que generó la línea
+    originalLineId UUID REFERENCES prueba.VentasLineas(id) ON DELETE CASCADE,
+    -- Línea de la venta original en devoluciones
+    --campos default para control
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Los movimientos generados por un ticket lo referencian
+ALTER TABLE prueba.movimientos
+ADD COLUMN saleId UUID REFERENCES prueba.Ventas(id) ON DELETE SET NULL;
+SELECT prueba.habilitar_tenant('prueba.ventas');
+SELECT prueba.habilitar_tenant('prueba.ventaslineas');
+CREATE UNIQUE INDEX idx_ventas_ticket ON prueba.ventas(tenantId, storeId, ticketNumber);
+CREATE INDEX idx_ventas_tienda_fecha ON prueba.ventas(storeId, soldAt);
+CREATE INDEX idx_ventas_original ON prueba.ventas(originalSaleId);
+CREATE UNIQUE INDEX idx_ventas_lineas_numero ON prueba.ventaslineas(saleId, lineNumber);
+CREATE INDEX idx_ventas_lineas_original ON prueba.ventaslineas(originalLineId);
+CREATE INDEX idx_movimientos_venta ON prueba.movimientos(saleId);
+-- Triggers para ventas
+CREATE TRIGGER update_ventas_updated_at BEFORE
+UPDATE ON prueba.ventas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_ventas_lineas_updated_at BEFORE
+UPDATE ON prueba.ventaslineas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Devoluciones de clientes (RMA)
+---------------------------------------------------------------------------------------
+-- Tabla RMAs (autorizaciones de devolución de mercancía)
+CREATE TABLE IF NOT EXISTS prueba.RMAs (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    rmaNumber VARCHAR(64) NOT NULL,
+    -- Folio de la autorización
+    storeId UUID NOT NULL REFERENCES catalogos.Tiendas(id) ON DELETE CASCADE,
+    -- Tienda que recibe la devolución
+    saleId UUID REFERENCES prueba.Ventas(id) ON DELETE SET NULL,
+    -- Venta original (opcional)
+    customerName VARCHAR(150),
+    -- Cliente que devuelve
+    reason VARCHAR(30) NOT NULL CHECK (
+        reason IN (
+            'DEFECTIVE',
+            'DAMAGED_IN_TRANSIT',
+            'WRONG_ITEM',
+            'NOT_AS_DESCRIBED',
+            'NO_LONGER_NEEDED',
+            'OTHER'
+        )
+    ),
+    -- Motivo declarado por el cliente
+    notes TEXT,
+    -- Observaciones
+    status VARCHAR(20) NOT NULL DEFAULT 'REQUESTED' CHECK (
+        status IN ('REQUESTED', 'RECEIVED', 'INSPECTED', 'COMPLETED', 'CANCELLED')
+    ),
+    -- Etapa del flujo
+    received_at TIMESTAMP,
+    -- Recepción de la mercancía en tienda
+    inspected_at TIMESTAMP,
+    -- Inspección y decisión sobre cada línea
+    inspected_by VARCHAR(100),
+    -- Quién inspeccionó
+    completed_at TIMESTAMP,
+    -- Aplicación de las decisiones al inventario
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación (solicitud)
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Tabla Líneas de RMA
+CREATE TABLE IF NOT EXISTS prueba.RMAsLineas (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    rmaId UUID NOT NULL REFERENCES prueba.RMAs(id) ON DELETE CASCADE,
+    -- Autorización a la que pertenece la línea
+    lineNumber INTEGER NOT NULL CHECK (lineNumber > 0),
+    -- Número de línea dentro de la autorización
+    productId UUID NOT NULL REFERENCES catalogos.Productos(id) ON DELETE CASCADE,
+    -- Producto devuelto
+    saleLineId UUID REFERENCES prueba.VentasLineas(id) ON DELETE SET NULL,
+    -- Línea de la venta original
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    -- Cantidad autorizada
+    receivedQuantity INTEGER CHECK (
+        receivedQuantity >= 0
+        AND receivedQuantity <= quantity
+    ),
+    -- Cantidad recibida en tienda
+    lotNumber VARCHAR(50),
+    -- Lote de la mercancía devuelta
+    expiryDate DATE,
+    -- Caducidad del lote
+    serials TEXT [] NOT NULL DEFAULT '{}',
+    -- Números de serie devueltos
+    inspectionNotes TEXT,
+    -- Resultado de la inspección
+    disposition VARCHAR(20) CHECK (
+        disposition IN ('RESTOCK', 'WRITE_OFF', 'RETURN_TO_SUPPLIER')
+    ),
+    -- Decisión: reingresar a venta, dar de baja o devolver al proveedor
+    dispositionReason VARCHAR(30) CHECK (
+        dispositionReason IN (
+            'RESELLABLE',
+            'DAMAGED',
+            'DEFECTIVE',
+            'EXPIRED',
+            'MISSING_PARTS',
+            'WARRANTY_CLAIM',
+            'OTHER'
+        )
+    ),
+    -- Motivo de la decisión
+    status VARCHAR(25) NOT NULL DEFAULT 'PENDING' CHECK (
+        status IN (
+            'PENDING',
+            'NOT_RECEIVED',
+            'RESTOCKED',
+            'WRITTEN_OFF',
+            'RETURNED_TO_SUPPLIER'
+        )
+    ),
+    -- Resultado aplicado al completar
+    movementId UUID REFERENCES prueba.Movimientos(id) ON DELETE SET NULL,
+    -- Movimiento IN de reingreso
+    writeOffMovementId UUID REFERENCES prueba.Movimientos(id) ON DELETE SET NULL,
+    -- Movimiento WRITE_OFF de la baja
+    --campos default para control
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+SELECT prueba.habilitar_tenant('prueba.rmas');
+SELECT prueba.habilitar_tenant('prueba.rmaslineas');
+CREATE UNIQUE INDEX idx_rmas_folio ON prueba.rmas(tenantId, rmaNumber);
+CREATE INDEX idx_rmas_tienda_estado ON prueba.rmas(storeId, status);
+CREATE INDEX idx_rmas_venta ON prueba.rmas(saleId);
+CREATE UNIQUE INDEX idx_rmas_lineas_numero ON prueba.rmaslineas(rmaId, lineNumber);
+CREATE INDEX idx_rmas_lineas_venta ON prueba.rmaslineas(saleLineId);
+-- Triggers para RMAs
+CREATE TRIGGER update_rmas_updated_at BEFORE
+UPDATE ON prueba.rmas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_rmas_lineas_updated_at BEFORE
+UPDATE ON prueba.rmaslineas FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Catálogo de tipos de movimiento y motivos
+---------------------------------------------------------------------------------------
+-- Tabla Tipos de movimiento (el efecto indica cómo afecta la existencia)
+CREATE TABLE IF NOT EXISTS catalogos.TiposMovimiento (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    code VARCHAR(20) NOT NULL CHECK (code ~ '^[A-Z][A-Z0-9_]*$'),
+    -- Código usado en prueba.Movimientos.type
+    name VARCHAR(100) NOT NULL,
+    -- Nombre descriptivo
+    effect VARCHAR(20) NOT NULL CHECK (
+        effect IN ('IN', 'OUT', 'TRANSFER', 'RELOCATE', 'ADJUSTMENT')
+    ),
+    -- Entra a la tienda destino, sale de la origen, cambia de tienda,
+    -- cambia de ubicación o ajusta por conteo
+    requiresReason BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Los movimientos deben indicar un motivo del tipo
+    isShrinkage BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Las salidas cuentan como merma
+    isSale BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Las salidas son ventas: cuentan como demanda y costo de lo vendido
+    allowManual BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Se puede registrar con /CrearMovimiento (falso si sólo lo genera un proceso)
+    isSystem BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Tipo base usado por los procesos internos; no se puede desactivar
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+-- Tabla Motivos de movimiento (códigos de motivo por tipo)
+CREATE TABLE IF NOT EXISTS catalogos.MotivosMovimiento (
+    id UUID PRIMARY KEY,
+    -- UUID para identificador único
+    movementTypeId UUID NOT NULL REFERENCES catalogos.TiposMovimiento(id) ON DELETE CASCADE,
+    -- Tipo al que aplica el motivo
+    code VARCHAR(30) NOT NULL CHECK (code ~ '^[A-Z][A-Z0-9_]*$'),
+    -- Código del motivo
+    name VARCHAR(100) NOT NULL,
+    -- Nombre descriptivo
+    --campos default para control
+    activo BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Estado activo/inactivo para borrado lógico
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Fecha de creación
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP -- Fecha de última modificación
+);
+SELECT prueba.habilitar_tenant('catalogos.tiposmovimiento');
+SELECT prueba.habilitar_tenant('catalogos.motivosmovimiento');
+CREATE UNIQUE INDEX idx_tipos_movimiento_codigo ON catalogos.tiposmovimiento(tenantId, code);
+CREATE UNIQUE INDEX idx_motivos_movimiento_codigo ON catalogos.motivosmovimiento(movementTypeId, code);
+-- Triggers para tipos y motivos de movimiento
+CREATE TRIGGER update_tipos_movimiento_updated_at BEFORE
+UPDATE ON catalogos.tiposmovimiento FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_motivos_movimiento_updated_at BEFORE
+UPDATE ON catalogos.motivosmovimiento FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+-- Tipos y motivos base de una empresa; se ejecuta al crearla
+CREATE OR REPLACE FUNCTION catalogos.sembrar_tipos_movimiento(p_tenant UUID) RETURNS VOID AS $$
+INSERT INTO catalogos.tiposmovimiento (
+        id,
+        tenantId,
+        code,
+        name,
+        effect,
+        requiresReason,
+        isShrinkage,
+        isSale,
+        allowManual,
+        isSystem
+    )
+VALUES (gen_random_uuid(), p_tenant, 'IN', 'Entrada', 'IN', false, false, false, true, true),
+    (gen_random_uuid(), p_tenant, 'OUT', 'Salida', 'OUT', false, false, true, true, true),
+    (gen_random_uuid(), p_tenant, 'TRANSFER', 'Transferencia', 'TRANSFER', false, false, false, true, true),
+    (gen_random_uuid(), p_tenant, 'RELOCATE', 'Reubicación', 'RELOCATE', false, false, false, true, true),
+    (gen_random_uuid(), p_tenant, 'ADJUSTMENT', 'Ajuste por conteo', 'ADJUSTMENT', false, true, false, false, true),
+    (gen_random_uuid(), p_tenant, 'WRITE_OFF', 'Baja', 'OUT', true, true, false, true, true),
+    (gen_random_uuid(), p_tenant, 'DAMAGE', 'Daño', 'OUT', true, true, false, true, false),
+    (gen_random_uuid(), p_tenant, 'THEFT', 'Robo', 'OUT', true, true, false, true, false),
+    (gen_random_uuid(), p_tenant, 'SPOILAGE', 'Descomposición', 'OUT', true, true, false, true, false),
+    (gen_random_uuid(), p_tenant, 'RETURN', 'Devolución de cliente', 'IN', true, false, false, true, true),
+    (gen_random_uuid(), p_tenant, 'RETURN_TO_SUPPLIER', 'Devolución a proveedor', 'OUT', true, false, false, true, false)
+ON CONFLICT (tenantId, code) DO NOTHING;
+INSERT INTO catalogos.motivosmovimiento (id, tenantId, movementTypeId, code, name)
+SELECT gen_random_uuid(),
+    p_tenant,
+    t.id,
+    m.code,
+    m.name
+FROM (
+        VALUES ('WRITE_OFF', 'EXPIRED', 'Caducado'),
+            ('WRITE_OFF', 'OBSOLETE', 'Obsoleto'),
+            ('WRITE_OFF', 'QUALITY', 'Fuera de especificación'),
+            ('WRITE_OFF', 'CUSTOMER_RETURN', 'Devolución de cliente no revendible'),
+            ('WRITE_OFF', 'OTHER', 'Otro'),
+            ('DAMAGE', 'BREAKAGE', 'Rotura'),
+            ('DAMAGE', 'HANDLING', 'Manejo en almacén'),
+            ('DAMAGE', 'WATER', 'Humedad o agua'),
+            ('DAMAGE', 'PESTS', 'Plagas'),
+            ('DAMAGE', 'OTHER', 'Otro'),
+            ('THEFT', 'SHOPLIFTING', 'Robo hormiga'),
+            ('THEFT', 'INTERNAL', 'Robo interno'),
+            ('THEFT', 'BURGLARY', 'Asalto o robo con violencia'),
+            ('THEFT', 'OTHER', 'Otro'),
+            ('SPOILAGE', 'EXPIRED', 'Caducado'),
+            ('SPOILAGE', 'TEMPERATURE', 'Falla de refrigeración'),
+            ('SPOILAGE', 'OTHER', 'Otro'),
+            ('RETURN', 'DEFECTIVE', 'Defectuoso'),
+            ('RETURN', 'DAMAGED_IN_TRANSIT', 'Dañado en el envío'),
+            ('RETURN', 'WRONG_ITEM', 'Artículo equivocado'),
+            ('RETURN', 'NOT_AS_DESCRIBED', 'No corresponde a la descripción'),
+            ('RETURN', 'NO_LONGER_NEEDED', 'Ya no lo necesita'),
+            ('RETURN', 'OTHER', 'Otro'),
+            ('RETURN_TO_SUPPLIER', 'DEFECTIVE', 'Defectuoso'),
+            ('RETURN_TO_SUPPLIER', 'RECALL', 'Retiro del fabricante'),
+            ('RETURN_TO_SUPPLIER', 'OVERSTOCK', 'Excedente'),
+            ('RETURN_TO_SUPPLIER', 'OTHER', 'Otro')
+    ) AS m(tipo, code, name)
+    JOIN catalogos.tiposmovimiento t ON t.tenantId = p_tenant
+    AND t.code = m.tipo ON CONFLICT (movementTypeId, code) DO NOTHING;
+$$ LANGUAGE sql;
+SELECT catalogos.sembrar_tipos_movimiento('00000000-0000-0000-0000-000000000001');
+-- El tipo de cada movimiento debe existir en el catálogo de su empresa
+ALTER TABLE prueba.movimientos
+ADD CONSTRAINT fk_movimientos_tipo FOREIGN KEY (tenantId, type) REFERENCES catalogos.tiposmovimiento(tenantId, code);
+-- Motivo del movimiento (requerido en los tipos que lo exigen)
+ALTER TABLE prueba.movimientos
+ADD COLUMN reasonId UUID REFERENCES catalogos.MotivosMovimiento(id) ON DELETE SET NULL;
+CREATE INDEX idx_movimientos_tipo_fecha ON prueba.movimientos(type, timestamp);
+-- Los movimientos afectan la existencia según el efecto de su tipo; se
+-- conserva security_invoker para que la vista respete las políticas por empresa
+CREATE OR REPLACE VIEW prueba.vw_movimientos_tienda WITH (security_invoker = true) AS
+SELECT m.id,
+    m.productId,
+    m.targetStoreId AS storeId,
+    m.type,
+    m.timestamp,
+    m.quantity AS delta,
+    t.effect,
+    t.isShrinkage,
+    t.isSale
+FROM prueba.movimientos m
+    JOIN catalogos.tiposmovimiento t ON t.tenantId = m.tenantId
+    AND t.code = m.type
+WHERE m.activo = true
+    AND t.effect IN ('IN', 'TRANSFER')
+UNION ALL
+SELECT m.id,
+    m.productId,
+    m.sourceStoreId,
+    m.type,
+    m.timestamp,
+    - m.quantity,
+    t.effect,
+    t.isShrinkage,
+    t.isSale
+FROM prueba.movimientos m
+    JOIN catalogos.tiposmovimiento t ON t.tenantId = m.tenantId
+    AND t.code = m.type
+WHERE m.activo = true
+    AND t.effect IN ('OUT', 'TRANSFER')
+UNION ALL
+SELECT m.id,
+    m.productId,
+    m.sourceStoreId,
+    m.type,
+    m.timestamp,
+    m.direction * m.quantity,
+    t.effect,
+    t.isShrinkage,
+    t.isSale
+FROM prueba.movimientos m
+    JOIN catalogos.tiposmovimiento t ON t.tenantId = m.tenantId
+    AND t.code = m.type
+WHERE m.activo = true
+    AND t.effect = 'ADJUSTMENT';
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"go-project/alerts"
+	"go-project/config"
 	_ "go-project/docs"
+	"go-project/events"
 	"go-project/handlers"
+	"go-project/idempotency"
 	"go-project/middleware"
+	"go-project/outbox"
+	"go-project/tenancy"
+	"go-project/utils"
+	"go-project/webhooks"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
@@ -22,8 +33,29 @@ import (
 // @schemes         http
 
 func main() {
+	cfg := config.LoadConfig()
+
+	// main admin-token -subject <quién>: token para /api/admin, sin levantar el servidor
+	if len(os.Args) > 1 && os.Args[1] == "admin-token" {
+		if err := emitirTokenAdmin(os.Args[2:], cfg.JWTSecret, time.Now(), os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Sin JWT_SECRET cualquiera actuaría como administrador de cualquier
+	// empresa: sólo se admite si se pide de forma explícita
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	authSecret := cfg.JWTSecret
+	if cfg.AuthDisabled {
+		authSecret = ""
+	}
+
 	// Configuración de la base de datos
-	db, err := sql.Open("postgres", "host=postgres port=5432 user=root password=root dbname=root sslmode=disable")
+	dsn := "host=postgres port=5432 user=root password=root dbname=root sslmode=disable"
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -34,51 +66,144 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Crear handlers
-	productHandler := handlers.NewProductHandler(db)
-	shopHandler := handlers.NewShopHandler(db)
+	// Handlers de los procesos periódicos: usan la conexión del sistema, que
+	// no está sujeta a las políticas de aislamiento y abarca todas las empresas
 	inventoryHandler := handlers.NewInventoryHandler(db)
-	movementHandler := handlers.NewMovementHandler(db)
+	priceHandler := handlers.NewPriceHandler(db)
+
+	// Escuchar los eventos de inventario que anuncia Postgres
+	hub := events.NewHub(db)
+	detenerEventos, err := hub.Listen(dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer detenerEventos()
+
+	// Detectar alertas de stock y avisar por correo
+	plantillas, err := alerts.LoadTemplates(cfg.AlertTemplatesDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var mailer alerts.Mailer
+	if cfg.SMTPAddr != "" {
+		mailer = &alerts.SMTPMailer{Addr: cfg.SMTPAddr, From: cfg.SMTPFrom,
+			Username: cfg.SMTPUser, Password: cfg.SMTPPassword}
+	} else {
+		log.Println("SMTP_ADDR no configurado: las alertas de stock se registran sin enviar correos")
+	}
+	notificador := alerts.NewNotifier(db, mailer, plantillas)
+	notificador.DigestHour = cfg.AlertDigestHour
+	detenerAlertas := utils.RunEvery(time.Minute, func() {
+		notificador.Run(time.Now())
+	})
+	defer detenerAlertas()
+
+	// Evaluar las reglas de alertas configurables
+	reglas := alerts.NewRuleEngine(db)
+	detenerReglas := utils.RunEvery(15*time.Minute, func() {
+		if _, err := reglas.Evaluate(); err != nil {
+			log.Printf("Error al evaluar reglas de alertas: %v", err)
+		}
+	})
+	defer detenerReglas()
+
+	// Enviar las entregas de webhooks pendientes
+	despachador := webhooks.NewDispatcher(db)
+	detenerWebhooks := utils.RunEvery(5*time.Second, func() {
+		if _, fallidas, err := despachador.DispatchDue(context.Background()); err != nil {
+			log.Printf("Error al despachar webhooks: %v", err)
+		} else if fallidas > 0 {
+			log.Printf("Entregas de webhooks fallidas: %d", fallidas)
+		}
+	})
+	defer detenerWebhooks()
+
+	// Publicar los eventos de dominio de la bandeja de salida en el broker
+	if cfg.BrokerURL != "" {
+		publicador, err := outbox.NewNATSPublisher(cfg.BrokerURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		publicador.Stream = "INVENTARIO"
+		defer publicador.Close()
+
+		relevo := outbox.NewRelay(db, publicador)
+		detenerOutbox := utils.RunEvery(2*time.Second, func() {
+			if _, err := relevo.PublishPending(context.Background()); err != nil {
+				log.Printf("Error al publicar eventos de dominio: %v", err)
+			}
+		})
+		defer detenerOutbox()
+	} else {
+		log.Println("BROKER_URL no configurado: los eventos de dominio quedan en la bandeja de salida")
+	}
+
+	// Fotografiar las existencias al cierre de cada día
+	detenerSnapshots := utils.RunEvery(time.Hour, func() {
+		if n, err := inventoryHandler.MaterializarSnapshots(); err != nil {
+			log.Printf("Error al materializar fotografías de existencias: %v", err)
+		} else if n > 0 {
+			log.Printf("Fotografías de existencias materializadas: %d", n)
+		}
+	})
+	defer detenerSnapshots()
+
+	// Activar precios programados al llegar su fecha de vigencia
+	detenerPrecios := utils.RunEvery(time.Minute, func() {
+		if n, err := priceHandler.ActivarPreciosProgramados(); err != nil {
+			log.Printf("Error al activar precios programados: %v", err)
+		} else if n > 0 {
+			log.Printf("Precios programados activados: %d", n)
+		}
+	})
+	defer detenerPrecios()
+
+	// Liberar las claves de idempotencia vencidas
+	idempotencyTTL := time.Duration(cfg.IdempotencyTTLHours) * time.Hour
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = idempotency.DefaultTTL
+	}
+	detenerIdempotencia := utils.RunEvery(time.Hour, func() {
+		if _, err := idempotency.Purge(db); err != nil {
+			log.Printf("Error al eliminar claves de idempotencia vencidas: %v", err)
+		}
+	})
+	defer detenerIdempotencia()
+
+	// Cada empresa opera con su propio pool del rol de la aplicación
+	tenantDSN := fmt.Sprintf("host=postgres port=5432 user=%s password=%s dbname=root sslmode=disable",
+		cfg.DBTenantUser, cfg.DBTenantPassword)
+	empresas := tenancy.NewRouter(db, tenantDSN, func(tdb *sql.DB) http.Handler {
+		return rutasEmpresa(tdb, hub, idempotencyTTL)
+	})
+	defer empresas.Close()
+	tenantHandler := handlers.NewTenantHandler(db, cfg.JWTSecret, empresas)
+
+	if cfg.AuthDisabled {
+		log.Println("AUTH_DISABLED=true: la API no exige token y la empresa se toma de X-Tenant-ID")
+	}
+
 	// Configurar rutas
 	r := mux.NewRouter() // Usamos mux.NewRouter()
 
 	// Ruta para la documentación Swagger
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
-	// Rutas de la API Productos
-	r.HandleFunc("/api/ListarProductos", productHandler.ListarProductos)
-	r.HandleFunc("/api/CrearProducto", productHandler.CrearProducto)
-	r.HandleFunc("/api/ObtenerProducto", productHandler.ObtenerProducto)
-	r.HandleFunc("/api/ActualizarProducto", productHandler.ActualizarProducto)
-	r.HandleFunc("/api/ActivarDesactivarProducto", productHandler.ToggleProductoEstado)
-	r.HandleFunc("/api/EliminarProducto", productHandler.EliminarProducto)
-
-	// Rutas de la API Tiendas
-	r.HandleFunc("/api/ListarTiendas", shopHandler.ListarTiendas)
-	r.HandleFunc("/api/CrearTiendas", shopHandler.CrearTienda)
-	r.HandleFunc("/api/ObtenerTiendas", shopHandler.ObtenerTienda)
-	r.HandleFunc("/api/ActualizarTiendas", shopHandler.ActualizarTienda)
-	r.HandleFunc("/api/ActivarDesactivarTiendas", shopHandler.ToggleTiendaEstado)
-	r.HandleFunc("/api/EliminarTiendas", shopHandler.EliminarTienda)
-
-	// Rutas de la API Inventarios
-	r.HandleFunc("/api/ListarInventarios", inventoryHandler.ListarInventarios)
-	r.HandleFunc("/api/CrearInventario", inventoryHandler.CrearInventario)
-	r.HandleFunc("/api/ObtenerInventario", inventoryHandler.ObtenerInventario)
-	r.HandleFunc("/api/ActualizarInventario", inventoryHandler.ActualizarInventario)
-	r.HandleFunc("/api/EliminarInventario", inventoryHandler.EliminarInventario)
-
-	// Rutas de la API Movimientos
-	r.HandleFunc("/api/ListarMovimientos", movementHandler.ListarMovimientos)
-	r.HandleFunc("/api/CrearMovimiento", movementHandler.CrearMovimiento)
-	r.HandleFunc("/api/ObtenerMovimiento", movementHandler.ObtenerMovimiento)
-	// Rutas de la API Operacion
-	r.HandleFunc("/api/stores/{id}/inventory", inventoryHandler.GetStoreInventory)
-	r.HandleFunc("/api/inventory/transfer", inventoryHandler.TransferInventory)
-	r.HandleFunc("/api/inventory/alerts", inventoryHandler.GetStockAlerts)
-
-	// Aplicar middleware CORS
-	handler := middleware.CORSMiddleware(r)
+	// Rutas de la API Empresas (administración del despliegue)
+	admin := r.PathPrefix("/api/admin").Subrouter()
+	admin.Use(func(next http.Handler) http.Handler {
+		return middleware.RequireRole(tenancy.RoleAdmin, next)
+	})
+	admin.HandleFunc("/ListarTenants", tenantHandler.ListarTenants)
+	admin.HandleFunc("/CrearTenant", tenantHandler.CrearTenant)
+	admin.HandleFunc("/ActivarDesactivarTenant", tenantHandler.ActivarDesactivarTenant)
+	admin.HandleFunc("/tenants/{id}/token", tenantHandler.IssueTenantToken)
+
+	// El resto de la API se atiende con los handlers de la empresa de la petición
+	r.PathPrefix("/").Handler(empresas)
+
+	// Aplicar middleware de autenticación y CORS
+	handler := middleware.CORSMiddleware(middleware.AuthMiddleware(authSecret, r))
 
 	// Iniciar servidor
 	fmt.Println("Servidor iniciado en http://localhost:8080")
@@ -7,7 +7,8 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		// Configurar headers CORS
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, If-None-Match, X-Tenant-ID, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Content-Disposition, Idempotent-Replayed")
 
 		// Manejar pre-flight requests
 		if r.Method == "OPTIONS" {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"go-project/money"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,10 +16,14 @@ type Producto struct {
 	Description string `json:"description" example:"Laptop HP Pavilion con procesador Intel i5"`
 	// Categoría del producto
 	Category string `json:"category" example:"Electrónicos"`
-	// Precio del producto
-	Price float64 `json:"price" example:"12999.99"`
+	// Precio del producto con su moneda
+	Price money.Money `json:"price"`
 	// SKU único del producto
 	SKU string `json:"sku" example:"LAP-HP-001"`
+	// Indica si el producto controla existencias por lote y caducidad
+	TrackLots bool `json:"track_lots" example:"false"`
+	// Indica si el producto controla cada unidad por número de serie
+	TrackSerials bool `json:"track_serials" example:"false"`
 	// Indica si el producto está activo
 	Activo bool `json:"activo" example:"true"`
 	// Fecha de creación del registro
@@ -13,13 +13,14 @@ type Repository struct {
 // ---------------------------------------------------------------------------------------------------------------------------
 func (r *Repository) CreateProduct(p *Producto) error {
 	query := `
-        INSERT INTO catalogos.productos (id, name, description, category, price, sku)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO catalogos.productos (id, name, description, category, price, currency, sku, track_lots, track_serials)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
         RETURNING id`
 
 	return r.db.QueryRow(
 		query,
-		p.ID, p.Name, p.Description, p.Category, p.Price, p.SKU,
+		p.ID, p.Name, p.Description, p.Category, p.Price.Amount, p.Price.Currency, p.SKU,
+		p.TrackLots, p.TrackSerials,
 	).Scan(&p.ID)
 }
 
@@ -27,10 +28,12 @@ func (r *Repository) CreateProduct(p *Producto) error {
 func (r *Repository) UpdateProduct(p *Producto) error {
 	query := `
         UPDATE catalogos.productos 
-        SET name = $2, description = $3, category = $4, price = $5, sku = $6, updated_at = CURRENT_TIMESTAMP
+        SET name = $2, description = $3, category = $4, price = $5, currency = $6, sku = $7,
+            track_lots = $8, track_serials = $9, updated_at = CURRENT_TIMESTAMP
         WHERE id = $1 AND activo = true`
 
-	result, err := r.db.Exec(query, p.ID, p.Name, p.Description, p.Category, p.Price, p.SKU)
+	result, err := r.db.Exec(query, p.ID, p.Name, p.Description, p.Category,
+		p.Price.Amount, p.Price.Currency, p.SKU, p.TrackLots, p.TrackSerials)
 	if err != nil {
 		return err
 	}
@@ -78,7 +81,7 @@ func NewRepository(db *sql.DB) *Repository {
 
 func (r *Repository) GetAllProductos() ([]Producto, error) {
 	query := `
-        SELECT id, name, description, category, price, sku, 
+        SELECT id, name, description, category, price, currency, sku, track_lots, track_serials,
                activo, created_at, updated_at
         FROM catalogos.productos 
         WHERE activo = true`
@@ -94,7 +97,7 @@ func (r *Repository) GetAllProductos() ([]Producto, error) {
 		var p Producto
 		err := rows.Scan(
 			&p.ID, &p.Name, &p.Description, &p.Category,
-			&p.Price, &p.SKU, &p.Activo, &p.CreatedAt, &p.UpdatedAt)
+			&p.Price.Amount, &p.Price.Currency, &p.SKU, &p.TrackLots, &p.TrackSerials, &p.Activo, &p.CreatedAt, &p.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
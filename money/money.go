@@ -0,0 +1,317 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency moneda usada cuando no se indica una explícitamente
+const DefaultCurrency = "MXN"
+
+var (
+	// ErrMonedaInvalida código de moneda que no tiene la forma ISO 4217
+	ErrMonedaInvalida = errors.New("moneda inválida")
+	// ErrMonedasDistintas operación entre importes de monedas distintas
+	ErrMonedasDistintas = errors.New("operación entre monedas distintas")
+)
+
+// ValidarMoneda revisa que el código tenga la forma ISO 4217: tres letras
+// mayúsculas
+func ValidarMoneda(currency string) error {
+	if len(currency) != 3 {
+		return fmt.Errorf("%w: %q, use un código ISO 4217 de tres letras mayúsculas", ErrMonedaInvalida, currency)
+	}
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return fmt.Errorf("%w: %q, use un código ISO 4217 de tres letras mayúsculas", ErrMonedaInvalida, currency)
+		}
+	}
+	return nil
+}
+
+// Decimal cantidad exacta con dos decimales, almacenada en centésimos para
+// evitar los errores de redondeo de float64. Corresponde a DECIMAL(p, 2).
+type Decimal int64
+
+// ParseDecimal interpreta una cantidad decimal como "12999.99" sin pasar por
+// punto flotante. Se rechazan más de dos decimales significativos.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("cantidad vacía")
+	}
+
+	negativo := false
+	switch s[0] {
+	case '-':
+		negativo = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	entero, fraccion, _ := strings.Cut(s, ".")
+	if entero == "" && fraccion == "" {
+		return 0, fmt.Errorf("cantidad inválida")
+	}
+	fraccion = strings.TrimRight(fraccion, "0")
+	if len(fraccion) > 2 {
+		return 0, fmt.Errorf("la cantidad %q tiene más de dos decimales", s)
+	}
+	fraccion += strings.Repeat("0", 2-len(fraccion))
+	if entero == "" {
+		entero = "0"
+	}
+
+	for _, c := range entero + fraccion {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("cantidad inválida: %q", s)
+		}
+	}
+
+	centesimos, err := strconv.ParseInt(entero+fraccion, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cantidad fuera de rango: %q", s)
+	}
+	if negativo {
+		centesimos = -centesimos
+	}
+	return Decimal(centesimos), nil
+}
+
+// MustParseDecimal igual que ParseDecimal pero entra en pánico ante un error
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// String devuelve la cantidad con exactamente dos decimales
+func (d Decimal) String() string {
+	signo := ""
+	v := int64(d)
+	if v < 0 {
+		signo = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", signo, v/100, v%100)
+}
+
+// Mul multiplica la cantidad por un entero (por ejemplo, unidades)
+func (d Decimal) Mul(n int64) Decimal {
+	return Decimal(int64(d) * n)
+}
+
+// DivRound divide la cantidad entre un entero redondeando al centésimo más
+// cercano (las mitades se alejan de cero)
+func (d Decimal) DivRound(n int64) Decimal {
+	if n == 0 {
+		return 0
+	}
+	v := int64(d)
+	if (v < 0) != (n < 0) {
+		return Decimal((v - n/2) / n)
+	}
+	return Decimal((v + n/2) / n)
+}
+
+// MarshalJSON serializa la cantidad como cadena para conservar la precisión
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON acepta la cantidad como cadena ("12999.99") o como número
+// literal (12999.99); en ambos casos se interpreta el texto exacto
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	texto := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &texto); err != nil {
+			return err
+		}
+	}
+
+	v, err := ParseDecimal(texto)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Scan implementa sql.Scanner para leer columnas NUMERIC sin pérdida
+func (d *Decimal) Scan(src interface{}) error {
+	var err error
+	switch v := src.(type) {
+	case nil:
+		*d = 0
+	case []byte:
+		*d, err = ParseDecimal(string(v))
+	case string:
+		*d, err = ParseDecimal(v)
+	case int64:
+		*d = Decimal(v * 100)
+	case float64:
+		*d, err = ParseDecimal(strconv.FormatFloat(v, 'f', 2, 64))
+	default:
+		err = fmt.Errorf("no se puede convertir %T a Decimal", src)
+	}
+	return err
+}
+
+// Value implementa driver.Valuer enviando la cantidad como texto a NUMERIC
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Money importe exacto con su código de moneda ISO 4217
+// @Description Importe exacto con moneda
+type Money struct {
+	Amount   Decimal `json:"amount" swaggertype:"string" example:"12999.99"`
+	Currency string  `json:"currency" example:"MXN"`
+}
+
+// New crea un importe; si no se indica moneda se usa DefaultCurrency
+func New(amount Decimal, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse interpreta un importe en texto con la moneda indicada
+func Parse(s, currency string) (Money, error) {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(d, currency), nil
+}
+
+// MustParse igual que Parse pero entra en pánico ante un error
+func MustParse(s, currency string) Money {
+	m, err := Parse(s, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// String devuelve el importe seguido de su moneda, por ejemplo "12999.99 MXN"
+func (m Money) String() string {
+	return m.Amount.String() + " " + m.Currency
+}
+
+// IsNegative indica si el importe es menor que cero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Equal indica si dos importes tienen la misma cantidad y moneda
+func (m Money) Equal(o Money) bool {
+	return m.Amount == o.Amount && m.Currency == o.Currency
+}
+
+// Add suma dos importes de la misma moneda. Un importe sin moneda (valor cero)
+// adopta la del otro operando, lo que permite acumular desde Money{}. Entra en
+// pánico si las monedas difieren; con importes que vienen de la solicitud o
+// de la base de datos use TryAdd.
+func (m Money) Add(o Money) Money {
+	r, err := m.TryAdd(o)
+	if err != nil {
+		panic("money: " + err.Error())
+	}
+	return r
+}
+
+// Sub resta dos importes de la misma moneda; entra en pánico si difieren
+func (m Money) Sub(o Money) Money {
+	r, err := m.TrySub(o)
+	if err != nil {
+		panic("money: " + err.Error())
+	}
+	return r
+}
+
+// TryAdd igual que Add pero devuelve ErrMonedasDistintas en lugar de entrar
+// en pánico
+func (m Money) TryAdd(o Money) (Money, error) {
+	currency, err := mismaMoneda(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: currency}, nil
+}
+
+// TrySub igual que Sub pero devuelve ErrMonedasDistintas en lugar de entrar
+// en pánico
+func (m Money) TrySub(o Money) (Money, error) {
+	currency, err := mismaMoneda(m, o)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: currency}, nil
+}
+
+// Mul multiplica el importe por una cantidad de unidades
+func (m Money) Mul(n int64) Money {
+	return Money{Amount: m.Amount.Mul(n), Currency: m.Currency}
+}
+
+// DivRound divide el importe entre una cantidad de unidades redondeando al centésimo
+func (m Money) DivRound(n int64) Money {
+	return Money{Amount: m.Amount.DivRound(n), Currency: m.Currency}
+}
+
+// UnmarshalJSON acepta el objeto {"amount": "...", "currency": "..."} y, por
+// compatibilidad, una cantidad sola como número o cadena en DefaultCurrency.
+// La moneda indicada debe ser un código ISO 4217 válido.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		type alias Money
+		var a alias
+		if err := json.Unmarshal(data, &a); err != nil {
+			return err
+		}
+		if a.Currency != "" {
+			if err := ValidarMoneda(a.Currency); err != nil {
+				return err
+			}
+		}
+		*m = New(a.Amount, a.Currency)
+		return nil
+	}
+
+	var d Decimal
+	if err := d.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*m = New(d, "")
+	return nil
+}
+
+func mismaMoneda(a, b Money) (string, error) {
+	switch {
+	case a.Currency == "":
+		return b.Currency, nil
+	case b.Currency == "" || a.Currency == b.Currency:
+		return a.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s y %s", ErrMonedasDistintas, a.Currency, b.Currency)
+}
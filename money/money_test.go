@@ -0,0 +1,120 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	casos := []struct {
+		entrada  string
+		esperado Decimal
+		invalido bool
+	}{
+		{"12999.99", 1299999, false},
+		{"0.1", 10, false},
+		{".5", 50, false},
+		{"-4.05", -405, false},
+		{"100", 10000, false},
+		{"1.230", 123, false},
+		{"1.234", 0, true},
+		{"1e3", 0, true},
+		{"", 0, true},
+	}
+
+	for _, c := range casos {
+		d, err := ParseDecimal(c.entrada)
+		if c.invalido {
+			if err == nil {
+				t.Errorf("ParseDecimal(%q) debía fallar", c.entrada)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDecimal(%q): %v", c.entrada, err)
+			continue
+		}
+		if d != c.esperado {
+			t.Errorf("ParseDecimal(%q) = %d, se esperaba %d", c.entrada, d, c.esperado)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	m := MustParse("12999.99", "MXN")
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Error al serializar: %v", err)
+	}
+	if string(data) != `{"amount":"12999.99","currency":"MXN"}` {
+		t.Errorf("Serialización inesperada: %s", data)
+	}
+
+	entradas := []string{
+		`{"amount":"12999.99","currency":"MXN"}`,
+		`{"amount":12999.99}`,
+		`12999.99`,
+		`"12999.99"`,
+	}
+	for _, entrada := range entradas {
+		var leido Money
+		if err := json.Unmarshal([]byte(entrada), &leido); err != nil {
+			t.Errorf("Error al leer %s: %v", entrada, err)
+			continue
+		}
+		if !leido.Equal(m) {
+			t.Errorf("Al leer %s se obtuvo %s, se esperaba %s", entrada, leido, m)
+		}
+	}
+
+	for _, entrada := range []string{
+		`{"amount":"1","currency":"mxn"}`,
+		`{"amount":"1","currency":"PESOS"}`,
+		`{"amount":"1","currency":"M1N"}`,
+	} {
+		var leido Money
+		if err := json.Unmarshal([]byte(entrada), &leido); !errors.Is(err, ErrMonedaInvalida) {
+			t.Errorf("Al leer %s: err = %v, se esperaba ErrMonedaInvalida", entrada, err)
+		}
+	}
+}
+
+func TestMoneyAritmetica(t *testing.T) {
+	total := Money{}
+	total = total.Add(MustParse("0.10", "MXN").Mul(3))
+	total = total.Add(MustParse("0.20", "MXN"))
+	if total.String() != "0.50 MXN" {
+		t.Errorf("Suma inesperada: %s", total)
+	}
+
+	if _, err := total.TryAdd(MustParse("1.00", "USD")); !errors.Is(err, ErrMonedasDistintas) {
+		t.Errorf("TryAdd entre monedas distintas: err = %v", err)
+	}
+	if _, err := total.TrySub(MustParse("1.00", "USD")); !errors.Is(err, ErrMonedasDistintas) {
+		t.Errorf("TrySub entre monedas distintas: err = %v", err)
+	}
+
+	if got := MustParse("10.00", "MXN").DivRound(3).Amount; got != 333 {
+		t.Errorf("DivRound(3) = %d, se esperaba 333", got)
+	}
+	if got := MustParse("-0.05", "MXN").DivRound(2).Amount; got != -3 {
+		t.Errorf("DivRound con negativo = %d, se esperaba -3", got)
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	var d Decimal
+	if err := d.Scan([]byte("4599.99")); err != nil {
+		t.Fatalf("Error al leer NUMERIC: %v", err)
+	}
+	if d != 459999 {
+		t.Errorf("Scan = %d, se esperaba 459999", d)
+	}
+
+	v, err := d.Value()
+	if err != nil || v != "4599.99" {
+		t.Errorf("Value = %v (%v), se esperaba 4599.99", v, err)
+	}
+}
@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryPublisher publicador en proceso: guarda los mensajes y opcionalmente
+// los entrega a un manejador. Sirve para pruebas y para consumir los eventos
+// dentro de la misma aplicación.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	mensajes []Message
+	// Handler, si se define, recibe cada mensaje; un error equivale a que el
+	// broker rechazó el mensaje
+	Handler func(Message) error
+}
+
+// NewMemoryPublisher crea un publicador en proceso
+func NewMemoryPublisher(handler func(Message) error) *MemoryPublisher {
+	return &MemoryPublisher{Handler: handler}
+}
+
+// Publish entrega el mensaje al manejador y lo guarda si fue aceptado
+func (p *MemoryPublisher) Publish(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if p.Handler != nil {
+		if err := p.Handler(m); err != nil {
+			return err
+		}
+	}
+	p.mu.Lock()
+	p.mensajes = append(p.mensajes, m)
+	p.mu.Unlock()
+	return nil
+}
+
+// Messages mensajes publicados hasta el momento
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Message(nil), p.mensajes...)
+}
+
+// Close no hace nada
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSPublisher publica en NATS JetStream con el cliente oficial. Cada
+// mensaje espera la confirmación del stream, lo que da la entrega al menos
+// una vez. El encabezado Nats-Msg-Id lleva el ID del evento para que el
+// stream descarte los duplicados dentro de su ventana. Se requiere un stream
+// que capture los subjects "inventario.>".
+type NATSPublisher struct {
+	nc *nats.Conn
+	js jetstream.JetStream
+	// Timeout espera máxima por la confirmación de cada mensaje
+	Timeout time.Duration
+	// Stream, si se define, se crea en el primer envío cuando aún no existe
+	Stream string
+
+	mu          sync.Mutex
+	streamListo bool
+}
+
+// NewNATSPublisher crea un publicador para una URL nats://[usuario:clave@]host:puerto.
+// Si el servidor no está disponible el cliente reintenta la conexión en
+// segundo plano y mientras tanto los envíos fallan con ErrUnavailable.
+func NewNATSPublisher(rawURL string) (*NATSPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+		return nil, fmt.Errorf("URL de NATS inválida: %q", rawURL)
+	}
+	nc, err := nats.Connect(rawURL, nats.Name("go-project-outbox"),
+		nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &NATSPublisher{nc: nc, js: js, Timeout: 5 * time.Second}, nil
+}
+
+// Publish envía el mensaje y espera la confirmación de JetStream
+func (p *NATSPublisher) Publish(ctx context.Context, m Message) error {
+	if !p.nc.IsConnected() {
+		return fmt.Errorf("%w: sin conexión con NATS (%s)", ErrUnavailable, p.nc.Status())
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	if err := p.crearStream(ctx); err != nil {
+		return err
+	}
+
+	cuerpo, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(m.Subject())
+	msg.Data = cuerpo
+	// Tenant-Id permite a los consumidores enrutar por empresa sin leer el cuerpo
+	msg.Header.Set("Tenant-Id", m.TenantID.String())
+	_, err = p.js.PublishMsg(ctx, msg, jetstream.WithMsgID(strconv.FormatInt(m.ID, 10)))
+
+	// Sólo un rechazo explícito de JetStream es propio del mensaje; cualquier
+	// otro error (tiempo agotado, sin stream, conexión perdida) es del broker
+	var rechazo *jetstream.APIError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &rechazo):
+		return fmt.Errorf("JetStream rechazó el mensaje (%d): %s", rechazo.Code, rechazo.Description)
+	default:
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+}
+
+// crearStream crea el stream que captura los subjects del outbox, con una
+// ventana de duplicados para descartar los reenvíos por Nats-Msg-Id
+func (p *NATSPublisher) crearStream(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Stream == "" || p.streamListo {
+		return nil
+	}
+	_, err := p.js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:       p.Stream,
+		Subjects:   []string{SubjectPrefix + ">"},
+		Storage:    jetstream.FileStorage,
+		Duplicates: 2 * time.Minute,
+	})
+	if err != nil && !errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+		return fmt.Errorf("%w: no se pudo crear el stream %s: %v", ErrUnavailable, p.Stream, err)
+	}
+	p.streamListo = true
+	return nil
+}
+
+// Close cierra la conexión
+func (p *NATSPublisher) Close() error {
+	p.nc.Close()
+	return nil
+}
@@ -0,0 +1,200 @@
+// Package outbox publica en un broker de mensajes los eventos de dominio
+// registrados en prueba.outbox. Los eventos se escriben en la misma
+// transacción que el cambio, por triggers o con Record, y un relevo los
+// publica en orden confirmando cada uno: la entrega es al menos una vez y
+// los eventos de un mismo agregado nunca se adelantan entre sí.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project/utils"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Message evento de dominio pendiente de publicar
+type Message struct {
+	ID            int64           `json:"id"`
+	TenantID      uuid.UUID       `json:"tenant_id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	TxID          int64           `json:"tx_id"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Prefijo de los subjects/tópicos publicados
+const SubjectPrefix = "inventario."
+
+// Subject subject (NATS) o tópico (Kafka) del mensaje, p. ej. inventario.product.updated
+func (m Message) Subject() string {
+	return SubjectPrefix + m.Type
+}
+
+// ErrUnavailable indica que el broker no está disponible; el relevo deja el
+// resto del lote para el siguiente intento en lugar de probar mensaje por mensaje
+var ErrUnavailable = errors.New("broker no disponible")
+
+// Publisher envía mensajes a un broker. Publish debe volver sólo cuando el
+// broker confirmó la recepción; un error provoca el reintento del mensaje.
+type Publisher interface {
+	Publish(ctx context.Context, m Message) error
+	Close() error
+}
+
+// Record registra un evento de aplicación en la bandeja de salida; debe
+// llamarse con la transacción del cambio (por ejemplo dentro de
+// utils.WithTransaction) para que se publique sólo si el cambio se confirma.
+// La empresa la toma la base de datos de la conexión de la petición.
+func Record(q utils.Querier, aggregateType string, aggregateID uuid.UUID, eventType string, payload interface{}) error {
+	datos, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(`
+        INSERT INTO prueba.outbox (aggregateType, aggregateId, eventType, payload)
+        VALUES ($1, $2, $3, $4)
+    `, aggregateType, aggregateID, eventType, datos)
+	return err
+}
+
+// Llave del candado consultivo con el que las réplicas se turnan para tomar lotes
+const relayLockKey = 7310401
+
+// Tiempo que un relevo tiene para publicar el lote que tomó; si no lo libera
+// antes (por ejemplo si la réplica se detuvo) otro relevo lo vuelve a tomar
+const plazoLote = time.Minute
+
+// Relay publica los eventos pendientes de la bandeja de salida
+type Relay struct {
+	db        *sql.DB
+	publisher Publisher
+	BatchSize int
+}
+
+// NewRelay crea un relevo hacia el publicador indicado
+func NewRelay(db *sql.DB, publisher Publisher) *Relay {
+	return &Relay{db: db, publisher: publisher, BatchSize: 100}
+}
+
+// PublishPending publica un lote de eventos pendientes y devuelve cuántos se
+// confirmaron. El lote se toma y se marca en transacciones cortas; la
+// publicación ocurre fuera de ellas. Si otra réplica tiene un lote vigente no
+// hace nada, para no adelantar eventos de un mismo agregado.
+func (r *Relay) PublishPending(ctx context.Context) (int, error) {
+	lote, err := r.tomarLote()
+	if err != nil || len(lote) == 0 {
+		return 0, err
+	}
+
+	// Terminar antes de que venza el lote para no publicar en paralelo con
+	// otro relevo que lo retome
+	ctx, cancel := context.WithTimeout(ctx, plazoLote/2)
+	defer cancel()
+	confirmados, fallidos := publishInOrder(ctx, r.publisher, lote)
+
+	ids := make([]int64, len(lote))
+	for i, m := range lote {
+		ids[i] = m.ID
+	}
+	err = utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+            UPDATE prueba.outbox SET publishedAt = CURRENT_TIMESTAMP, lastError = NULL, claimedUntil = NULL
+            WHERE id = ANY($1)
+        `, pq.Array(confirmados)); err != nil {
+			return err
+		}
+		for id, errPub := range fallidos {
+			if _, err := tx.Exec(`
+                UPDATE prueba.outbox SET attempts = attempts + 1, lastError = $2 WHERE id = $1
+            `, id, errPub.Error()); err != nil {
+				return err
+			}
+		}
+		// Liberar el lote: lo no publicado se reintenta en el siguiente
+		_, err := tx.Exec(`UPDATE prueba.outbox SET claimedUntil = NULL WHERE id = ANY($1)`, pq.Array(ids))
+		return err
+	})
+	return len(confirmados), err
+}
+
+// tomarLote reserva los eventos pendientes más antiguos durante plazoLote,
+// salvo que otro relevo tenga un lote vigente
+func (r *Relay) tomarLote() ([]Message, error) {
+	var lote []Message
+	err := utils.WithTransaction(r.db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, relayLockKey); err != nil {
+			return err
+		}
+		var ocupado bool
+		if err := tx.QueryRow(`
+            SELECT EXISTS(SELECT 1 FROM prueba.outbox
+                          WHERE publishedAt IS NULL AND claimedUntil > CURRENT_TIMESTAMP)
+        `).Scan(&ocupado); err != nil || ocupado {
+			return err
+		}
+
+		rows, err := tx.Query(`
+            UPDATE prueba.outbox SET claimedUntil = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+            WHERE id IN (SELECT id FROM prueba.outbox WHERE publishedAt IS NULL ORDER BY id LIMIT $1)
+            RETURNING id, tenantId, aggregateType, aggregateId, eventType, payload, txId, created_at
+        `, r.BatchSize, plazoLote.Seconds())
+		if err != nil {
+			return err
+		}
+		lote, err = escanearMensajes(rows)
+		return err
+	})
+	// RETURNING no garantiza el orden
+	sort.Slice(lote, func(i, j int) bool { return lote[i].ID < lote[j].ID })
+	return lote, err
+}
+
+func escanearMensajes(rows *sql.Rows) ([]Message, error) {
+	defer rows.Close()
+	var mensajes []Message
+	for rows.Next() {
+		var m Message
+		var payload []byte
+		if err := rows.Scan(&m.ID, &m.TenantID, &m.AggregateType, &m.AggregateID, &m.Type, &payload, &m.TxID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		m.Payload = payload
+		mensajes = append(mensajes, m)
+	}
+	return mensajes, rows.Err()
+}
+
+// publishInOrder publica los mensajes en el orden recibido. Cuando uno falla,
+// los siguientes del mismo agregado se omiten para no adelantarlos; se
+// reintentan juntos en el próximo lote. Devuelve los IDs confirmados y el
+// error de cada mensaje que falló.
+func publishInOrder(ctx context.Context, p Publisher, mensajes []Message) ([]int64, map[int64]error) {
+	var confirmados []int64
+	fallidos := make(map[int64]error)
+	bloqueados := make(map[string]bool)
+	for _, m := range mensajes {
+		agregado := m.AggregateType + ":" + m.AggregateID.String()
+		if bloqueados[agregado] {
+			continue
+		}
+		if err := p.Publish(ctx, m); err != nil {
+			fallidos[m.ID] = fmt.Errorf("publicar %s: %w", m.Subject(), err)
+			if errors.Is(err, ErrUnavailable) || ctx.Err() != nil {
+				break
+			}
+			bloqueados[agregado] = true
+			continue
+		}
+		confirmados = append(confirmados, m.ID)
+	}
+	return confirmados, fallidos
+}
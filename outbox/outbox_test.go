@@ -0,0 +1,164 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublishInOrder(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	mensajes := []Message{
+		{ID: 1, AggregateType: "product", AggregateID: a, Type: "product.updated"},
+		{ID: 2, AggregateType: "product", AggregateID: b, Type: "product.updated"},
+		{ID: 3, AggregateType: "product", AggregateID: a, Type: "product.updated"},
+		{ID: 4, AggregateType: "product", AggregateID: b, Type: "product.deleted"},
+	}
+
+	// El broker rechaza el primer evento de b: el segundo no debe adelantarse
+	p := NewMemoryPublisher(func(m Message) error {
+		if m.ID == 2 {
+			return errors.New("rechazado")
+		}
+		return nil
+	})
+	confirmados, fallidos := publishInOrder(context.Background(), p, mensajes)
+
+	if fmt.Sprint(confirmados) != "[1 3]" {
+		t.Errorf("confirmados = %v, se esperaba [1 3]", confirmados)
+	}
+	if len(fallidos) != 1 || fallidos[2] == nil {
+		t.Errorf("fallidos = %v, se esperaba sólo el 2", fallidos)
+	}
+	if n := len(p.Messages()); n != 2 {
+		t.Errorf("publicados %d, se esperaban 2", n)
+	}
+}
+
+func TestPublishInOrderBrokerCaido(t *testing.T) {
+	llamadas := 0
+	p := NewMemoryPublisher(func(m Message) error {
+		llamadas++
+		return ErrUnavailable
+	})
+	mensajes := []Message{{ID: 1, AggregateID: uuid.New()}, {ID: 2, AggregateID: uuid.New()}}
+	confirmados, fallidos := publishInOrder(context.Background(), p, mensajes)
+	if llamadas != 1 || len(confirmados) != 0 || len(fallidos) != 1 {
+		t.Errorf("llamadas=%d confirmados=%v fallidos=%v", llamadas, confirmados, fallidos)
+	}
+}
+
+// servidorNATS simula un servidor NATS con JetStream: confirma cada
+// publicación respondiendo a su bandeja, o devuelve el error indicado para el
+// subject
+func servidorNATS(t *testing.T, errores map[string]string) (addr string, recibidos chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	recibidos = make(chan string, 10)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "INFO {\"server_id\":\"prueba\",\"headers\":true,\"max_payload\":1048576,\"proto\":1}\r\n")
+		seq := 0
+		for {
+			linea, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			campos := strings.Fields(linea)
+			if len(campos) == 0 {
+				continue
+			}
+			switch campos[0] {
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PUB", "HPUB":
+				// PUB <subject> <reply> <bytes> o HPUB <subject> <reply> <hdr> <total>
+				total, _ := strconv.Atoi(campos[len(campos)-1])
+				datos := make([]byte, total+2)
+				io.ReadFull(r, datos)
+				hdr := 0
+				if campos[0] == "HPUB" {
+					hdr, _ = strconv.Atoi(campos[3])
+				}
+				recibidos <- campos[1] + " " + strings.TrimSpace(string(datos[:hdr]))
+
+				seq++
+				ack := fmt.Sprintf(`{"stream":"INVENTARIO","seq":%d}`, seq)
+				if e, ok := errores[campos[1]]; ok {
+					ack = e
+				}
+				// Un PING intermedio no debe confundir al cliente
+				fmt.Fprintf(conn, "PING\r\nMSG %s 1 %d\r\n%s\r\n", campos[2], len(ack), ack)
+			}
+		}
+	}()
+	return ln.Addr().String(), recibidos
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, recibidos := servidorNATS(t, map[string]string{
+		"inventario.store.deleted": `{"error":{"code":400,"err_code":10060,"description":"expected stream does not match"}}`,
+	})
+	p, err := NewNATSPublisher("nats://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.Stream = "INVENTARIO"
+
+	m := Message{ID: 42, AggregateType: "product", AggregateID: uuid.New(), Type: "product.created", Payload: []byte(`{}`)}
+	if err := p.Publish(context.Background(), m); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if got := <-recibidos; !strings.HasPrefix(got, "$JS.API.STREAM.CREATE.INVENTARIO") {
+		t.Errorf("se esperaba la creación del stream, se recibió %q", got)
+	}
+	if got := <-recibidos; !strings.HasPrefix(got, "inventario.product.created NATS/1.0") || !strings.Contains(got, "Nats-Msg-Id: 42") {
+		t.Errorf("HPUB recibido: %q", got)
+	}
+
+	m.ID, m.Type = 43, "store.deleted"
+	err = p.Publish(context.Background(), m)
+	if err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("se esperaba rechazo de JetStream, se obtuvo %v", err)
+	}
+}
+
+func TestNATSPublisherSinServidor(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	p, err := NewNATSPublisher("nats://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	err = p.Publish(context.Background(), Message{ID: 1, Type: "product.created"})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("se esperaba ErrUnavailable, se obtuvo %v", err)
+	}
+}
+
+func TestNewNATSPublisherURL(t *testing.T) {
+	if _, err := NewNATSPublisher("http://nats:4222"); err == nil {
+		t.Error("se esperaba error con esquema distinto de nats")
+	}
+}
@@ -0,0 +1,183 @@
+// Package pdf genera documentos PDF sencillos (texto con las fuentes estándar
+// Helvetica y rectángulos) sin dependencias externas.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tamaños de página en puntos (1/72 de pulgada)
+var (
+	Letter = [2]float64{612, 792}
+	A4     = [2]float64{595.28, 841.89}
+)
+
+// Document documento en construcción. Las coordenadas se expresan en puntos
+// desde la esquina superior izquierda de la página, con y creciendo hacia abajo.
+type Document struct {
+	width, height float64
+	pages         []*bytes.Buffer
+}
+
+// New crea un documento vacío con el tamaño de página indicado
+func New(size [2]float64) *Document {
+	return &Document{width: size[0], height: size[1]}
+}
+
+// Size ancho y alto de la página
+func (d *Document) Size() (float64, float64) {
+	return d.width, d.height
+}
+
+// AddPage inicia una página nueva; los dibujos posteriores van en ella
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// PageCount número de páginas
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) actual() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text escribe una línea de texto con su línea base en (x, y)
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	fuente := "F1"
+	if bold {
+		fuente = "F2"
+	}
+	fmt.Fprintf(d.actual(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		fuente, num(size), num(x), num(d.height-y), escapar(s))
+}
+
+// Rect dibuja un rectángulo relleno de negro con esquina superior izquierda en (x, y)
+func (d *Document) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.actual(), "%s %s %s %s re f\n", num(x), num(d.height-y-h), num(w), num(h))
+}
+
+// StrokeRect dibuja el contorno de un rectángulo en gris claro
+func (d *Document) StrokeRect(x, y, w, h, lineWidth float64) {
+	fmt.Fprintf(d.actual(), "q 0.75 G %s w %s %s %s %s re S Q\n",
+		num(lineWidth), num(x), num(d.height-y-h), num(w), num(h))
+}
+
+// WriteTo escribe el documento completo
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+	objeto := func(contenido string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), contenido)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catálogo, 2 árbol de páginas, 3 y 4 fuentes, luego página y contenido
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objeto("<< /Type /Catalog /Pages 2 0 R >>")
+	objeto(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %s %s] >>",
+		strings.Join(kids, " "), len(d.pages), num(d.width), num(d.height)))
+	objeto("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	objeto("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, p := range d.pages {
+		objeto(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", 6+2*i))
+		objeto(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.String()))
+	}
+
+	inicioXref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, inicioXref)
+
+	return buf.WriteTo(w)
+}
+
+// TextWidth ancho aproximado del texto en puntos, con las métricas de Helvetica
+func TextWidth(s string, size float64, bold bool) float64 {
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r < 127 {
+			total += int(anchosHelvetica[r-32])
+		} else {
+			total += 556
+		}
+	}
+	ancho := float64(total) * size / 1000
+	if bold {
+		ancho *= 1.06
+	}
+	return ancho
+}
+
+// Fit recorta el texto con puntos suspensivos para que quepa en el ancho dado
+func Fit(s string, size float64, bold bool, width float64) string {
+	if TextWidth(s, size, bold) <= width {
+		return s
+	}
+	runas := []rune(s)
+	for len(runas) > 0 && TextWidth(string(runas)+"...", size, bold) > width {
+		runas = runas[:len(runas)-1]
+	}
+	return strings.TrimRight(string(runas), " ") + "..."
+}
+
+// Anchos de Helvetica (milésimas de em) de los caracteres 32 a 126
+var anchosHelvetica = [...]uint16{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// escapar convierte el texto a WinAnsi (Latin-1 para acentos y ñ) y escapa
+// los caracteres especiales de las cadenas PDF
+func escapar(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString(`\200`)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// num formatea un número con a lo más dos decimales
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDocumento(t *testing.T) {
+	d := New(Letter)
+	d.Text(20, 30, 10, true, "Café (molido) 500g")
+	d.Rect(20, 40, 1.5, 30)
+	d.AddPage()
+	d.Text(20, 30, 10, false, "Página 2")
+
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	salida := buf.String()
+
+	if !strings.HasPrefix(salida, "%PDF-1.4") || !strings.HasSuffix(salida, "%%EOF\n") {
+		t.Fatal("encabezado o cierre PDF inválido")
+	}
+	if !strings.Contains(salida, `(Caf\351 \(molido\) 500g) Tj`) {
+		t.Error("el texto debe ir en WinAnsi con paréntesis escapados")
+	}
+	if !strings.Contains(salida, "/Count 2") {
+		t.Error("se esperaban 2 páginas")
+	}
+
+	// Cada entrada de la tabla xref debe apuntar al inicio de su objeto
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(salida)
+	inicio, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(salida[inicio:], "xref") {
+		t.Fatal("startxref no apunta a la tabla xref")
+	}
+	entradas := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(salida[inicio:], -1)
+	for i, e := range entradas {
+		offset, _ := strconv.Atoi(e[1])
+		if !strings.HasPrefix(salida[offset:], fmt.Sprintf("%d 0 obj", i+1)) {
+			t.Errorf("xref del objeto %d apunta a %q", i+1, salida[offset:offset+10])
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	if s := Fit("Laptop", 10, false, 200); s != "Laptop" {
+		t.Errorf("Fit = %q, no debía recortar", s)
+	}
+	s := Fit("Laptop HP Pavilion 15 pulgadas con procesador Intel", 10, false, 100)
+	if !strings.HasSuffix(s, "...") || TextWidth(s, 10, false) > 100 {
+		t.Errorf("Fit = %q (%.1f pt), se esperaba recortado a 100 pt", s, TextWidth(s, 10, false))
+	}
+}
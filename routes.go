@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"go-project/events"
+	"go-project/handlers"
+	"go-project/idempotency"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// rutasEmpresa arma los handlers de la API sobre el pool de una empresa; las
+// políticas de aislamiento de Postgres limitan sus consultas a esa empresa
+func rutasEmpresa(db *sql.DB, hub *events.Hub, idempotencyTTL time.Duration) http.Handler {
+	// Crear handlers
+	productHandler := handlers.NewProductHandler(db)
+	shopHandler := handlers.NewShopHandler(db)
+	inventoryHandler := handlers.NewInventoryHandler(db)
+	movementHandler := handlers.NewMovementHandler(db)
+	movementTypeHandler := handlers.NewMovementTypeHandler(db)
+	priceHandler := handlers.NewPriceHandler(db)
+	valuationHandler := handlers.NewValuationHandler(db)
+	analyticsHandler := handlers.NewAnalyticsHandler(db)
+	locationHandler := handlers.NewLocationHandler(db)
+	countHandler := handlers.NewCountHandler(db)
+	barcodeHandler := handlers.NewBarcodeHandler(db)
+	labelHandler := handlers.NewLabelHandler(db)
+	eventsHandler := handlers.NewEventsHandler(hub)
+	webhookHandler := handlers.NewWebhookHandler(db)
+	alertHandler := handlers.NewAlertHandler(db)
+	alertRuleHandler := handlers.NewAlertRuleHandler(db)
+	forecastHandler := handlers.NewForecastHandler(db)
+	balancingHandler := handlers.NewBalancingHandler(db)
+	salesHandler := handlers.NewSalesHandler(db)
+	rmaHandler := handlers.NewRMAHandler(db)
+
+	r := mux.NewRouter()
+
+	// Los reintentos con Idempotency-Key repiten la respuesta original
+	r.Use(idempotency.Middleware(idempotency.NewSQLStore(db), idempotencyTTL))
+
+	// Rutas de la API Productos
+	r.HandleFunc("/api/ListarProductos", productHandler.ListarProductos)
+	r.HandleFunc("/api/CrearProducto", productHandler.CrearProducto)
+	r.HandleFunc("/api/ObtenerProducto", productHandler.ObtenerProducto)
+	r.HandleFunc("/api/ActualizarProducto", productHandler.ActualizarProducto)
+	r.HandleFunc("/api/ActivarDesactivarProducto", productHandler.ToggleProductoEstado)
+	r.HandleFunc("/api/EliminarProducto", productHandler.EliminarProducto)
+
+	// Rutas de la API Códigos de barras
+	r.HandleFunc("/api/ListarCodigosBarras", barcodeHandler.ListarCodigosBarras)
+	r.HandleFunc("/api/CrearCodigoBarras", barcodeHandler.CrearCodigoBarras)
+	r.HandleFunc("/api/EliminarCodigoBarras", barcodeHandler.EliminarCodigoBarras)
+	r.HandleFunc("/api/barcodes/{code}", barcodeHandler.LookupBarcode)
+	r.HandleFunc("/api/labels/barcode", labelHandler.GetBarcodeImage)
+	r.HandleFunc("/api/labels/sheet", labelHandler.CreateLabelSheet)
+
+	// Rutas de la API Tiendas
+	r.HandleFunc("/api/ListarTiendas", shopHandler.ListarTiendas)
+	r.HandleFunc("/api/CrearTiendas", shopHandler.CrearTienda)
+	r.HandleFunc("/api/ObtenerTiendas", shopHandler.ObtenerTienda)
+	r.HandleFunc("/api/ActualizarTiendas", shopHandler.ActualizarTienda)
+	r.HandleFunc("/api/ActivarDesactivarTiendas", shopHandler.ToggleTiendaEstado)
+	r.HandleFunc("/api/EliminarTiendas", shopHandler.EliminarTienda)
+	r.HandleFunc("/api/stores/nearby", shopHandler.GetNearbyStores)
+
+	// Rutas de la API Inventarios
+	r.HandleFunc("/api/ListarInventarios", inventoryHandler.ListarInventarios)
+	r.HandleFunc("/api/CrearInventario", inventoryHandler.CrearInventario)
+	r.HandleFunc("/api/ObtenerInventario", inventoryHandler.ObtenerInventario)
+	r.HandleFunc("/api/ActualizarInventario", inventoryHandler.ActualizarInventario)
+	r.HandleFunc("/api/EliminarInventario", inventoryHandler.EliminarInventario)
+
+	// Rutas de la API Ubicaciones
+	r.HandleFunc("/api/ListarUbicaciones", locationHandler.ListarUbicaciones)
+	r.HandleFunc("/api/CrearUbicacion", locationHandler.CrearUbicacion)
+	r.HandleFunc("/api/EliminarUbicacion", locationHandler.EliminarUbicacion)
+
+	// Rutas de la API Movimientos
+	r.HandleFunc("/api/ListarMovimientos", movementHandler.ListarMovimientos)
+	r.HandleFunc("/api/CrearMovimiento", movementHandler.CrearMovimiento)
+	r.HandleFunc("/api/ObtenerMovimiento", movementHandler.ObtenerMovimiento)
+
+	// Rutas de la API Tipos y motivos de movimiento
+	r.HandleFunc("/api/ListarTiposMovimiento", movementTypeHandler.ListarTiposMovimiento)
+	r.HandleFunc("/api/CrearTipoMovimiento", movementTypeHandler.CrearTipoMovimiento)
+	r.HandleFunc("/api/CrearMotivoMovimiento", movementTypeHandler.CrearMotivoMovimiento)
+	r.HandleFunc("/api/ActivarDesactivarTipoMovimiento", movementTypeHandler.ToggleTipoMovimientoEstado)
+	// Rutas de la API Operacion
+	r.HandleFunc("/api/stores/{id}/inventory", inventoryHandler.GetStoreInventory)
+	r.HandleFunc("/api/inventory/transfer", inventoryHandler.TransferInventory)
+	r.HandleFunc("/api/inventory/transfer/batch", inventoryHandler.TransferInventoryBatch)
+	r.HandleFunc("/api/inventory/balancing/preview", balancingHandler.PreviewBalancing)
+	r.HandleFunc("/api/inventory/balancing/apply", balancingHandler.ApplyBalancing)
+	r.HandleFunc("/api/inventory/alerts", inventoryHandler.GetStockAlerts)
+	r.HandleFunc("/api/inventory/alerts/history", alertHandler.GetAlertHistory)
+	r.HandleFunc("/api/inventory/alerts/{id}/acknowledge", alertHandler.AcknowledgeAlert)
+	r.HandleFunc("/api/inventory/alerts/{id}/snooze", alertHandler.SnoozeAlert)
+	r.HandleFunc("/api/ListarDestinatariosAlertas", alertHandler.ListarDestinatariosAlertas)
+	r.HandleFunc("/api/CrearDestinatarioAlertas", alertHandler.CrearDestinatarioAlertas)
+	r.HandleFunc("/api/EliminarDestinatarioAlertas", alertHandler.EliminarDestinatarioAlertas)
+	r.HandleFunc("/api/ListarReglasAlertas", alertRuleHandler.ListarReglasAlertas)
+	r.HandleFunc("/api/CrearReglaAlerta", alertRuleHandler.CrearReglaAlerta)
+	r.HandleFunc("/api/EliminarReglaAlerta", alertRuleHandler.EliminarReglaAlerta)
+	r.HandleFunc("/api/inventory/alerts/rules/evaluate", alertRuleHandler.EvaluateAlertRules)
+	r.HandleFunc("/api/inventory/expiry-alerts", inventoryHandler.GetExpiryAlerts)
+	r.HandleFunc("/api/inventory/forecast", forecastHandler.GetDemandForecast)
+	r.HandleFunc("/api/inventory/forecast/backtest", forecastHandler.GetForecastBacktest)
+	r.HandleFunc("/api/ListarLotes", inventoryHandler.ListarLotes)
+	r.HandleFunc("/api/inventory/serials/{serial}", inventoryHandler.GetSerialHistory)
+	r.HandleFunc("/api/ListarSeries", inventoryHandler.ListarSeries)
+
+	// Rutas de la API Conteos físicos
+	r.HandleFunc("/api/ListarConteos", countHandler.ListarConteos)
+	r.HandleFunc("/api/CrearConteo", countHandler.CrearConteo)
+	r.HandleFunc("/api/inventory/counts/{id}/entries", countHandler.RegistrarCapturas)
+	r.HandleFunc("/api/inventory/counts/{id}/variance", countHandler.GetCountVariance)
+	r.HandleFunc("/api/inventory/counts/{id}/approve", countHandler.ApproveCount)
+	r.HandleFunc("/api/inventory/counts/{id}/cancel", countHandler.CancelCount)
+
+	// Rutas de la API Eventos en tiempo real
+	r.HandleFunc("/api/events/stream", eventsHandler.StreamEvents)
+	r.HandleFunc("/api/events/ws", eventsHandler.StreamEventsWS)
+
+	// Rutas de la API Webhooks
+	r.HandleFunc("/api/ListarWebhooks", webhookHandler.ListarWebhooks)
+	r.HandleFunc("/api/CrearWebhook", webhookHandler.CrearWebhook)
+	r.HandleFunc("/api/EliminarWebhook", webhookHandler.EliminarWebhook)
+	r.HandleFunc("/api/ListarEntregasWebhook", webhookHandler.ListarEntregasWebhook)
+	r.HandleFunc("/api/webhooks/deliveries/{id}/retry", webhookHandler.RetryWebhookDelivery)
+
+	// Rutas de la API Precios
+	r.HandleFunc("/api/ListarListasPrecios", priceHandler.ListarListasPrecios)
+	r.HandleFunc("/api/CrearListaPrecios", priceHandler.CrearListaPrecios)
+	r.HandleFunc("/api/HistorialPrecios", priceHandler.HistorialPrecios)
+	r.HandleFunc("/api/ProgramarPrecio", priceHandler.ProgramarPrecio)
+	r.HandleFunc("/api/ObtenerPrecio", priceHandler.ObtenerPrecio)
+
+	// Rutas de la API Ventas
+	r.HandleFunc("/api/ListarVentas", salesHandler.ListarVentas)
+	r.HandleFunc("/api/sales", salesHandler.RegistrarVenta)
+	r.HandleFunc("/api/sales/{id}", salesHandler.GetSale)
+	r.HandleFunc("/api/sales/{id}/returns", salesHandler.RegistrarDevolucion)
+
+	// Rutas de la API Devoluciones de clientes (RMA)
+	r.HandleFunc("/api/ListarRMAs", rmaHandler.ListarRMAs)
+	r.HandleFunc("/api/CrearRMA", rmaHandler.CrearRMA)
+	r.HandleFunc("/api/rmas/{id}", rmaHandler.GetRMA)
+	r.HandleFunc("/api/rmas/{id}/receive", rmaHandler.ReceiveRMA)
+	r.HandleFunc("/api/rmas/{id}/inspect", rmaHandler.InspectRMA)
+	r.HandleFunc("/api/rmas/{id}/complete", rmaHandler.CompleteRMA)
+	r.HandleFunc("/api/rmas/{id}/cancel", rmaHandler.CancelRMA)
+
+	// Rutas de la API Reportes
+	r.HandleFunc("/api/reports/valuation", valuationHandler.GetInventoryValuation)
+	r.HandleFunc("/api/reports/cogs", valuationHandler.GetCostOfGoods)
+	r.HandleFunc("/api/reports/analytics", analyticsHandler.GetInventoryAnalytics)
+	r.HandleFunc("/api/reports/sales/daily", salesHandler.GetDailySales)
+	r.HandleFunc("/api/reports/rma/reasons", rmaHandler.GetRMAReasons)
+	r.HandleFunc("/api/reports/shrinkage", movementTypeHandler.GetShrinkageReport)
+
+	return r
+}
@@ -0,0 +1,176 @@
+// Package stores calcula distancias entre tiendas y clientes y evalúa los
+// horarios de atención de cada tienda en su propia zona horaria.
+package stores
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimezone zona horaria de las tiendas que no indican otra
+const DefaultTimezone = "America/Mexico_City"
+
+// Radio medio de la Tierra en kilómetros
+const radioTierraKm = 6371.0088
+
+const (
+	minutosDia    = 24 * 60
+	minutosSemana = 7 * minutosDia
+)
+
+// DistanceKm distancia en kilómetros sobre la superficie terrestre entre dos
+// puntos (fórmula del haversine)
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := func(g float64) float64 { return g * math.Pi / 180 }
+	dLat := rad(lat2 - lat1)
+	dLng := rad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * radioTierraKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ValidCoordinates indica si la latitud y la longitud están en rango
+func ValidCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// Period horario de atención de un día de la semana. Si Closes es menor o
+// igual que Opens el periodo termina al día siguiente (p. ej. 22:00 a 02:00).
+type Period struct {
+	// Día de la semana: 0 domingo ... 6 sábado
+	Day    time.Weekday `json:"day" example:"1"`
+	Opens  string       `json:"opens" example:"09:00"`
+	Closes string       `json:"closes" example:"21:00"`
+}
+
+// Hours horario semanal de una tienda; un día sin periodos está cerrado y un
+// horario vacío significa que no hay restricción (siempre abierta)
+type Hours []Period
+
+// ParseClock convierte "HH:MM" en minutos desde la medianoche; se acepta
+// "24:00" como fin del día
+func ParseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hora, errH := strconv.Atoi(h)
+	minuto, errM := strconv.Atoi(m)
+	if !ok || len(m) != 2 || errH != nil || errM != nil ||
+		hora < 0 || minuto < 0 || minuto > 59 || hora > 24 || (hora == 24 && minuto != 0) {
+		return 0, fmt.Errorf("hora inválida %q: use HH:MM", s)
+	}
+	return hora*60 + minuto, nil
+}
+
+// Validate verifica los días y las horas de cada periodo
+func (h Hours) Validate() error {
+	for _, p := range h {
+		if p.Day < time.Sunday || p.Day > time.Saturday {
+			return fmt.Errorf("día inválido %d: use 0 (domingo) a 6 (sábado)", p.Day)
+		}
+		abre, err := ParseClock(p.Opens)
+		if err != nil {
+			return err
+		}
+		if abre == minutosDia {
+			return fmt.Errorf("hora de apertura inválida %q", p.Opens)
+		}
+		if _, err := ParseClock(p.Closes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// intervalo periodo en minutos desde el domingo a medianoche; fin puede
+// pasar del fin de la semana
+type intervalo struct{ inicio, fin int }
+
+func (h Hours) intervalos() []intervalo {
+	lista := make([]intervalo, 0, len(h))
+	for _, p := range h {
+		abre, err1 := ParseClock(p.Opens)
+		cierra, err2 := ParseClock(p.Closes)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		duracion := cierra - abre
+		if duracion <= 0 {
+			duracion += minutosDia
+		}
+		inicio := int(p.Day)*minutosDia + abre
+		lista = append(lista, intervalo{inicio, inicio + duracion})
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].inicio < lista[j].inicio })
+	return lista
+}
+
+func minutoSemana(t time.Time) int {
+	return int(t.Weekday())*minutosDia + t.Hour()*60 + t.Minute()
+}
+
+// IsOpen indica si la tienda atiende en el instante t, expresado en la zona
+// horaria de la tienda
+func (h Hours) IsOpen(t time.Time) bool {
+	_, abierta := h.OpenUntil(t)
+	return abierta || len(h) == 0
+}
+
+// OpenUntil devuelve hasta cuándo sigue abierta la tienda si lo está en t;
+// encadena los periodos contiguos (p. ej. 00:00 a 24:00 de días seguidos)
+func (h Hours) OpenUntil(t time.Time) (time.Time, bool) {
+	lista := h.intervalos()
+	ahora := minutoSemana(t)
+	fin := -1
+	for _, iv := range lista {
+		// Un periodo que cruza el fin de semana también cubre el domingo siguiente
+		for _, desplazamiento := range []int{0, -minutosSemana} {
+			if iv.inicio+desplazamiento <= ahora && ahora < iv.fin+desplazamiento && iv.fin+desplazamiento > fin {
+				fin = iv.fin + desplazamiento
+			}
+		}
+	}
+	if fin < 0 {
+		return time.Time{}, false
+	}
+	// Extender con los periodos que empiezan justo al cerrar (hasta una semana)
+	for extendido := true; extendido && fin-ahora < minutosSemana; {
+		extendido = false
+		for _, iv := range lista {
+			for _, desplazamiento := range []int{0, minutosSemana} {
+				if iv.inicio+desplazamiento <= fin && iv.fin+desplazamiento > fin {
+					fin = iv.fin + desplazamiento
+					extendido = true
+				}
+			}
+		}
+	}
+	return truncarMinuto(t).Add(time.Duration(fin-ahora) * time.Minute), true
+}
+
+// NextOpening devuelve la próxima apertura posterior a t; false si la tienda
+// no tiene periodos de atención
+func (h Hours) NextOpening(t time.Time) (time.Time, bool) {
+	lista := h.intervalos()
+	if len(lista) == 0 {
+		return time.Time{}, false
+	}
+	ahora := minutoSemana(t)
+	siguiente := -1
+	for _, iv := range lista {
+		inicio := iv.inicio
+		if inicio <= ahora {
+			inicio += minutosSemana
+		}
+		if siguiente < 0 || inicio < siguiente {
+			siguiente = inicio
+		}
+	}
+	return truncarMinuto(t).Add(time.Duration(siguiente-ahora) * time.Minute), true
+}
+
+func truncarMinuto(t time.Time) time.Time {
+	return t.Truncate(time.Minute)
+}
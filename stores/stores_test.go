@@ -0,0 +1,88 @@
+package stores
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDistanceKm(t *testing.T) {
+	// Zócalo de la Ciudad de México a la Macroplaza de Monterrey: ~705 km
+	d := DistanceKm(19.432608, -99.133209, 25.669254, -100.309563)
+	if math.Abs(d-704.6) > 2 {
+		t.Errorf("distancia = %.1f km", d)
+	}
+	if DistanceKm(19.4, -99.1, 19.4, -99.1) != 0 {
+		t.Error("la distancia a sí mismo debía ser 0")
+	}
+	// Puntos opuestos del globo: media circunferencia
+	if d := DistanceKm(0, 0, 0, 180); math.Abs(d-math.Pi*radioTierraKm) > 0.001 {
+		t.Errorf("antípodas = %.3f km", d)
+	}
+	if ValidCoordinates(91, 0) || ValidCoordinates(0, -181) || !ValidCoordinates(-90, 180) {
+		t.Error("validación de coordenadas incorrecta")
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	for s, want := range map[string]int{"00:00": 0, "09:30": 570, "24:00": 1440} {
+		if got, err := ParseClock(s); err != nil || got != want {
+			t.Errorf("ParseClock(%q) = %d, %v", s, got, err)
+		}
+	}
+	for _, s := range []string{"9", "9:5", "25:00", "24:30", "12:60", "aa:bb"} {
+		if _, err := ParseClock(s); err == nil {
+			t.Errorf("ParseClock(%q) debía fallar", s)
+		}
+	}
+	if err := (Hours{{Day: 7, Opens: "09:00", Closes: "18:00"}}).Validate(); err == nil {
+		t.Error("día 7 debía rechazarse")
+	}
+}
+
+func TestHours(t *testing.T) {
+	// Lunes a viernes 09:00-21:00 y sábado 22:00 a domingo 02:00
+	h := Hours{
+		{Day: time.Monday, Opens: "09:00", Closes: "21:00"},
+		{Day: time.Tuesday, Opens: "09:00", Closes: "21:00"},
+		{Day: time.Saturday, Opens: "22:00", Closes: "02:00"},
+	}
+	loc := time.FixedZone("CST", -6*3600)
+	// 2024-03-04 es lunes
+	lunes := func(dia, hora, minuto int) time.Time {
+		return time.Date(2024, 3, 4+dia, hora, minuto, 0, 0, loc)
+	}
+
+	if !h.IsOpen(lunes(0, 9, 0)) || h.IsOpen(lunes(0, 21, 0)) || h.IsOpen(lunes(0, 8, 59)) {
+		t.Error("horario del lunes incorrecto")
+	}
+	if hasta, ok := h.OpenUntil(lunes(0, 12, 30)); !ok || !hasta.Equal(lunes(0, 21, 0)) {
+		t.Errorf("OpenUntil = %v %v", hasta, ok)
+	}
+	// Domingo 01:00 pertenece al periodo que abrió el sábado
+	domingo := lunes(6, 1, 0)
+	if hasta, ok := h.OpenUntil(domingo); !ok || !hasta.Equal(lunes(6, 2, 0)) {
+		t.Errorf("periodo nocturno: %v %v", hasta, ok)
+	}
+	if abre, ok := h.NextOpening(lunes(0, 21, 0)); !ok || !abre.Equal(lunes(1, 9, 0)) {
+		t.Errorf("NextOpening = %v %v", abre, ok)
+	}
+	// Del martes en la noche la siguiente apertura es el sábado
+	if abre, _ := h.NextOpening(lunes(1, 22, 0)); !abre.Equal(lunes(5, 22, 0)) {
+		t.Errorf("NextOpening martes = %v", abre)
+	}
+
+	// Sin horario se considera siempre abierta
+	if !(Hours{}).IsOpen(domingo) {
+		t.Error("sin horario debía estar abierta")
+	}
+
+	// Periodos contiguos se encadenan
+	continuo := Hours{
+		{Day: time.Monday, Opens: "00:00", Closes: "24:00"},
+		{Day: time.Tuesday, Opens: "00:00", Closes: "12:00"},
+	}
+	if hasta, ok := continuo.OpenUntil(lunes(0, 23, 0)); !ok || !hasta.Equal(lunes(1, 12, 0)) {
+		t.Errorf("periodos contiguos: %v %v", hasta, ok)
+	}
+}
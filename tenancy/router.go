@@ -0,0 +1,130 @@
+package tenancy
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnknownTenant la empresa no existe o está inactiva
+var ErrUnknownTenant = errors.New("empresa inexistente o inactiva")
+
+// Cada cuánto se vuelve a confirmar que una empresa sigue activa
+const revalidarEmpresa = time.Minute
+
+// BuildFunc arma los handlers de una empresa sobre su pool de conexiones
+type BuildFunc func(db *sql.DB) http.Handler
+
+// Router despacha cada petición a los handlers de la empresa de su identidad.
+// Los handlers y el pool de cada empresa se crean en su primera petición.
+type Router struct {
+	system *sql.DB
+	dsn    string
+	build  BuildFunc
+	// MaxOpenConns límite de conexiones del pool de cada empresa
+	MaxOpenConns int
+
+	mu       sync.Mutex
+	empresas map[uuid.UUID]*empresa
+}
+
+type empresa struct {
+	db         *sql.DB
+	handler    http.Handler
+	verificada time.Time
+}
+
+// NewRouter crea el despachador. system consulta el catálogo de empresas;
+// dsn es la conexión del rol de la aplicación sujeto a las políticas de
+// aislamiento, a la que se agrega la empresa de cada pool.
+func NewRouter(system *sql.DB, dsn string, build BuildFunc) *Router {
+	return &Router{system: system, dsn: dsn, build: build, MaxOpenConns: 10,
+		empresas: make(map[uuid.UUID]*empresa)}
+}
+
+// TenantDSN agrega app.tenant_id a las opciones de la conexión
+func TenantDSN(dsn string, tenantID uuid.UUID) string {
+	return strings.TrimSpace(dsn) + " options='-c app.tenant_id=" + tenantID.String() + "'"
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tenantID := TenantFromContext(r.Context())
+	if tenantID == uuid.Nil {
+		http.Error(w, "Empresa no identificada", http.StatusUnauthorized)
+		return
+	}
+	h, err := rt.handler(tenantID)
+	if errors.Is(err, ErrUnknownTenant) {
+		http.Error(w, "Empresa inexistente o inactiva", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (rt *Router) handler(tenantID uuid.UUID) (http.Handler, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	e, ok := rt.empresas[tenantID]
+	if ok && time.Since(e.verificada) < revalidarEmpresa {
+		return e.handler, nil
+	}
+
+	var activa bool
+	err := rt.system.QueryRow(`SELECT activo FROM prueba.tenants WHERE id = $1`, tenantID).Scan(&activa)
+	if err == sql.ErrNoRows || (err == nil && !activa) {
+		rt.cerrar(tenantID)
+		return nil, ErrUnknownTenant
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		e.verificada = time.Now()
+		return e.handler, nil
+	}
+
+	db, err := sql.Open("postgres", TenantDSN(rt.dsn, tenantID))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(rt.MaxOpenConns)
+	e = &empresa{db: db, handler: rt.build(db), verificada: time.Now()}
+	rt.empresas[tenantID] = e
+	return e.handler, nil
+}
+
+// Evict descarta los handlers y el pool de la empresa (p. ej. al desactivarla)
+func (rt *Router) Evict(tenantID uuid.UUID) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.cerrar(tenantID)
+}
+
+func (rt *Router) cerrar(tenantID uuid.UUID) {
+	if e, ok := rt.empresas[tenantID]; ok {
+		if err := e.db.Close(); err != nil {
+			log.Printf("Error al cerrar el pool de la empresa %s: %v", tenantID, err)
+		}
+		delete(rt.empresas, tenantID)
+	}
+}
+
+// Close cierra los pools de todas las empresas
+func (rt *Router) Close() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for id := range rt.empresas {
+		rt.cerrar(id)
+	}
+}
@@ -0,0 +1,49 @@
+// Package tenancy aísla a las empresas (tenants) que comparten el despliegue.
+// La empresa viaja en el token de acceso; cada empresa usa su propio pool de
+// conexiones con app.tenant_id fijado, de modo que las políticas de seguridad
+// a nivel de fila de Postgres filtran todas las consultas de sus handlers.
+package tenancy
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// DefaultTenantID empresa predeterminada, dueña de los datos previos a la
+// separación por empresas
+var DefaultTenantID = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// Roles
+const (
+	// RoleAdmin administra las empresas del despliegue
+	RoleAdmin = "admin"
+	// RoleUser opera dentro de su empresa
+	RoleUser = "user"
+)
+
+// Identity quien hace la petición y en nombre de qué empresa
+type Identity struct {
+	Subject  string
+	TenantID uuid.UUID
+	Role     string
+}
+
+type claveIdentidad struct{}
+
+// WithIdentity agrega la identidad al contexto de la petición
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, claveIdentidad{}, id)
+}
+
+// FromContext identidad de la petición
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(claveIdentidad{}).(Identity)
+	return id, ok
+}
+
+// TenantFromContext empresa de la petición (uuid.Nil si no hay)
+func TenantFromContext(ctx context.Context) uuid.UUID {
+	id, _ := FromContext(ctx)
+	return id.TenantID
+}
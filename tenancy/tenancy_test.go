@@ -0,0 +1,73 @@
+package tenancy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestToken(t *testing.T) {
+	secreto := []byte("secreto")
+	ahora := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := Claims{Subject: "pos-1", TenantID: uuid.New().String(), Role: RoleUser,
+		IssuedAt: ahora.Unix(), ExpiresAt: ahora.Add(time.Hour).Unix()}
+
+	token, err := Sign(secreto, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(secreto, token, ahora)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != c {
+		t.Errorf("Parse = %+v, se esperaba %+v", got, c)
+	}
+
+	if _, err := Parse(secreto, token, ahora.Add(time.Hour)); err != ErrExpiredToken {
+		t.Errorf("token vencido: err = %v", err)
+	}
+	if _, err := Parse([]byte("otro"), token, ahora); err != ErrInvalidToken {
+		t.Errorf("firma ajena: err = %v", err)
+	}
+
+	// Cambiar la empresa invalida la firma
+	partes := strings.Split(token, ".")
+	otra, _ := Sign(secreto, Claims{Subject: "pos-1", TenantID: uuid.New().String()})
+	alterado := partes[0] + "." + strings.Split(otra, ".")[1] + "." + partes[2]
+	if _, err := Parse(secreto, alterado, ahora); err != ErrInvalidToken {
+		t.Errorf("token alterado: err = %v", err)
+	}
+
+	// Un token sin firma (alg none) se rechaza
+	sinFirma := codificacion.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + partes[1] + "."
+	if _, err := Parse(secreto, sinFirma, ahora); err != ErrInvalidToken {
+		t.Errorf("alg none: err = %v", err)
+	}
+}
+
+func TestTenantDSN(t *testing.T) {
+	id := uuid.MustParse("3f2c1a9e-0000-4000-8000-000000000001")
+	got := TenantDSN("host=postgres user=app ", id)
+	want := "host=postgres user=app options='-c app.tenant_id=3f2c1a9e-0000-4000-8000-000000000001'"
+	if got != want {
+		t.Errorf("TenantDSN = %q", got)
+	}
+}
+
+func TestIdentidad(t *testing.T) {
+	if TenantFromContext(context.Background()) != uuid.Nil {
+		t.Error("sin identidad la empresa debía ser nula")
+	}
+	id := Identity{Subject: "ana", TenantID: uuid.New(), Role: RoleAdmin}
+	ctx := WithIdentity(context.Background(), id)
+	if got, ok := FromContext(ctx); !ok || got != id {
+		t.Errorf("FromContext = %+v %v", got, ok)
+	}
+	if TenantFromContext(ctx) != id.TenantID {
+		t.Error("empresa del contexto incorrecta")
+	}
+}
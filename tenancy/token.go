@@ -0,0 +1,85 @@
+package tenancy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errores de validación del token
+var (
+	ErrInvalidToken = errors.New("token inválido")
+	ErrExpiredToken = errors.New("token vencido")
+)
+
+// Claims contenido del token de acceso (JWT firmado con HS256)
+type Claims struct {
+	Subject   string `json:"sub"`
+	TenantID  string `json:"tenant_id,omitempty"`
+	Role      string `json:"role,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+var codificacion = base64.RawURLEncoding
+
+// encabezadoHS256 encabezado fijo de los tokens emitidos
+var encabezadoHS256 = codificacion.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Sign emite un JWT HS256 con los datos de c
+func Sign(secret []byte, c Claims) (string, error) {
+	cuerpo, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	contenido := encabezadoHS256 + "." + codificacion.EncodeToString(cuerpo)
+	return contenido + "." + firmar(secret, contenido), nil
+}
+
+// Parse valida la firma HS256 y la vigencia del token y devuelve su contenido
+func Parse(secret []byte, token string, now time.Time) (Claims, error) {
+	partes := strings.Split(token, ".")
+	if len(partes) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	encabezado, err := codificacion.DecodeString(partes[0])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	// Sólo se acepta HS256; en particular se rechaza "none"
+	if json.Unmarshal(encabezado, &h) != nil || h.Alg != "HS256" {
+		return Claims{}, ErrInvalidToken
+	}
+
+	firma := firmar(secret, partes[0]+"."+partes[1])
+	if !hmac.Equal([]byte(firma), []byte(partes[2])) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	cuerpo, err := codificacion.DecodeString(partes[1])
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(cuerpo, &c); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if c.ExpiresAt != 0 && now.Unix() >= c.ExpiresAt {
+		return Claims{}, ErrExpiredToken
+	}
+	return c, nil
+}
+
+func firmar(secret []byte, contenido string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(contenido))
+	return codificacion.EncodeToString(mac.Sum(nil))
+}
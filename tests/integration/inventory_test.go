@@ -4,20 +4,74 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"go-project/money"
+	"go-project/tenancy"
 	"io"
 	"net/http"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// La suite corre contra el stack de docker-compose en localhost:8080. Si
+// JWT_SECRET está definido (el mismo del contenedor) cada petición lleva un
+// token de la empresa predeterminada; si no, el stack debe levantarse con
+// AUTH_DISABLED=true y la empresa se envía en X-Tenant-ID.
+
+// autenticar agrega a la petición las credenciales de la empresa predeterminada
+func autenticar(req *http.Request) error {
+	secreto := os.Getenv("JWT_SECRET")
+	if secreto == "" {
+		req.Header.Set("X-Tenant-ID", tenancy.DefaultTenantID.String())
+		return nil
+	}
+	ahora := time.Now()
+	token, err := tenancy.Sign([]byte(secreto), tenancy.Claims{
+		Subject:   "pruebas-integracion",
+		TenantID:  tenancy.DefaultTenantID.String(),
+		Role:      tenancy.RoleUser,
+		IssuedAt:  ahora.Unix(),
+		ExpiresAt: ahora.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func post(url string, cuerpo io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, cuerpo)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := autenticar(req); err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := autenticar(req); err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
 // Estructuras necesarias
 type CrearProducto struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Category    string  `json:"category"`
-	Price       float64 `json:"price"`
-	SKU         string  `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Category    string      `json:"category"`
+	Price       money.Money `json:"price"`
+	SKU         string      `json:"sku"`
 }
 
 type CrearTienda struct {
@@ -41,12 +95,12 @@ type StockTransfer struct {
 }
 
 type ProductoDetalle struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Category    string    `json:"category"`
-	Price       float64   `json:"price"`
-	SKU         string    `json:"sku"`
+	ID          uuid.UUID   `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Category    string      `json:"category"`
+	Price       money.Money `json:"price"`
+	SKU         string      `json:"sku"`
 }
 
 type TiendaDetalle struct {
@@ -69,7 +123,7 @@ func TestTransferInventoryFlow(t *testing.T) {
 		Name:        "Laptop HP",
 		Description: "Laptop HP con procesador Intel i5",
 		Category:    "Electrónicos",
-		Price:       999.99,
+		Price:       money.MustParse("999.99", "MXN"),
 		SKU:         "LAP-101",
 	}
 	productoID := crearProducto(t, producto)
@@ -122,8 +176,7 @@ func crearProducto(t *testing.T, producto CrearProducto) uuid.UUID {
 		t.Fatalf("Error al convertir producto a JSON: %v", err)
 	}
 
-	resp, err := http.Post("http://localhost:8080/api/CrearProducto",
-		"application/json", bytes.NewBuffer(cuerpo))
+	resp, err := post("http://localhost:8080/api/CrearProducto", bytes.NewBuffer(cuerpo))
 	if err != nil {
 		t.Fatalf("Error al crear producto: %v", err)
 	}
@@ -153,8 +206,7 @@ func crearTienda(t *testing.T, tienda CrearTienda) uuid.UUID {
 		t.Fatalf("Error al convertir tienda a JSON: %v", err)
 	}
 
-	resp, err := http.Post("http://localhost:8080/api/CrearTiendas",
-		"application/json", bytes.NewBuffer(cuerpo))
+	resp, err := post("http://localhost:8080/api/CrearTiendas", bytes.NewBuffer(cuerpo))
 	if err != nil {
 		t.Fatalf("Error al crear tienda: %v", err)
 	}
@@ -174,8 +226,7 @@ func crearInventario(t *testing.T, inv CrearInventario) {
 		t.Fatalf("Error al convertir inventario a JSON: %v", err)
 	}
 
-	resp, err := http.Post("http://localhost:8080/api/CrearInventario",
-		"application/json", bytes.NewBuffer(cuerpo))
+	resp, err := post("http://localhost:8080/api/CrearInventario", bytes.NewBuffer(cuerpo))
 	if err != nil {
 		t.Fatalf("Error al crear inventario: %v", err)
 	}
@@ -192,15 +243,14 @@ func realizarTransferencia(t *testing.T, transferencia StockTransfer) *http.Resp
 		t.Fatalf("Error al convertir transferencia a JSON: %v", err)
 	}
 
-	resp, err := http.Post("http://localhost:8080/api/inventory/transfer",
-		"application/json", bytes.NewBuffer(cuerpo))
+	resp, err := post("http://localhost:8080/api/inventory/transfer", bytes.NewBuffer(cuerpo))
 	if err != nil {
 		t.Fatalf("Error al realizar transferencia: %v", err)
 	}
 	return resp
 }
 func verificarInventario(t *testing.T, tiendaID, productoID uuid.UUID, cantidadEsperada int) {
-	resp, err := http.Get(fmt.Sprintf("http://localhost:8080/api/stores/%s/inventory", tiendaID))
+	resp, err := get(fmt.Sprintf("http://localhost:8080/api/stores/%s/inventory", tiendaID))
 	if err != nil {
 		t.Fatalf("Error al obtener inventario: %v", err)
 	}
@@ -0,0 +1,113 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-project/money"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type CrearMovimiento struct {
+	ProductID     uuid.UUID    `json:"product_id"`
+	SourceStoreID uuid.UUID    `json:"source_store_id"`
+	TargetStoreID uuid.UUID    `json:"target_store_id"`
+	Quantity      int          `json:"quantity"`
+	Type          string       `json:"type"`
+	ReasonCode    string       `json:"reason_code,omitempty"`
+	UnitCost      *money.Money `json:"unit_cost,omitempty"`
+}
+
+type CostoVentaLinea struct {
+	ProductID uuid.UUID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+type MetricaAnalitica struct {
+	Key      string `json:"key"`
+	Received int    `json:"received"`
+	Sold     int    `json:"sold"`
+}
+
+// Una devolución a proveedor sale de la tienda sin ser venta: no debe
+// aparecer en el costo de lo vendido ni en las unidades vendidas
+func TestReturnToSupplierIsNotASale(t *testing.T) {
+	productoID := crearProducto(t, CrearProducto{
+		Name:     "Cafetera de goteo",
+		Category: "Electrodomésticos",
+		Price:    money.MustParse("650.00", "MXN"),
+		SKU:      "RTS-" + uuid.NewString()[:8],
+	})
+	tiendaID := crearTienda(t, CrearTienda{Name: "Tienda Devoluciones", Address: "Calle 5 de Mayo 10", Phone: "555-0130"})
+	crearInventario(t, CrearInventario{ProductID: productoID, StoreID: tiendaID, Quantity: 0, MinStock: 1})
+
+	costo := money.MustParse("400.00", "MXN")
+	crearMovimiento(t, CrearMovimiento{ProductID: productoID, SourceStoreID: tiendaID, TargetStoreID: tiendaID,
+		Quantity: 10, Type: "IN", UnitCost: &costo})
+	crearMovimiento(t, CrearMovimiento{ProductID: productoID, SourceStoreID: tiendaID, TargetStoreID: tiendaID,
+		Quantity: 4, Type: "RETURN_TO_SUPPLIER", ReasonCode: "DEFECTIVE"})
+	verificarInventario(t, tiendaID, productoID, 6)
+
+	var cogs struct {
+		Lines []CostoVentaLinea `json:"lines"`
+	}
+	obtenerJSON(t, fmt.Sprintf("http://localhost:8080/api/reports/cogs?store_id=%s", tiendaID), &cogs)
+	for _, l := range cogs.Lines {
+		if l.ProductID == productoID {
+			t.Errorf("La devolución a proveedor aparece en el costo de lo vendido: %+v", l)
+		}
+	}
+
+	var analitica struct {
+		Lines []MetricaAnalitica `json:"lines"`
+	}
+	obtenerJSON(t, fmt.Sprintf("http://localhost:8080/api/reports/analytics?store_id=%s", tiendaID), &analitica)
+	encontrado := false
+	for _, m := range analitica.Lines {
+		if m.Key != productoID.String() {
+			continue
+		}
+		encontrado = true
+		if m.Sold != 0 || m.Received != 10 {
+			t.Errorf("Se esperaba 10 recibidas y 0 vendidas, se obtuvo %+v", m)
+		}
+	}
+	if !encontrado {
+		t.Errorf("El producto no aparece en la analítica de la tienda")
+	}
+}
+
+func crearMovimiento(t *testing.T, mov CrearMovimiento) {
+	cuerpo, err := json.Marshal(mov)
+	if err != nil {
+		t.Fatalf("Error al convertir movimiento a JSON: %v", err)
+	}
+
+	resp, err := post("http://localhost:8080/api/CrearMovimiento", bytes.NewBuffer(cuerpo))
+	if err != nil {
+		t.Fatalf("Error al crear movimiento: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Movimiento %s: se esperaba estado 201, se obtuvo %d", mov.Type, resp.StatusCode)
+	}
+}
+
+func obtenerJSON(t *testing.T, url string, destino any) {
+	resp, err := get(url)
+	if err != nil {
+		t.Fatalf("Error al consultar %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: se esperaba estado 200, se obtuvo %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(destino); err != nil {
+		t.Fatalf("Error al decodificar %s: %v", url, err)
+	}
+}
@@ -1,6 +1,14 @@
 package utils
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrCommit la confirmación de la transacción falló; si se perdió la conexión
+// no se sabe si los cambios se aplicaron
+var ErrCommit = errors.New("no se pudo confirmar la transacción")
 
 // TxFn representa una función que se ejecutará dentro de una transacción
 type TxFn func(*sql.Tx) error
@@ -25,5 +33,16 @@ func WithTransaction(db *sql.DB, fn TxFn) error {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCommit, err)
+	}
+	return nil
+}
+
+// Querier agrupa los métodos comunes de *sql.DB y *sql.Tx para que las
+// consultas puedan reutilizarse dentro y fuera de una transacción
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
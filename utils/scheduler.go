@@ -0,0 +1,24 @@
+package utils
+
+import "time"
+
+// RunEvery ejecuta fn en segundo plano cada intervalo y devuelve una función
+// para detener la ejecución
+func RunEvery(interval time.Duration, fn func()) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
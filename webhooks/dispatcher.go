@@ -0,0 +1,120 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+// Valores por defecto del despachador
+const (
+	DefaultMaxAttempts = 10
+	DefaultBatchSize   = 50
+	// Tiempo que una entrega tomada queda reservada para el despachador que la
+	// tomó; si éste se cae, otra réplica la reintenta al vencer
+	leaseDuration = 5 * time.Minute
+)
+
+// Dispatcher envía las entregas pendientes de la bandeja de salida
+type Dispatcher struct {
+	db          *sql.DB
+	Client      *http.Client
+	MaxAttempts int
+	BatchSize   int
+}
+
+// NewDispatcher crea un despachador con los valores por defecto
+func NewDispatcher(db *sql.DB) *Dispatcher {
+	return &Dispatcher{
+		db:          db,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: DefaultMaxAttempts,
+		BatchSize:   DefaultBatchSize,
+	}
+}
+
+// DispatchDue envía las entregas vencidas y devuelve cuántas se entregaron
+// y cuántas fallaron
+func (d *Dispatcher) DispatchDue(ctx context.Context) (entregadas, fallidas int, err error) {
+	pendientes, err := d.tomar()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, p := range pendientes {
+		codigo, errEnvio := Send(ctx, d.Client, p, time.Now())
+		if err := d.registrar(p, codigo, errEnvio); err != nil {
+			return entregadas, fallidas, err
+		}
+		if errEnvio != nil {
+			fallidas++
+		} else {
+			entregadas++
+		}
+	}
+	return entregadas, fallidas, nil
+}
+
+// tomar reserva un lote de entregas vencidas moviendo su próximo intento al
+// futuro; SKIP LOCKED permite varias réplicas despachando a la vez
+func (d *Dispatcher) tomar() ([]Delivery, error) {
+	rows, err := d.db.Query(`
+        UPDATE prueba.webhooksentregas e
+        SET nextAttemptAt = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second'
+        FROM prueba.webhooks w
+        WHERE w.id = e.webhookId
+          AND e.id IN (
+              SELECT id FROM prueba.webhooksentregas
+              WHERE status = 'PENDING' AND nextAttemptAt <= CURRENT_TIMESTAMP
+              ORDER BY nextAttemptAt
+              LIMIT $1
+              FOR UPDATE SKIP LOCKED
+          )
+        RETURNING e.id, e.eventId, e.eventType, w.url, w.secret, e.payload, e.attempts
+    `, d.BatchSize, int(leaseDuration.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pendientes []Delivery
+	for rows.Next() {
+		var p Delivery
+		if err := rows.Scan(&p.ID, &p.EventID, &p.EventType, &p.URL, &p.Secret, &p.Payload, &p.Attempts); err != nil {
+			return nil, err
+		}
+		pendientes = append(pendientes, p)
+	}
+	return pendientes, rows.Err()
+}
+
+// registrar guarda el resultado de un intento: entregada, reprogramada con
+// espera exponencial o enviada a la cola de muertos al agotar los intentos
+func (d *Dispatcher) registrar(p Delivery, codigo int, errEnvio error) error {
+	var status sql.NullInt64
+	if codigo > 0 {
+		status = sql.NullInt64{Int64: int64(codigo), Valid: true}
+	}
+	if errEnvio == nil {
+		_, err := d.db.Exec(`
+            UPDATE prueba.webhooksentregas
+            SET status = 'DELIVERED', attempts = attempts + 1, lastStatusCode = $2,
+                lastError = NULL, deliveredAt = CURRENT_TIMESTAMP
+            WHERE id = $1
+        `, p.ID, status)
+		return err
+	}
+
+	intentos := p.Attempts + 1
+	estado := "PENDING"
+	if intentos >= d.MaxAttempts {
+		estado = "DEAD"
+	}
+	_, err := d.db.Exec(`
+        UPDATE prueba.webhooksentregas
+        SET status = $2, attempts = $3, lastStatusCode = $4, lastError = $5,
+            nextAttemptAt = CURRENT_TIMESTAMP + $6 * INTERVAL '1 second'
+        WHERE id = $1
+    `, p.ID, estado, intentos, status, errEnvio.Error(), int(Backoff(intentos).Seconds()))
+	return err
+}
@@ -0,0 +1,156 @@
+// Package webhooks entrega los eventos de inventario a sistemas externos. Los
+// eventos se encolan en prueba.webhooksentregas dentro de la misma transacción
+// que el cambio (por medio de triggers) y un despachador los envía firmados,
+// reintentando con espera exponencial hasta mandarlos a la cola de muertos.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tipos de evento disponibles para suscripción
+const (
+	EventMovementCreated   = "movement.created"
+	EventStockLow          = "stock.low"
+	EventTransferCompleted = "transfer.completed"
+	EventProductUpdated    = "product.updated"
+	// EventAll suscribe a todos los eventos
+	EventAll = "*"
+)
+
+// EventTypes tipos de evento válidos
+var EventTypes = []string{EventMovementCreated, EventStockLow, EventTransferCompleted, EventProductUpdated}
+
+// Encabezados enviados con cada entrega
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-Id"
+)
+
+// ErrInvalidSignature indica una firma ausente, mal formada o que no coincide
+var ErrInvalidSignature = errors.New("firma de webhook inválida")
+
+// Sign firma el cuerpo con HMAC-SHA256 sobre "timestamp.cuerpo" y devuelve el
+// valor del encabezado X-Webhook-Signature: "t=<unix>,v1=<hex>"
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + firma(secret, ts, body)
+}
+
+func firma(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify comprueba la firma de una entrega, como lo haría el receptor. Con
+// tolerance > 0 también rechaza marcas de tiempo alejadas de now, para evitar
+// que se reenvíe una entrega capturada.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, v1 string
+	for _, parte := range strings.Split(header, ",") {
+		clave, valor, ok := strings.Cut(strings.TrimSpace(parte), "=")
+		if !ok {
+			continue
+		}
+		switch clave {
+		case "t":
+			ts = valor
+		case "v1":
+			v1 = valor
+		}
+	}
+	if ts == "" || v1 == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(v1), []byte(firma(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		segundos, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return ErrInvalidSignature
+		}
+		if d := now.Sub(time.Unix(segundos, 0)); d > tolerance || d < -tolerance {
+			return fmt.Errorf("%w: marca de tiempo fuera de tolerancia", ErrInvalidSignature)
+		}
+	}
+	return nil
+}
+
+// Espera entre reintentos: 30s, 1m, 2m, 4m... hasta 6 horas
+const (
+	backoffBase = 30 * time.Second
+	backoffMax  = 6 * time.Hour
+)
+
+// Backoff espera antes del siguiente intento tras attempts intentos fallidos
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	d := backoffBase
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= backoffMax {
+			return backoffMax
+		}
+	}
+	return d
+}
+
+// Delivery entrega pendiente de enviar
+type Delivery struct {
+	ID        uuid.UUID
+	EventID   uuid.UUID
+	EventType string
+	URL       string
+	Secret    string
+	Payload   []byte
+	Attempts  int
+}
+
+// Límite de la respuesta que se guarda como error
+const maxRespuesta = 512
+
+// Send envía una entrega por POST con su firma. Devuelve el código HTTP y un
+// error si la petición falló o la respuesta no fue 2xx.
+func Send(ctx context.Context, client *http.Client, d Delivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-project-webhooks/1.0")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderID, d.EventID.String())
+	req.Header.Set(HeaderSignature, Sign(d.Secret, now, d.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	cuerpo, _ := io.ReadAll(io.LimitReader(resp.Body, maxRespuesta))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("respuesta %d: %s", resp.StatusCode, strings.TrimSpace(string(cuerpo)))
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSignVerify(t *testing.T) {
+	ahora := time.Unix(1700000000, 0)
+	cuerpo := []byte(`{"type":"stock.low"}`)
+	firma := Sign("whsec_prueba", ahora, cuerpo)
+
+	if err := Verify("whsec_prueba", firma, cuerpo, 5*time.Minute, ahora); err != nil {
+		t.Fatalf("firma válida rechazada: %v", err)
+	}
+	if err := Verify("otro", firma, cuerpo, 0, ahora); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("se aceptó un secreto distinto")
+	}
+	if err := Verify("whsec_prueba", firma, []byte(`{"type":"otro"}`), 0, ahora); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("se aceptó un cuerpo alterado")
+	}
+	if err := Verify("whsec_prueba", firma, cuerpo, 5*time.Minute, ahora.Add(time.Hour)); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("se aceptó una marca de tiempo vencida")
+	}
+	if err := Verify("whsec_prueba", "v1=abc", cuerpo, 0, ahora); !errors.Is(err, ErrInvalidSignature) {
+		t.Error("se aceptó una firma sin marca de tiempo")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	casos := map[int]time.Duration{
+		0:  0,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		20: 6 * time.Hour,
+	}
+	for intentos, esperado := range casos {
+		if got := Backoff(intentos); got != esperado {
+			t.Errorf("Backoff(%d) = %v, se esperaba %v", intentos, got, esperado)
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	const secreto = "whsec_prueba"
+	var recibido struct {
+		evento, id string
+		err        error
+	}
+	receptor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cuerpo, _ := io.ReadAll(r.Body)
+		recibido.evento = r.Header.Get(HeaderEvent)
+		recibido.id = r.Header.Get(HeaderID)
+		recibido.err = Verify(secreto, r.Header.Get(HeaderSignature), cuerpo, time.Minute, time.Now())
+		if r.URL.Path == "/falla" {
+			http.Error(w, "no disponible", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receptor.Close()
+
+	d := Delivery{
+		EventID:   uuid.New(),
+		EventType: EventStockLow,
+		URL:       receptor.URL + "/ok",
+		Secret:    secreto,
+		Payload:   []byte(`{"type":"stock.low","data":{"quantity":2}}`),
+	}
+	codigo, err := Send(context.Background(), receptor.Client(), d, time.Now())
+	if err != nil || codigo != http.StatusNoContent {
+		t.Fatalf("Send = %d, %v", codigo, err)
+	}
+	if recibido.err != nil || recibido.evento != EventStockLow || recibido.id != d.EventID.String() {
+		t.Errorf("entrega recibida incorrecta: %+v", recibido)
+	}
+
+	d.URL = receptor.URL + "/falla"
+	codigo, err = Send(context.Background(), receptor.Client(), d, time.Now())
+	if err == nil || codigo != http.StatusServiceUnavailable {
+		t.Errorf("se esperaba error 503, se obtuvo %d, %v", codigo, err)
+	}
+}